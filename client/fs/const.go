@@ -46,6 +46,11 @@ const (
 	DeleteExtentsTimeout = 600 * time.Second
 )
 
+const (
+	// the access time is only updated on open if it is older than this, as relatime does
+	AccessTimeUpdateInterval = time.Hour
+)
+
 var (
 	// The following two are used in the FUSE cache
 	// every time the lookup will be performed on the fly, and the result will not be cached
@@ -66,6 +71,14 @@ func ParseError(err error) fuse.Errno {
 	}
 }
 
+// ParseWriteError returns ENOSPC if the volume reaches its capacity, and EIO for the other errors of the writes.
+func ParseWriteError(err error) fuse.Errno {
+	if err == syscall.ENOSPC {
+		return fuse.Errno(syscall.ENOSPC)
+	}
+	return fuse.EIO
+}
+
 // ParseType returns the dentry type.
 func ParseType(t uint32) fuse.DirentType {
 	if proto.IsDir(t) {
@@ -369,19 +369,20 @@ func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	return newFile, nil
 }
 
-// Getxattr has not been implemented yet.
+// Getxattr only supports the media class.
 func (d *Dir) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return fuse.ENOSYS
+	return d.super.getMediaXattr(d.inode.ino, req, resp)
 }
 
-// Listxattr has not been implemented yet.
+// Listxattr only supports the media class.
 func (d *Dir) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return fuse.ENOSYS
+	resp.Append(MediaXattr)
+	return nil
 }
 
-// Setxattr has not been implemented yet.
+// Setxattr only supports the media class.
 func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	return fuse.ENOSYS
+	return d.super.setMediaXattr(d.inode.ino, req)
 }
 
 // Removexattr has not been implemented yet.
@@ -115,6 +115,7 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	start := time.Now()
 
 	f.super.ec.OpenStream(ino)
+	f.super.touchAccessTime(ino)
 
 	elapsed := time.Since(start)
 	log.LogDebugf("TRACE Open: ino(%v) req(%v) resp(%v) (%v)ns", ino, req, resp, elapsed.Nanoseconds())
@@ -208,7 +209,7 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 	if err != nil {
 		msg := fmt.Sprintf("Write: ino(%v) offset(%v) len(%v) err(%v)", ino, req.Offset, reqlen, err)
 		f.super.handleError("Write", msg)
-		return fuse.EIO
+		return ParseWriteError(err)
 	}
 
 	resp.Size = size
@@ -220,7 +221,7 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 		if err = f.super.ec.Flush(ino); err != nil {
 			msg := fmt.Sprintf("Write: failed to wait for flush, ino(%v) offset(%v) len(%v) err(%v) req(%v)", ino, req.Offset, reqlen, err, req)
 			f.super.handleError("Wrtie", msg)
-			return fuse.EIO
+			return ParseWriteError(err)
 		}
 	}
 
@@ -243,7 +244,7 @@ func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 	if err != nil {
 		msg := fmt.Sprintf("Fsync: ino(%v) err(%v)", f.inode.ino, err)
 		f.super.handleError("Fsync", msg)
-		return fuse.EIO
+		return ParseWriteError(err)
 	}
 	f.super.ic.Delete(f.inode.ino)
 	elapsed := time.Since(start)
@@ -307,19 +308,20 @@ func (f *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string,
 	return string(inode.target), nil
 }
 
-// Getxattr has not been implemented yet.
+// Getxattr only supports the media class.
 func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return fuse.ENOSYS
+	return f.super.getMediaXattr(f.inode.ino, req, resp)
 }
 
-// Listxattr has not been implemented yet.
+// Listxattr only supports the media class.
 func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return fuse.ENOSYS
+	resp.Append(MediaXattr)
+	return nil
 }
 
-// Setxattr has not been implemented yet.
+// Setxattr only supports the media class.
 func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	return fuse.ENOSYS
+	return f.super.setMediaXattr(f.inode.ino, req)
 }
 
 // Removexattr has not been implemented yet.
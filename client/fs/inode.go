@@ -42,6 +42,7 @@ type Inode struct {
 	atime  time.Time // time of last access
 	mode   os.FileMode
 	target []byte
+	media  uint8 // media class of the data, or of the files created in the directory
 
 	// protected under the inode cache lock
 	expiration int64
@@ -112,6 +113,7 @@ func (inode *Inode) fill(info *proto.InodeInfo) {
 	inode.mtime = info.ModifyTime
 	inode.target = info.Target
 	inode.mode = proto.OsMode(info.Mode)
+	inode.media = info.MediaType
 }
 
 func (inode *Inode) fillAttr(attr *fuse.Attr) {
@@ -0,0 +1,79 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// MediaXattr is the extended attribute of the media class of a file or a directory,
+// i.e. "ssd" or "hdd". The files created in a directory inherit its media class.
+const MediaXattr = "user.cfs.media"
+
+func (s *Super) getMediaXattr(ino uint64, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	if req.Name != MediaXattr {
+		return fuse.ErrNoXattr
+	}
+	inode, err := s.InodeGet(ino)
+	if err != nil {
+		return ParseError(err)
+	}
+	resp.Xattr = []byte(proto.MediaTypeString(inode.media))
+	return nil
+}
+
+func (s *Super) setMediaXattr(ino uint64, req *fuse.SetxattrRequest) error {
+	if req.Name != MediaXattr {
+		return fuse.ENOTSUP
+	}
+	mediaType, err := proto.ParseMediaType(string(req.Xattr))
+	if err != nil {
+		log.LogErrorf("Setxattr: ino(%v) err(%v)", ino, err)
+		return fuse.Errno(syscall.EINVAL)
+	}
+	if err = s.mw.SetMediaType(ino, mediaType); err != nil {
+		return ParseError(err)
+	}
+	s.ic.Delete(ino)
+	log.LogDebugf("TRACE Setxattr: ino(%v) media(%v)", ino, string(req.Xattr))
+	return nil
+}
+
+// touchAccessTime updates the access time of a file which is opened, so that the
+// mover knows whether the file is cold.
+func (s *Super) touchAccessTime(ino uint64) {
+	if s.mw.MediaType() == proto.MediaUnspecified {
+		return
+	}
+	inode, err := s.InodeGet(ino)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(inode.atime) < AccessTimeUpdateInterval {
+		return
+	}
+	if err = s.mw.SetAccessTime(ino, now.Unix()); err != nil {
+		log.LogWarnf("touchAccessTime: ino(%v) err(%v)", ino, err)
+		return
+	}
+	inode.atime = now
+}
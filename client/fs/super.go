@@ -23,6 +23,8 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
 	"github.com/chubaofs/chubaofs/sdk/data/stream"
 	"github.com/chubaofs/chubaofs/sdk/meta"
 	"github.com/chubaofs/chubaofs/util/log"
@@ -51,17 +53,30 @@ var (
 )
 
 // NewSuper returns a new Super.
-func NewSuper(volname, owner, master string, icacheTimeout, lookupValid, attrValid, enSyncWrite int64) (s *Super, err error) {
+func NewSuper(volname, owner, token, master string, icacheTimeout, lookupValid, attrValid, enSyncWrite int64, keys crypt.KeyProvider) (s *Super, err error) {
 	s = new(Super)
-	s.mw, err = meta.NewMetaWrapper(volname, owner, master)
+	s.mw, err = meta.NewMetaWrapperWithToken(volname, owner, token, master)
 	if err != nil {
 		return nil, errors.Trace(err, "NewMetaWrapper failed!")
 	}
+	if s.mw.Encrypted() && keys == nil {
+		return nil, errors.New(fmt.Sprintf("NewSuper: vol(%v) is encrypted, but no key provider is configured", volname))
+	}
 
 	s.ec, err = stream.NewExtentClient(volname, master, s.mw.AppendExtentKey, s.mw.GetExtents, s.mw.Truncate)
 	if err != nil {
 		return nil, errors.Trace(err, "NewExtentClient failed!")
 	}
+	s.ec.SetToken(token)
+
+	if keys != nil {
+		s.mw.SetKeyProvider(keys)
+	}
+	s.ec.SetKeyProvider(keys, s.mw.GetKeyID)
+	s.ec.SetModifyTimeUpdater(s.mw.SetModifyTime)
+	if s.mw.MediaType() != proto.MediaUnspecified {
+		s.ec.SetMediaTypeProvider(s.mw.GetMediaType)
+	}
 
 	s.volname = volname
 	s.owner = owner
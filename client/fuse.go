@@ -34,6 +34,8 @@ import (
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	cfs "github.com/chubaofs/chubaofs/client/fs"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
@@ -90,6 +92,7 @@ func Mount(cfg *config.Config) (err error) {
 	mnt := cfg.GetString("mountPoint")
 	volname := cfg.GetString("volName")
 	owner := cfg.GetString("owner")
+	token := cfg.GetString("token")
 	master := cfg.GetString("masterAddr")
 	logpath := cfg.GetString("logDir")
 	loglvl := cfg.GetString("logLevel")
@@ -98,6 +101,7 @@ func Mount(cfg *config.Config) (err error) {
 	lookupValid := ParseConfigString(cfg, "lookupValid")
 	attrValid := ParseConfigString(cfg, "attrValid")
 	enSyncWrite := ParseConfigString(cfg, "enSyncWrite")
+	keyProvider := cfg.GetString("keyProvider")
 	autoInvalData := ParseConfigString(cfg, "autoInvalData")
 	umpDatadir := cfg.GetString("warnLogDir")
 
@@ -112,7 +116,18 @@ func Mount(cfg *config.Config) (err error) {
 	}
 	defer log.LogFlush()
 
-	super, err := cfs.NewSuper(volname, owner, master, icacheTimeout, lookupValid, attrValid, enSyncWrite)
+	if err = util.InitTLS(cfg); err != nil {
+		log.LogError(errors.Stack(err))
+		return err
+	}
+
+	keys, err := crypt.NewKeyProvider(keyProvider, volname, cfg)
+	if err != nil {
+		log.LogError(errors.Stack(err))
+		return err
+	}
+
+	super, err := cfs.NewSuper(volname, owner, token, master, icacheTimeout, lookupValid, attrValid, enSyncWrite, keys)
 	if err != nil {
 		log.LogError(errors.Stack(err))
 		return err
@@ -65,6 +65,14 @@ func ParseError(err error) syscall.Errno {
 	}
 }
 
+// ParseWriteError returns ENOSPC if the volume reaches its capacity, and EIO for the other errors of the writes.
+func ParseWriteError(err error) syscall.Errno {
+	if err == syscall.ENOSPC {
+		return syscall.ENOSPC
+	}
+	return fuse.EIO
+}
+
 // ParseType returns the dentry type.
 func ParseType(t uint32) fuseutil.DirentType {
 	if proto.IsDir(t) {
@@ -122,7 +122,7 @@ func (s *Super) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
 	size, err := s.ec.Write(ino, offset, op.Data, enSyncWrite)
 	if err != nil {
 		log.LogErrorf("Write: failed to write, op(%v) err(%v)", desc, err)
-		return fuse.EIO
+		return ParseWriteError(err)
 	}
 
 	if size != reqlen {
@@ -133,7 +133,7 @@ func (s *Super) WriteFile(ctx context.Context, op *fuseops.WriteFileOp) error {
 	if waitForFlush {
 		if err = s.ec.Flush(ino); err != nil {
 			log.LogErrorf("Write: failed to wait for flush, op(%v) err(%v)", desc, err)
-			return fuse.EIO
+			return ParseWriteError(err)
 		}
 	}
 
@@ -151,7 +151,7 @@ func (s *Super) SyncFile(ctx context.Context, op *fuseops.SyncFileOp) error {
 	err := s.ec.Flush(ino)
 	if err != nil {
 		log.LogErrorf("Fsync: op(%v) err(%v)", desc, err)
-		return fuse.EIO
+		return ParseWriteError(err)
 	}
 	s.ic.Delete(ino)
 
@@ -168,7 +168,7 @@ func (s *Super) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) error {
 	err := s.ec.Flush(ino)
 	if err != nil {
 		log.LogErrorf("Flush: op(%v) err(%v)", desc, err)
-		return fuse.EIO
+		return ParseWriteError(err)
 	}
 	s.ic.Delete(ino)
 
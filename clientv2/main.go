@@ -36,6 +36,8 @@ import (
 	"github.com/jacobsa/fuse/fuseutil"
 
 	cfs "github.com/chubaofs/chubaofs/clientv2/fs"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
@@ -92,6 +94,7 @@ func Mount(cfg *config.Config) (err error) {
 	mnt := cfg.GetString("mountPoint")
 	volname := cfg.GetString("volName")
 	owner := cfg.GetString("owner")
+	token := cfg.GetString("token")
 	master := cfg.GetString("masterAddr")
 	logpath := cfg.GetString("logDir")
 	loglvl := cfg.GetString("logLevel")
@@ -101,6 +104,7 @@ func Mount(cfg *config.Config) (err error) {
 	lookupValid := ParseConfigString(cfg, "lookupValid")
 	attrValid := ParseConfigString(cfg, "attrValid")
 	enSyncWrite := ParseConfigString(cfg, "enSyncWrite")
+	keyProvider := cfg.GetString("keyProvider")
 	//autoInvalData := ParseConfigString(cfg, "autoInvalData")
 
 	if mnt == "" || volname == "" || owner == "" || master == "" {
@@ -114,7 +118,18 @@ func Mount(cfg *config.Config) (err error) {
 	}
 	defer log.LogFlush()
 
-	super, err := cfs.NewSuper(volname, owner, master, icacheTimeout, lookupValid, attrValid, enSyncWrite)
+	if err = util.InitTLS(cfg); err != nil {
+		log.LogError(errors.Stack(err))
+		return err
+	}
+
+	keys, err := crypt.NewKeyProvider(keyProvider, volname, cfg)
+	if err != nil {
+		log.LogError(errors.Stack(err))
+		return err
+	}
+
+	super, err := cfs.NewSuper(volname, owner, token, master, icacheTimeout, lookupValid, attrValid, enSyncWrite, keys)
 	if err != nil {
 		log.LogError(errors.Stack(err))
 		return err
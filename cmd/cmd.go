@@ -20,6 +20,8 @@ import (
 	"github.com/chubaofs/chubaofs/datanode"
 	"github.com/chubaofs/chubaofs/master"
 	"github.com/chubaofs/chubaofs/metanode"
+	"github.com/chubaofs/chubaofs/mover"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 
 	"flag"
@@ -52,17 +54,21 @@ const (
 	RoleMaster = "master"
 	RoleMeta   = "metanode"
 	RoleData   = "datanode"
+	RoleMover  = "mover"
 )
 
 const (
 	ModuleMaster = "master"
 	ModuleMeta   = "metaNode"
 	ModuleData   = "dataNode"
+	ModuleMover  = "mover"
 )
 
 var (
 	configFile    = flag.String("c", "", "config file path")
 	configVersion = flag.Bool("v", false, "show version")
+	restoreFile   = flag.String("restore", "", "restore the metadata of a new master from the backup file, and exit")
+	restoreIDGap  = flag.Uint64("idGap", 0, "the gap the ID allocators are moved forward by when restoring, larger than the IDs allocated since the backup")
 )
 
 type Server interface {
@@ -152,6 +158,9 @@ func main() {
 	case RoleData:
 		server = datanode.NewServer()
 		module = ModuleData
+	case RoleMover:
+		server = mover.NewServer()
+		module = ModuleMover
 	default:
 		fmt.Println("Fatal: role mismatch: ", role)
 		os.Exit(1)
@@ -191,6 +200,29 @@ func main() {
 		return
 	}
 
+	if *restoreFile != "" {
+		if role != RoleMaster {
+			fmt.Println("Fatal: only the metadata of a master can be restored")
+			os.Exit(1)
+		}
+		if err = master.RestoreMetadata(cfg, *restoreFile, *restoreIDGap); err != nil {
+			fmt.Println("Fatal: failed to restore the metadata - ", err)
+			log.LogFlush()
+			os.Exit(1)
+		}
+		fmt.Printf("restored the metadata from %v\n", *restoreFile)
+		log.LogFlush()
+		os.Exit(0)
+	}
+
+	if err = util.InitTLS(cfg); err != nil {
+		fmt.Println("Fatal: failed to init tls - ", err)
+		log.LogFatal("Fatal: failed to init tls - ", err)
+		log.LogFlush()
+		os.Exit(1)
+		return
+	}
+
 	interceptSignal(server)
 	err = server.Start(cfg)
 	if err != nil {
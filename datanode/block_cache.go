@@ -0,0 +1,375 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"container/list"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	MetricBlockCacheHit  = "dataNode_blockCache_hit"
+	MetricBlockCacheMiss = "dataNode_blockCache_miss"
+
+	maxBlockCacheTrackedGens = 65536 // the generations of the extents and the partitions tracked at most
+)
+
+type blockKey struct {
+	partitionID uint64
+	extentID    uint64
+	offset      int64
+}
+
+type extentKey struct {
+	partitionID uint64
+	extentID    uint64
+}
+
+type blockCacheEntry struct {
+	key  blockKey
+	size int64
+	crc  uint32
+	data []byte // only kept by the memory tier
+}
+
+// blockCacheTier is a size bounded LRU list of the cached blocks.
+type blockCacheTier struct {
+	capacity int64
+	used     int64
+	lru      *list.List
+	entries  map[blockKey]*list.Element
+	extents  map[extentKey]map[int64]struct{}
+}
+
+func newBlockCacheTier(capacity int64) *blockCacheTier {
+	return &blockCacheTier{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[blockKey]*list.Element),
+		extents:  make(map[extentKey]map[int64]struct{}),
+	}
+}
+
+func (t *blockCacheTier) get(key blockKey) (entry *blockCacheEntry) {
+	elem, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	t.lru.MoveToFront(elem)
+	return elem.Value.(*blockCacheEntry)
+}
+
+func (t *blockCacheTier) add(entry *blockCacheEntry) {
+	t.remove(entry.key)
+	t.entries[entry.key] = t.lru.PushFront(entry)
+	ek := extentKey{entry.key.partitionID, entry.key.extentID}
+	if t.extents[ek] == nil {
+		t.extents[ek] = make(map[int64]struct{})
+	}
+	t.extents[ek][entry.key.offset] = struct{}{}
+	t.used += entry.size
+}
+
+func (t *blockCacheTier) remove(key blockKey) (entry *blockCacheEntry) {
+	elem, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	entry = elem.Value.(*blockCacheEntry)
+	t.lru.Remove(elem)
+	delete(t.entries, key)
+	ek := extentKey{key.partitionID, key.extentID}
+	if offsets := t.extents[ek]; offsets != nil {
+		delete(offsets, key.offset)
+		if len(offsets) == 0 {
+			delete(t.extents, ek)
+		}
+	}
+	t.used -= entry.size
+	return
+}
+
+// evict removes the least recently used blocks until the tier fits into its capacity.
+func (t *blockCacheTier) evict() (evicted []*blockCacheEntry) {
+	for t.used > t.capacity && t.lru.Len() > 0 {
+		entry := t.lru.Back().Value.(*blockCacheEntry)
+		t.remove(entry.key)
+		evicted = append(evicted, entry)
+	}
+	return
+}
+
+func (t *blockCacheTier) removeExtent(ek extentKey) (removed []*blockCacheEntry) {
+	for offset := range t.extents[ek] {
+		if entry := t.remove(blockKey{ek.partitionID, ek.extentID, offset}); entry != nil {
+			removed = append(removed, entry)
+		}
+	}
+	return
+}
+
+func (t *blockCacheTier) removePartition(partitionID uint64) (removed []*blockCacheEntry) {
+	for ek := range t.extents {
+		if ek.partitionID == partitionID {
+			removed = append(removed, t.removeExtent(ek)...)
+		}
+	}
+	return
+}
+
+// BlockCache caches the blocks read from the extents by the stream reads.
+// The blocks are kept in memory first, and the ones evicted from the memory are
+// moved to the SSD directory if it is configured.
+// A block is identified by its partition, extent and offset, and a hit requires the same size.
+//
+// An invalidation moves the generation of its extent or partition forward, so that the reads of them in
+// flight are not cached, while the reads of the other extents are. Every generation is taken from a clock
+// increased by every invalidation. Only the generations of the extents and the partitions invalidated are
+// tracked, the others are at the floor, and once too many are tracked, they are all dropped by raising the
+// floor to the clock, which drops the reads in flight but never lets a stale one in.
+type BlockCache struct {
+	sync.Mutex
+	mem           *blockCacheTier
+	ssd           *blockCacheTier
+	ssdDir        string
+	clock         uint64
+	floor         uint64
+	extentGens    map[extentKey]uint64
+	partitionGens map[uint64]uint64
+}
+
+// NewBlockCache creates a new block cache. The SSD tier is disabled if ssdDir is empty.
+func NewBlockCache(memSize int64, ssdDir string, ssdSize int64) (c *BlockCache, err error) {
+	c = &BlockCache{
+		mem:           newBlockCacheTier(memSize),
+		extentGens:    make(map[extentKey]uint64),
+		partitionGens: make(map[uint64]uint64),
+	}
+	if ssdDir != "" && ssdSize > 0 {
+		// the blocks left by the last run may be stale
+		if err = os.RemoveAll(ssdDir); err != nil {
+			return
+		}
+		if err = os.MkdirAll(ssdDir, 0755); err != nil {
+			return
+		}
+		c.ssdDir = ssdDir
+		c.ssd = newBlockCacheTier(ssdSize)
+	}
+	return
+}
+
+// Generation returns the current generation of the extent, which needs to be passed to Put.
+func (c *BlockCache) Generation(partitionID, extentID uint64) (gen uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	gen = c.generationWithLock(extentKey{partitionID, extentID})
+	c.Unlock()
+	return
+}
+
+func (c *BlockCache) generationWithLock(ek extentKey) (gen uint64) {
+	gen = c.floor
+	if g, ok := c.extentGens[ek]; ok && g > gen {
+		gen = g
+	}
+	if g, ok := c.partitionGens[ek.partitionID]; ok && g > gen {
+		gen = g
+	}
+	return
+}
+
+// invalidateWithLock moves the generation of the extent, or of the whole partition if the extent is nil, forward.
+func (c *BlockCache) invalidateWithLock(partitionID uint64, ek *extentKey) {
+	c.clock++
+	if len(c.extentGens)+len(c.partitionGens) >= maxBlockCacheTrackedGens {
+		c.extentGens = make(map[extentKey]uint64)
+		c.partitionGens = make(map[uint64]uint64)
+		c.floor = c.clock
+		return
+	}
+	if ek != nil {
+		c.extentGens[*ek] = c.clock
+	} else {
+		c.partitionGens[partitionID] = c.clock
+	}
+}
+
+// Get copies the cached block into data and returns its crc.
+func (c *BlockCache) Get(partitionID, extentID uint64, offset, size int64, data []byte) (crc uint32, hit bool) {
+	if c == nil {
+		return
+	}
+	defer func() {
+		if hit {
+			exporter.NewCounter(MetricBlockCacheHit).Add(1)
+		} else {
+			exporter.NewCounter(MetricBlockCacheMiss).Add(1)
+		}
+	}()
+	key := blockKey{partitionID, extentID, offset}
+	c.Lock()
+	if entry := c.mem.get(key); entry != nil {
+		if entry.size == size {
+			copy(data[:size], entry.data)
+			crc, hit = entry.crc, true
+		}
+		c.Unlock()
+		return
+	}
+	var entry *blockCacheEntry
+	if c.ssd != nil {
+		entry = c.ssd.get(key)
+	}
+	gen := c.generationWithLock(extentKey{partitionID, extentID})
+	c.Unlock()
+	if entry == nil || entry.size != size {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(c.blockFilePath(key))
+	if err != nil || int64(len(buf)) != size || crc32.ChecksumIEEE(buf) != entry.crc {
+		return
+	}
+	copy(data[:size], buf)
+	crc, hit = entry.crc, true
+	c.Put(partitionID, extentID, offset, buf, entry.crc, gen)
+	return
+}
+
+// Put adds a block into the cache. The block is dropped if its extent has been
+// invalidated since the given generation, as it may be read before the invalidation.
+func (c *BlockCache) Put(partitionID, extentID uint64, offset int64, data []byte, crc uint32, gen uint64) {
+	if c == nil || int64(len(data)) > c.mem.capacity {
+		return
+	}
+	entry := &blockCacheEntry{
+		key:  blockKey{partitionID, extentID, offset},
+		size: int64(len(data)),
+		crc:  crc,
+		data: make([]byte, len(data)),
+	}
+	copy(entry.data, data)
+
+	c.Lock()
+	if gen != c.generationWithLock(extentKey{partitionID, extentID}) {
+		c.Unlock()
+		return
+	}
+	c.mem.add(entry)
+	evicted := c.mem.evict()
+	gens := make([]uint64, len(evicted))
+	for i, e := range evicted {
+		gens[i] = c.generationWithLock(extentKey{e.key.partitionID, e.key.extentID})
+	}
+	c.Unlock()
+
+	for i, e := range evicted {
+		c.demote(e, gens[i])
+	}
+}
+
+// demote moves a block evicted from the memory to the SSD directory.
+func (c *BlockCache) demote(entry *blockCacheEntry, gen uint64) {
+	if c.ssd == nil || entry.size > c.ssd.capacity {
+		return
+	}
+	filePath := c.blockFilePath(entry.key)
+	if err := ioutil.WriteFile(filePath, entry.data, 0644); err != nil {
+		log.LogWarnf("action[BlockCache.demote] write block file(%v) err(%v)", filePath, err)
+		os.Remove(filePath)
+		return
+	}
+	ssdEntry := &blockCacheEntry{key: entry.key, size: entry.size, crc: entry.crc}
+
+	c.Lock()
+	if gen != c.generationWithLock(extentKey{entry.key.partitionID, entry.key.extentID}) {
+		c.Unlock()
+		os.Remove(filePath)
+		return
+	}
+	c.ssd.add(ssdEntry)
+	evicted := c.ssd.evict()
+	c.Unlock()
+
+	for _, e := range evicted {
+		os.Remove(c.blockFilePath(e.key))
+	}
+}
+
+// InvalidateExtent drops all the cached blocks of the given extent.
+func (c *BlockCache) InvalidateExtent(partitionID, extentID uint64) {
+	if c == nil {
+		return
+	}
+	ek := extentKey{partitionID, extentID}
+	c.Lock()
+	c.invalidateWithLock(partitionID, &ek)
+	c.mem.removeExtent(ek)
+	var removed []*blockCacheEntry
+	if c.ssd != nil {
+		removed = c.ssd.removeExtent(ek)
+	}
+	c.Unlock()
+	for _, e := range removed {
+		os.Remove(c.blockFilePath(e.key))
+	}
+}
+
+// InvalidatePartition drops all the cached blocks of the given partition.
+func (c *BlockCache) InvalidatePartition(partitionID uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	c.invalidateWithLock(partitionID, nil)
+	c.mem.removePartition(partitionID)
+	var removed []*blockCacheEntry
+	if c.ssd != nil {
+		removed = c.ssd.removePartition(partitionID)
+	}
+	c.Unlock()
+	for _, e := range removed {
+		os.Remove(c.blockFilePath(e.key))
+	}
+}
+
+// Stats returns the number of blocks and the bytes cached in the memory and the SSD.
+func (c *BlockCache) Stats() (memBlocks int, memUsed int64, ssdBlocks int, ssdUsed int64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	memBlocks, memUsed = c.mem.lru.Len(), c.mem.used
+	if c.ssd != nil {
+		ssdBlocks, ssdUsed = c.ssd.lru.Len(), c.ssd.used
+	}
+	return
+}
+
+func (c *BlockCache) blockFilePath(key blockKey) string {
+	return path.Join(c.ssdDir, fmt.Sprintf("%v_%v_%v", key.partitionID, key.extentID, key.offset))
+}
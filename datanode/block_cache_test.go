@@ -0,0 +1,166 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const testBlockSize = 4
+
+func newTestBlockCache(t *testing.T, memBlocks, ssdBlocks int64) (c *BlockCache, ssdDir string) {
+	if ssdBlocks > 0 {
+		dir, err := ioutil.TempDir("", "blockcache")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ssdDir = path.Join(dir, "ssd")
+	}
+	c, err := NewBlockCache(memBlocks*testBlockSize, ssdDir, ssdBlocks*testBlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func testBlock(b byte) []byte {
+	return bytes.Repeat([]byte{b}, testBlockSize)
+}
+
+func putTestBlock(c *BlockCache, partitionID, extentID uint64, offset int64, data []byte) {
+	c.Put(partitionID, extentID, offset, data, crc32.ChecksumIEEE(data), c.Generation(partitionID, extentID))
+}
+
+// checkTestBlock checks if the block is cached with the data, or not cached if data is nil.
+func checkTestBlock(t *testing.T, c *BlockCache, partitionID, extentID uint64, offset int64, data []byte) {
+	buf := make([]byte, testBlockSize)
+	crc, hit := c.Get(partitionID, extentID, offset, testBlockSize, buf)
+	if data == nil {
+		if hit {
+			t.Fatalf("block(%v_%v_%v) is cached", partitionID, extentID, offset)
+		}
+		return
+	}
+	if !hit || !bytes.Equal(buf, data) || crc != crc32.ChecksumIEEE(data) {
+		t.Fatalf("block(%v_%v_%v) hit(%v) data(%v) crc(%v), expected %v", partitionID, extentID, offset, hit, buf, crc, data)
+	}
+}
+
+func TestBlockCacheHitAndMiss(t *testing.T) {
+	c, _ := newTestBlockCache(t, 4, 0)
+	checkTestBlock(t, c, 1, 1, 0, nil)
+	putTestBlock(c, 1, 1, 0, testBlock('a'))
+	checkTestBlock(t, c, 1, 1, 0, testBlock('a'))
+	checkTestBlock(t, c, 1, 1, testBlockSize, nil)
+	checkTestBlock(t, c, 1, 2, 0, nil)
+	checkTestBlock(t, c, 2, 1, 0, nil)
+	// a hit requires the same size
+	if _, hit := c.Get(1, 1, 0, testBlockSize-1, make([]byte, testBlockSize)); hit {
+		t.Fatalf("block of another size is hit")
+	}
+
+	var disabled *BlockCache
+	disabled.Put(1, 1, 0, testBlock('a'), 0, disabled.Generation(1, 1))
+	if _, hit := disabled.Get(1, 1, 0, testBlockSize, make([]byte, testBlockSize)); hit {
+		t.Fatalf("disabled cache is hit")
+	}
+}
+
+func TestBlockCacheInvalidation(t *testing.T) {
+	c, _ := newTestBlockCache(t, 8, 0)
+	putTestBlock(c, 1, 1, 0, testBlock('a'))
+	putTestBlock(c, 1, 2, 0, testBlock('b'))
+	putTestBlock(c, 2, 1, 0, testBlock('c'))
+
+	// a block read before a write of its extent is not cached after the write
+	gen, otherGen := c.Generation(1, 1), c.Generation(1, 2)
+	c.InvalidateExtent(1, 1)
+	checkTestBlock(t, c, 1, 1, 0, nil)
+	checkTestBlock(t, c, 1, 2, 0, testBlock('b'))
+	c.Put(1, 1, 0, testBlock('a'), crc32.ChecksumIEEE(testBlock('a')), gen)
+	checkTestBlock(t, c, 1, 1, 0, nil)
+	// the reads of the other extents are still cached
+	c.Put(1, 2, testBlockSize, testBlock('d'), crc32.ChecksumIEEE(testBlock('d')), otherGen)
+	checkTestBlock(t, c, 1, 2, testBlockSize, testBlock('d'))
+	putTestBlock(c, 1, 1, 0, testBlock('e'))
+	checkTestBlock(t, c, 1, 1, 0, testBlock('e'))
+
+	gen, otherGen = c.Generation(1, 3), c.Generation(2, 1)
+	c.InvalidatePartition(1)
+	checkTestBlock(t, c, 1, 1, 0, nil)
+	checkTestBlock(t, c, 1, 2, 0, nil)
+	checkTestBlock(t, c, 2, 1, 0, testBlock('c'))
+	c.Put(1, 3, 0, testBlock('f'), crc32.ChecksumIEEE(testBlock('f')), gen)
+	checkTestBlock(t, c, 1, 3, 0, nil)
+	c.Put(2, 1, testBlockSize, testBlock('g'), crc32.ChecksumIEEE(testBlock('g')), otherGen)
+	checkTestBlock(t, c, 2, 1, testBlockSize, testBlock('g'))
+
+	// a stale read is still dropped once the generations tracked are dropped
+	gen = c.Generation(3, 1)
+	c.InvalidateExtent(3, 1)
+	for i := uint64(0); i < maxBlockCacheTrackedGens; i++ {
+		c.InvalidateExtent(4, i)
+	}
+	c.Put(3, 1, 0, testBlock('h'), crc32.ChecksumIEEE(testBlock('h')), gen)
+	checkTestBlock(t, c, 3, 1, 0, nil)
+	if tracked := len(c.extentGens) + len(c.partitionGens); tracked >= maxBlockCacheTrackedGens {
+		t.Fatalf("%v generations are tracked", tracked)
+	}
+}
+
+func TestBlockCacheSSDEviction(t *testing.T) {
+	c, ssdDir := newTestBlockCache(t, 2, 2)
+	defer os.RemoveAll(path.Dir(ssdDir))
+	for i := int64(0); i < 4; i++ {
+		putTestBlock(c, 1, 1, i*testBlockSize, testBlock(byte('a'+i)))
+	}
+	// the two least recently used blocks are moved to the SSD
+	memBlocks, _, ssdBlocks, ssdUsed := c.Stats()
+	if memBlocks != 2 || ssdBlocks != 2 || ssdUsed != 2*testBlockSize {
+		t.Fatalf("memory blocks(%v) ssd blocks(%v) ssd used(%v)", memBlocks, ssdBlocks, ssdUsed)
+	}
+	checkTestBlock(t, c, 1, 1, 0, testBlock('a'))
+
+	// the blocks evicted from the SSD are removed from the directory
+	for i := int64(4); i < 8; i++ {
+		putTestBlock(c, 1, 1, i*testBlockSize, testBlock(byte('a'+i)))
+	}
+	files, err := ioutil.ReadDir(ssdDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ssdBlocks, _ = c.Stats(); ssdBlocks != 2 || len(files) != 2 {
+		t.Fatalf("ssd blocks(%v) files(%v)", ssdBlocks, len(files))
+	}
+	checkTestBlock(t, c, 1, 1, testBlockSize, nil)
+
+	// a corrupted block on the SSD is not hit
+	key := blockKey{1, 1, 5 * testBlockSize}
+	if err = ioutil.WriteFile(c.blockFilePath(key), testBlock('z'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkTestBlock(t, c, 1, 1, 5*testBlockSize, nil)
+
+	// the blocks of an extent invalidated are removed from the SSD as well
+	c.InvalidateExtent(1, 1)
+	if files, err = ioutil.ReadDir(ssdDir); err != nil || len(files) != 0 {
+		t.Fatalf("%v files left in the ssd directory, err(%v)", len(files), err)
+	}
+}
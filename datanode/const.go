@@ -61,6 +61,8 @@ const (
 	ActionWrite                      = "ActionWrite:"
 	ActionRepair                     = "ActionRepair:"
 	ActionDecommissionPartition      = "ActionDecommissionPartition"
+	ActionChangeRaftMember           = "ActionChangeRaftMember"
+	ActionTryToLeader                = "ActionTryToLeader"
 	ActionCreateDataPartition        = "ActionCreateDataPartition"
 	ActionLoadDataPartition          = "ActionLoadDataPartition"
 	ActionDeleteDataPartition        = "ActionDeleteDataPartition"
@@ -93,11 +93,17 @@ func (dp *DataPartition) repair(extentType uint8) {
 		log.LogErrorf("action[repair] partition(%v) err(%v).",
 			dp.partitionID, err)
 		dp.moveToBrokenTinyExtentC(extentType, tinyExtents)
+		if extentType == proto.NormalExtentType {
+			dp.setSyncedReplicas(nil)
+		}
 		return
 	}
 
 	// compare all the extents in the replicas to compute the good and bad ones
 	availableTinyExtents, brokenTinyExtents := dp.prepareRepairTasks(repairTasks)
+	if extentType == proto.NormalExtentType {
+		dp.updateSyncedReplicas(repairTasks)
+	}
 
 	// notify the replicas to repair the extent
 	err = dp.NotifyExtentRepair(repairTasks)
@@ -128,6 +134,31 @@ func (dp *DataPartition) repair(extentType uint8) {
 		(end-start)/int64(time.Millisecond))
 }
 
+// updateSyncedReplicas records the replicas which miss no extent and hold every extent up to the largest
+// watermark among the replicas, so that the master knows when a new replica has caught up with the others.
+func (dp *DataPartition) updateSyncedReplicas(repairTasks []*DataPartitionRepairTask) {
+	synced := make([]string, 0, len(repairTasks))
+	for _, task := range repairTasks {
+		if len(task.ExtentsToBeCreated) == 0 && len(task.ExtentsToBeRepaired) == 0 {
+			synced = append(synced, task.addr)
+		}
+	}
+	dp.setSyncedReplicas(synced)
+}
+
+func (dp *DataPartition) setSyncedReplicas(synced []string) {
+	dp.syncedReplicasMutex.Lock()
+	defer dp.syncedReplicasMutex.Unlock()
+	dp.syncedReplicas = synced
+}
+
+// SyncedReplicas returns the replicas found in sync by the last repair of the normal extents on the leader.
+func (dp *DataPartition) SyncedReplicas() []string {
+	dp.syncedReplicasMutex.RLock()
+	defer dp.syncedReplicasMutex.RUnlock()
+	return dp.syncedReplicas
+}
+
 func (dp *DataPartition) buildDataPartitionRepairTask(repairTasks []*DataPartitionRepairTask, extentType uint8, tinyExtents []uint64) (err error) {
 	// get the local extent info
 	extents, LeaderTinyDeleteFileSize, err := dp.getLocalExtentInfo(extentType, tinyExtents)
@@ -190,7 +221,7 @@ func (dp *DataPartition) getRemoteExtentInfo(extentType uint8, tinyExtents []uin
 		}
 		p.Size = uint32(len(p.Data))
 	}
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = gConnPool.GetConnect(target) // get remote connection
 	if err != nil {
 		err = errors.Trace(err, "getRemoteExtentInfo DataPartition(%v) get host(%v) connect", dp.partitionID, target)
@@ -363,7 +394,8 @@ func (dp *DataPartition) buildExtentRepairTasks(repairTasks []*DataPartitionRepa
 
 func (dp *DataPartition) notifyFollower(wg *sync.WaitGroup, index int, members []*DataPartitionRepairTask) (err error) {
 	p := repl.NewPacketToNotifyExtentRepair(dp.partitionID) // notify all the followers to repair
-	var conn *net.TCPConn
+	dp.setServiceToken(p)
+	var conn net.Conn
 	target := dp.replicas[index]
 	p.Data, _ = json.Marshal(members[index])
 	p.Size = uint32(len(p.Data))
@@ -436,7 +468,8 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 	// size difference between the local extent and the remote extent
 	sizeDiff := remoteExtentInfo.Size - localExtentInfo.Size
 	request := repl.NewExtentRepairReadPacket(dp.partitionID, remoteExtentInfo.FileID, int(localExtentInfo.Size), int(sizeDiff))
-	var conn *net.TCPConn
+	dp.setServiceToken(request)
+	var conn net.Conn
 	conn, err = gConnPool.GetConnect(remoteExtentInfo.Source)
 	if err != nil {
 		return errors.Trace(err, "streamRepairExtent get conn from host[%v] error", remoteExtentInfo.Source)
@@ -487,6 +520,7 @@ func (dp *DataPartition) streamRepairExtent(remoteExtentInfo *storage.ExtentInfo
 		}
 
 		// write to the local extent file
+		dp.disk.space.RepairLimiter().Wait(dp.disk, int64(reply.Size))
 		err = store.Write(uint64(localExtentInfo.FileID), int64(currFixOffset), int64(reply.Size), reply.Data, reply.CRC, UpdateSize, BufferWrite)
 		if err != nil {
 			err = errors.Trace(err, "streamRepairExtent repair data error")
@@ -49,23 +49,32 @@ type Disk struct {
 	Unallocated uint64
 	Allocated   uint64
 
-	MaxErrCnt     int // maximum number of errors
-	Status        int // disk status such as READONLY
-	ReservedSpace uint64
+	MaxReadErrCnt  uint64 // the disk is considered as broken once the read errors reach this threshold
+	MaxWriteErrCnt uint64 // the disk is considered as broken once the write errors reach this threshold
+	Status         int    // disk status such as READONLY
+	ReservedSpace  uint64
+	MediaType      uint8 // media class of the disk such as ssd or hdd
+	broken         int32 // set once the disk is considered as broken
+	clientIOs      int64 // number of the client IO in flight, which takes priority over the repair
 
 	partitionMap map[uint64]*DataPartition
 	space        *SpaceManager
+	stopC        chan bool
+	stopOnce     sync.Once
 }
 
 type PartitionVisitor func(dp *DataPartition)
 
-func NewDisk(path string, restSize uint64, maxErrCnt int, space *SpaceManager) (d *Disk) {
+func NewDisk(path string, restSize uint64, mediaType uint8, maxReadErrCnt, maxWriteErrCnt uint64, space *SpaceManager) (d *Disk) {
 	d = new(Disk)
 	d.Path = path
 	d.ReservedSpace = restSize
-	d.MaxErrCnt = maxErrCnt
+	d.MediaType = mediaType
+	d.MaxReadErrCnt = maxReadErrCnt
+	d.MaxWriteErrCnt = maxWriteErrCnt
 	d.space = space
 	d.partitionMap = make(map[uint64]*DataPartition)
+	d.stopC = make(chan bool, 0)
 
 	d.computeUsage()
 
@@ -73,6 +82,21 @@ func NewDisk(path string, restSize uint64, maxErrCnt int, space *SpaceManager) (
 	return
 }
 
+// Stop stops the space info updating of the disk.
+func (d *Disk) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopC)
+	})
+}
+
+func (d *Disk) beginClientIO() {
+	atomic.AddInt64(&d.clientIOs, 1)
+}
+
+func (d *Disk) endClientIO() {
+	atomic.AddInt64(&d.clientIOs, -1)
+}
+
 // PartitionCount returns the number of partitions in the partition map.
 func (d *Disk) PartitionCount() int {
 	d.RLock()
@@ -150,6 +174,8 @@ func (d *Disk) startScheduleToUpdateSpaceInfo() {
 				d.computeUsage()
 
 				d.updateSpaceInfo()
+			case <-d.stopC:
+				return
 			}
 		}
 	}()
@@ -160,22 +186,48 @@ func (d *Disk) updateSpaceInfo() (err error) {
 	if err = syscall.Statfs(d.Path, &statsInfo); err != nil {
 		d.incReadErrCnt()
 	}
-	currErrs := d.ReadErrCnt + d.WriteErrCnt
-	if currErrs >= uint64(d.MaxErrCnt) {
-		d.Status = proto.Unavailable
-		exporter.NewAlarm(fmt.Sprintf("disk path %v error on %v", d.Path, LocalIP))
-		d.ForceExitRaftStore()
+	if d.checkErrors() {
+		// the status of a broken disk never changes
 	} else if d.Available <= 0 {
 		d.Status = proto.ReadOnly
 	} else {
 		d.Status = proto.ReadWrite
 	}
 	log.LogDebugf("action[updateSpaceInfo] disk[%v] total[%v] available[%v] remain[%v] "+
-		"restSize[%v] maxErrs[%v] readErrs[%v] writeErrs[%v] status[%v]", d.Path,
-		d.Total, d.Available, d.Unallocated, d.ReservedSpace, d.MaxErrCnt, d.ReadErrCnt, d.WriteErrCnt, d.Status)
+		"restSize[%v] maxReadErrs[%v] maxWriteErrs[%v] readErrs[%v] writeErrs[%v] status[%v]", d.Path,
+		d.Total, d.Available, d.Unallocated, d.ReservedSpace, d.MaxReadErrCnt, d.MaxWriteErrCnt, d.ReadErrCnt, d.WriteErrCnt, d.Status)
 	return
 }
 
+// IsBroken returns true if the disk has been marked as broken.
+func (d *Disk) IsBroken() bool {
+	return atomic.LoadInt32(&d.broken) == 1
+}
+
+// checkErrors marks the disk as broken once the errors pass the thresholds, and returns if it is broken.
+// A broken disk is unavailable, so no partition is allocated on it, and its partitions are out of
+// the write rotation. The master rebuilds the replicas elsewhere once the disk status is reported by heartbeat.
+func (d *Disk) checkErrors() bool {
+	if d.IsBroken() {
+		d.Status = proto.Unavailable
+		return true
+	}
+	readErrs := atomic.LoadUint64(&d.ReadErrCnt)
+	writeErrs := atomic.LoadUint64(&d.WriteErrCnt)
+	if readErrs < d.MaxReadErrCnt && writeErrs < d.MaxWriteErrCnt {
+		return false
+	}
+	d.Status = proto.Unavailable
+	if atomic.CompareAndSwapInt32(&d.broken, 0, 1) {
+		mesg := fmt.Sprintf("disk path %v error on %v, readErrs(%v/%v) writeErrs(%v/%v), mark it as broken",
+			d.Path, LocalIP, readErrs, d.MaxReadErrCnt, writeErrs, d.MaxWriteErrCnt)
+		log.LogError(mesg)
+		exporter.NewAlarm(mesg)
+		d.ForceExitRaftStore()
+	}
+	return true
+}
+
 // AttachDataPartition adds a data partition to the partition map.
 func (d *Disk) AttachDataPartition(dp *DataPartition) {
 	d.Lock()
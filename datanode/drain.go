@@ -0,0 +1,279 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	DefaultDrainTimeout = 10 * 60 // in terms of seconds
+	drainCheckInterval  = 5 * time.Second
+	drainQuietTime      = 30 // the writes are considered finished if there is no write in this time, in seconds
+)
+
+var ErrDraining = errors.New("data node is draining")
+
+// DrainStatus describes the progress of draining the data node.
+// A draining node creates no new extents, and gives away the raft leadership of its partitions.
+// It is drained once the leadership has been transferred and the open write streams have finished,
+// and is shut down then if asked.
+type DrainStatus struct {
+	Draining       bool  `json:"draining"`
+	ByMaster       bool  `json:"byMaster"`
+	Shutdown       bool  `json:"shutdown"`
+	StartTime      int64 `json:"startTime"`
+	Timeout        int64 `json:"timeout"`
+	RaftLeaders    int   `json:"raftLeaders"`
+	InflightWrites int64 `json:"inflightWrites"`
+	LastWriteTime  int64 `json:"lastWriteTime"`
+	Drained        bool  `json:"drained"`
+}
+
+type drainer struct {
+	sync.RWMutex
+	status         DrainStatus
+	byAdmin        bool
+	running        bool
+	inflightWrites int64
+	lastWriteTime  int64
+}
+
+func newDrainer() *drainer {
+	return new(drainer)
+}
+
+func (d *drainer) isDraining() bool {
+	d.RLock()
+	defer d.RUnlock()
+	return d.status.Draining
+}
+
+func (d *drainer) beginWrite() {
+	atomic.AddInt64(&d.inflightWrites, 1)
+	atomic.StoreInt64(&d.lastWriteTime, time.Now().Unix())
+}
+
+func (d *drainer) endWrite() {
+	atomic.AddInt64(&d.inflightWrites, -1)
+}
+
+func (d *drainer) getStatus() (status DrainStatus) {
+	d.RLock()
+	status = d.status
+	d.RUnlock()
+	status.InflightWrites = atomic.LoadInt64(&d.inflightWrites)
+	status.LastWriteTime = atomic.LoadInt64(&d.lastWriteTime)
+	return
+}
+
+func isWritePacket(p *repl.Packet) bool {
+	switch p.Opcode {
+	case proto.OpCreateExtent, proto.OpWrite, proto.OpSyncWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite:
+		return true
+	}
+	return false
+}
+
+// startDrain starts to drain the data node, either asked by the master or by the admin API.
+func (s *DataNode) startDrain(byMaster, shutdown bool, timeout int64) {
+	d := s.drainer
+	d.Lock()
+	defer d.Unlock()
+	if byMaster {
+		d.status.ByMaster = true
+	} else {
+		d.byAdmin = true
+		d.status.Shutdown = shutdown
+		d.status.Timeout = timeout
+	}
+	if d.status.Timeout <= 0 {
+		d.status.Timeout = DefaultDrainTimeout
+	}
+	if !d.status.Draining {
+		d.status.Draining = true
+		d.status.Drained = false
+		d.status.StartTime = time.Now().Unix()
+		log.LogWarnf("action[startDrain] byMaster(%v) shutdown(%v) timeout(%v).", byMaster, shutdown, timeout)
+	}
+	if !d.running {
+		d.running = true
+		go s.runDrain()
+	}
+}
+
+// stopDrain stops draining the data node, unless it is still asked to drain by the other side.
+func (s *DataNode) stopDrain(byMaster bool) {
+	d := s.drainer
+	d.Lock()
+	defer d.Unlock()
+	if byMaster {
+		d.status.ByMaster = false
+	} else {
+		d.byAdmin = false
+		d.status.Shutdown = false
+	}
+	if d.status.Draining && !d.status.ByMaster && !d.byAdmin {
+		d.status = DrainStatus{}
+		log.LogWarnf("action[stopDrain] byMaster(%v).", byMaster)
+	}
+}
+
+// setDrainByMaster follows the drain flag in the heartbeat of the master.
+func (s *DataNode) setDrainByMaster(drain bool) {
+	s.drainer.RLock()
+	byMaster := s.drainer.status.ByMaster
+	s.drainer.RUnlock()
+	if drain && !byMaster {
+		s.startDrain(true, false, 0)
+	} else if !drain && byMaster {
+		s.stopDrain(true)
+	}
+}
+
+func (s *DataNode) runDrain() {
+	d := s.drainer
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		d.Lock()
+		if !d.status.Draining {
+			d.running = false
+			d.Unlock()
+			return
+		}
+		d.Unlock()
+
+		leaders := s.transferRaftLeaders()
+		now := time.Now().Unix()
+		writesDone := atomic.LoadInt64(&d.inflightWrites) == 0 && now-atomic.LoadInt64(&d.lastWriteTime) >= drainQuietTime
+
+		d.Lock()
+		d.status.RaftLeaders = leaders
+		timedOut := now-d.status.StartTime >= d.status.Timeout
+		if !d.status.Drained && ((leaders == 0 && writesDone) || timedOut) {
+			d.status.Drained = true
+			log.LogWarnf("action[runDrain] drained, raftLeaders(%v) inflightWrites(%v) timedOut(%v).",
+				leaders, atomic.LoadInt64(&d.inflightWrites), timedOut)
+		}
+		shutdown := d.status.Drained && d.status.Shutdown
+		d.Unlock()
+
+		if shutdown {
+			log.LogWarnf("action[runDrain] shut down the drained data node.")
+			s.Shutdown()
+			return
+		}
+		select {
+		case <-s.stopC:
+			d.Lock()
+			d.running = false
+			d.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// transferRaftLeaders asks the other replicas to take over the raft leadership of the partitions led by
+// this node, and returns the number of the partitions still led by this node.
+func (s *DataNode) transferRaftLeaders() (leaders int) {
+	s.space.RangePartitions(func(dp *DataPartition) bool {
+		if _, isLeader := dp.IsRaftLeader(); !isLeader {
+			return true
+		}
+		leaders++
+		if err := dp.transferRaftLeader(); err != nil {
+			log.LogWarnf("action[transferRaftLeaders] partition(%v) err(%v).", dp.partitionID, err)
+		}
+		return true
+	})
+	return
+}
+
+func (dp *DataPartition) transferRaftLeader() (err error) {
+	err = fmt.Errorf("no other replica")
+	for _, peer := range dp.config.Peers {
+		if peer.ID == dp.config.NodeID {
+			continue
+		}
+		if err = dp.askToBeLeader(peer.Addr); err == nil {
+			log.LogInfof("action[transferRaftLeader] partition(%v) asked %v to be the leader.", dp.partitionID, peer.Addr)
+			return
+		}
+	}
+	return
+}
+
+func (dp *DataPartition) askToBeLeader(target string) (err error) {
+	var conn net.Conn
+	p := NewPacketToTryToLeader(dp.partitionID)
+	dp.setServiceToken(p)
+	if conn, err = gConnPool.GetConnect(target); err != nil {
+		return
+	}
+	defer gConnPool.PutConnect(conn, true)
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = fmt.Errorf("%v", string(p.Data[:p.Size]))
+	}
+	return
+}
+
+// NewPacketToTryToLeader returns a new packet to ask a replica to take over the raft leadership.
+func NewPacketToTryToLeader(partitionID uint64) (p *repl.Packet) {
+	p = new(repl.Packet)
+	p.Opcode = proto.OpTryToLeader
+	p.PartitionID = partitionID
+	p.Magic = proto.ProtoMagic
+	p.ReqID = proto.GenerateRequestID()
+	return
+}
+
+// Handle OpTryToLeader packet. A draining node refuses to be the leader.
+func (s *DataNode) handlePacketToTryToLeader(p *repl.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionTryToLeader, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+	if s.drainer.isDraining() {
+		err = ErrDraining
+		return
+	}
+	partition := p.Object.(*DataPartition)
+	if partition.raftPartition == nil {
+		err = fmt.Errorf("%s partition=%v", RaftNotStarted, partition.partitionID)
+		return
+	}
+	err = partition.raftPartition.TryToLeader()
+}
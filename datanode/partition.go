@@ -32,7 +32,6 @@ import (
 	"github.com/chubaofs/chubaofs/repl"
 	"github.com/chubaofs/chubaofs/storage"
 	"github.com/chubaofs/chubaofs/util/errors"
-	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
 	raftProto "github.com/tiglabs/raft/proto"
 	"hash/crc32"
@@ -108,6 +107,8 @@ type DataPartition struct {
 	intervalToUpdateReplicas      int64 // interval to ask the master for updating the replica information
 	snapshot                      []*proto.File
 	snapshotMutex                 sync.RWMutex
+	syncedReplicas                []string
+	syncedReplicasMutex           sync.RWMutex
 	intervalToUpdatePartitionSize int64
 	loadExtentHeaderStatus        int
 
@@ -426,20 +427,22 @@ func (dp *DataPartition) ExtentStore() *storage.ExtentStore {
 	return dp.extentStore
 }
 
-func (dp *DataPartition) checkIsDiskError(err error) {
+// checkIsDiskError counts the disk error, and takes the partition out of service once the disk is broken.
+func (dp *DataPartition) checkIsDiskError(err error, flag uint8) {
 	if err == nil {
 		return
 	}
-	if IsDiskErr(err.Error()) {
-		mesg := fmt.Sprintf("disk path %v error on %v", dp.Path, LocalIP)
-		exporter.NewAlarm(mesg)
-		log.LogErrorf(mesg)
-		dp.stopRaft()
-		dp.disk.incReadErrCnt()
+	if !IsDiskErr(err.Error()) {
+		return
+	}
+	log.LogErrorf("disk path %v error on %v, err(%v)", dp.Path(), LocalIP, err)
+	if flag == WriteFlag {
 		dp.disk.incWriteErrCnt()
-		dp.disk.Status = proto.Unavailable
+	} else {
+		dp.disk.incReadErrCnt()
+	}
+	if dp.disk.checkErrors() {
 		dp.statusUpdate()
-		dp.disk.ForceExitRaftStore()
 	}
 }
 
@@ -458,6 +461,7 @@ func (dp *DataPartition) LaunchRepair(extentType uint8) {
 		return
 	}
 	if !dp.isLeader {
+		dp.setSyncedReplicas(nil)
 		return
 	}
 	if dp.extentStore.BrokenTinyExtentCnt() == 0 {
@@ -618,7 +622,7 @@ func (dp *DataPartition) doStreamFixTinyDeleteRecord(repairTask *DataPartitionRe
 	var (
 		localTinyDeleteFileSize int64
 		err                     error
-		conn                    *net.TCPConn
+		conn                    net.Conn
 	)
 	if !isFullSync {
 		localTinyDeleteFileSize = dp.extentStore.LoadTinyDeleteFileOffset()
@@ -636,6 +640,7 @@ func (dp *DataPartition) doStreamFixTinyDeleteRecord(repairTask *DataPartitionRe
 	}()
 
 	p := repl.NewPacketToTinyDeleteRecord(dp.partitionID, localTinyDeleteFileSize)
+	dp.setServiceToken(p)
 	if conn, err = gConnPool.GetConnect(repairTask.LeaderAddr); err != nil {
 		return
 	}
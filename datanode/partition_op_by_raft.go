@@ -204,7 +204,7 @@ func (dp *DataPartition) ApplyRandomWrite(msg *RaftCmdItem, raftApplyID uint64)
 	opItem := &rndWrtOpItem{}
 	defer func() {
 		if err != nil {
-			atomic.StoreUint64(&dp.disk.WriteErrCnt, uint64(dp.disk.MaxErrCnt))
+			atomic.StoreUint64(&dp.disk.WriteErrCnt, dp.disk.MaxWriteErrCnt)
 			err = storage.BrokenExtentError
 		}
 	}()
@@ -217,7 +217,7 @@ func (dp *DataPartition) ApplyRandomWrite(msg *RaftCmdItem, raftApplyID uint64)
 		raftApplyID, dp.partitionID, opItem.extentID, opItem.offset, opItem.size)
 	for i := 0; i < maxRetryCounts; i++ {
 		err = dp.ExtentStore().Write(opItem.extentID, opItem.offset, opItem.size, opItem.data, opItem.crc, NotUpdateSize, msg.Op == opRandomSyncWrite)
-		dp.checkIsDiskError(err)
+		dp.checkIsDiskError(err, WriteFlag)
 		if err != nil {
 			if dp.checkWriteErrs(err.Error()) {
 				log.LogErrorf("[ApplyRandomWrite] ApplyID(%v) Partition(%v)_Extent(%v)_ExtentOffset(%v)_Size(%v) ignore error[%v]",
@@ -226,6 +226,7 @@ func (dp *DataPartition) ApplyRandomWrite(msg *RaftCmdItem, raftApplyID uint64)
 			}
 		}
 		dp.addDiskErrs(err, WriteFlag)
+		dp.disk.space.BlockCache().InvalidateExtent(dp.partitionID, opItem.extentID)
 		if err == nil {
 			break
 		}
@@ -28,6 +28,7 @@ import (
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
 	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
@@ -395,6 +396,7 @@ func (s *DataNode) startRaftServer(cfg *config.Config) (err error) {
 		HeartbeatPort:     heartbeatPort,
 		ReplicaPort:       replicatePort,
 		NumOfLogsToRetain: DefaultRaftLogsToRetain,
+		TLSConfig:         util.TLSConfig(),
 	}
 	s.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -469,7 +471,7 @@ func (dp *DataPartition) findMaxAppliedID(allAppliedIDs []uint64) (maxAppliedID
 // Get the partition size from the leader.
 func (dp *DataPartition) getPartitionSize() (size uint64, err error) {
 	var (
-		conn *net.TCPConn
+		conn net.Conn
 	)
 
 	p := NewPacketToGetPartitionSize(dp.partitionID)
@@ -501,6 +503,7 @@ func (dp *DataPartition) getPartitionSize() (size uint64, err error) {
 func (dp *DataPartition) broadcastMinAppliedID(minAppliedID uint64) (err error) {
 	for i := 0; i < len(dp.replicas); i++ {
 		p := NewPacketToBroadcastMinAppliedID(dp.partitionID, minAppliedID)
+		dp.setServiceToken(p)
 		replicaHostParts := strings.Split(dp.replicas[i], ":")
 		replicaHost := strings.TrimSpace(replicaHostParts[0])
 		if LocalIP == replicaHost {
@@ -510,7 +513,7 @@ func (dp *DataPartition) broadcastMinAppliedID(minAppliedID uint64) (err error)
 			continue
 		}
 		target := dp.replicas[i]
-		var conn *net.TCPConn
+		var conn net.Conn
 		conn, err = gConnPool.GetConnect(target)
 		if err != nil {
 			return
@@ -593,7 +596,7 @@ func (dp *DataPartition) getAllReplicaAppliedID() (allAppliedID []uint64, replyN
 
 // Get target members' applied id
 func (dp *DataPartition) getRemoteAppliedID(target string, p *repl.Packet) (appliedID uint64, err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = gConnPool.GetConnect(target)
 	if err != nil {
 		return
@@ -0,0 +1,120 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/ratelimit"
+)
+
+const (
+	RepairYieldInterval = 10 * time.Millisecond
+	RepairMaxYieldCnt   = 10 // the repair proceeds after yielding to the client IO for at most 100ms
+)
+
+// RepairLimit defines the limits of the repair traffic, in bytes per second and operations per second.
+// Zero means unlimited.
+type RepairLimit struct {
+	NodeBandwidth int64 `json:"nodeBandwidth"`
+	NodeIOPS      int64 `json:"nodeIOPS"`
+	DiskBandwidth int64 `json:"diskBandwidth"`
+	DiskIOPS      int64 `json:"diskIOPS"`
+}
+
+type diskRepairLimiter struct {
+	bandwidth *ratelimit.Limiter
+	iops      *ratelimit.Limiter
+}
+
+// RepairLimiter throttles the repair traffic, including the one caused by decommission,
+// of the whole data node and of every disk.
+type RepairLimiter struct {
+	sync.RWMutex
+	limit         RepairLimit
+	nodeBandwidth *ratelimit.Limiter
+	nodeIOPS      *ratelimit.Limiter
+	disks         map[string]*diskRepairLimiter
+}
+
+// NewRepairLimiter creates a new repair limiter.
+func NewRepairLimiter(limit RepairLimit) (l *RepairLimiter) {
+	l = &RepairLimiter{
+		limit:         limit,
+		nodeBandwidth: ratelimit.NewLimiter(limit.NodeBandwidth, 0),
+		nodeIOPS:      ratelimit.NewLimiter(limit.NodeIOPS, 0),
+		disks:         make(map[string]*diskRepairLimiter),
+	}
+	return
+}
+
+// Limit returns the current limits.
+func (l *RepairLimiter) Limit() RepairLimit {
+	l.RLock()
+	defer l.RUnlock()
+	return l.limit
+}
+
+// SetLimit changes the limits at runtime.
+func (l *RepairLimiter) SetLimit(limit RepairLimit) {
+	l.Lock()
+	defer l.Unlock()
+	l.limit = limit
+	l.nodeBandwidth.SetRate(limit.NodeBandwidth, 0)
+	l.nodeIOPS.SetRate(limit.NodeIOPS, 0)
+	for _, dl := range l.disks {
+		dl.bandwidth.SetRate(limit.DiskBandwidth, 0)
+		dl.iops.SetRate(limit.DiskIOPS, 0)
+	}
+}
+
+func (l *RepairLimiter) diskLimiter(path string) (dl *diskRepairLimiter) {
+	l.RLock()
+	dl = l.disks[path]
+	l.RUnlock()
+	if dl != nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if dl = l.disks[path]; dl == nil {
+		dl = &diskRepairLimiter{
+			bandwidth: ratelimit.NewLimiter(l.limit.DiskBandwidth, 0),
+			iops:      ratelimit.NewLimiter(l.limit.DiskIOPS, 0),
+		}
+		l.disks[path] = dl
+	}
+	return
+}
+
+// Wait blocks the repair of size bytes on the given disk until it is allowed by the limits.
+// The repair also yields to the client IO in flight on the same disk.
+func (l *RepairLimiter) Wait(d *Disk, size int64) {
+	for i := 0; i < RepairMaxYieldCnt && atomic.LoadInt64(&d.clientIOs) > 0; i++ {
+		time.Sleep(RepairYieldInterval)
+	}
+	dl := l.diskLimiter(d.Path)
+	wait := l.nodeBandwidth.Reserve(size)
+	for _, w := range []time.Duration{l.nodeIOPS.Reserve(1), dl.bandwidth.Reserve(size), dl.iops.Reserve(1)} {
+		if w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -35,7 +36,6 @@ import (
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
-	"os"
 	"syscall"
 )
 
@@ -52,8 +52,9 @@ var (
 const (
 	DefaultRackName         = "cfs_rack1"
 	DefaultRaftDir          = "raft"
-	DefaultRaftLogsToRetain = 2000 // Count of raft logs per data partition
-	DefaultDiskMaxErr       = 1
+	AttachedDisksFileName   = "attached_disks.json"
+	DefaultRaftLogsToRetain = 2000         // Count of raft logs per data partition
+	DefaultDiskMaxErr       = 1            // default threshold of both the read and write errors of a disk
 	DefaultDiskRetain       = 20 * util.GB // GB
 )
 
@@ -62,14 +63,25 @@ const (
 )
 
 const (
-	ConfigKeyLocalIP       = "localIP"       // string
-	ConfigKeyPort          = "port"          // int
-	ConfigKeyMasterAddr    = "masterAddr"    // array
-	ConfigKeyRack          = "rack"          // string
-	ConfigKeyDisks         = "disks"         // array
-	ConfigKeyRaftDir       = "raftDir"       // string
-	ConfigKeyRaftHeartbeat = "raftHeartbeat" // string
-	ConfigKeyRaftReplica   = "raftReplica"   // string
+	ConfigKeyLocalIP             = "localIP"             // string
+	ConfigKeyPort                = "port"                // int
+	ConfigKeyMasterAddr          = "masterAddr"          // array
+	ConfigKeyRack                = "rack"                // string
+	ConfigKeyZone                = "zone"                // string
+	ConfigKeyDisks               = "disks"               // array
+	ConfigKeyRaftDir             = "raftDir"             // string
+	ConfigKeyRaftHeartbeat       = "raftHeartbeat"       // string
+	ConfigKeyRaftReplica         = "raftReplica"         // string
+	ConfigKeyDiskMaxReadErrCnt   = "diskMaxReadErrCnt"   // int
+	ConfigKeyDiskMaxWriteErrCnt  = "diskMaxWriteErrCnt"  // int
+	ConfigKeyBlockCacheSize      = "blockCacheSize"      // int, MB
+	ConfigKeyBlockCacheSSDDir    = "blockCacheSSDDir"    // string
+	ConfigKeyBlockCacheSSDSize   = "blockCacheSSDSize"   // int, MB
+	ConfigKeyRepairNodeBandwidth = "repairNodeBandwidth" // int, MB/s
+	ConfigKeyRepairNodeIOPS      = "repairNodeIOPS"      // int
+	ConfigKeyRepairDiskBandwidth = "repairDiskBandwidth" // int, MB/s
+	ConfigKeyRepairDiskIOPS      = "repairDiskIOPS"      // int
+	ConfigKeyZeroCopyRead        = "zeroCopyRead"        // bool
 )
 
 // DataNode defines the structure of a data node.
@@ -77,6 +89,7 @@ type DataNode struct {
 	space           *SpaceManager
 	port            string
 	rackName        string
+	zoneName        string
 	clusterID       string
 	localIP         string
 	localServerAddr string
@@ -86,6 +99,11 @@ type DataNode struct {
 	raftReplica     string
 	raftStore       raftstore.RaftStore
 	tcpListener     net.Listener
+	volQoS          *VolQoSLimiter
+	volSpace        *VolSpaceLimiter
+	tokens          *proto.TokenVerifier
+	zeroCopyRead    bool
+	drainer         *drainer
 	stopC           chan bool
 	state           uint32
 	wg              sync.WaitGroup
@@ -132,6 +150,10 @@ func (s *DataNode) Sync() {
 // Workflow of starting up a data node.
 func (s *DataNode) onStart(cfg *config.Config) (err error) {
 	s.stopC = make(chan bool, 0)
+	s.volQoS = NewVolQoSLimiter()
+	s.volSpace = NewVolSpaceLimiter()
+	s.tokens = proto.NewTokenVerifier()
+	s.drainer = newDrainer()
 
 	// parse the config file
 	if err = s.parseConfig(cfg); err != nil {
@@ -139,6 +161,7 @@ func (s *DataNode) onStart(cfg *config.Config) (err error) {
 	}
 
 	s.register()
+	s.initTokenVerifier()
 
 	exporter.Init(s.clusterID, ModuleName, cfg)
 
@@ -192,9 +215,12 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 	if s.rackName == "" {
 		s.rackName = DefaultRackName
 	}
+	s.zoneName = cfg.GetString(ConfigKeyZone)
+	s.zeroCopyRead = cfg.GetBool(ConfigKeyZeroCopyRead)
 	log.LogDebugf("action[parseConfig] load masterAddrs[%v].", MasterHelper.Nodes())
 	log.LogDebugf("action[parseConfig] load port[%v].", s.port)
 	log.LogDebugf("action[parseConfig] load rackName[%v].", s.rackName)
+	log.LogDebugf("action[parseConfig] load zoneName[%v].", s.zoneName)
 	return
 }
 
@@ -208,22 +234,42 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 	s.space.SetRaftStore(s.raftStore)
 	s.space.SetNodeID(s.nodeID)
 	s.space.SetClusterID(s.clusterID)
+	s.space.SetTokenVerifier(s.tokens)
+	s.space.SetDiskMaxErrCnt(uint64(cfg.GetInt64(ConfigKeyDiskMaxReadErrCnt)), uint64(cfg.GetInt64(ConfigKeyDiskMaxWriteErrCnt)))
+	s.space.RepairLimiter().SetLimit(RepairLimit{
+		NodeBandwidth: cfg.GetInt64(ConfigKeyRepairNodeBandwidth) * util.MB,
+		NodeIOPS:      cfg.GetInt64(ConfigKeyRepairNodeIOPS),
+		DiskBandwidth: cfg.GetInt64(ConfigKeyRepairDiskBandwidth) * util.MB,
+		DiskIOPS:      cfg.GetInt64(ConfigKeyRepairDiskIOPS),
+	})
+	if cacheSize := cfg.GetInt64(ConfigKeyBlockCacheSize); cacheSize > 0 {
+		var cache *BlockCache
+		ssdDir := cfg.GetString(ConfigKeyBlockCacheSSDDir)
+		ssdSize := cfg.GetInt64(ConfigKeyBlockCacheSSDSize)
+		if cache, err = NewBlockCache(cacheSize*util.MB, ssdDir, ssdSize*util.MB); err != nil {
+			return
+		}
+		s.space.SetBlockCache(cache)
+		log.LogInfof("action[startSpaceManager] block cache memory(%vMB) ssd(%v:%vMB).", cacheSize, ssdDir, ssdSize)
+	}
 
 	var wg sync.WaitGroup
 	for _, d := range cfg.GetArray(ConfigKeyDisks) {
 		log.LogDebugf("action[startSpaceManager] load disk raw config(%v).", d)
 
-		// format "PATH:RESET_SIZE:MAX_ERR
+		// format "PATH:RESET_SIZE[:MEDIA_TYPE]", the disk is taken as hdd if the media type is not given
 		arr := strings.Split(d.(string), ":")
-		if len(arr) != 2 {
+		if len(arr) != 2 && len(arr) != 3 {
 			return ErrBadConfFile
 		}
-		path := arr[0]
-		fileInfo, err := os.Stat(path)
-		if err != nil {
-			return ErrBadConfFile
+		mediaType := proto.MediaHDD
+		if len(arr) == 3 {
+			if mediaType, err = proto.ParseMediaType(arr[2]); err != nil || mediaType == proto.MediaUnspecified {
+				return ErrBadConfFile
+			}
 		}
-		if !fileInfo.IsDir() {
+		path := arr[0]
+		if err = checkDiskPath(path); err != nil {
 			return ErrBadConfFile
 		}
 		restSize, err := strconv.ParseUint(arr[1], 10, 64)
@@ -235,10 +281,32 @@ func (s *DataNode) startSpaceManager(cfg *config.Config) (err error) {
 			restSize = DefaultDiskRetain
 		}
 		wg.Add(1)
-		go func(wg *sync.WaitGroup, path string, restSize uint64) {
+		go func(wg *sync.WaitGroup, path string, restSize uint64, mediaType uint8) {
+			defer wg.Done()
+			s.space.LoadDisk(path, restSize, mediaType)
+		}(&wg, path, restSize, mediaType)
+	}
+
+	// the disks attached at runtime are loaded as well, unless they are missing
+	attachedDisksFile := path.Join(s.raftDir, AttachedDisksFileName)
+	attachedDisks, err := LoadAttachedDisks(attachedDisksFile)
+	if err != nil {
+		wg.Wait()
+		return
+	}
+	s.space.SetAttachedDisksFile(attachedDisksFile)
+	for _, d := range attachedDisks {
+		if err = checkDiskPath(d.Path); err != nil {
+			log.LogErrorf("action[startSpaceManager] attached disk(%v) err(%v).", d.Path, err)
+			continue
+		}
+		wg.Add(1)
+		go func(d *AttachedDisk) {
 			defer wg.Done()
-			s.space.LoadDisk(path, restSize, DefaultDiskMaxErr)
-		}(&wg, path, restSize)
+			if err := s.space.LoadDisk(d.Path, d.ReservedSpace, d.MediaType); err != nil {
+				log.LogWarnf("action[startSpaceManager] attached disk(%v) err(%v).", d.Path, err)
+			}
+		}(d)
 	}
 	wg.Wait()
 	return nil
@@ -283,6 +351,8 @@ func (s *DataNode) register() {
 			// register this data node on the master
 			params := make(map[string]string)
 			params["addr"] = fmt.Sprintf("%s:%v", LocalIP, s.port)
+			params["zone"] = s.zoneName
+			params["rack"] = s.rackName
 			data, err = MasterHelper.Request(http.MethodPost, proto.AddDataNode, params, nil)
 			if err != nil {
 				log.LogErrorf("action[registerToMaster] cannot register this node to master[%] err(%v).",
@@ -309,12 +379,20 @@ func (s *DataNode) registerHandler() {
 	http.HandleFunc("/block", s.getBlockCrcAPI)
 	http.HandleFunc("/stats", s.getStatAPI)
 	http.HandleFunc("/raftStatus", s.getRaftStatus)
+	http.HandleFunc("/disk/attach", s.attachDiskAPI)
+	http.HandleFunc("/disk/detach", s.detachDiskAPI)
+	http.HandleFunc("/blockCache", s.getBlockCacheAPI)
+	http.HandleFunc("/repairLimit", s.getRepairLimitAPI)
+	http.HandleFunc("/repairLimit/set", s.setRepairLimitAPI)
+	http.HandleFunc("/drain", s.drainAPI)
+	http.HandleFunc("/drain/status", s.getDrainStatusAPI)
+	http.HandleFunc("/drain/cancel", s.cancelDrainAPI)
 }
 
 func (s *DataNode) startTCPService() (err error) {
 	log.LogInfo("Start: startTCPService")
 	addr := fmt.Sprintf(":%v", s.port)
-	l, err := net.Listen(NetworkProtocol, addr)
+	l, err := util.Listen(addr)
 	log.LogDebugf("action[startTCPService] listen %v address(%v).", NetworkProtocol, addr)
 	if err != nil {
 		log.LogError("failed to listen, err:", err)
@@ -347,36 +425,11 @@ func (s *DataNode) stopTCPService() (err error) {
 func (s *DataNode) serveConn(conn net.Conn) {
 	space := s.space
 	space.Stats().AddConnection()
-	c, _ := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
-	packetProcessor := repl.NewReplProtocol(c, s.Prepare, s.OperatePacket, s.Post)
+	util.SetTCPOptions(conn)
+	packetProcessor := repl.NewReplProtocol(conn, s.Prepare, s.OperatePacket, s.Post)
 	packetProcessor.ServerConn()
 }
 
-// Increase the disk error count by one.
-func (s *DataNode) incDiskErrCnt(partitionID uint64, err error, flag uint8) {
-	if err == nil {
-		return
-	}
-	dp := s.space.Partition(partitionID)
-	if dp == nil {
-		return
-	}
-	d := dp.Disk()
-	if d == nil {
-		return
-	}
-	if !IsDiskErr(err.Error()) {
-		return
-	}
-	if flag == WriteFlag {
-		d.incWriteErrCnt()
-	} else if flag == ReadFlag {
-		d.incReadErrCnt()
-	}
-}
-
 func IsDiskErr(errMsg string) bool {
 	if strings.Contains(errMsg, syscall.EIO.Error()) {
 		return true
@@ -19,9 +19,12 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/storage"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
 )
 
 func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
@@ -37,6 +40,8 @@ func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
 			Status      int    `json:"status"`
 			RestSize    uint64 `json:"restSize"`
 			Partitions  int    `json:"partitions"`
+			ReadErrs    uint64 `json:"readErrs"`
+			WriteErrs   uint64 `json:"writeErrs"`
 		}{
 			Path:        diskItem.Path,
 			Total:       diskItem.Total,
@@ -47,6 +52,8 @@ func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
 			Status:      diskItem.Status,
 			RestSize:    diskItem.ReservedSpace,
 			Partitions:  diskItem.PartitionCount(),
+			ReadErrs:    atomic.LoadUint64(&diskItem.ReadErrCnt),
+			WriteErrs:   atomic.LoadUint64(&diskItem.WriteErrCnt),
 		}
 		disks = append(disks, disk)
 	}
@@ -60,6 +67,68 @@ func (s *DataNode) getDiskAPI(w http.ResponseWriter, r *http.Request) {
 	s.buildSuccessResp(w, diskReport)
 }
 
+func (s *DataNode) attachDiskAPI(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPath          = "path"
+		paramReservedSpace = "reservedSpace"
+		paramMediaType     = "mediaType"
+	)
+	var (
+		reservedSpace uint64
+		mediaType     = proto.MediaHDD
+		err           error
+	)
+	if err = r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := r.FormValue(paramPath)
+	if path == "" {
+		s.buildFailureResp(w, http.StatusBadRequest, fmt.Sprintf("param %v is required", paramPath))
+		return
+	}
+	if value := r.FormValue(paramReservedSpace); value != "" {
+		if reservedSpace, err = strconv.ParseUint(value, 10, 64); err != nil {
+			err = fmt.Errorf("parse param %v fail: %v", paramReservedSpace, err)
+			s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if value := r.FormValue(paramMediaType); value != "" {
+		if mediaType, err = proto.ParseMediaType(value); err != nil {
+			s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err = s.space.AttachDisk(path, reservedSpace, mediaType); err != nil {
+		s.buildFailureResp(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.buildSuccessResp(w, fmt.Sprintf("disk(%v) attached", path))
+}
+
+func (s *DataNode) detachDiskAPI(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramPath = "path"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := r.FormValue(paramPath)
+	if path == "" {
+		s.buildFailureResp(w, http.StatusBadRequest, fmt.Sprintf("param %v is required", paramPath))
+		return
+	}
+	if err := s.space.DetachDisk(path); err != nil {
+		s.buildFailureResp(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.buildSuccessResp(w, fmt.Sprintf("disk(%v) detached", path))
+}
+
 func (s *DataNode) getStatAPI(w http.ResponseWriter, r *http.Request) {
 	response := &proto.DataNodeHeartbeatResponse{}
 	s.buildHeartBeatResponse(response)
@@ -67,6 +136,110 @@ func (s *DataNode) getStatAPI(w http.ResponseWriter, r *http.Request) {
 	s.buildSuccessResp(w, response)
 }
 
+func (s *DataNode) getBlockCacheAPI(w http.ResponseWriter, r *http.Request) {
+	memBlocks, memUsed, ssdBlocks, ssdUsed := s.space.BlockCache().Stats()
+	result := &struct {
+		Enabled   bool  `json:"enabled"`
+		MemBlocks int   `json:"memBlocks"`
+		MemUsed   int64 `json:"memUsed"`
+		SSDBlocks int   `json:"ssdBlocks"`
+		SSDUsed   int64 `json:"ssdUsed"`
+	}{
+		Enabled:   s.space.BlockCache() != nil,
+		MemBlocks: memBlocks,
+		MemUsed:   memUsed,
+		SSDBlocks: ssdBlocks,
+		SSDUsed:   ssdUsed,
+	}
+	s.buildSuccessResp(w, result)
+}
+
+func (s *DataNode) getRepairLimitAPI(w http.ResponseWriter, r *http.Request) {
+	s.buildSuccessResp(w, s.space.RepairLimiter().Limit())
+}
+
+// The bandwidth is given in MB/s. The limits not given are unchanged.
+func (s *DataNode) setRepairLimitAPI(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramNodeBandwidth = "nodeBandwidth"
+		paramNodeIOPS      = "nodeIOPS"
+		paramDiskBandwidth = "diskBandwidth"
+		paramDiskIOPS      = "diskIOPS"
+	)
+	if err := r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := s.space.RepairLimiter().Limit()
+	for _, param := range []struct {
+		name  string
+		value *int64
+		unit  int64
+	}{
+		{paramNodeBandwidth, &limit.NodeBandwidth, util.MB},
+		{paramNodeIOPS, &limit.NodeIOPS, 1},
+		{paramDiskBandwidth, &limit.DiskBandwidth, util.MB},
+		{paramDiskIOPS, &limit.DiskIOPS, 1},
+	} {
+		value := r.FormValue(param.name)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v < 0 {
+			s.buildFailureResp(w, http.StatusBadRequest, fmt.Sprintf("parse param %v fail: %v", param.name, value))
+			return
+		}
+		*param.value = v * param.unit
+	}
+	s.space.RepairLimiter().SetLimit(limit)
+	log.LogInfof("action[setRepairLimitAPI] repair limit changed to %v", limit)
+	s.buildSuccessResp(w, limit)
+}
+
+// drainAPI starts to drain the data node. The node is shut down once drained if shutdown is true,
+// and is considered drained anyway after the timeout, given in seconds.
+func (s *DataNode) drainAPI(w http.ResponseWriter, r *http.Request) {
+	const (
+		paramShutdown = "shutdown"
+		paramTimeout  = "timeout"
+	)
+	var (
+		shutdown bool
+		timeout  int64
+		err      error
+	)
+	if err = r.ParseForm(); err != nil {
+		err = fmt.Errorf("parse form fail: %v", err)
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if value := r.FormValue(paramShutdown); value != "" {
+		if shutdown, err = strconv.ParseBool(value); err != nil {
+			s.buildFailureResp(w, http.StatusBadRequest, fmt.Sprintf("parse param %v fail: %v", paramShutdown, value))
+			return
+		}
+	}
+	if value := r.FormValue(paramTimeout); value != "" {
+		if timeout, err = strconv.ParseInt(value, 10, 64); err != nil || timeout <= 0 {
+			s.buildFailureResp(w, http.StatusBadRequest, fmt.Sprintf("parse param %v fail: %v", paramTimeout, value))
+			return
+		}
+	}
+	s.startDrain(false, shutdown, timeout)
+	s.buildSuccessResp(w, s.drainer.getStatus())
+}
+
+func (s *DataNode) getDrainStatusAPI(w http.ResponseWriter, r *http.Request) {
+	s.buildSuccessResp(w, s.drainer.getStatus())
+}
+
+func (s *DataNode) cancelDrainAPI(w http.ResponseWriter, r *http.Request) {
+	s.stopDrain(false)
+	s.buildSuccessResp(w, s.drainer.getStatus())
+}
+
 func (s *DataNode) getRaftStatus(w http.ResponseWriter, r *http.Request) {
 	const (
 		paramRaftID = "raftID"
@@ -15,12 +15,14 @@
 package datanode
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"sync"
 	"time"
 
-	"os"
-
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
 	"github.com/chubaofs/chubaofs/util"
@@ -35,12 +37,27 @@ type SpaceManager struct {
 	raftStore            raftstore.RaftStore
 	nodeID               uint64
 	diskMutex            sync.RWMutex
+	loadingDisks         map[string]bool // the disks being loaded, which are not in disks yet
 	partitionMutex       sync.RWMutex
 	stats                *Stats
 	stopC                chan bool
 	selectedIndex        int // TODO what is selected index
 	diskList             []string
 	createPartitionMutex sync.RWMutex
+	diskMaxReadErrCnt    uint64
+	diskMaxWriteErrCnt   uint64
+	blockCache           *BlockCache
+	repairLimiter        *RepairLimiter
+	tokens               *proto.TokenVerifier
+	attachedDisksFile    string
+	attachMutex          sync.Mutex // serializes the attaching and the detaching of the disks with their persistence
+}
+
+// AttachedDisk is a disk attached at runtime, which is loaded again at the next start.
+type AttachedDisk struct {
+	Path          string
+	ReservedSpace uint64
+	MediaType     uint8
 }
 
 // NewSpaceManager creates a new space manager.
@@ -48,10 +65,14 @@ func NewSpaceManager(rack string) *SpaceManager {
 	var space *SpaceManager
 	space = &SpaceManager{}
 	space.disks = make(map[string]*Disk)
+	space.loadingDisks = make(map[string]bool)
 	space.diskList = make([]string, 0)
 	space.partitions = make(map[uint64]*DataPartition)
 	space.stats = NewStats(rack)
 	space.stopC = make(chan bool, 0)
+	space.diskMaxReadErrCnt = DefaultDiskMaxErr
+	space.diskMaxWriteErrCnt = DefaultDiskMaxErr
+	space.repairLimiter = NewRepairLimiter(RepairLimit{})
 
 	go space.statUpdateScheduler()
 
@@ -81,6 +102,10 @@ func (manager *SpaceManager) GetClusterID() (clusterID string) {
 	return manager.clusterID
 }
 
+func (manager *SpaceManager) SetTokenVerifier(tokens *proto.TokenVerifier) {
+	manager.tokens = tokens
+}
+
 func (manager *SpaceManager) SetRaftStore(raftStore raftstore.RaftStore) {
 	manager.raftStore = raftStore
 }
@@ -88,6 +113,36 @@ func (manager *SpaceManager) GetRaftStore() (raftStore raftstore.RaftStore) {
 	return manager.raftStore
 }
 
+// SetDiskMaxErrCnt sets the error thresholds above which a disk is considered as broken. Zero means the default.
+func (manager *SpaceManager) SetDiskMaxErrCnt(maxReadErrCnt, maxWriteErrCnt uint64) {
+	if maxReadErrCnt > 0 {
+		manager.diskMaxReadErrCnt = maxReadErrCnt
+	}
+	if maxWriteErrCnt > 0 {
+		manager.diskMaxWriteErrCnt = maxWriteErrCnt
+	}
+}
+
+// SetAttachedDisksFile sets the file persisting the disks attached at runtime. Empty disables the persistence.
+func (manager *SpaceManager) SetAttachedDisksFile(file string) {
+	manager.attachedDisksFile = file
+}
+
+// SetBlockCache sets the cache of the blocks read from the extents. Nil disables the cache.
+func (manager *SpaceManager) SetBlockCache(cache *BlockCache) {
+	manager.blockCache = cache
+}
+
+// BlockCache returns the block cache, which may be nil.
+func (manager *SpaceManager) BlockCache() *BlockCache {
+	return manager.blockCache
+}
+
+// RepairLimiter returns the limiter of the repair traffic.
+func (manager *SpaceManager) RepairLimiter() *RepairLimiter {
+	return manager.repairLimiter
+}
+
 func (manager *SpaceManager) RangePartitions(f func(partition *DataPartition) bool) {
 	if f == nil {
 		return
@@ -120,7 +175,7 @@ func (manager *SpaceManager) Stats() *Stats {
 	return manager.stats
 }
 
-func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, maxErrCnt int) (err error) {
+func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, mediaType uint8) (err error) {
 	var (
 		disk    *Disk
 		visitor PartitionVisitor
@@ -134,13 +189,18 @@ func (manager *SpaceManager) LoadDisk(path string, reservedSpace uint64, maxErrC
 			log.LogDebugf("action[LoadDisk] put partition(%v) to manager manager.", dp.partitionID)
 		}
 	}
-	if _, err = manager.GetDisk(path); err != nil {
-
-		disk = NewDisk(path, reservedSpace, maxErrCnt, manager)
-		disk.RestorePartition(visitor)
-		manager.putDisk(disk)
-		err = nil
+	// the disk is reserved before its partitions are restored, so that it is never loaded twice
+	manager.diskMutex.Lock()
+	if _, has := manager.disks[path]; has || manager.loadingDisks[path] {
+		manager.diskMutex.Unlock()
+		return fmt.Errorf("disk(%v) already exists", path)
 	}
+	manager.loadingDisks[path] = true
+	manager.diskMutex.Unlock()
+
+	disk = NewDisk(path, reservedSpace, mediaType, manager.diskMaxReadErrCnt, manager.diskMaxWriteErrCnt, manager)
+	disk.RestorePartition(visitor)
+	manager.putDisk(disk)
 	return
 }
 
@@ -158,11 +218,152 @@ func (manager *SpaceManager) GetDisk(path string) (d *Disk, err error) {
 
 func (manager *SpaceManager) putDisk(d *Disk) {
 	manager.diskMutex.Lock()
+	delete(manager.loadingDisks, d.Path)
 	manager.disks[d.Path] = d
 	manager.diskList = append(manager.diskList, d.Path)
 	manager.diskMutex.Unlock()
 }
 
+// AttachDisk loads a new disk at runtime. The partitions already stored on the disk are restored as well.
+// The disk is persisted in the attached disks file, so that it is loaded again at the next start.
+func (manager *SpaceManager) AttachDisk(path string, reservedSpace uint64, mediaType uint8) (err error) {
+	manager.attachMutex.Lock()
+	defer manager.attachMutex.Unlock()
+	if err = checkDiskPath(path); err != nil {
+		return
+	}
+	if reservedSpace < DefaultDiskRetain {
+		reservedSpace = DefaultDiskRetain
+	}
+	if err = manager.LoadDisk(path, reservedSpace, mediaType); err != nil {
+		return
+	}
+	manager.updateMetrics()
+	if err = manager.updateAttachedDisks(func(disks []*AttachedDisk) []*AttachedDisk {
+		return append(removeAttachedDisk(disks, path), &AttachedDisk{Path: path, ReservedSpace: reservedSpace, MediaType: mediaType})
+	}); err != nil {
+		return fmt.Errorf("disk(%v) is attached, but not persisted to be loaded at the next start: %v", path, err)
+	}
+	log.LogInfof("action[AttachDisk] disk(%v) reservedSpace(%v) mediaType(%v) attached.", path, reservedSpace,
+		proto.MediaTypeString(mediaType))
+	return
+}
+
+// DetachDisk removes a disk at runtime. The partitions on the disk must be migrated away beforehand.
+// A disk in the config file is loaded again at the next start, unless it is removed from the config.
+func (manager *SpaceManager) DetachDisk(path string) (err error) {
+	manager.attachMutex.Lock()
+	defer manager.attachMutex.Unlock()
+	// hold the partition lock so that no partition can be created on the disk meanwhile.
+	manager.partitionMutex.Lock()
+	defer manager.partitionMutex.Unlock()
+	manager.diskMutex.Lock()
+	d, ok := manager.disks[path]
+	if !ok {
+		manager.diskMutex.Unlock()
+		return fmt.Errorf("disk(%v) not exsit", path)
+	}
+	if cnt := d.PartitionCount(); cnt > 0 {
+		manager.diskMutex.Unlock()
+		return fmt.Errorf("disk(%v) still has %v partitions", path, cnt)
+	}
+	delete(manager.disks, path)
+	for i, p := range manager.diskList {
+		if p == path {
+			manager.diskList = append(manager.diskList[:i], manager.diskList[i+1:]...)
+			break
+		}
+	}
+	manager.diskMutex.Unlock()
+
+	d.Stop()
+	manager.updateMetrics()
+	log.LogInfof("action[DetachDisk] disk(%v) detached.", path)
+	if err = manager.updateAttachedDisks(func(disks []*AttachedDisk) []*AttachedDisk {
+		return removeAttachedDisk(disks, path)
+	}); err != nil {
+		return fmt.Errorf("disk(%v) is detached, but is still persisted to be loaded at the next start: %v", path, err)
+	}
+	return
+}
+
+func removeAttachedDisk(disks []*AttachedDisk, path string) []*AttachedDisk {
+	kept := make([]*AttachedDisk, 0, len(disks))
+	for _, d := range disks {
+		if d.Path != path {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// LoadAttachedDisks returns the disks persisted in the file, and none if the file does not exist.
+func LoadAttachedDisks(file string) (disks []*AttachedDisk, err error) {
+	disks = make([]*AttachedDisk, 0)
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return disks, nil
+	}
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &disks); err != nil {
+		return nil, fmt.Errorf("attached disks file(%v) is corrupted: %v", file, err)
+	}
+	return
+}
+
+// updateAttachedDisks persists the attached disks changed by the function. The file is replaced by a renamed
+// temporary file, so that it is never left partially written.
+func (manager *SpaceManager) updateAttachedDisks(change func(disks []*AttachedDisk) []*AttachedDisk) (err error) {
+	if manager.attachedDisksFile == "" {
+		return
+	}
+	disks, err := LoadAttachedDisks(manager.attachedDisksFile)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(change(disks))
+	if err != nil {
+		return
+	}
+	tmpFile := manager.attachedDisksFile + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return
+	}
+	if err = os.Rename(tmpFile, manager.attachedDisksFile); err != nil {
+		return
+	}
+	// the rename is durable once the directory is synced
+	if dir, dirErr := os.Open(path.Dir(manager.attachedDisksFile)); dirErr == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return
+}
+
+func checkDiskPath(path string) (err error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("disk(%v) is not a directory", path)
+	}
+	return
+}
+
 func (manager *SpaceManager) updateMetrics() {
 	manager.diskMutex.RLock()
 	var (
@@ -193,6 +394,9 @@ func (manager *SpaceManager) minPartitionCnt() (d *Disk) {
 	manager.diskMutex.Lock()
 	defer manager.diskMutex.Unlock()
 	var path string
+	if len(manager.diskList) == 0 {
+		return
+	}
 	if manager.selectedIndex >= len(manager.diskList) {
 		manager.selectedIndex = 0
 	}
@@ -255,10 +459,17 @@ func (manager *SpaceManager) CreatePartition(request *proto.CreateDataPartitionR
 	)
 	for i := 0; i < len(manager.disks); i++ {
 		disk = manager.minPartitionCnt()
+		if disk == nil {
+			break
+		}
 		if disk.Available < 5*util.GB || disk.Status != proto.ReadWrite {
 			disk = nil
 			continue
 		}
+		if request.MediaType != proto.MediaUnspecified && disk.MediaType != request.MediaType {
+			disk = nil
+			continue
+		}
 		break
 	}
 	if disk == nil {
@@ -283,6 +494,7 @@ func (manager *SpaceManager) DeletePartition(dpID uint64) {
 	delete(manager.partitions, dpID)
 	manager.partitionMutex.Unlock()
 	dp.Stop()
+	manager.blockCache.InvalidatePartition(dpID)
 	dp.Disk().DetachDataPartition(dp)
 	os.RemoveAll(dp.Path())
 }
@@ -299,10 +511,23 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 	response.MaxCapacity = stat.MaxCapacityToCreatePartition
 	response.RemainingCapacity = stat.RemainingCapacityToCreatePartition
 	stat.Unlock()
+	response.Draining = s.drainer.isDraining()
 
 	response.RackName = s.rackName
-	response.PartitionReports = make([]*proto.PartitionReport, 0)
+	response.DiskReports = make([]*proto.DiskReport, 0)
 	space := s.space
+	for _, d := range space.GetDisks() {
+		response.DiskReports = append(response.DiskReports, &proto.DiskReport{
+			Path:           d.Path,
+			Total:          d.Total,
+			Used:           d.Used,
+			Available:      d.Available,
+			Status:         d.Status,
+			PartitionCount: d.PartitionCount(),
+			MediaType:      d.MediaType,
+		})
+	}
+	response.PartitionReports = make([]*proto.PartitionReport, 0)
 	space.RangePartitions(func(partition *DataPartition) bool {
 		leaderAddr, isLeader := partition.IsRaftLeader()
 		vr := &proto.PartitionReport{
@@ -315,6 +540,8 @@ func (s *DataNode) buildHeartBeatResponse(response *proto.DataNodeHeartbeatRespo
 			IsLeader:        isLeader,
 			ExtentCount:     partition.GetExtentCount(),
 			NeedCompare:     true,
+			ApplyID:         partition.GetAppliedID(),
+			SyncedReplicas:  partition.SyncedReplicas(),
 		}
 		log.LogDebugf("action[Heartbeats] dpid[%v], status[%v] total[%v] used[%v] leader[%v] b[%v].", vr.PartitionID, vr.PartitionStatus, vr.Total, vr.Used, leaderAddr, vr.IsLeader)
 		response.PartitionReports = append(response.PartitionReports, vr)
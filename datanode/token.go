@@ -0,0 +1,68 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Checks the token carried by a packet reading or changing the data of a volume, if the volume requires the tokens.
+// The clients carry their own tokens. The packets sent among the replicas, such as the repairs and the raft leadership
+// transfers, and the deletions sent by the meta nodes carry the service tokens pushed by the master.
+// Every such packet is refused until the verify info is loaded, since the volumes requiring the tokens are unknown.
+func (s *DataNode) checkVolToken(vol string, p *repl.Packet) (err error) {
+	var write bool
+	switch p.Opcode {
+	case proto.OpStreamRead, proto.OpRead, proto.OpExtentRepairRead, proto.OpReadTinyDelete:
+	case proto.OpWrite, proto.OpSyncWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpCreateExtent,
+		proto.OpMarkDelete, proto.OpNotifyReplicasToRepair, proto.OpBroadcastMinAppliedID, proto.OpTryToLeader:
+		write = true
+	default:
+		// the watermarks, the sizes and the applied IDs of the partitions carry no data
+		return
+	}
+	if !s.tokens.Loaded() {
+		return proto.ErrTokenInfoNotLoaded
+	}
+	return s.tokens.Verify(vol, p.GetToken(), write)
+}
+
+// setServiceToken carries the service token of the volume on a packet sent to the other replicas.
+func (dp *DataPartition) setServiceToken(p *repl.Packet) {
+	p.SetToken(dp.disk.space.tokens.ServiceToken(dp.volumeID))
+}
+
+// Fetches the token verify info at the startup, before serving any packet, since the master pushes it
+// through the heartbeats which may not come until a while.
+func (s *DataNode) initTokenVerifier() {
+	for {
+		data, err := MasterHelper.Request(http.MethodGet, proto.GetTokenVerifyInfo, nil, nil)
+		if err == nil {
+			info := new(proto.TokenVerifyInfo)
+			if err = json.Unmarshal(data, info); err == nil {
+				s.tokens.Update(info)
+				return
+			}
+		}
+		log.LogErrorf("action[initTokenVerifier] err(%v)", err)
+		time.Sleep(5 * time.Second)
+	}
+}
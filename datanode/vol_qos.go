@@ -0,0 +1,102 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
+)
+
+const (
+	// A read or random write is rejected with OpLimitedErr if it cannot be served within this time,
+	// and the client backs off and retries.
+	VolQoSMaxWait = 100 * time.Millisecond
+	// Appends are pipelined by the client, so they are rather delayed, which also slows down the client.
+	VolQoSMaxAppendWait = time.Second
+)
+
+// VolQoSLimiter enforces the qos limits of the volumes pushed by the master.
+// Every volume has its own token buckets, so the requests of a volume exceeding
+// its limits are queued or rejected without delaying the other volumes.
+// The limits apply to this data node only, not to the volume across the cluster,
+// so the total throughput of a volume grows with the number of its data nodes.
+type VolQoSLimiter struct {
+	readBandwidth  *ratelimit.Group
+	writeBandwidth *ratelimit.Group
+	readIOPS       *ratelimit.Group
+	writeIOPS      *ratelimit.Group
+}
+
+// NewVolQoSLimiter creates a new limiter without any limit.
+func NewVolQoSLimiter() *VolQoSLimiter {
+	return &VolQoSLimiter{
+		readBandwidth:  ratelimit.NewGroup(),
+		writeBandwidth: ratelimit.NewGroup(),
+		readIOPS:       ratelimit.NewGroup(),
+		writeIOPS:      ratelimit.NewGroup(),
+	}
+}
+
+// Update replaces the limits by the ones in the heartbeat.
+func (l *VolQoSLimiter) Update(volQoS map[string]*proto.VolQoS) {
+	readBandwidth := make(map[string]int64)
+	writeBandwidth := make(map[string]int64)
+	readIOPS := make(map[string]int64)
+	writeIOPS := make(map[string]int64)
+	for name, qos := range volQoS {
+		readBandwidth[name] = qos.ReadBandwidth
+		writeBandwidth[name] = qos.WriteBandwidth
+		readIOPS[name] = qos.ReadIOPS
+		writeIOPS[name] = qos.WriteIOPS
+	}
+	l.readBandwidth.Reset(readBandwidth)
+	l.writeBandwidth.Reset(writeBandwidth)
+	l.readIOPS.Reset(readIOPS)
+	l.writeIOPS.Reset(writeIOPS)
+}
+
+// Limit waits until the packet is allowed by the limits of its volume, or
+// returns ErrQoSLimited if it has to wait for too long.
+// An append is limited once on the leader, which is the only replica of a single-replica partition,
+// and not again on the followers it is forwarded to.
+func (l *VolQoSLimiter) Limit(dp *DataPartition, p *repl.Packet) (err error) {
+	var (
+		bandwidth, iops *ratelimit.Group
+		maxWait         = VolQoSMaxWait
+	)
+	switch p.Opcode {
+	case proto.OpStreamRead, proto.OpRead:
+		bandwidth, iops = l.readBandwidth, l.readIOPS
+	case proto.OpRandomWrite, proto.OpSyncRandomWrite:
+		bandwidth, iops = l.writeBandwidth, l.writeIOPS
+	case proto.OpWrite, proto.OpSyncWrite:
+		if !p.IsForwardPkt() && len(dp.Replicas()) > 1 {
+			return
+		}
+		bandwidth, iops = l.writeBandwidth, l.writeIOPS
+		maxWait = VolQoSMaxAppendWait
+	default:
+		return
+	}
+	if !ratelimit.AcquireAll(maxWait,
+		ratelimit.Tokens{Group: iops, Key: dp.volumeID, N: 1},
+		ratelimit.Tokens{Group: bandwidth, Key: dp.volumeID, N: int64(p.Size)}) {
+		err = proto.ErrQoSLimited
+	}
+	return
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/repl"
+)
+
+// VolSpaceLimiter enforces the capacity of the volumes with the grants pushed by the master.
+// The master splits the remaining space of a volume among the data nodes leading its writable
+// partitions, and every data node reserves the bytes appended by the clients from its own grant
+// until the next heartbeat, which replaces the grant.
+type VolSpaceLimiter struct {
+	sync.RWMutex
+	grants map[string]*volSpaceGrant
+}
+
+type volSpaceGrant struct {
+	grant    int64
+	reserved int64 // bytes accepted since the grant
+}
+
+// NewVolSpaceLimiter creates a new limiter without any limit.
+func NewVolSpaceLimiter() *VolSpaceLimiter {
+	return &VolSpaceLimiter{grants: make(map[string]*volSpaceGrant)}
+}
+
+// Update replaces the grants by the ones in the heartbeat.
+// The volumes without a grant are not limited.
+func (l *VolSpaceLimiter) Update(volSpace map[string]*proto.VolSpace) {
+	grants := make(map[string]*volSpaceGrant)
+	for name, space := range volSpace {
+		grants[name] = &volSpaceGrant{grant: int64(space.Grant)}
+	}
+	l.Lock()
+	l.grants = grants
+	l.Unlock()
+}
+
+// Reserve reserves the space of the packet from the grant of its volume, or returns ErrVolFull if the
+// grant is used up. Creating an extent takes no space, but is refused once the grant is used up.
+// Only the packets sent by the clients are counted, not the ones forwarded by the leader.
+func (l *VolSpaceLimiter) Reserve(dp *DataPartition, p *repl.Packet) (err error) {
+	if !p.IsForwardPkt() && len(dp.Replicas()) > 1 {
+		return
+	}
+	var size int64
+	switch p.Opcode {
+	case proto.OpCreateExtent:
+	case proto.OpWrite, proto.OpSyncWrite:
+		size = int64(p.Size)
+	default:
+		return
+	}
+	l.RLock()
+	g, ok := l.grants[dp.volumeID]
+	l.RUnlock()
+	if !ok {
+		return
+	}
+	if atomic.LoadInt64(&g.reserved) >= g.grant {
+		return proto.ErrVolFull
+	}
+	atomic.AddInt64(&g.reserved, size)
+	return
+}
@@ -36,7 +36,7 @@ import (
 	"strings"
 )
 
-func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
+func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 	sz := p.Size
 	tpObject := exporter.NewTPCnt(p.GetOpMsg())
 	start := time.Now().UnixNano()
@@ -64,12 +64,20 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 		p.Size = resultSize
 		tpObject.Set(err)
 	}()
+	if partition, ok := p.Object.(*DataPartition); ok && isClientIOPacket(p) {
+		partition.disk.beginClientIO()
+		defer partition.disk.endClientIO()
+	}
+	if isWritePacket(p) {
+		s.drainer.beginWrite()
+		defer s.drainer.endWrite()
+	}
 	switch p.Opcode {
 	case proto.OpCreateExtent:
 		s.handlePacketToCreateExtent(p)
 	case proto.OpWrite, proto.OpSyncWrite:
 		s.handleWritePacket(p)
-	case proto.OpStreamRead:
+	case proto.OpStreamRead, proto.OpRead:
 		s.handleStreamReadPacket(p, c, StreamRead)
 	case proto.OpExtentRepairRead:
 		s.handleExtentRepaiReadPacket(p, c, RepairRead)
@@ -93,12 +101,16 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 		s.handlePacketToGetAppliedID(p)
 	case proto.OpDecommissionDataPartition:
 		s.handlePacketToDecommissionDataPartition(p)
+	case proto.OpAddDataPartitionRaftMember, proto.OpRemoveDataPartitionRaftMember:
+		s.handlePacketToChangeRaftMember(p)
 	case proto.OpGetPartitionSize:
 		s.handlePacketToGetPartitionSize(p)
 	case proto.OpReadTinyDelete:
 		s.handlePacketToReadTinyDelete(p, c)
 	case proto.OpBroadcastMinAppliedID:
 		s.handleBroadcastMinAppliedID(p)
+	case proto.OpTryToLeader:
+		s.handlePacketToTryToLeader(p)
 	default:
 		p.PackErrorBody(repl.ErrorUnknownOp.Error(), repl.ErrorUnknownOp.Error()+strconv.Itoa(int(p.Opcode)))
 	}
@@ -106,6 +118,14 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c *net.TCPConn) (err error) {
 	return
 }
 
+func isClientIOPacket(p *repl.Packet) bool {
+	switch p.Opcode {
+	case proto.OpStreamRead, proto.OpRead, proto.OpWrite, proto.OpSyncWrite, proto.OpRandomWrite, proto.OpSyncRandomWrite:
+		return true
+	}
+	return false
+}
+
 // Handle OpCreateExtent packet.
 func (s *DataNode) handlePacketToCreateExtent(p *repl.Packet) {
 	var err error
@@ -117,6 +137,10 @@ func (s *DataNode) handlePacketToCreateExtent(p *repl.Packet) {
 		}
 	}()
 	partition := p.Object.(*DataPartition)
+	if s.drainer.isDraining() {
+		err = ErrDraining
+		return
+	}
 	if partition.Available() <= 0 || partition.disk.Status == proto.ReadOnly {
 		err = storage.NoSpaceError
 		return
@@ -197,6 +221,10 @@ func (s *DataNode) handleHeartbeatPacket(p *repl.Packet) {
 		json.Unmarshal(bytes, request)
 		response.Status = proto.TaskSucceeds
 		MasterHelper.AddNode(request.MasterAddr)
+		s.volQoS.Update(request.VolQoS)
+		s.volSpace.Update(request.VolSpace)
+		s.tokens.Update(request.TokenVerify)
+		s.setDrainByMaster(request.Drain)
 	} else {
 		response.Status = proto.TaskFailed
 		err = fmt.Errorf("illegal opcode")
@@ -332,6 +360,7 @@ func (s *DataNode) handleMarkDeletePacket(p *repl.Packet, c net.Conn) {
 	} else {
 		err = partition.ExtentStore().MarkDelete(p.ExtentID, 0, 0, 0)
 	}
+	s.space.BlockCache().InvalidateExtent(partition.partitionID, p.ExtentID)
 	if err != nil {
 		p.PackErrorBody(ActionMarkDelete, err.Error())
 	} else {
@@ -362,7 +391,7 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 	store := partition.ExtentStore()
 	if p.Size <= util.BlockSize {
 		err = store.Write(p.ExtentID, p.ExtentOffset, int64(p.Size), p.Data, p.CRC, UpdateSize, p.Opcode == proto.OpSyncWrite)
-		partition.checkIsDiskError(err)
+		partition.checkIsDiskError(err, WriteFlag)
 	} else {
 		size := p.Size
 		offset := 0
@@ -374,7 +403,7 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 			data := p.Data[offset : offset+currSize]
 			crc := crc32.ChecksumIEEE(data)
 			err = store.Write(p.ExtentID, p.ExtentOffset+int64(offset), int64(currSize), data, crc, UpdateSize, p.Opcode == proto.OpSyncWrite)
-			partition.checkIsDiskError(err)
+			partition.checkIsDiskError(err, WriteFlag)
 			if err != nil {
 				break
 			}
@@ -382,7 +411,6 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 			offset += currSize
 		}
 	}
-	s.incDiskErrCnt(p.PartitionID, err, WriteFlag)
 	return
 }
 
@@ -450,6 +478,11 @@ func (s *DataNode) handleExtentRepaiReadPacket(p *repl.Packet, connect net.Conn,
 	needReplySize := p.Size
 	offset := p.ExtentOffset
 	store := partition.ExtentStore()
+	var cache *BlockCache
+	if !isRepairRead {
+		cache = s.space.BlockCache()
+	}
+	zeroCopy := !isRepairRead && s.zeroCopyRead && p.IsZeroCopyRead()
 
 	for {
 		if needReplySize <= 0 {
@@ -459,6 +492,21 @@ func (s *DataNode) handleExtentRepaiReadPacket(p *repl.Packet, connect net.Conn,
 		reply := repl.NewStreamReadResponsePacket(p.ReqID, p.PartitionID, p.ExtentID)
 		reply.StartT = p.StartT
 		currReadSize := uint32(util.Min(int(needReplySize), util.ReadBlockSize))
+		if zeroCopy && currReadSize == util.ReadBlockSize {
+			var sent bool
+			if sent, err = writeZeroCopyReply(reply, connect, store, offset, currReadSize); err != nil {
+				return
+			}
+			if sent {
+				p.Size = currReadSize
+				p.ExtentOffset = offset
+				p.CRC = reply.CRC
+				p.ResultCode = proto.OpOk
+				needReplySize -= currReadSize
+				offset += int64(currReadSize)
+				continue
+			}
+		}
 		if currReadSize == util.ReadBlockSize {
 			reply.Data, _ = proto.Buffers.Get(util.ReadBlockSize)
 		} else {
@@ -468,8 +516,18 @@ func (s *DataNode) handleExtentRepaiReadPacket(p *repl.Packet, connect net.Conn,
 		reply.ExtentOffset = offset
 		p.Size = uint32(currReadSize)
 		p.ExtentOffset = offset
-		reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
-		partition.checkIsDiskError(err)
+		if isRepairRead {
+			s.space.RepairLimiter().Wait(partition.disk, int64(currReadSize))
+		}
+		var hit bool
+		if reply.CRC, hit = cache.Get(partition.partitionID, reply.ExtentID, offset, int64(currReadSize), reply.Data); !hit {
+			gen := cache.Generation(partition.partitionID, reply.ExtentID)
+			reply.CRC, err = store.Read(reply.ExtentID, offset, int64(currReadSize), reply.Data, isRepairRead)
+			partition.checkIsDiskError(err, ReadFlag)
+			if err == nil {
+				cache.Put(partition.partitionID, reply.ExtentID, offset, reply.Data[:currReadSize], reply.CRC, gen)
+			}
+		}
 		tpObject.Set(err)
 		p.CRC = reply.CRC
 		if err != nil {
@@ -521,7 +579,7 @@ func (s *DataNode) handlePacketToGetAllWatermarks(p *repl.Packet) {
 	return
 }
 
-func (s *DataNode) handlePacketToReadTinyDelete(p *repl.Packet, connect *net.TCPConn) {
+func (s *DataNode) handlePacketToReadTinyDelete(p *repl.Packet, connect net.Conn) {
 	var (
 		err error
 	)
@@ -699,9 +757,67 @@ end:
 	return
 }
 
+// Handle OpAddDataPartitionRaftMember and OpRemoveDataPartitionRaftMember packets.
+// Different from the decommission, only one member is changed, and the reply is sent
+// after the change has been committed, so that the master can add a new replica and
+// remove the old one at different times.
+func (s *DataNode) handlePacketToChangeRaftMember(p *repl.Packet) {
+	var (
+		err          error
+		reqData      []byte
+		isRaftLeader bool
+		req          = &proto.DataPartitionDecommissionRequest{}
+	)
+
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionChangeRaftMember, err.Error())
+			return
+		}
+		// the reply of the leader has been copied into the packet if it is forwarded
+		if isRaftLeader {
+			p.PacketOkReply()
+		}
+	}()
+
+	adminTask := &proto.AdminTask{}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		return
+	}
+	if reqData, err = json.Marshal(adminTask.Request); err != nil {
+		return
+	}
+	if err = json.Unmarshal(reqData, req); err != nil {
+		return
+	}
+	dp := s.space.Partition(req.PartitionId)
+	if dp == nil {
+		err = proto.ErrDataPartitionNotExists
+		return
+	}
+	if isRaftLeader, err = s.forwardToRaftLeader(dp, p); !isRaftLeader {
+		return
+	}
+
+	if p.Opcode == proto.OpAddDataPartitionRaftMember {
+		_, err = dp.ChangeRaftMember(raftProto.ConfAddNode, raftProto.Peer{ID: req.AddPeer.ID}, reqData)
+	} else {
+		_, err = dp.ChangeRaftMember(raftProto.ConfRemoveNode, raftProto.Peer{ID: req.RemovePeer.ID}, reqData)
+	}
+	if err != nil {
+		log.LogErrorf("action[handlePacketToChangeRaftMember] partition(%v) op(%v) addPeer(%v) removePeer(%v) err(%v)",
+			req.PartitionId, p.GetOpMsg(), req.AddPeer, req.RemovePeer, err)
+		return
+	}
+	log.LogInfof("action[handlePacketToChangeRaftMember] partition(%v) op(%v) addPeer(%v) removePeer(%v) success",
+		req.PartitionId, p.GetOpMsg(), req.AddPeer, req.RemovePeer)
+}
+
 func (s *DataNode) forwardToRaftLeader(dp *DataPartition, p *repl.Packet) (ok bool, err error) {
 	var (
-		conn       *net.TCPConn
+		conn       net.Conn
 		leaderAddr string
 	)
 
@@ -43,6 +43,15 @@ func (s *DataNode) Prepare(p *repl.Packet) (err error) {
 	if err = s.checkPartition(p); err != nil {
 		return
 	}
+	if err = s.checkVolToken(p.Object.(*DataPartition).volumeID, p); err != nil {
+		return
+	}
+	if err = s.volQoS.Limit(p.Object.(*DataPartition), p); err != nil {
+		return
+	}
+	if err = s.volSpace.Reserve(p.Object.(*DataPartition), p); err != nil {
+		return
+	}
 
 	// For certain packet, we meed to add some additional extent information.
 	if err = s.addExtentInfo(p); err != nil {
@@ -0,0 +1,86 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/repl"
+	"github.com/chubaofs/chubaofs/storage"
+)
+
+// Sends a whole block of the extent to the client without reading it into the user space.
+// The CRC in the reply header is the persisted block CRC. Nothing is sent if the block is
+// not eligible, e.g. its CRC is not persisted, and the caller falls back to the normal read.
+func writeZeroCopyReply(reply *repl.Packet, conn net.Conn, store *storage.ExtentStore, offset int64, size uint32) (sent bool, err error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	file, crc, e := store.ZeroCopyBlock(reply.ExtentID, offset, int64(size))
+	if e != nil || crc == 0 {
+		return
+	}
+	reply.ExtentOffset = offset
+	reply.Size = size
+	reply.CRC = crc
+	reply.Data = nil
+	reply.ResultCode = proto.OpOk
+	// the header only, as the data is nil
+	if err = reply.WriteToConn(tcpConn); err != nil {
+		return
+	}
+	if err = sendFile(tcpConn, file, offset, int64(size)); err != nil {
+		return
+	}
+	return true, nil
+}
+
+func sendFile(conn *net.TCPConn, file *os.File, offset, size int64) (err error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		for size > 0 {
+			n, e := syscall.Sendfile(int(fd), int(file.Fd()), &offset, int(size))
+			if n > 0 {
+				size -= int64(n)
+			}
+			if e == syscall.EAGAIN {
+				// wait until the socket is writable
+				return false
+			}
+			if e != nil {
+				sendErr = e
+				return true
+			}
+			if n == 0 {
+				sendErr = io.ErrUnexpectedEOF
+				return true
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = sendErr
+	}
+	return
+}
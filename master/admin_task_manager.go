@@ -207,6 +207,13 @@ func (sender *AdminTaskManager) DelTask(t *proto.AdminTask) {
 	delete(sender.TaskMap, t.ID)
 }
 
+func (sender *AdminTaskManager) hasTask(id string) bool {
+	sender.RLock()
+	defer sender.RUnlock()
+	_, ok := sender.TaskMap[id]
+	return ok
+}
+
 // AddTask adds a new task to the task map.
 func (sender *AdminTaskManager) AddTask(t *proto.AdminTask) {
 	sender.Lock()
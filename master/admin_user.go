@@ -0,0 +1,490 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// When the authentication is enabled, the admin APIs are only served to the admin users whose role is
+// granted the permission of the API. The users are replicated by raft, and the built-in root user, whose
+// key is set in the config of the masters, creates the first ones. The APIs called by the meta nodes,
+// the data nodes and the clients are still open, since they are authenticated by the mutual TLS or
+// by the auth keys and the tokens of the volumes. With the TLS enabled, the APIs called only by the nodes
+// need a certificate of a node, which can only register the addresses in the certificate.
+
+const (
+	maxSignatureSkew  = 5 * 60 // in terms of seconds
+	maxSignedBodySize = 1 << 20
+	adminUserKeySize  = 32
+	defaultNodeCertOU = "cfs-node"
+)
+
+// nodeAPIs are only served to the meta nodes and the data nodes, which are identified by the
+// organizational unit in their certificates when both the authentication and the TLS are enabled.
+var nodeAPIs = map[string]bool{
+	proto.AddDataNode:             true,
+	proto.AddMetaNode:             true,
+	proto.GetDataNodeTaskResponse: true,
+	proto.GetMetaNodeTaskResponse: true,
+}
+
+// apisOpenToNodesAndClients are not authenticated as the admin APIs.
+var apisOpenToNodesAndClients = map[string]bool{
+	proto.AdminGetIP:              true,
+	proto.AdminGetVol:             true,
+	proto.AdminGetDataPartition:   true,
+	proto.AddDataNode:             true,
+	proto.AddMetaNode:             true,
+	proto.GetDataNodeTaskResponse: true,
+	proto.GetMetaNodeTaskResponse: true,
+	proto.ClientDataPartitions:    true,
+	proto.ClientVol:               true,
+	proto.ClientMetaPartition:     true,
+	proto.ClientVolStat:           true,
+	proto.GetTokenVerifyInfo:      true,
+	proto.ReportLifecycleRun:      true,
+	proto.AdminIssueToken:         true,
+	proto.AdminRevokeToken:        true,
+	proto.AdminRevokeAllTokens:    true,
+	proto.AdminSetTokenRequired:   true,
+}
+
+// apiRoles is the lowest role granted the permission of the admin APIs. The APIs not listed
+// are only granted to the admins.
+var apiRoles = map[string]string{
+	proto.AdminGetCluster:                proto.AdminRoleViewer,
+	proto.AdminListVols:                  proto.AdminRoleViewer,
+	proto.AdminListDataPartitions:        proto.AdminRoleViewer,
+	proto.AdminListMetaPartitions:        proto.AdminRoleViewer,
+	proto.AdminListDataNodes:             proto.AdminRoleViewer,
+	proto.AdminListMetaNodes:             proto.AdminRoleViewer,
+	proto.GetDataNode:                    proto.AdminRoleViewer,
+	proto.GetMetaNode:                    proto.AdminRoleViewer,
+	proto.GetTopologyView:                proto.AdminRoleViewer,
+	proto.AdminGetLifecycle:              proto.AdminRoleViewer,
+	proto.AdminRebalanceProgress:         proto.AdminRoleViewer,
+	proto.AdminListTasks:                 proto.AdminRoleViewer,
+	proto.AdminGetTask:                   proto.AdminRoleViewer,
+	proto.AdminListDecommissionJobs:      proto.AdminRoleViewer,
+	proto.AdminGetDecommissionJob:        proto.AdminRoleViewer,
+	proto.AdminQueryUsageHistory:         proto.AdminRoleViewer,
+	proto.AdminCreateVol:                 proto.AdminRoleOperator,
+	proto.AdminUpdateVol:                 proto.AdminRoleOperator,
+	proto.AdminSetVolQoS:                 proto.AdminRoleOperator,
+	proto.AdminSetVolPlacement:           proto.AdminRoleOperator,
+	proto.AdminSetVolTier:                proto.AdminRoleOperator,
+	proto.AdminSetVolReplicaNum:          proto.AdminRoleOperator,
+	proto.AdminSetLifecycleRule:          proto.AdminRoleOperator,
+	proto.AdminDeleteLifecycleRule:       proto.AdminRoleOperator,
+	proto.AdminCreateDataPartition:       proto.AdminRoleOperator,
+	proto.AdminLoadDataPartition:         proto.AdminRoleOperator,
+	proto.AdminDecommissionDataPartition: proto.AdminRoleOperator,
+	proto.AdminCreateMP:                  proto.AdminRoleOperator,
+	proto.AdminLoadMetaPartition:         proto.AdminRoleOperator,
+	proto.AdminDecommissionMetaPartition: proto.AdminRoleOperator,
+	proto.DecommissionDataNode:           proto.AdminRoleOperator,
+	proto.DrainDataNode:                  proto.AdminRoleOperator,
+	proto.SetDataNodeState:               proto.AdminRoleOperator,
+	proto.SetMetaNodeState:               proto.AdminRoleOperator,
+	proto.DecommissionDisk:               proto.AdminRoleOperator,
+	proto.DecommissionMetaNode:           proto.AdminRoleOperator,
+	proto.AdminRebalancePlan:             proto.AdminRoleOperator,
+	proto.AdminRebalancePause:            proto.AdminRoleOperator,
+	proto.AdminRebalanceResume:           proto.AdminRoleOperator,
+	proto.AdminRetryTask:                 proto.AdminRoleOperator,
+	proto.AdminCancelTask:                proto.AdminRoleOperator,
+	proto.AdminPauseDecommissionJob:      proto.AdminRoleOperator,
+	proto.AdminResumeDecommissionJob:     proto.AdminRoleOperator,
+	proto.AdminCancelDecommissionJob:     proto.AdminRoleOperator,
+}
+
+// adminUser is an admin user of the master. The key is kept in clear in memory, since the signatures
+// of the requests are verified with it, and is sealed in the raft store if a secret key is configured.
+type adminUser struct {
+	Name       string
+	Role       string
+	Key        string
+	CreateTime int64
+}
+
+// adminUserValue is the admin user persisted by raft.
+type adminUserValue struct {
+	Name       string
+	Role       string
+	Key        string `json:",omitempty"` // in clear if no secret key is configured
+	SealedKey  []byte `json:",omitempty"`
+	CreateTime int64
+}
+
+func (u *adminUser) info() *proto.AdminUserInfo {
+	return &proto.AdminUserInfo{Name: u.Name, Role: u.Role, CreateTime: u.CreateTime}
+}
+
+func (u *adminUser) view() *proto.AdminUserView {
+	return &proto.AdminUserView{Name: u.Name, Role: u.Role, CreateTime: u.CreateTime, Key: u.Key}
+}
+
+func newAdminUserKey() (key string, err error) {
+	b := make([]byte, adminUserKeySize)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requiredRole returns the lowest role granted the permission of the API, empty if the API is open.
+func requiredRole(path string) string {
+	if apisOpenToNodesAndClients[path] {
+		return ""
+	}
+	if role, ok := apiRoles[path]; ok {
+		return role
+	}
+	return proto.AdminRoleAdmin
+}
+
+//key=#user#name,value=json.Marshal(adminUser)
+func (c *Cluster) syncPutUser(u *adminUser) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutUser
+	metadata.K = userPrefix + u.Name
+	uv := &adminUserValue{Name: u.Name, Role: u.Role, CreateTime: u.CreateTime}
+	if uv.SealedKey, err = c.sealSecret(metadata.K, []byte(u.Key)); err != nil {
+		return
+	}
+	if uv.SealedKey == nil {
+		uv.Key = u.Key
+	}
+	if metadata.V, err = json.Marshal(uv); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteUser(name string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteUser
+	metadata.K = userPrefix + name
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadUsers() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(userPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadUsers],err:%v", err.Error())
+		return err
+	}
+	users := make(map[string]*adminUser, len(result))
+	for _, value := range result {
+		uv := new(adminUserValue)
+		if err = json.Unmarshal(value, uv); err != nil {
+			err = fmt.Errorf("action[loadUsers],unmarshal err:%v", err)
+			return err
+		}
+		u := &adminUser{Name: uv.Name, Role: uv.Role, Key: uv.Key, CreateTime: uv.CreateTime}
+		if uv.SealedKey != nil {
+			var key []byte
+			if key, err = c.openSecret(userPrefix+uv.Name, uv.SealedKey); err != nil {
+				err = fmt.Errorf("action[loadUsers],err:%v", err)
+				return err
+			}
+			u.Key = string(key)
+		}
+		users[u.Name] = u
+		log.LogInfof("action[loadUsers],user[%v] role[%v]", u.Name, u.Role)
+	}
+	c.usersMutex.Lock()
+	c.users = users
+	c.usersMutex.Unlock()
+	return
+}
+
+// getUser returns the admin user, including the root user if its key is configured.
+func (c *Cluster) getUser(name string) (u *adminUser, ok bool) {
+	if name == proto.AdminRootUser {
+		if c.cfg.rootKey == "" {
+			return nil, false
+		}
+		return &adminUser{Name: name, Role: proto.AdminRoleAdmin, Key: c.cfg.rootKey}, true
+	}
+	c.usersMutex.RLock()
+	defer c.usersMutex.RUnlock()
+	u, ok = c.users[name]
+	return
+}
+
+func (c *Cluster) createUser(name, role string) (u *adminUser, err error) {
+	c.usersMutex.Lock()
+	defer c.usersMutex.Unlock()
+	if _, ok := c.users[name]; ok || name == proto.AdminRootUser {
+		return nil, proto.ErrDuplicateUser
+	}
+	u = &adminUser{Name: name, Role: role, CreateTime: time.Now().Unix()}
+	if u.Key, err = newAdminUserKey(); err != nil {
+		goto errHandler
+	}
+	if err = c.syncPutUser(u); err != nil {
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	c.users[name] = u
+	Warn(c.Name, fmt.Sprintf("action[createUser] clusterID[%v] user[%v] role[%v]", c.Name, name, role))
+	return
+errHandler:
+	err = fmt.Errorf("action[createUser], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return nil, err
+}
+
+func (c *Cluster) deleteUser(name string) (err error) {
+	c.usersMutex.Lock()
+	defer c.usersMutex.Unlock()
+	if _, ok := c.users[name]; !ok {
+		return proto.ErrUserNotExists
+	}
+	if err = c.syncDeleteUser(name); err != nil {
+		err = fmt.Errorf("action[deleteUser], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+		log.LogError(errors.Stack(err))
+		Warn(c.Name, err.Error())
+		return proto.ErrPersistenceByRaft
+	}
+	delete(c.users, name)
+	Warn(c.Name, fmt.Sprintf("action[deleteUser] clusterID[%v] user[%v]", c.Name, name))
+	return
+}
+
+// updateUser changes the role of the user, and generates a new key if resetKey is set.
+func (c *Cluster) updateUser(name, role string, resetKey bool) (u *adminUser, err error) {
+	c.usersMutex.Lock()
+	defer c.usersMutex.Unlock()
+	old, ok := c.users[name]
+	if !ok {
+		return nil, proto.ErrUserNotExists
+	}
+	u = &adminUser{Name: name, Role: old.Role, Key: old.Key, CreateTime: old.CreateTime}
+	if role != "" {
+		u.Role = role
+	}
+	if resetKey {
+		if u.Key, err = newAdminUserKey(); err != nil {
+			goto errHandler
+		}
+	}
+	if err = c.syncPutUser(u); err != nil {
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	c.users[name] = u
+	Warn(c.Name, fmt.Sprintf("action[updateUser] clusterID[%v] user[%v] role[%v] resetKey[%v]", c.Name, name, u.Role, resetKey))
+	return
+errHandler:
+	err = fmt.Errorf("action[updateUser], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return nil, err
+}
+
+func (c *Cluster) listUsers() (users []*proto.AdminUserInfo) {
+	c.usersMutex.RLock()
+	defer c.usersMutex.RUnlock()
+	users = make([]*proto.AdminUserInfo, 0, len(c.users))
+	for _, u := range c.users {
+		users = append(users, u.info())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return
+}
+
+// authenticate returns the admin user sending the request, either with its API key,
+// or with the signature of the request computed with the key.
+func (c *Cluster) authenticate(r *http.Request) (u *adminUser, err error) {
+	name := r.Header.Get(proto.AdminUserHeader)
+	if name == "" {
+		return nil, proto.ErrAuthRequired
+	}
+	u, ok := c.getUser(name)
+	if !ok {
+		return nil, proto.ErrAuthFailed
+	}
+	if key := r.Header.Get(proto.AdminKeyHeader); key != "" {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(u.Key)) != 1 {
+			return nil, proto.ErrAuthFailed
+		}
+		return
+	}
+	date, nonce := r.Header.Get(proto.AdminDateHeader), r.Header.Get(proto.AdminNonceHeader)
+	signature := r.Header.Get(proto.AdminSignatureHeader)
+	if date == "" || nonce == "" || signature == "" {
+		return nil, proto.ErrAuthRequired
+	}
+	signedAt, err := strconv.ParseInt(date, 10, 64)
+	if err != nil {
+		return nil, proto.ErrAuthFailed
+	}
+	if skew := time.Now().Unix() - signedAt; skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return nil, proto.ErrAuthFailed
+	}
+	// the form values in the body take precedence over the query, so the body is signed as well
+	if r.Body != nil {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(r.Body, maxSignedBodySize+1), r.Body}
+	}
+	body, err := proto.ReadRequestBody(r)
+	if err != nil || len(body) > maxSignedBodySize {
+		return nil, proto.ErrAuthFailed
+	}
+	expected := proto.AdminRequestSignature(u.Key, r.Method, r.URL.Path, r.URL.RawQuery, date, nonce, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, proto.ErrAuthFailed
+	}
+	if !c.nonces.use(name+"/"+nonce, signedAt+maxSignatureSkew) {
+		return nil, proto.ErrAuthFailed
+	}
+	return
+}
+
+// signatureNonces records the nonces of the signed requests until their signatures expire,
+// so that a signed request is served only once.
+type signatureNonces struct {
+	sync.Mutex
+	seen      map[string]int64 // nonce -> the unix time when the signature expires
+	lastPurge int64
+}
+
+func newSignatureNonces() *signatureNonces {
+	return &signatureNonces{seen: make(map[string]int64)}
+}
+
+// use records the nonce, and returns false if it has been used by an unexpired signature.
+func (n *signatureNonces) use(nonce string, expireAt int64) bool {
+	now := time.Now().Unix()
+	n.Lock()
+	defer n.Unlock()
+	if now-n.lastPurge > maxSignatureSkew {
+		for k, t := range n.seen {
+			if t < now {
+				delete(n.seen, k)
+			}
+		}
+		n.lastPurge = now
+	}
+	if t, ok := n.seen[nonce]; ok && t >= now {
+		return false
+	}
+	n.seen[nonce] = expireAt
+	return true
+}
+
+// checkPermission checks if the request is granted the permission of the API, and answers the
+// denied ones with the error.
+func (m *Server) checkPermission(w http.ResponseWriter, r *http.Request) bool {
+	if !m.config.AuthEnabled {
+		return true
+	}
+	role := requiredRole(r.URL.Path)
+	if role == "" {
+		if err := m.checkNodeIdentity(r); err != nil {
+			log.LogWarnf("action[checkPermission] denied,remoteAddr[%v] method[%v] path[%v] err[%v]",
+				r.RemoteAddr, r.Method, r.URL.Path, err)
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrPermissionDenied))
+			return false
+		}
+		return true
+	}
+	u, err := m.cluster.authenticate(r)
+	if err == nil {
+		setAuditUser(r, u.Name)
+		if proto.AdminRoleLevel(u.Role) < proto.AdminRoleLevel(role) {
+			err = proto.ErrPermissionDenied
+		}
+	}
+	if err != nil {
+		log.LogWarnf("action[checkPermission] denied,user[%v] remoteAddr[%v] method[%v] path[%v] required[%v] err[%v]",
+			r.Header.Get(proto.AdminUserHeader), r.RemoteAddr, r.Method, r.URL.Path, role, err)
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return false
+	}
+	return true
+}
+
+// checkNodeIdentity checks that the request to a node API is sent with a certificate of a node,
+// whose IP or DNS names cover the address registered by the node.
+func (m *Server) checkNodeIdentity(r *http.Request) (err error) {
+	if !m.config.AuthEnabled || !nodeAPIs[r.URL.Path] || !util.TLSEnabled() {
+		return
+	}
+	// the followers check the nodes before proxying their requests to the leader
+	if m.partition.IsRaftLeader() && m.isMasterPeer(r.RemoteAddr) {
+		return
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("no client certificate")
+	}
+	cert := r.TLS.PeerCertificates[0]
+	isNode := false
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if ou == m.config.NodeCertOU {
+			isNode = true
+		}
+	}
+	if !isNode {
+		return fmt.Errorf("certificate[%v] is not issued to a node of OU[%v]", cert.Subject, m.config.NodeCertOU)
+	}
+	if r.URL.Path != proto.AddDataNode && r.URL.Path != proto.AddMetaNode {
+		return
+	}
+	host, _, err := net.SplitHostPort(r.FormValue(addrKey))
+	if err != nil {
+		return
+	}
+	if err = cert.VerifyHostname(host); err != nil {
+		return fmt.Errorf("certificate[%v] cannot register addr[%v]: %v", cert.Subject, r.FormValue(addrKey), err)
+	}
+	return
+}
+
+func (m *Server) isMasterPeer(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	for _, peer := range m.config.peers {
+		if peer.Address == host {
+			return true
+		}
+	}
+	return false
+}
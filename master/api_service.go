@@ -62,6 +62,7 @@ type NodeView struct {
 	Status     bool
 	ID         uint64
 	IsWritable bool
+	AdminState string
 }
 
 // TopologyView provides the view of the topology view of the cluster
@@ -145,7 +146,7 @@ func (m *Server) getTopology(w http.ResponseWriter, r *http.Request) {
 		tv.NodeSet[ns.ID] = nsView
 		ns.metaNodes.Range(func(key, value interface{}) bool {
 			metaNode := value.(*MetaNode)
-			nsView.MetaNodes = append(nsView.MetaNodes, NodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive, IsWritable: metaNode.isWritable()})
+			nsView.MetaNodes = append(nsView.MetaNodes, NodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive, IsWritable: metaNode.isWritable(), AdminState: metaNode.AdminState})
 			return true
 		})
 		for _, rack := range ns.rackMap {
@@ -154,7 +155,7 @@ func (m *Server) getTopology(w http.ResponseWriter, r *http.Request) {
 			nsView.Racks = append(nsView.Racks, rv)
 			rack.dataNodes.Range(func(key, value interface{}) bool {
 				dataNode := value.(*DataNode)
-				rv.DataNodes = append(rv.DataNodes, NodeView{ID: dataNode.ID, Addr: dataNode.Addr, Status: dataNode.isActive, IsWritable: dataNode.isWriteAble()})
+				rv.DataNodes = append(rv.DataNodes, NodeView{ID: dataNode.ID, Addr: dataNode.Addr, Status: dataNode.isActive, IsWritable: dataNode.isWriteAble(), AdminState: dataNode.AdminState})
 				return true
 			})
 		}
@@ -162,6 +163,43 @@ func (m *Server) getTopology(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(tv))
 }
 
+// Plan the moves to rebalance the data partition replicas among the data nodes.
+// The plan replaces the pending moves of the last one, and is executed in the background unless dryRun is true.
+func (m *Server) planRebalance(w http.ResponseWriter, r *http.Request) {
+	var (
+		threshold   float64
+		concurrency int
+		maxMoves    int
+		dryRun      bool
+		progress    *RebalanceProgress
+		err         error
+	)
+	if threshold, concurrency, maxMoves, dryRun, err = m.parseRequestToPlanRebalance(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if progress, err = m.cluster.rebalancer.plan(threshold, concurrency, maxMoves, dryRun); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(progress))
+}
+
+func (m *Server) getRebalanceProgress(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.rebalancer.progress()))
+}
+
+// Pausing stops starting new moves, and the moves in progress are still finished.
+func (m *Server) pauseRebalance(w http.ResponseWriter, r *http.Request) {
+	m.cluster.rebalancer.setPaused(true)
+	sendOkReply(w, r, newSuccessHTTPReply("rebalance paused"))
+}
+
+func (m *Server) resumeRebalance(w http.ResponseWriter, r *http.Request) {
+	m.cluster.rebalancer.setPaused(false)
+	sendOkReply(w, r, newSuccessHTTPReply("rebalance resumed"))
+}
+
 func (m *Server) getCluster(w http.ResponseWriter, r *http.Request) {
 	cv := &ClusterView{
 		Name:               m.cluster.Name,
@@ -233,6 +271,7 @@ func (m *Server) createDataPartition(w http.ResponseWriter, r *http.Request) {
 		reqCreateCount             int
 		lastTotalDataPartitions    int
 		clusterTotalDataPartitions int
+		mediaType                  uint8
 		err                        error
 	)
 
@@ -240,15 +279,22 @@ func (m *Server) createDataPartition(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
+	if mediaType, err = proto.ParseMediaType(r.FormValue(mediaTypeKey)); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
 
 	if vol, err = m.cluster.getVol(volName); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
 		return
 	}
+	if mediaType == proto.MediaUnspecified {
+		mediaType = vol.getMediaType()
+	}
 	lastTotalDataPartitions = len(vol.dataPartitions.partitions)
 	clusterTotalDataPartitions = m.cluster.getDataPartitionCount()
 	for i := 0; i < reqCreateCount; i++ {
-		if _, err = m.cluster.createDataPartition(volName); err != nil {
+		if _, err = m.cluster.createDataPartition(volName, mediaType); err != nil {
 			break
 		}
 	}
@@ -344,7 +390,7 @@ func (m *Server) markDeleteVol(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	if err = m.cluster.markDeleteVol(name, authKey); err != nil {
+	if err = m.cluster.markDeleteVol(name, authKey, r.FormValue(tokenKey)); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -373,23 +419,420 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(msg))
 }
 
-func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
+func (m *Server) setVolQoS(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		vol     *Vol
+		qos     proto.VolQoS
+		err     error
+	)
+	if err = r.ParseForm(); err == nil {
+		if name, err = extractName(r); err == nil {
+			authKey, err = extractAuthKey(r)
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	qos = vol.getQoS()
+	if err = parseVolQoS(r, &qos); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolQoS(name, authKey, qos); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set qos of vol[%v] to %v successfully", name, qos)))
+}
+
+// An empty policy removes the placement policy of the volume.
+func (m *Server) setVolPlacement(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		placement *placementPolicy
+		err       error
+	)
+	if err = r.ParseForm(); err == nil {
+		if name, err = extractName(r); err == nil {
+			if authKey, err = extractAuthKey(r); err == nil {
+				placement, err = parsePlacementPolicy(r.FormValue(placementKey))
+			}
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolPlacement(name, authKey, placement); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set placement policy of vol[%v] to [%v] successfully", name, placement)))
+}
+
+func (m *Server) setVolReplicaNum(w http.ResponseWriter, r *http.Request) {
+	var (
+		name         string
+		authKey      string
+		dpReplicaNum uint8
+		mpReplicaNum uint8
+		err          error
+	)
+	if err = r.ParseForm(); err == nil {
+		if name, err = extractName(r); err == nil {
+			if authKey, err = extractAuthKey(r); err == nil {
+				dpReplicaNum, mpReplicaNum, err = parseReplicaNum(r)
+			}
+		}
+	}
+	if err == nil && dpReplicaNum == 0 && mpReplicaNum == 0 {
+		err = fmt.Errorf("either %v or %v is required", dpReplicaNumKey, mpReplicaNumKey)
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolReplicaNum(name, authKey, dpReplicaNum, mpReplicaNum); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set replica number of vol[%v] successfully, the replicas are being changed", name)))
+}
+
+// setVolTier sets the default media class of a volume, and the seconds after the last access when a file is
+// moved from ssd to hdd. An empty media type makes the data partitions created on any disk, and zero
+// seconds stops moving the files.
+func (m *Server) setVolTier(w http.ResponseWriter, r *http.Request) {
+	var (
+		name      string
+		authKey   string
+		mediaType uint8
+		moveAfter int64
+		err       error
+	)
+	if err = r.ParseForm(); err == nil {
+		if name, err = extractName(r); err == nil {
+			if authKey, err = extractAuthKey(r); err == nil {
+				if mediaType, err = proto.ParseMediaType(r.FormValue(mediaTypeKey)); err == nil {
+					moveAfter, err = parseMoveAfter(r)
+				}
+			}
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setVolTier(name, authKey, mediaType, moveAfter); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set tier of vol[%v] to mediaType[%v] moveAfter[%v] successfully",
+		name, proto.MediaTypeString(mediaType), moveAfter)))
+}
+
+func (m *Server) setLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		rule    *proto.LifecycleRule
+		err     error
+	)
+	if err = r.ParseForm(); err == nil {
+		if name, err = extractName(r); err == nil {
+			if authKey, err = extractAuthKey(r); err == nil {
+				rule, err = parseLifecycleRule(r)
+			}
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setLifecycleRule(name, authKey, rule); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set lifecycle rule[%v] of vol[%v] successfully", rule.ID, name)))
+}
+
+func (m *Server) deleteLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		id      string
+		err     error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err == nil {
+		if id = r.FormValue(ruleIDKey); id == "" {
+			err = keyNotFound(ruleIDKey)
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.deleteLifecycleRule(name, authKey, id); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete lifecycle rule[%v] of vol[%v] successfully", id, name)))
+}
+
+func (m *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		mode    string
+		ttl     = int64(defaultTokenTTL)
+		signed  string
+		t       *proto.VolumeToken
+		err     error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err == nil {
+		if mode = r.FormValue(modeKey); !proto.ValidTokenMode(mode) {
+			err = fmt.Errorf("parameter %v should be %v or %v", modeKey, proto.TokenModeReadOnly, proto.TokenModeReadWrite)
+		} else if value := r.FormValue(ttlKey); value != "" {
+			if ttl, err = strconv.ParseInt(value, 10, 64); err == nil && (ttl <= 0 || ttl > maxTokenTTL) {
+				err = fmt.Errorf("parameter %v should be in (0, %v] seconds", ttlKey, maxTokenTTL)
+			}
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if signed, t, err = m.cluster.issueToken(name, authKey, r.FormValue(tokenKey), mode, ttl); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(&proto.TokenView{Token: signed, ID: t.ID, VolName: t.VolName, Mode: t.Mode, Expire: t.Expire}))
+}
+
+func (m *Server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		id      string
+		err     error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err == nil {
+		if id = r.FormValue(idKey); id == "" {
+			err = keyNotFound(idKey)
+		}
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.revokeToken(name, authKey, r.FormValue(tokenKey), id); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("revoke the token[%v] of vol[%v] successfully", id, name)))
+}
+
+func (m *Server) revokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	var (
+		name    string
+		authKey string
+		signed  string
+		t       *proto.VolumeToken
+		err     error
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if signed, t, err = m.cluster.revokeAllTokens(name, authKey, r.FormValue(tokenKey)); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(&proto.TokenView{Token: signed, ID: t.ID, VolName: t.VolName, Mode: t.Mode, Expire: t.Expire}))
+}
+
+func (m *Server) setTokenRequired(w http.ResponseWriter, r *http.Request) {
 	var (
 		name     string
-		owner    string
+		authKey  string
+		required bool
 		err      error
-		msg      string
-		size     int
-		mpCount  int
-		capacity int
-		vol      *Vol
+	)
+	if name, authKey, err = parseVolNameAndAuthKey(r); err == nil {
+		required, err = extractStatus(r)
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.setTokenRequired(name, authKey, r.FormValue(tokenKey), required); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set token required of vol[%v] to %v successfully", name, required)))
+}
+
+func (m *Server) getTokenVerifyInfo(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.tokenVerifyInfo()))
+}
+
+func (m *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		role string
+		u    *adminUser
+		err  error
+	)
+	if name, role, err = parseRequestToSetUser(r, true); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if u, err = m.cluster.createUser(name, role); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(u.view()))
+}
+
+func (m *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.deleteUser(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("delete user[%v] successfully", name)))
+}
+
+func (m *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		role string
+		u    *adminUser
+		err  error
+	)
+	if name, role, err = parseRequestToSetUser(r, true); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if u, err = m.cluster.updateUser(name, role, false); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(u.info()))
+}
+
+func (m *Server) resetUserKey(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		u    *adminUser
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if u, err = m.cluster.updateUser(name, "", true); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(u.view()))
+}
+
+func (m *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.listUsers()))
+}
+
+func (m *Server) getLifecycle(w http.ResponseWriter, r *http.Request) {
+	var (
+		name string
+		vol  *Vol
+		err  error
+	)
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(vol.getLifecycleView()))
+}
+
+// reportLifecycleRun receives the result of a run of a lifecycle rule from a meta node.
+func (m *Server) reportLifecycleRun(w http.ResponseWriter, r *http.Request) {
+	var (
+		body   []byte
+		report = &proto.LifecycleReport{}
+		err    error
+	)
+	if body, err = ioutil.ReadAll(r.Body); err == nil {
+		err = json.Unmarshal(body, report)
+	}
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if err = m.cluster.addLifecycleReport(report); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("%v", http.StatusOK)))
+}
+
+func (m *Server) createVol(w http.ResponseWriter, r *http.Request) {
+	var (
+		name           string
+		owner          string
+		err            error
+		msg            string
+		size           int
+		mpCount        int
+		capacity       int
+		vol            *Vol
+		placement      *placementPolicy
+		dpReplicaNum   uint8
+		mpReplicaNum   uint8
+		mediaType      uint8
+		encryptionMode uint8
 	)
 
 	if name, owner, mpCount, size, capacity, err = parseRequestToCreateVol(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	if vol, err = m.cluster.createVol(name, owner, mpCount, size, capacity); err != nil {
+	if dpReplicaNum, mpReplicaNum, err = parseReplicaNum(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if placement, err = parsePlacementPolicy(r.FormValue(placementKey)); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if mediaType, err = proto.ParseMediaType(r.FormValue(mediaTypeKey)); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if encryptionMode, err = proto.ParseEncryptionMode(r.FormValue(encryptionKey)); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.createVol(name, owner, mpCount, size, capacity, dpReplicaNum, mpReplicaNum, placement, mediaType, encryptionMode); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -425,6 +868,12 @@ func newSimpleView(vol *Vol) *proto.SimpleVolView {
 		MpReplicaNum: vol.mpReplicaNum,
 		Status:       vol.Status,
 		Capacity:     vol.Capacity,
+		QoS:          vol.getQoS(),
+		Placement:    vol.getPlacement().String(),
+		MediaType:    proto.MediaTypeString(vol.getMediaType()),
+		Encryption:   proto.EncryptionModeString(vol.encryptionMode),
+		MoveAfter:    vol.getMoveAfter(),
+		Lifecycle:    vol.getLifecycleRules(),
 		RwDpCnt:      vol.dataPartitions.readableAndWritableCnt,
 		MpCnt:        len(vol.MetaPartitions),
 		DpCnt:        len(vol.dataPartitions.partitionMap),
@@ -442,7 +891,7 @@ func (m *Server) addDataNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if id, err = m.cluster.addDataNode(nodeAddr); err != nil {
+	if id, err = m.cluster.addDataNode(nodeAddr, r.FormValue(zoneKey), r.FormValue(rackKey)); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -467,12 +916,12 @@ func (m *Server) getDataNode(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(dataNode))
 }
 
-// Decommission a data node. This will decommission all the data partition on that node.
+// Decommission a data node. This starts a job decommissioning all the data partitions on that node.
 func (m *Server) dataNodeOffline(w http.ResponseWriter, r *http.Request) {
 	var (
-		node        *DataNode
-		rstMsg      string
+		view        *proto.DecommissionJobView
 		offLineAddr string
+		concurrency int
 		err         error
 	)
 
@@ -480,52 +929,67 @@ func (m *Server) dataNodeOffline(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
+	if concurrency, err = parseRequestToCreateDecommissionJob(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if view, err = m.cluster.createDecommissionJob(proto.DecommissionTypeDataNode, offLineAddr, "", concurrency); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
+
+// Drain a data node before maintenance, or stop draining it.
+func (m *Server) drainDataNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		node   *DataNode
+		addr   string
+		enable bool
+		err    error
+	)
 
-	if node, err = m.cluster.dataNode(offLineAddr); err != nil {
+	if addr, err = parseAndExtractNodeAddr(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if enable, err = extractStatus(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if node, err = m.cluster.dataNode(addr); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
 		return
 	}
-	if err = m.cluster.dataNodeOffLine(node); err != nil {
+	if err = m.cluster.setDataNodeDrain(node, enable); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	rstMsg = fmt.Sprintf("decommission data node [%v] successfully", offLineAddr)
-	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set drain of data node [%v] to [%v] successfully", addr, enable)))
 }
 
-// Decommission a disk. This will decommission all the data partitions on this disk.
+// Decommission a disk. This starts a job decommissioning all the data partitions on this disk.
 func (m *Server) decommissionDisk(w http.ResponseWriter, r *http.Request) {
 	var (
-		node                  *DataNode
-		rstMsg                string
+		view                  *proto.DecommissionJobView
 		offLineAddr, diskPath string
+		concurrency           int
 		err                   error
-		badPartitionIds       []uint64
 	)
 
 	if offLineAddr, diskPath, err = parseRequestToDecommissionNode(r); err != nil {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	if node, err = m.cluster.dataNode(offLineAddr); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
-		return
-	}
-	badPartitionIds = node.badPartitionIDs(diskPath)
-	if len(badPartitionIds) == 0 {
-		err = fmt.Errorf("node[%v] disk[%v] does not have any data partition", node.Addr, diskPath)
-		sendErrReply(w, r, newErrHTTPReply(err))
+	if concurrency, err = parseRequestToCreateDecommissionJob(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	rstMsg = fmt.Sprintf("recive decommissionDisk node[%v] disk[%v], badPartitionIds[%v] has offline successfully",
-		node.Addr, diskPath, badPartitionIds)
-	if err = m.cluster.decommissionDisk(node, diskPath, badPartitionIds); err != nil {
+	if view, err = m.cluster.createDecommissionJob(proto.DecommissionTypeDisk, offLineAddr, diskPath, concurrency); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
-	Warn(m.clusterName, rstMsg)
-	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	sendOkReply(w, r, newSuccessHTTPReply(view))
 }
 
 // handle tasks such as heartbeat，loadDataPartition，deleteDataPartition, etc.
@@ -550,7 +1014,7 @@ func (m *Server) addMetaNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if id, err = m.cluster.addMetaNode(nodeAddr); err != nil {
+	if id, err = m.cluster.addMetaNode(nodeAddr, r.FormValue(zoneKey), r.FormValue(rackKey)); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
 	}
@@ -624,9 +1088,9 @@ func (m *Server) loadMetaPartition(w http.ResponseWriter, r *http.Request) {
 
 func (m *Server) decommissionMetaNode(w http.ResponseWriter, r *http.Request) {
 	var (
-		metaNode    *MetaNode
-		rstMsg      string
+		view        *proto.DecommissionJobView
 		offLineAddr string
+		concurrency int
 		err         error
 	)
 
@@ -634,14 +1098,15 @@ func (m *Server) decommissionMetaNode(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-
-	if metaNode, err = m.cluster.metaNode(offLineAddr); err != nil {
-		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaNodeNotExists))
+	if concurrency, err = parseRequestToCreateDecommissionJob(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
 		return
 	}
-	m.cluster.decommissionMetaNode(metaNode)
-	rstMsg = fmt.Sprintf("decommissionMetaNode metaNode [%v] has offline successfully", offLineAddr)
-	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
+	if view, err = m.cluster.createDecommissionJob(proto.DecommissionTypeMetaNode, offLineAddr, "", concurrency); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
 }
 
 func (m *Server) handleMetaNodeTaskResponse(w http.ResponseWriter, r *http.Request) {
@@ -767,6 +1232,25 @@ func parseVolNameAndAuthKey(r *http.Request) (name, authKey string, err error) {
 
 }
 
+func parseRequestToSetUser(r *http.Request, roleRequired bool) (name, role string, err error) {
+	if name, err = parseAndExtractName(r); err != nil {
+		return
+	}
+	if name == proto.AdminRootUser {
+		err = fmt.Errorf("user %v is built in", proto.AdminRootUser)
+		return
+	}
+	role = r.FormValue(roleKey)
+	if role == "" && roleRequired {
+		err = keyNotFound(roleKey)
+		return
+	}
+	if role != "" && proto.AdminRoleLevel(role) == 0 {
+		err = fmt.Errorf("parameter %v should be %v, %v or %v", roleKey, proto.AdminRoleViewer, proto.AdminRoleOperator, proto.AdminRoleAdmin)
+	}
+	return
+}
+
 func parseRequestToDeleteVol(r *http.Request) (name, authKey string, err error) {
 	return parseVolNameAndAuthKey(r)
 
@@ -792,6 +1276,71 @@ func parseRequestToUpdateVol(r *http.Request) (name, authKey string, capacity in
 	return
 }
 
+// The bandwidth is given in MB/s. The limits not given are unchanged, and zero means unlimited.
+// A limit must allow at least one packet per second, otherwise no packet could ever pass.
+func parseVolQoS(r *http.Request, qos *proto.VolQoS) (err error) {
+	for _, param := range []struct {
+		key   string
+		value *int64
+		unit  int64
+		min   int64
+	}{
+		{readBandwidthKey, &qos.ReadBandwidth, util.MB, util.BlockSize},
+		{writeBandwidthKey, &qos.WriteBandwidth, util.MB, util.BlockSize},
+		{readIOPSKey, &qos.ReadIOPS, 1, 1},
+		{writeIOPSKey, &qos.WriteIOPS, 1, 1},
+		{metaOPSKey, &qos.MetaOPS, 1, 1},
+	} {
+		value := r.FormValue(param.key)
+		if value == "" {
+			continue
+		}
+		var v int64
+		if v, err = strconv.ParseInt(value, 10, 64); err != nil || v < 0 {
+			return unmatchedKey(param.key)
+		}
+		if v > 0 && v*param.unit < param.min {
+			return unmatchedKey(param.key)
+		}
+		*param.value = v * param.unit
+	}
+	return
+}
+
+func (m *Server) parseRequestToPlanRebalance(r *http.Request) (threshold float64, concurrency, maxMoves int, dryRun bool, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	threshold = m.config.RebalanceThreshold
+	if value := r.FormValue(thresholdKey); value != "" {
+		if threshold, err = strconv.ParseFloat(value, 64); err != nil || threshold < 0 || threshold >= 1 {
+			err = unmatchedKey(thresholdKey)
+			return
+		}
+	}
+	concurrency = m.config.RebalanceConcurrency
+	if value := r.FormValue(concurrencyKey); value != "" {
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency <= 0 {
+			err = unmatchedKey(concurrencyKey)
+			return
+		}
+	}
+	maxMoves = defaultRebalanceMaxMoves
+	if value := r.FormValue(maxMovesKey); value != "" {
+		if maxMoves, err = strconv.Atoi(value); err != nil || maxMoves <= 0 {
+			err = unmatchedKey(maxMovesKey)
+			return
+		}
+	}
+	if value := r.FormValue(dryRunKey); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			err = unmatchedKey(dryRunKey)
+			return
+		}
+	}
+	return
+}
+
 func parseRequestToCreateVol(r *http.Request) (name, owner string, mpCount, size, capacity int, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -825,6 +1374,62 @@ func parseRequestToCreateVol(r *http.Request) (name, owner string, mpCount, size
 	return
 }
 
+func parseLifecycleRule(r *http.Request) (rule *proto.LifecycleRule, err error) {
+	rule = &proto.LifecycleRule{
+		ID:     r.FormValue(ruleIDKey),
+		Path:   r.FormValue(pathKey),
+		Action: r.FormValue(actionKey),
+	}
+	if rule.ID == "" {
+		return nil, keyNotFound(ruleIDKey)
+	}
+	if rule.Path == "" {
+		return nil, keyNotFound(pathKey)
+	}
+	if rule.Action == "" {
+		return nil, keyNotFound(actionKey)
+	}
+	if rule.Days, err = strconv.Atoi(r.FormValue(daysKey)); err != nil {
+		return nil, unmatchedKey(daysKey)
+	}
+	if err = rule.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func parseMoveAfter(r *http.Request) (moveAfter int64, err error) {
+	value := r.FormValue(moveAfterKey)
+	if value == "" {
+		return
+	}
+	if moveAfter, err = strconv.ParseInt(value, 10, 64); err != nil || moveAfter < 0 {
+		err = unmatchedKey(moveAfterKey)
+	}
+	return
+}
+
+// parseReplicaNum parses the replica numbers of the data partitions and the meta partitions,
+// zero is returned if a number is not given.
+func parseReplicaNum(r *http.Request) (dpReplicaNum, mpReplicaNum uint8, err error) {
+	parse := func(key string) (num uint8, err error) {
+		value := r.FormValue(key)
+		if value == "" {
+			return
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxReplicaNum {
+			return 0, fmt.Errorf("%v must be between 1 and %v", key, maxReplicaNum)
+		}
+		return uint8(n), nil
+	}
+	if dpReplicaNum, err = parse(dpReplicaNumKey); err != nil {
+		return
+	}
+	mpReplicaNum, err = parse(mpReplicaNumKey)
+	return
+}
+
 func parseRequestToCreateDataPartition(r *http.Request) (count int, name string, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -1076,6 +1681,10 @@ func (m *Server) getVol(w http.ResponseWriter, r *http.Request) {
 		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolAuthKeyNotMatch))
 		return
 	}
+	if err = m.cluster.checkVolToken(vol, r.FormValue(tokenKey), false); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
 	if volView, err = m.getVolView(vol); err != nil {
 		sendErrReply(w, r, newErrHTTPReply(err))
 		return
@@ -1102,7 +1711,13 @@ func (m *Server) getVolStatInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Server) getVolView(vol *Vol) (view *proto.VolView, err error) {
+	return volView(vol), nil
+}
+
+func volView(vol *Vol) (view *proto.VolView) {
 	view = proto.NewVolView(vol.Name, vol.Status)
+	view.MediaType = vol.getMediaType()
+	view.EncryptionMode = vol.encryptionMode
 	setMetaPartitions(vol, view)
 	setDataPartitions(vol, view)
 	return
@@ -0,0 +1,310 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The leader appends an audit record of every mutating request it serves, including the ones denied,
+// through raft, so that the records survive the leader changes. The records are keyed by their time,
+// and the ones older than the retention are trimmed.
+
+const (
+	defaultAuditRetentionDays     = 180
+	defaultIntervalToTrimAuditLog = 3600 // in terms of seconds
+	defaultAuditQueryLimit        = 100
+	maxAuditQueryLimit            = 1000
+	maxAuditReplySize             = 4096 // the code and the message lead the reply
+	auditMask                     = "***"
+)
+
+// unauditedAPIs do not change the cluster, or are called by the nodes periodically.
+// The APIs granted to the viewers are not audited either.
+var unauditedAPIs = map[string]bool{
+	proto.AdminGetIP:              true,
+	proto.AdminGetVol:             true,
+	proto.AdminGetDataPartition:   true,
+	proto.GetDataNodeTaskResponse: true,
+	proto.GetMetaNodeTaskResponse: true,
+	proto.ClientDataPartitions:    true,
+	proto.ClientVol:               true,
+	proto.ClientMetaPartition:     true,
+	proto.ClientVolStat:           true,
+	proto.GetTokenVerifyInfo:      true,
+	proto.ReportLifecycleRun:      true,
+	proto.AdminListUsers:          true,
+	proto.AdminQueryAuditLog:      true,
+}
+
+// auditMaskedParams are the secrets which are not recorded.
+var auditMaskedParams = map[string]bool{
+	volAuthKey: true,
+	tokenKey:   true,
+}
+
+// auditObjectParams identify the object of an operation, in the order of precedence.
+var auditObjectParams = []string{nameKey, volKey, idKey, addrKey}
+
+var auditSeq uint64
+
+func isAudited(path string) bool {
+	return !unauditedAPIs[path] && requiredRole(path) != proto.AdminRoleViewer
+}
+
+// auditIdentity is carried by the context of an audited request, and filled by checkPermission
+// with the admin user authenticated.
+type auditIdentity struct {
+	user string
+}
+
+type auditIdentityKey struct{}
+
+func withAuditIdentity(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), auditIdentityKey{}, new(auditIdentity)))
+}
+
+func setAuditUser(r *http.Request, user string) {
+	if id, ok := r.Context().Value(auditIdentityKey{}).(*auditIdentity); ok {
+		id.user = user
+	}
+}
+
+// auditResponseWriter keeps the head of the reply to record its result.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	reply bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if room := maxAuditReplySize - w.reply.Len(); room > 0 {
+		if room > len(b) {
+			room = len(b)
+		}
+		w.reply.Write(b[:room])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// result returns the code and the message of the reply, without decoding its data which may be truncated.
+func (w *auditResponseWriter) result() (code int32, msg string) {
+	if w.Header().Get("Content-Type") == backupContentType {
+		// replied by a file, which is never followed by a code
+		return proto.ErrCodeSuccess, proto.ErrSuc.Error()
+	}
+	dec := json.NewDecoder(bytes.NewReader(w.reply.Bytes()))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		// replied by http.Error
+		return proto.ErrCodeInternalError, strings.TrimSpace(w.reply.String())
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t {
+		case "code":
+			err = dec.Decode(&code)
+		case "msg":
+			dec.Decode(&msg)
+			return
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func auditKey(nanos int64) string {
+	return auditPrefix + fmt.Sprintf("%020d", nanos)
+}
+
+func newAuditRecord(r *http.Request, w *auditResponseWriter) (rec *proto.AuditRecord) {
+	now := time.Now().UnixNano()
+	rec = &proto.AuditRecord{
+		ID:           fmt.Sprintf("%020d%v%06d", now, underlineSeparator, atomic.AddUint64(&auditSeq, 1)%1000000),
+		Time:         now,
+		RemoteAddr:   r.RemoteAddr,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Operation:    r.URL.Path,
+		Params:       make(map[string]string),
+	}
+	// the user in the header is only trusted once checkPermission has authenticated it
+	if id, ok := r.Context().Value(auditIdentityKey{}).(*auditIdentity); ok && id.user != "" {
+		rec.User, rec.Authenticated = id.user, true
+	} else {
+		rec.ClaimedUser = r.Header.Get(proto.AdminUserHeader)
+	}
+	r.ParseForm()
+	for key, values := range r.Form {
+		if auditMaskedParams[key] {
+			rec.Params[key] = auditMask
+		} else {
+			rec.Params[key] = strings.Join(values, commaSplit)
+		}
+	}
+	for _, key := range auditObjectParams {
+		if value := r.FormValue(key); value != "" {
+			rec.Object = value
+			break
+		}
+	}
+	rec.Code, rec.Result = w.result()
+	return
+}
+
+// auditRecordMatches returns if the record operates the object, which is either the object of the record
+// or one of its parameters, e.g. the address of the replica decommissioned from a partition.
+func auditRecordMatches(rec *proto.AuditRecord, operation, object, user string) bool {
+	if (operation != "" && rec.Operation != operation) || (user != "" && rec.User != user) {
+		return false
+	}
+	if object == "" || rec.Object == object {
+		return true
+	}
+	for _, value := range rec.Params {
+		if value == object {
+			return true
+		}
+	}
+	return false
+}
+
+//key=#audit#time_seq,value=json.Marshal(AuditRecord)
+func (c *Cluster) syncPutAuditRecord(rec *proto.AuditRecord) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutAuditRecord
+	metadata.K = auditPrefix + rec.ID
+	if metadata.V, err = json.Marshal(rec); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+// syncTrimAuditLog deletes the audit records before the key.
+func (c *Cluster) syncTrimAuditLog(key string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncTrimAuditLog
+	metadata.K = key
+	return c.submit(metadata)
+}
+
+func (c *Cluster) audit(r *http.Request, w *auditResponseWriter) {
+	rec := newAuditRecord(r, w)
+	if err := c.syncPutAuditRecord(rec); err != nil {
+		log.LogErrorf("action[audit] user[%v] remoteAddr[%v] operation[%v] object[%v] err[%v]",
+			rec.User, rec.RemoteAddr, rec.Operation, rec.Object, err)
+	}
+}
+
+func (c *Cluster) scheduleToTrimAuditLog() {
+	go func() {
+		for {
+			if c.cfg.AuditRetentionDays > 0 && c.partition != nil && c.partition.IsRaftLeader() {
+				cutoff := time.Now().Add(-time.Duration(c.cfg.AuditRetentionDays) * 24 * time.Hour)
+				if err := c.syncTrimAuditLog(auditKey(cutoff.UnixNano())); err != nil {
+					log.LogErrorf("action[trimAuditLog] err[%v]", err)
+				}
+			}
+			time.Sleep(time.Second * defaultIntervalToTrimAuditLog)
+		}
+	}()
+}
+
+// queryAuditLog returns the records in [from, to) matching the filters, after the given record.
+func (c *Cluster) queryAuditLog(from, to time.Time, after, operation, object, user string, limit int) (page *proto.AuditPage, err error) {
+	page = &proto.AuditPage{Records: make([]*proto.AuditRecord, 0)}
+	start := auditKey(from.UnixNano())
+	if after != "" && auditPrefix+after >= start {
+		// the smallest key after the record
+		start = auditPrefix + after + "\x00"
+	}
+	err = c.fsm.store.RangeScan([]byte(start), []byte(auditKey(to.UnixNano())), func(key string, value []byte) bool {
+		rec := new(proto.AuditRecord)
+		if err := json.Unmarshal(value, rec); err != nil {
+			log.LogErrorf("action[queryAuditLog] key[%v] err[%v]", key, err)
+			return true
+		}
+		if !auditRecordMatches(rec, operation, object, user) {
+			return true
+		}
+		if len(page.Records) == limit {
+			page.More = true
+			return false
+		}
+		page.Records = append(page.Records, rec)
+		return true
+	})
+	return
+}
+
+func parseRequestToQueryAuditLog(r *http.Request) (from, to time.Time, limit int, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	from, to, limit = time.Unix(0, 0), time.Now().Add(time.Second), defaultAuditQueryLimit
+	parseTime := func(key string, t *time.Time) {
+		if value := r.FormValue(key); value != "" && err == nil {
+			var seconds int64
+			if seconds, err = strconv.ParseInt(value, 10, 64); err == nil {
+				*t = time.Unix(seconds, 0)
+			}
+		}
+	}
+	parseTime(fromKey, &from)
+	parseTime(toKey, &to)
+	if err != nil {
+		return
+	}
+	if value := r.FormValue(limitKey); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			return
+		}
+		if limit <= 0 || limit > maxAuditQueryLimit {
+			err = fmt.Errorf("parameter %v should be in (0, %v]", limitKey, maxAuditQueryLimit)
+		}
+	}
+	return
+}
+
+func (m *Server) queryAuditLog(w http.ResponseWriter, r *http.Request) {
+	from, to, limit, err := parseRequestToQueryAuditLog(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	page, err := m.cluster.queryAuditLog(from, to, r.FormValue(afterKey), r.FormValue(operationKey),
+		r.FormValue(objectKey), r.FormValue(userKey), limit)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(page))
+}
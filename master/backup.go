@@ -0,0 +1,349 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/log"
+	raftproto "github.com/tiglabs/raft/proto"
+)
+
+// A metadata backup is a consistent snapshot of the store of the masters, in a file independent of RocksDB.
+// The file starts with a line of the JSON encoded header, followed by the key-value pairs of the store, each one
+// a RaftCmd prefixed by its length in 4 bytes. A zero length ends the pairs, followed by their count in 8 bytes
+// and the CRC32 of everything between the header and the count in 4 bytes.
+
+const (
+	backupMagic            = "chubaofs-master-backup"
+	backupVersion          = 1
+	maxBackupRecordSize    = 64 << 20
+	restoreBatchSize       = 1024
+	backupContentType      = "application/octet-stream"
+	backupFileNameTemplate = "%v_%v.backup" // cluster name and applied index
+)
+
+type backupHeader struct {
+	Magic        string
+	Version      int
+	ClusterName  string
+	AppliedIndex uint64
+	CreateTime   int64
+}
+
+// writeMetadataBackup writes the snapshot of the store to the writer.
+func writeMetadataBackup(w io.Writer, clusterName string, snapshot raftproto.Snapshot) (count uint64, err error) {
+	header, err := json.Marshal(&backupHeader{
+		Magic:        backupMagic,
+		Version:      backupVersion,
+		ClusterName:  clusterName,
+		AppliedIndex: snapshot.ApplyIndex(),
+		CreateTime:   time.Now().Unix(),
+	})
+	if err != nil {
+		return
+	}
+	bw := bufio.NewWriter(w)
+	if _, err = bw.Write(append(header, '\n')); err != nil {
+		return
+	}
+	checksum := crc32.NewIEEE()
+	out := io.MultiWriter(bw, checksum)
+	length := make([]byte, 4)
+	for {
+		var data []byte
+		if data, err = snapshot.Next(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		binary.BigEndian.PutUint32(length, uint32(len(data)))
+		if _, err = out.Write(length); err != nil {
+			return
+		}
+		if _, err = out.Write(data); err != nil {
+			return
+		}
+		count++
+	}
+	binary.BigEndian.PutUint32(length, 0)
+	if _, err = out.Write(length); err != nil {
+		return
+	}
+	trailer := make([]byte, 12)
+	binary.BigEndian.PutUint64(trailer[:8], count)
+	binary.BigEndian.PutUint32(trailer[8:], checksum.Sum32())
+	if _, err = bw.Write(trailer); err != nil {
+		return
+	}
+	err = bw.Flush()
+	return
+}
+
+// readMetadataBackup calls the function on each key-value pair in the backup file, and verifies the
+// integrity of the file at the end.
+func readMetadataBackup(file string, fn func(cmd *RaftCmd) error) (header *backupHeader, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read header of backup[%v],err:%v", file, err)
+	}
+	header = new(backupHeader)
+	if err = json.Unmarshal(line, header); err != nil || header.Magic != backupMagic {
+		return nil, fmt.Errorf("%v is not a metadata backup", file)
+	}
+	if header.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported version[%v] of backup[%v]", header.Version, file)
+	}
+	checksum := crc32.NewIEEE()
+	in := io.TeeReader(br, checksum)
+	length := make([]byte, 4)
+	var count uint64
+	for {
+		if _, err = io.ReadFull(in, length); err != nil {
+			return nil, fmt.Errorf("backup[%v] is truncated after %v records,err:%v", file, count, err)
+		}
+		size := binary.BigEndian.Uint32(length)
+		if size == 0 {
+			break
+		}
+		if size > maxBackupRecordSize {
+			return nil, fmt.Errorf("backup[%v] is corrupted, record[%v] has size[%v]", file, count, size)
+		}
+		data := make([]byte, size)
+		if _, err = io.ReadFull(in, data); err != nil {
+			return nil, fmt.Errorf("backup[%v] is truncated after %v records,err:%v", file, count, err)
+		}
+		cmd := new(RaftCmd)
+		if err = cmd.Unmarshal(data); err != nil {
+			return nil, fmt.Errorf("backup[%v] is corrupted, record[%v] err:%v", file, count, err)
+		}
+		if err = fn(cmd); err != nil {
+			return
+		}
+		count++
+	}
+	sum := checksum.Sum32()
+	trailer := make([]byte, 12)
+	if _, err = io.ReadFull(br, trailer); err != nil {
+		return nil, fmt.Errorf("backup[%v] is truncated,err:%v", file, err)
+	}
+	if binary.BigEndian.Uint64(trailer[:8]) != count || binary.BigEndian.Uint32(trailer[8:]) != sum {
+		return nil, fmt.Errorf("backup[%v] is corrupted, count or checksum mismatches", file)
+	}
+	return
+}
+
+// backupIDs validates the ID allocators in a backup against the IDs allocated to the partitions, the volumes,
+// the nodes, the node sets and the decommission jobs.
+type backupIDs struct {
+	maxDataPartitionID   uint64
+	maxMetaPartitionID   uint64
+	maxCommonID          uint64
+	allocDataPartitionID uint64
+	allocMetaPartitionID uint64
+	allocCommonID        uint64
+}
+
+func (ids *backupIDs) add(cmd *RaftCmd) (err error) {
+	parseID := func(value string) (id uint64) {
+		if err == nil {
+			id, err = strconv.ParseUint(value, 10, 64)
+		}
+		return
+	}
+	keepMax := func(current *uint64, id uint64) {
+		if id > *current {
+			*current = id
+		}
+	}
+	switch cmd.K {
+	case maxDataPartitionIDKey:
+		ids.allocDataPartitionID = parseID(string(cmd.V))
+	case maxMetaPartitionIDKey:
+		ids.allocMetaPartitionID = parseID(string(cmd.V))
+	case maxCommonIDKey:
+		ids.allocCommonID = parseID(string(cmd.V))
+	}
+	keyArr := strings.Split(cmd.K, keySeparator)
+	if len(keyArr) < 3 {
+		return
+	}
+	switch keyArr[1] {
+	case dataPartitionAcronym:
+		if len(keyArr) > 3 {
+			keepMax(&ids.maxDataPartitionID, parseID(keyArr[3]))
+		}
+	case metaPartitionAcronym:
+		if len(keyArr) > 3 {
+			keepMax(&ids.maxMetaPartitionID, parseID(keyArr[3]))
+		}
+	case volAcronym, nodeSetAcronym, dataNodeAcronym, metaNodeAcronym, decommissionAcronym:
+		keepMax(&ids.maxCommonID, parseID(keyArr[2]))
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid ID in key[%v],err:%v", cmd.K, err)
+	}
+	return
+}
+
+func (ids *backupIDs) validate() (err error) {
+	if ids.allocDataPartitionID < ids.maxDataPartitionID {
+		return fmt.Errorf("data partition ID allocator[%v] is behind data partition[%v]", ids.allocDataPartitionID, ids.maxDataPartitionID)
+	}
+	if ids.allocMetaPartitionID < ids.maxMetaPartitionID {
+		return fmt.Errorf("meta partition ID allocator[%v] is behind meta partition[%v]", ids.allocMetaPartitionID, ids.maxMetaPartitionID)
+	}
+	if ids.allocCommonID < ids.maxCommonID {
+		return fmt.Errorf("common ID allocator[%v] is behind ID[%v]", ids.allocCommonID, ids.maxCommonID)
+	}
+	return
+}
+
+// allocators returns the ID allocators moved forward by the gap, so that the IDs allocated after the backup,
+// which are still used by the partitions and the volumes on the nodes, are not allocated again.
+func (ids *backupIDs) allocators(gap uint64) (values map[string][]byte, err error) {
+	if gap == 0 {
+		return nil, fmt.Errorf("ID gap is required to skip the IDs allocated after the backup")
+	}
+	values = make(map[string][]byte)
+	for key, id := range map[string]uint64{
+		maxDataPartitionIDKey: ids.allocDataPartitionID,
+		maxMetaPartitionIDKey: ids.allocMetaPartitionID,
+		maxCommonIDKey:        ids.allocCommonID,
+	} {
+		if id+gap < id {
+			return nil, fmt.Errorf("ID allocator %v[%v] overflows with gap[%v]", key, id, gap)
+		}
+		values[key] = []byte(strconv.FormatUint(id+gap, 10))
+	}
+	return
+}
+
+func checkEmptyDir(dir string) (err error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%v is not empty", dir)
+	}
+	return
+}
+
+// RestoreMetadata seeds the store of a master in a new raft group from the backup file, with the cluster name,
+// the store and the raft log directories in the config. It refuses to overwrite any existing store or raft log.
+// Every master of the new group is restored from the same file with the same ID gap before its first start,
+// and the raft log starts from scratch. The ID allocators are moved forward by the gap, which has to be larger
+// than the number of the IDs allocated since the backup.
+func RestoreMetadata(cfg *config.Config, file string, idGap uint64) (err error) {
+	clusterName, storeDir, walDir := cfg.GetString(ClusterName), cfg.GetString(StoreDir), cfg.GetString(WalDir)
+	if clusterName == "" || storeDir == "" || walDir == "" {
+		return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, "one of (walDir,storeDir,clusterName) is null")
+	}
+	for _, dir := range []string{storeDir, walDir} {
+		if err = checkEmptyDir(dir); err != nil {
+			return
+		}
+	}
+	ids := new(backupIDs)
+	header, err := readMetadataBackup(file, ids.add)
+	if err != nil {
+		return
+	}
+	if header.ClusterName != clusterName {
+		return fmt.Errorf("backup of cluster[%v] can not be restored to cluster[%v]", header.ClusterName, clusterName)
+	}
+	if err = ids.validate(); err != nil {
+		return
+	}
+	allocators, err := ids.allocators(idGap)
+	if err != nil {
+		return
+	}
+
+	store := raftstore.NewRocksDBStore(storeDir, LRUCacheSize, WriteBufferSize)
+	defer store.Close()
+	var count int
+	batch := make(map[string][]byte, restoreBatchSize)
+	if _, err = readMetadataBackup(file, func(cmd *RaftCmd) error {
+		// the applied index belongs to the raft log of the old group
+		if cmd.K == applied {
+			return nil
+		}
+		batch[cmd.K] = cmd.V
+		count++
+		if len(batch) < restoreBatchSize {
+			return nil
+		}
+		defer func() { batch = make(map[string][]byte, restoreBatchSize) }()
+		return store.BatchPut(batch, false)
+	}); err != nil {
+		return
+	}
+	for key, value := range allocators {
+		batch[key] = value
+	}
+	if err = store.BatchPut(batch, true); err != nil {
+		return
+	}
+	log.LogWarnf("action[RestoreMetadata] cluster[%v] restored %v keys from backup[%v] of applied index[%v] created at %v, ID gap[%v]",
+		clusterName, count, file, header.AppliedIndex, time.Unix(header.CreateTime, 0).Format(time.RFC3339), idGap)
+	return
+}
+
+// backupMetadata streams a consistent snapshot of the store as a backup file.
+func (m *Server) backupMetadata(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := m.fsm.Snapshot()
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	defer snapshot.Close()
+	w.Header().Set("Content-Type", backupContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+fmt.Sprintf(backupFileNameTemplate, m.clusterName, snapshot.ApplyIndex()))
+	count, err := writeMetadataBackup(w, m.clusterName, snapshot)
+	if err != nil {
+		// the reply has started, the truncated file fails the verification when restored
+		Warn(m.clusterName, fmt.Sprintf("action[backupMetadata] clusterID[%v] remoteAddr[%v] failed after %v records,err[%v]",
+			m.clusterName, r.RemoteAddr, count, err))
+		return
+	}
+	log.LogInfof("action[backupMetadata] clusterID[%v] remoteAddr[%v] applied[%v] records[%v]",
+		m.clusterName, r.RemoteAddr, snapshot.ApplyIndex(), count)
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+type testSnapshot struct {
+	index   uint64
+	records [][]byte
+}
+
+func (s *testSnapshot) Next() (data []byte, err error) {
+	if len(s.records) == 0 {
+		return nil, io.EOF
+	}
+	data, s.records = s.records[0], s.records[1:]
+	return
+}
+
+func (s *testSnapshot) ApplyIndex() uint64 {
+	return s.index
+}
+
+func (s *testSnapshot) Close() {}
+
+func newTestSnapshot(t *testing.T, index uint64, cmds []*RaftCmd) *testSnapshot {
+	s := &testSnapshot{index: index}
+	for _, cmd := range cmds {
+		data, err := cmd.Marshal()
+		if err != nil {
+			t.Fatalf("marshal cmd[%v] err[%v]", cmd.K, err)
+		}
+		s.records = append(s.records, data)
+	}
+	return s
+}
+
+// writeTestBackup writes the backup of the commands into a file in the directory, and returns the file.
+func writeTestBackup(t *testing.T, dir string, cmds []*RaftCmd) (file string) {
+	buf := new(bytes.Buffer)
+	count, err := writeMetadataBackup(buf, "test", newTestSnapshot(t, 100, cmds))
+	if err != nil {
+		t.Fatalf("write backup err[%v]", err)
+	}
+	if count != uint64(len(cmds)) {
+		t.Fatalf("backup has %v records, expected %v", count, len(cmds))
+	}
+	file = path.Join(dir, "test.backup")
+	if err = ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write file err[%v]", err)
+	}
+	return
+}
+
+func testBackupCmds() []*RaftCmd {
+	return []*RaftCmd{
+		{Op: opSyncAllocDataPartitionID, K: maxDataPartitionIDKey, V: []byte("12")},
+		{Op: opSyncAllocMetaPartitionID, K: maxMetaPartitionIDKey, V: []byte("3")},
+		{Op: opSyncAllocCommonID, K: maxCommonIDKey, V: []byte("7")},
+		{Op: opSyncAddVol, K: volPrefix + "7", V: []byte(`{"Name":"vol"}`)},
+		{Op: opSyncAddDataPartition, K: dataPartitionPrefix + "7" + keySeparator + "12", V: []byte(`{"PartitionID":12}`)},
+		{Op: opSyncAddMetaPartition, K: metaPartitionPrefix + "7" + keySeparator + "3", V: []byte(`{"PartitionID":3}`)},
+		{K: applied, V: []byte("100")},
+	}
+}
+
+func TestMetadataBackupRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmds := testBackupCmds()
+	file := writeTestBackup(t, dir, cmds)
+
+	ids := new(backupIDs)
+	read := make([]*RaftCmd, 0)
+	header, err := readMetadataBackup(file, func(cmd *RaftCmd) error {
+		read = append(read, cmd)
+		return ids.add(cmd)
+	})
+	if err != nil {
+		t.Fatalf("read backup err[%v]", err)
+	}
+	if header.ClusterName != "test" || header.AppliedIndex != 100 {
+		t.Fatalf("unexpected header %v", header)
+	}
+	if len(read) != len(cmds) {
+		t.Fatalf("read %v records, expected %v", len(read), len(cmds))
+	}
+	for i, cmd := range cmds {
+		if read[i].Op != cmd.Op || read[i].K != cmd.K || !bytes.Equal(read[i].V, cmd.V) {
+			t.Fatalf("record[%v] is %v, expected %v", i, read[i], cmd)
+		}
+	}
+	if err = ids.validate(); err != nil {
+		t.Fatalf("validate IDs err[%v]", err)
+	}
+}
+
+func TestTruncatedMetadataBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeTestBackup(t, dir, testBackupCmds())
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for size := 0; size < len(data); size++ {
+		if err = ioutil.WriteFile(file, data[:size], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = readMetadataBackup(file, func(cmd *RaftCmd) error { return nil }); err == nil {
+			t.Fatalf("backup truncated to %v of %v bytes is read", size, len(data))
+		}
+	}
+}
+
+func TestCorruptedMetadataBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeTestBackup(t, dir, testBackupCmds())
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every byte after the header is covered by the lengths, the count or the checksum
+	for i := bytes.IndexByte(data, '\n') + 1; i < len(data); i++ {
+		corrupted := append([]byte{}, data...)
+		corrupted[i] ^= 0x01
+		if err = ioutil.WriteFile(file, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = readMetadataBackup(file, func(cmd *RaftCmd) error { return nil }); err == nil {
+			t.Fatalf("backup corrupted at byte %v of %v is read", i, len(data))
+		}
+	}
+}
+
+func TestMetadataBackupIDAllocatorBehind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, behind := range []*RaftCmd{
+		{Op: opSyncAllocDataPartitionID, K: maxDataPartitionIDKey, V: []byte("11")},
+		{Op: opSyncAllocMetaPartitionID, K: maxMetaPartitionIDKey, V: []byte("2")},
+		{Op: opSyncAllocCommonID, K: maxCommonIDKey, V: []byte("6")},
+	} {
+		cmds := testBackupCmds()
+		for i, cmd := range cmds {
+			if cmd.K == behind.K {
+				cmds[i] = behind
+			}
+		}
+		file := writeTestBackup(t, dir, cmds)
+		ids := new(backupIDs)
+		if _, err = readMetadataBackup(file, ids.add); err != nil {
+			t.Fatalf("read backup err[%v]", err)
+		}
+		if err = ids.validate(); err == nil {
+			t.Fatalf("allocator %v[%s] behind the IDs is accepted", behind.K, behind.V)
+		}
+	}
+}
+
+func TestMetadataBackupIDGap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeTestBackup(t, dir, testBackupCmds())
+	ids := new(backupIDs)
+	if _, err = readMetadataBackup(file, ids.add); err != nil {
+		t.Fatalf("read backup err[%v]", err)
+	}
+	if _, err = ids.allocators(0); err == nil {
+		t.Fatalf("restore without an ID gap is accepted")
+	}
+	allocators, err := ids.allocators(1000)
+	if err != nil {
+		t.Fatalf("allocators err[%v]", err)
+	}
+	for key, expected := range map[string]string{maxDataPartitionIDKey: "1012", maxMetaPartitionIDKey: "1003", maxCommonIDKey: "1007"} {
+		if string(allocators[key]) != expected {
+			t.Fatalf("allocator %v[%s], expected %v", key, allocators[key], expected)
+		}
+	}
+	if _, err = ids.allocators(^uint64(0)); err == nil {
+		t.Fatalf("ID gap overflowing the allocators is accepted")
+	}
+}
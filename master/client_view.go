@@ -0,0 +1,279 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	defaultClientViewMaxStaleness       = 30 // in terms of seconds
+	defaultIntervalToPublishClientViews = 5  // in terms of seconds
+)
+
+// clientViewValue holds the views polled by the clients of a volume. The leader publishes it through raft,
+// so that the followers can answer the polls from their applied state instead of proxying them to the leader.
+// The stat of the volume changes with almost every heartbeat, so it is not published, and its polls are
+// always proxied to the leader.
+type clientViewValue struct {
+	VolName        string
+	Owner          string
+	TokenRequired  bool  // the followers proxy the mounts of the volumes requiring the tokens to the leader
+	Time           int64 // unix time when the leader built the views
+	VolView        json.RawMessage
+	DataPartitions json.RawMessage
+}
+
+// clientViewLease is published by the leader after all the client views are up to date, so that the
+// followers know how old the views are without the leader publishing the unchanged views again.
+type clientViewLease struct {
+	Time int64 // unix time when the leader started building the views
+}
+
+// clientViewPublisher records what the leader has published.
+type clientViewPublisher struct {
+	crcs      map[string]uint32 // volume name -> crc of the last client views published
+	leaseTime int64
+}
+
+func newClientViewPublisher() *clientViewPublisher {
+	return &clientViewPublisher{crcs: make(map[string]uint32)}
+}
+
+func newClientViewValue(vol *Vol) (cv *clientViewValue, err error) {
+	cv = &clientViewValue{VolName: vol.Name, Owner: vol.Owner, TokenRequired: vol.isTokenRequired(), Time: time.Now().Unix()}
+	// the partitions are sorted, so that the views only change with the partitions, their hosts, leaders or status
+	view := volView(vol)
+	sort.Slice(view.MetaPartitions, func(i, j int) bool {
+		return view.MetaPartitions[i].PartitionID < view.MetaPartitions[j].PartitionID
+	})
+	sort.Slice(view.DataPartitions, func(i, j int) bool {
+		return view.DataPartitions[i].PartitionID < view.DataPartitions[j].PartitionID
+	})
+	if cv.VolView, err = json.Marshal(view); err != nil {
+		return
+	}
+	// the followers proxy the poll to the leader if the volume has no data partition available
+	if len(view.DataPartitions) > 0 {
+		dpView := proto.NewDataPartitionsView()
+		dpView.DataPartitions = view.DataPartitions
+		cv.DataPartitions, err = json.Marshal(dpView)
+	}
+	return
+}
+
+func (cv *clientViewValue) crc() uint32 {
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(cv.Owner))
+	crc.Write([]byte(strconv.FormatBool(cv.TokenRequired)))
+	crc.Write(cv.VolView)
+	crc.Write(cv.DataPartitions)
+	return crc.Sum32()
+}
+
+//key=#cv#volName,value=json.Marshal(cv)
+func (c *Cluster) syncPutClientView(cv *clientViewValue) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutClientView
+	metadata.K = clientViewPrefix + cv.VolName
+	if metadata.V, err = json.Marshal(cv); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteClientView(volName string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteClientView
+	metadata.K = clientViewPrefix + volName
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncPutClientViewLease(lease *clientViewLease) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutClientViewLease
+	metadata.K = clientViewLeaseKey
+	if metadata.V, err = json.Marshal(lease); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (m *Server) scheduleToPublishClientViews() {
+	go func() {
+		publisher := newClientViewPublisher()
+		for {
+			if m.config.ClientViewMaxStaleness > 0 && m.partition != nil && m.partition.IsRaftLeader() && m.metaReady {
+				m.cluster.publishClientViews(publisher)
+			} else if publisher.leaseTime != 0 {
+				publisher = newClientViewPublisher()
+			}
+			time.Sleep(time.Second * defaultIntervalToPublishClientViews)
+		}
+	}()
+}
+
+// publishClientViews publishes the client views of the volumes which have changed since the last time,
+// and then the lease if the views have changed or the lease is about to get too old. The lease is not
+// renewed while some views fail to be published, so that the followers stop serving them in time.
+func (c *Cluster) publishClientViews(publisher *clientViewPublisher) {
+	var (
+		now     = time.Now().Unix()
+		vols    = c.copyVols()
+		changed bool
+		failed  bool
+	)
+	for _, vol := range vols {
+		cv, err := newClientViewValue(vol)
+		if err != nil {
+			log.LogErrorf("action[publishClientViews] vol[%v] err[%v]", vol.Name, err)
+			failed = true
+			continue
+		}
+		crc := cv.crc()
+		if last, ok := publisher.crcs[vol.Name]; ok && last == crc {
+			continue
+		}
+		if err = c.syncPutClientView(cv); err != nil {
+			log.LogErrorf("action[publishClientViews] vol[%v] err[%v]", vol.Name, err)
+			failed = true
+			continue
+		}
+		publisher.crcs[vol.Name] = crc
+		changed = true
+	}
+	if len(vols) == 0 {
+		return
+	}
+	result, err := c.fsm.store.SeekForPrefix([]byte(clientViewPrefix))
+	if err != nil {
+		log.LogErrorf("action[publishClientViews] err[%v]", err)
+		return
+	}
+	for key := range result {
+		volName := strings.TrimPrefix(key, clientViewPrefix)
+		if _, ok := vols[volName]; ok {
+			continue
+		}
+		if err = c.syncDeleteClientView(volName); err != nil {
+			log.LogErrorf("action[publishClientViews] delete vol[%v] err[%v]", volName, err)
+			failed = true
+			continue
+		}
+		delete(publisher.crcs, volName)
+	}
+	if failed || !changed && now-publisher.leaseTime < c.cfg.ClientViewMaxStaleness/2 {
+		return
+	}
+	if err = c.syncPutClientViewLease(&clientViewLease{Time: now}); err != nil {
+		log.LogErrorf("action[publishClientViews] lease err[%v]", err)
+		return
+	}
+	publisher.leaseTime = now
+}
+
+// clientViewStaleness returns the seconds since the leader last confirmed the client views published,
+// or false if the leader has not published them.
+func (m *Server) clientViewStaleness() (staleness int64, ok bool) {
+	value, err := m.fsm.store.Get(clientViewLeaseKey)
+	if err != nil || len(value.([]byte)) == 0 {
+		return
+	}
+	lease := new(clientViewLease)
+	if err = json.Unmarshal(value.([]byte), lease); err != nil {
+		log.LogErrorf("action[clientViewStaleness] err[%v]", err)
+		return
+	}
+	return time.Now().Unix() - lease.Time, true
+}
+
+func isClientView(path string) bool {
+	switch path {
+	case proto.ClientVol, proto.ClientDataPartitions, proto.ClientVolStat:
+		return true
+	}
+	return false
+}
+
+func (m *Server) setClientViewHeader(w http.ResponseWriter, staleness int64) {
+	w.Header().Set(proto.MasterAppliedIndexHeader, strconv.FormatUint(m.fsm.applied, 10))
+	w.Header().Set(proto.MasterViewStalenessHeader, strconv.FormatInt(staleness, 10))
+	w.Header().Set(proto.MasterMaxStalenessHeader, strconv.FormatInt(m.config.ClientViewMaxStaleness, 10))
+}
+
+// serveClientView answers a client view on a follower from the views published by the leader.
+// It returns false if the follower has no view of the volume younger than the staleness bound,
+// in which case the request has to be proxied to the leader.
+func (m *Server) serveClientView(w http.ResponseWriter, r *http.Request) (served bool) {
+	if m.config.ClientViewMaxStaleness <= 0 || r.URL.Path == proto.ClientVolStat {
+		return false
+	}
+	name, err := parseAndExtractName(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return true
+	}
+	staleness, ok := m.clientViewStaleness()
+	if !ok {
+		return false
+	}
+	if staleness > m.config.ClientViewMaxStaleness {
+		log.LogWarnf("action[serveClientView] client views are stale for %v seconds", staleness)
+		return false
+	}
+	value, err := m.fsm.store.Get(clientViewPrefix + name)
+	if err != nil || len(value.([]byte)) == 0 {
+		return false
+	}
+	cv := new(clientViewValue)
+	if err = json.Unmarshal(value.([]byte), cv); err != nil {
+		log.LogErrorf("action[serveClientView] vol[%v] err[%v]", name, err)
+		return false
+	}
+	var data json.RawMessage
+	switch r.URL.Path {
+	case proto.ClientVol:
+		if cv.TokenRequired || r.FormValue(tokenKey) != "" {
+			return false
+		}
+		var authKey string
+		if authKey, err = extractAuthKey(r); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return true
+		}
+		if !matchKey(cv.Owner, authKey) {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrVolAuthKeyNotMatch))
+			return true
+		}
+		data = cv.VolView
+	case proto.ClientDataPartitions:
+		data = cv.DataPartitions
+	}
+	if len(data) == 0 {
+		return false
+	}
+	m.setClientViewHeader(w, staleness)
+	sendOkReply(w, r, newSuccessHTTPReply(data))
+	return true
+}
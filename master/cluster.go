@@ -15,6 +15,7 @@
 package master
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
@@ -45,7 +46,15 @@ type Cluster struct {
 	metaNodeStatInfo    *nodeStatInfo
 	volStatInfo         sync.Map
 	BadDataPartitionIds *sync.Map
+	rebalancer          *rebalancer
 	DisableAutoAllocate bool
+	signingKey          ed25519.PrivateKey // signs the volume tokens
+	signingKeyMutex     sync.Mutex
+	users               map[string]*adminUser // the admin users, without the root user
+	usersMutex          sync.RWMutex
+	nonces              *signatureNonces // the nonces of the signed admin requests, to reject the replayed ones
+	taskQueue           *adminTaskQueue  // the admin tasks sent to the nodes
+	decommissionJobs    *decommissionJobs
 	fsm                 *MetadataFsm
 	partition           raftstore.Partition
 }
@@ -55,6 +64,10 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.Name = name
 	c.leaderInfo = leaderInfo
 	c.vols = make(map[string]*Vol, 0)
+	c.users = make(map[string]*adminUser)
+	c.nonces = newSignatureNonces()
+	c.taskQueue = newAdminTaskQueue()
+	c.decommissionJobs = newDecommissionJobs()
 	c.cfg = cfg
 	c.t = newTopology()
 	c.BadDataPartitionIds = new(sync.Map)
@@ -63,6 +76,9 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.fsm = fsm
 	c.partition = partition
 	c.idAlloc = newIDAllocator(c.fsm.store, c.partition)
+	c.rebalancer = newRebalancer(c)
+	c.rebalancer.threshold = cfg.RebalanceThreshold
+	c.rebalancer.concurrency = cfg.RebalanceConcurrency
 	return
 }
 
@@ -76,7 +92,13 @@ func (c *Cluster) scheduleTask() {
 	c.scheduleToCheckAutoDataPartitionCreation()
 	c.scheduleToCheckVolStatus()
 	c.scheduleToCheckDiskRecoveryProgress()
+	c.scheduleToRebalance()
+	c.scheduleToChangeReplicaNum()
 	c.startCheckLoadMetaPartitions()
+	c.scheduleToTrimAuditLog()
+	c.scheduleToCheckAdminTasks()
+	c.scheduleToDecommission()
+	c.scheduleToSampleUsage()
 }
 
 func (c *Cluster) masterAddr() (addr string) {
@@ -213,12 +235,27 @@ func (c *Cluster) checkLeaderAddr() {
 	c.leaderInfo.addr = AddrDatabase[leaderID]
 }
 
+// Returns the qos limits of the limited volumes, which are pushed to the nodes through the heartbeats.
+func (c *Cluster) volQoS() (volQoS map[string]*proto.VolQoS) {
+	volQoS = make(map[string]*proto.VolQoS)
+	for name, vol := range c.allVols() {
+		if qos := vol.getQoS(); !qos.IsZero() {
+			volQoS[name] = &qos
+		}
+	}
+	return
+}
+
 func (c *Cluster) checkDataNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volQoS := c.volQoS()
+	volSpace, grants := c.volSpace()
+	tokenVerify := c.tokenVerifyInfo()
+	tokenVerify.ServiceTokens = c.issueServiceTokens(tokenVerify.EnforcedVols)
 	c.dataNodes.Range(func(addr, dataNode interface{}) bool {
 		node := dataNode.(*DataNode)
 		node.checkLiveness()
-		task := node.createHeartbeatTask(c.masterAddr())
+		task := node.createHeartbeatTask(c.masterAddr(), volQoS, dataNodeVolSpace(volSpace, grants[node.Addr]), tokenVerify)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -227,10 +264,14 @@ func (c *Cluster) checkDataNodeHeartbeat() {
 
 func (c *Cluster) checkMetaNodeHeartbeat() {
 	tasks := make([]*proto.AdminTask, 0)
+	volQoS := c.volQoS()
+	volSpace, _ := c.volSpace()
+	tokenVerify := c.tokenVerifyInfo()
+	tokenVerify.ServiceTokens = c.issueServiceTokens(tokenVerify.EnforcedVols)
 	c.metaNodes.Range(func(addr, metaNode interface{}) bool {
 		node := metaNode.(*MetaNode)
 		node.checkHeartbeat()
-		task := node.createHeartbeatTask(c.masterAddr())
+		task := node.createHeartbeatTask(c.masterAddr(), volQoS, volSpace, tokenVerify)
 		tasks = append(tasks, task)
 		return true
 	})
@@ -255,15 +296,20 @@ func (c *Cluster) checkMetaPartitions() {
 	}
 }
 
-func (c *Cluster) addMetaNode(nodeAddr string) (id uint64, err error) {
+func (c *Cluster) addMetaNode(nodeAddr, zoneName, rackName string) (id uint64, err error) {
 	c.mnMutex.Lock()
 	defer c.mnMutex.Unlock()
 	var metaNode *MetaNode
 	if value, ok := c.metaNodes.Load(nodeAddr); ok {
 		metaNode = value.(*MetaNode)
+		if err = c.updateMetaNodeLabels(metaNode, zoneName, rackName); err != nil {
+			return
+		}
 		return metaNode.ID, nil
 	}
 	metaNode = newMetaNode(nodeAddr, c.Name)
+	metaNode.ZoneName = zoneName
+	metaNode.RackName = rackName
 	ns := c.t.getAvailNodeSetForMetaNode()
 	if ns == nil {
 		if ns, err = c.createNodeSet(); err != nil {
@@ -308,16 +354,21 @@ func (c *Cluster) createNodeSet() (ns *nodeSet, err error) {
 	return
 }
 
-func (c *Cluster) addDataNode(nodeAddr string) (id uint64, err error) {
+func (c *Cluster) addDataNode(nodeAddr, zoneName, rackName string) (id uint64, err error) {
 	c.dnMutex.Lock()
 	defer c.dnMutex.Unlock()
 	var dataNode *DataNode
 	if node, ok := c.dataNodes.Load(nodeAddr); ok {
 		dataNode = node.(*DataNode)
+		if err = c.updateDataNodeZone(dataNode, zoneName); err != nil {
+			return
+		}
 		return dataNode.ID, nil
 	}
 
 	dataNode = newDataNode(nodeAddr, c.Name)
+	dataNode.ZoneName = zoneName
+	dataNode.RackName = rackName
 	ns := c.t.getAvailNodeSetForDataNode()
 	if ns == nil {
 		if ns, err = c.createNodeSet(); err != nil {
@@ -349,6 +400,70 @@ errHandler:
 	return
 }
 
+// The zone of a data node is persisted, and the rack is reported by the heartbeats.
+func (c *Cluster) updateDataNodeZone(dataNode *DataNode, zoneName string) (err error) {
+	dataNode.RLock()
+	oldZone := dataNode.ZoneName
+	dataNode.RUnlock()
+	if oldZone == zoneName {
+		return
+	}
+	dataNode.Lock()
+	dataNode.ZoneName = zoneName
+	dataNode.Unlock()
+	if err = c.syncAddDataNode(dataNode); err != nil {
+		dataNode.Lock()
+		dataNode.ZoneName = oldZone
+		dataNode.Unlock()
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] zone from [%v] to [%v]", c.Name, dataNode.Addr, oldZone, zoneName))
+	return
+}
+
+// A data node asked to drain creates no new extents, gives away the raft leadership of its partitions,
+// and its data partitions become read only. The flag is pushed to the data node by the heartbeats.
+func (c *Cluster) setDataNodeDrain(dataNode *DataNode, drain bool) (err error) {
+	dataNode.RLock()
+	oldDrain := dataNode.ToBeDrained
+	dataNode.RUnlock()
+	if oldDrain == drain {
+		return
+	}
+	dataNode.Lock()
+	dataNode.ToBeDrained = drain
+	dataNode.Unlock()
+	if err = c.syncAddDataNode(dataNode); err != nil {
+		dataNode.Lock()
+		dataNode.ToBeDrained = oldDrain
+		dataNode.Unlock()
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] drain from [%v] to [%v]", c.Name, dataNode.Addr, oldDrain, drain))
+	return
+}
+
+func (c *Cluster) updateMetaNodeLabels(metaNode *MetaNode, zoneName, rackName string) (err error) {
+	metaNode.RLock()
+	oldZone, oldRack := metaNode.ZoneName, metaNode.RackName
+	metaNode.RUnlock()
+	if oldZone == zoneName && oldRack == rackName {
+		return
+	}
+	metaNode.Lock()
+	metaNode.ZoneName, metaNode.RackName = zoneName, rackName
+	metaNode.Unlock()
+	if err = c.syncAddMetaNode(metaNode); err != nil {
+		metaNode.Lock()
+		metaNode.ZoneName, metaNode.RackName = oldZone, oldRack
+		metaNode.Unlock()
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] metaNode[%v] zone from [%v] to [%v], rack from [%v] to [%v]",
+		c.Name, metaNode.Addr, oldZone, zoneName, oldRack, rackName))
+	return
+}
+
 func (c *Cluster) getDataPartitionByID(partitionID uint64) (dp *DataPartition, err error) {
 	vols := c.copyVols()
 	for _, vol := range vols {
@@ -396,7 +511,7 @@ func (c *Cluster) deleteVol(name string) {
 	return
 }
 
-func (c *Cluster) markDeleteVol(name, authKey string) (err error) {
+func (c *Cluster) markDeleteVol(name, authKey, token string) (err error) {
 	var (
 		vol           *Vol
 		serverAuthKey string
@@ -409,6 +524,9 @@ func (c *Cluster) markDeleteVol(name, authKey string) (err error) {
 	if !matchKey(serverAuthKey, authKey) {
 		return proto.ErrVolAuthKeyNotMatch
 	}
+	if err = c.checkVolToken(vol, token, true); err != nil {
+		return
+	}
 
 	vol.Status = markDelete
 	if err = c.syncUpdateVol(vol); err != nil {
@@ -424,7 +542,7 @@ func (c *Cluster) markDeleteVol(name, authKey string) (err error) {
 // 3. Communicate with the data node to synchronously create a data partition.
 // - If succeeded, replicate the data through raft and persist it to RocksDB.
 // - Otherwise, throw errors
-func (c *Cluster) createDataPartition(volName string) (dp *DataPartition, err error) {
+func (c *Cluster) createDataPartition(volName string, mediaType uint8) (dp *DataPartition, err error) {
 	var (
 		vol         *Vol
 		partitionID uint64
@@ -438,13 +556,14 @@ func (c *Cluster) createDataPartition(volName string) (dp *DataPartition, err er
 		return
 	}
 	errChannel := make(chan error, vol.dpReplicaNum)
-	if targetHosts, targetPeers, err = c.chooseTargetDataNodes(int(vol.dpReplicaNum)); err != nil {
+	if targetHosts, targetPeers, err = c.chooseTargetDataNodes(nil, nil, int(vol.dpReplicaNum), vol.getPlacement(), mediaType); err != nil {
 		goto errHandler
 	}
 	if partitionID, err = c.idAlloc.allocateDataPartitionID(); err != nil {
 		goto errHandler
 	}
 	dp = newDataPartition(partitionID, vol.dpReplicaNum, volName, vol.ID)
+	dp.MediaType = mediaType
 	dp.Hosts = targetHosts
 	dp.Peers = targetPeers
 	for _, host := range targetHosts {
@@ -488,7 +607,8 @@ func (c *Cluster) createDataPartition(volName string) (dp *DataPartition, err er
 		goto errHandler
 	}
 	vol.dataPartitions.put(dp)
-	log.LogInfof("action[createDataPartition] success,volName[%v],partitionId[%v]", volName, partitionID)
+	log.LogInfof("action[createDataPartition] success,volName[%v],partitionId[%v],mediaType[%v]", volName, partitionID,
+		proto.MediaTypeString(mediaType))
 	return
 errHandler:
 	err = fmt.Errorf("action[createDataPartition],clusterID[%v] vol[%v] Err:%v ", c.Name, volName, err.Error())
@@ -515,6 +635,106 @@ func (c *Cluster) syncCreateDataPartitionToDataNode(host string, size uint64, dp
 	return string(replicaDiskPath), nil
 }
 
+// syncChangeDataPartitionRaftMember sends the task to change a raft member of a data partition,
+// and waits until the change has been committed.
+func (c *Cluster) syncChangeDataPartitionRaftMember(task *proto.AdminTask) (err error) {
+	dataNode, err := c.dataNode(task.OperatorAddr)
+	if err != nil {
+		return
+	}
+	conn, err := dataNode.TaskManager.connPool.GetConnect(dataNode.Addr)
+	if err != nil {
+		return
+	}
+	if _, err = dataNode.TaskManager.syncSendAdminTask(task, conn); err != nil {
+		return
+	}
+	dataNode.TaskManager.connPool.PutConnect(conn, false)
+	return
+}
+
+// addDataPartitionReplica creates a new replica of the partition on the data node, and adds it into
+// the raft group of the partition. The caller must hold the lock of the partition.
+func (c *Cluster) addDataPartitionReplica(dp *DataPartition, vol *Vol, dataNode *DataNode) (err error) {
+	var (
+		task     *proto.AdminTask
+		diskPath string
+	)
+	addPeer := proto.Peer{ID: dataNode.ID, Addr: dataNode.Addr}
+	if err = dp.addHostAndPeer(addPeer, c); err != nil {
+		return
+	}
+	if diskPath, err = c.syncCreateDataPartitionToDataNode(dataNode.Addr, vol.dataPartitionSize, dp); err != nil {
+		goto errHandler
+	}
+	if err = dp.afterCreation(dataNode.Addr, diskPath, c); err != nil {
+		goto errHandler
+	}
+	if task, err = dp.createTaskToChangeRaftMember(proto.OpAddDataPartitionRaftMember, proto.Peer{}, addPeer); err != nil {
+		goto errHandler
+	}
+	if err = c.syncChangeDataPartitionRaftMember(task); err != nil {
+		goto errHandler
+	}
+	return
+
+errHandler:
+	dp.removeReplicaByAddr(dataNode.Addr)
+	c.addDataNodeTasks([]*proto.AdminTask{dp.createTaskToDeleteDataPartition(dataNode.Addr)})
+	if rollbackErr := dp.removeHostAndPeer(dataNode.Addr, c); rollbackErr != nil {
+		log.LogErrorf("action[addDataPartitionReplica] partitionID[%v] rollback host[%v] err[%v]", dp.PartitionID, dataNode.Addr, rollbackErr)
+	}
+	return
+}
+
+// removeDataPartitionReplica removes the replica from the raft group and the host list of the partition.
+// The data node deletes its replica itself once it has been removed from the raft group.
+func (c *Cluster) removeDataPartitionReplica(dp *DataPartition, addr string) (err error) {
+	var (
+		dataNode *DataNode
+		task     *proto.AdminTask
+	)
+	if dataNode, err = c.dataNode(addr); err != nil {
+		return
+	}
+	dp.Lock()
+	defer dp.Unlock()
+	if !dp.hasHost(addr) {
+		return
+	}
+	removePeer := proto.Peer{ID: dataNode.ID, Addr: addr}
+	if task, err = dp.createTaskToChangeRaftMember(proto.OpRemoveDataPartitionRaftMember, removePeer, proto.Peer{}); err != nil {
+		return
+	}
+	if err = c.syncChangeDataPartitionRaftMember(task); err != nil {
+		return errors.Trace(err, "remove raft member[%v] of partition[%v] failed", addr, dp.PartitionID)
+	}
+	if err = dp.removeHostAndPeer(addr, c); err != nil {
+		return
+	}
+	dp.removeReplicaByAddr(addr)
+	dp.checkAndRemoveMissReplica(addr)
+	return
+}
+
+// syncChangeMetaPartitionRaftMember sends the task to change a raft member of a meta partition,
+// and waits until the change has been committed.
+func (c *Cluster) syncChangeMetaPartitionRaftMember(task *proto.AdminTask) (err error) {
+	metaNode, err := c.metaNode(task.OperatorAddr)
+	if err != nil {
+		return
+	}
+	conn, err := metaNode.Sender.connPool.GetConnect(metaNode.Addr)
+	if err != nil {
+		return
+	}
+	if _, err = metaNode.Sender.syncSendAdminTask(task, conn); err != nil {
+		return
+	}
+	metaNode.Sender.connPool.PutConnect(conn, false)
+	return
+}
+
 func (c *Cluster) syncCreateMetaPartitionToMetaNode(host string, mp *MetaPartition) (err error) {
 	hosts := make([]string, 0)
 	hosts = append(hosts, host)
@@ -534,7 +754,10 @@ func (c *Cluster) syncCreateMetaPartitionToMetaNode(host string, mp *MetaPartiti
 	return
 }
 
-func (c *Cluster) chooseTargetDataNodes(replicaNum int) (hosts []string, peers []proto.Peer, err error) {
+// Choose the data nodes for the new replicas of a data partition.
+// The keptHosts hold the replicas kept by the partition, and the excludeHosts are not chosen either.
+// Only the data nodes with the disks of the media class are chosen if the media type is specified.
+func (c *Cluster) chooseTargetDataNodes(keptHosts, excludeHosts []string, replicaNum int, placement *placementPolicy, mediaType uint8) (hosts []string, peers []proto.Peer, err error) {
 	var (
 		masterAddr  []string
 		addrs       []string
@@ -545,7 +768,16 @@ func (c *Cluster) chooseTargetDataNodes(replicaNum int) (hosts []string, peers [
 	)
 	hosts = make([]string, 0)
 	peers = make([]proto.Peer, 0)
+	excludes := append(append([]string{}, keptHosts...), excludeHosts...)
 	ns, err := c.t.allocNodeSetForDataNode(uint8(replicaNum))
+	if placement != nil || mediaType != proto.MediaUnspecified {
+		if placement == nil {
+			// any zone is allowed
+			placement = new(placementPolicy)
+		}
+		// the nodes of the whole cluster are tried if there is no node set available
+		return c.chooseDataNodesByPolicy(placement, ns, keptHosts, excludeHosts, replicaNum, mediaType)
+	}
 	if err != nil {
 		return nil, nil, errors.NewError(err)
 	}
@@ -554,7 +786,7 @@ func (c *Cluster) chooseTargetDataNodes(replicaNum int) (hosts []string, peers [
 		if rack, err = ns.getRack(ns.racks[0]); err != nil {
 			return nil, nil, errors.NewError(err)
 		}
-		if newHosts, peers, err = rack.getAvailDataNodeHosts(hosts, replicaNum); err != nil {
+		if newHosts, peers, err = rack.getAvailDataNodeHosts(excludes, replicaNum); err != nil {
 			return nil, nil, errors.NewError(err)
 		}
 		hosts = newHosts
@@ -570,12 +802,12 @@ func (c *Cluster) chooseTargetDataNodes(replicaNum int) (hosts []string, peers [
 		slaveRack := racks[1]
 		masterReplicaNum := replicaNum/2 + 1
 		slaveReplicaNum := replicaNum - masterReplicaNum
-		if masterAddr, masterPeers, err = masterRack.getAvailDataNodeHosts(hosts, masterReplicaNum); err != nil {
+		if masterAddr, masterPeers, err = masterRack.getAvailDataNodeHosts(excludes, masterReplicaNum); err != nil {
 			return nil, nil, errors.NewError(err)
 		}
 		hosts = append(hosts, masterAddr...)
 		peers = append(peers, masterPeers...)
-		if addrs, slavePeers, err = slaveRack.getAvailDataNodeHosts(hosts, slaveReplicaNum); err != nil {
+		if addrs, slavePeers, err = slaveRack.getAvailDataNodeHosts(append(excludes, hosts...), slaveReplicaNum); err != nil {
 			return nil, nil, errors.NewError(err)
 		}
 		hosts = append(hosts, addrs...)
@@ -584,7 +816,7 @@ func (c *Cluster) chooseTargetDataNodes(replicaNum int) (hosts []string, peers [
 		for index := 0; index < replicaNum; index++ {
 			rack := racks[index]
 			var selectPeers []proto.Peer
-			if addrs, selectPeers, err = rack.getAvailDataNodeHosts(hosts, 1); err != nil {
+			if addrs, selectPeers, err = rack.getAvailDataNodeHosts(append(excludes, hosts...), 1); err != nil {
 				return nil, nil, errors.NewError(err)
 			}
 			hosts = append(hosts, addrs...)
@@ -617,30 +849,6 @@ func (c *Cluster) metaNode(addr string) (metaNode *MetaNode, err error) {
 	return
 }
 
-func (c *Cluster) dataNodeOffLine(dataNode *DataNode) (err error) {
-	msg := fmt.Sprintf("action[dataNodeOffLine], Node[%v] OffLine", dataNode.Addr)
-	log.LogWarn(msg)
-	safeVols := c.allVols()
-	for _, vol := range safeVols {
-		for _, dp := range vol.dataPartitions.partitions {
-			if err = c.decommissionDataPartition(dataNode.Addr, dp, dataNodeOfflineErr); err != nil {
-				return
-			}
-		}
-	}
-	if err = c.syncDeleteDataNode(dataNode); err != nil {
-		msg = fmt.Sprintf("action[dataNodeOffLine],clusterID[%v] Node[%v] OffLine failed,err[%v]",
-			c.Name, dataNode.Addr, err)
-		Warn(c.Name, msg)
-		return
-	}
-	c.delDataNodeFromCache(dataNode)
-	msg = fmt.Sprintf("action[dataNodeOffLine],clusterID[%v] Node[%v] OffLine success",
-		c.Name, dataNode.Addr)
-	Warn(c.Name, msg)
-	return
-}
-
 func (c *Cluster) delDataNodeFromCache(dataNode *DataNode) {
 	c.dataNodes.Delete(dataNode.Addr)
 	c.t.deleteDataNode(dataNode)
@@ -671,6 +879,7 @@ func (c *Cluster) decommissionDataPartition(offlineAddr string, dp *DataPartitio
 		removePeer proto.Peer
 		replica    *DataReplica
 		diskPath   string
+		keptHosts  []string
 	)
 	dp.Lock()
 	defer dp.Unlock()
@@ -698,14 +907,32 @@ func (c *Cluster) decommissionDataPartition(offlineAddr string, dp *DataPartitio
 	if dataNode.RackName == "" {
 		return
 	}
-	if rack, err = c.t.getRack(dataNode); err != nil {
-		goto errHandler
+	for _, host := range dp.Hosts {
+		if host != offlineAddr {
+			keptHosts = append(keptHosts, host)
+		}
 	}
-	if newHosts, newPeers, err = rack.getAvailDataNodeHosts(dp.Hosts, 1); err != nil {
-		// select data nodes from the node set
-		if newHosts, newPeers, err = c.chooseTargetDataNodes(1); err != nil {
+	if placement := vol.getPlacement(); placement != nil || dp.MediaType != proto.MediaUnspecified {
+		// the new replica has to keep the zones of the replicas satisfying the placement policy,
+		// and the media class of the partition
+		var ns *nodeSet
+		if placement == nil {
+			placement = new(placementPolicy)
+		}
+		ns, _ = c.t.getNodeSet(dataNode.NodeSetID)
+		if newHosts, newPeers, err = c.chooseDataNodesByPolicy(placement, ns, keptHosts, []string{offlineAddr}, 1, dp.MediaType); err != nil {
 			goto errHandler
 		}
+	} else {
+		if rack, err = c.t.getRack(dataNode); err != nil {
+			goto errHandler
+		}
+		if newHosts, newPeers, err = rack.getAvailDataNodeHosts(dp.Hosts, 1); err != nil {
+			// select data nodes from the node set
+			if newHosts, newPeers, err = c.chooseTargetDataNodes(keptHosts, []string{offlineAddr}, 1, nil, dp.MediaType); err != nil {
+				goto errHandler
+			}
+		}
 	}
 	newAddr = newHosts[0]
 	for _, host := range dp.Hosts {
@@ -746,7 +973,7 @@ func (c *Cluster) decommissionDataPartition(offlineAddr string, dp *DataPartitio
 		c.Name, dp.PartitionID, offlineAddr, newAddr, dp.Hosts)
 	return
 errHandler:
-	msg = fmt.Sprintf(errMsg + " clusterID[%v] partitionID:%v  on Node:%v  "+
+	msg = fmt.Sprintf(errMsg+" clusterID[%v] partitionID:%v  on Node:%v  "+
 		"Then Fix It on newHost:%v   Err:%v , PersistenceHosts:%v  ",
 		c.Name, dp.PartitionID, offlineAddr, newAddr, err, dp.Hosts)
 	if err != nil {
@@ -771,28 +998,6 @@ func (c *Cluster) putBadDataPartitionIDs(replica *DataReplica, offlineAddr strin
 	c.BadDataPartitionIds.Store(key, newBadPartitionIDs)
 }
 
-func (c *Cluster) decommissionMetaNode(metaNode *MetaNode) {
-	msg := fmt.Sprintf("action[decommissionMetaNode],clusterID[%v] Node[%v] OffLine", c.Name, metaNode.Addr)
-	log.LogWarn(msg)
-
-	safeVols := c.allVols()
-	for _, vol := range safeVols {
-		for _, mp := range vol.MetaPartitions {
-			// err is not handled here.
-			c.decommissionMetaPartition(metaNode.Addr, mp)
-		}
-	}
-	if err := c.syncDeleteMetaNode(metaNode); err != nil {
-		msg = fmt.Sprintf("action[decommissionMetaNode],clusterID[%v] Node[%v] OffLine failed,err[%v]",
-			c.Name, metaNode.Addr, err)
-		Warn(c.Name, msg)
-		return
-	}
-	c.deleteMetaNodeFromCache(metaNode)
-	msg = fmt.Sprintf("action[decommissionMetaNode],clusterID[%v] Node[%v] OffLine success", c.Name, metaNode.Addr)
-	Warn(c.Name, msg)
-}
-
 func (c *Cluster) deleteMetaNodeFromCache(metaNode *MetaNode) {
 	c.metaNodes.Delete(metaNode.Addr)
 	c.t.deleteMetaNode(metaNode)
@@ -803,6 +1008,7 @@ func (c *Cluster) updateVol(name, authKey string, capacity int) (err error) {
 	var (
 		vol           *Vol
 		serverAuthKey string
+		oldCapacity   uint64
 	)
 	if vol, err = c.getVol(name); err != nil {
 		log.LogErrorf("action[updateVol] err[%v]", err)
@@ -813,16 +1019,21 @@ func (c *Cluster) updateVol(name, authKey string, capacity int) (err error) {
 	if !matchKey(serverAuthKey, authKey) {
 		return proto.ErrVolAuthKeyNotMatch
 	}
-	if uint64(capacity) < vol.Capacity {
-		err = fmt.Errorf("capacity[%v] less than old capacity[%v]", capacity, vol.Capacity)
+	if capacity <= 0 {
+		err = fmt.Errorf("capacity[%v] is not positive", capacity)
 		goto errHandler
 	}
+	// the capacity may be lowered below the used space, which refuses the writes at once
+	oldCapacity = vol.capacity()
 	vol.setCapacity(uint64(capacity))
 	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setCapacity(oldCapacity)
 		log.LogErrorf("action[updateVol] vol[%v] err[%v]", name, err)
 		err = proto.ErrPersistenceByRaft
 		goto errHandler
 	}
+	log.LogWarnf("action[updateVol] vol[%v] capacity changed from %v to %v", name, oldCapacity, capacity)
+	c.pushVolSpace(vol)
 	return
 errHandler:
 	err = fmt.Errorf("action[updateVol], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
@@ -831,9 +1042,104 @@ errHandler:
 	return
 }
 
+func (c *Cluster) setVolQoS(name, authKey string, qos proto.VolQoS) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setVolQoS] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	vol.setQoS(qos)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolQoS] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	return
+errHandler:
+	err = fmt.Errorf("action[setVolQoS], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+// Changing the placement policy of a volume only affects the replicas placed afterwards.
+func (c *Cluster) setVolPlacement(name, authKey string, placement *placementPolicy) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setVolPlacement] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if err = placement.check(int(vol.dpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	if err = placement.check(int(vol.mpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	vol.setPlacement(placement)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolPlacement] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	return
+errHandler:
+	err = fmt.Errorf("action[setVolPlacement], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+// setVolReplicaNum changes the replica numbers of a volume, zero means unchanged.
+// The replicas of the existing partitions are added or removed one by one in the background.
+func (c *Cluster) setVolReplicaNum(name, authKey string, dpReplicaNum, mpReplicaNum uint8) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setVolReplicaNum] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if dpReplicaNum == 0 {
+		dpReplicaNum = vol.dpReplicaNum
+	}
+	if mpReplicaNum == 0 {
+		mpReplicaNum = vol.mpReplicaNum
+	}
+	if err = vol.getPlacement().check(int(dpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	if err = vol.getPlacement().check(int(mpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	vol.setReplicaNum(dpReplicaNum, mpReplicaNum)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolReplicaNum] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[setVolReplicaNum] clusterID[%v] vol[%v] dpReplicaNum[%v] mpReplicaNum[%v]",
+		c.Name, name, dpReplicaNum, mpReplicaNum))
+	return
+errHandler:
+	err = fmt.Errorf("action[setVolReplicaNum], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
 // Create a new volume.
 // By default we create 3 meta partitions and 10 data partitions during initialization.
-func (c *Cluster) createVol(name, owner string, mpCount, size, capacity int) (vol *Vol, err error) {
+func (c *Cluster) createVol(name, owner string, mpCount, size, capacity int, dpReplicaNum, mpReplicaNum uint8, placement *placementPolicy, mediaType, encryptionMode uint8) (vol *Vol, err error) {
 	var (
 		dataPartitionSize       uint64
 		readWriteDataPartitions int
@@ -850,7 +1156,19 @@ func (c *Cluster) createVol(name, owner string, mpCount, size, capacity int) (vo
 		err = proto.ErrDuplicateVol
 		goto errHandler
 	}
-	if err = c.doCreateVol(name, owner, dataPartitionSize, uint64(capacity)); err != nil {
+	if dpReplicaNum == 0 {
+		dpReplicaNum = defaultReplicaNum
+	}
+	if mpReplicaNum == 0 {
+		mpReplicaNum = defaultReplicaNum
+	}
+	if err = placement.check(int(dpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	if err = placement.check(int(mpReplicaNum)); err != nil {
+		goto errHandler
+	}
+	if err = c.doCreateVol(name, owner, dataPartitionSize, uint64(capacity), dpReplicaNum, mpReplicaNum, placement, mediaType, encryptionMode); err != nil {
 		goto errHandler
 	}
 	if vol, err = c.getVol(name); err != nil {
@@ -882,13 +1200,16 @@ errHandler:
 	return
 }
 
-func (c *Cluster) doCreateVol(name, owner string, dpSize, capacity uint64) (err error) {
+func (c *Cluster) doCreateVol(name, owner string, dpSize, capacity uint64, dpReplicaNum, mpReplicaNum uint8, placement *placementPolicy, mediaType, encryptionMode uint8) (err error) {
 	var vol *Vol
 	id, err := c.idAlloc.allocateCommonID()
 	if err != nil {
 		goto errHandler
 	}
-	vol = newVol(id, name, owner, dpSize, capacity)
+	vol = newVol(id, name, owner, dpSize, capacity, dpReplicaNum, mpReplicaNum)
+	vol.placement = placement
+	vol.mediaType = mediaType
+	vol.encryptionMode = encryptionMode
 	if err = c.syncAddVol(vol); err != nil {
 		goto errHandler
 	}
@@ -950,7 +1271,7 @@ func (c *Cluster) createMetaPartition(volName string, start, end uint64) (err er
 	}
 	errChannel := make(chan error, vol.mpReplicaNum)
 
-	if hosts, peers, err = c.chooseTargetMetaHosts(int(vol.mpReplicaNum)); err != nil {
+	if hosts, peers, err = c.chooseTargetMetaHosts(nil, nil, int(vol.mpReplicaNum), vol.getPlacement()); err != nil {
 		return errors.NewError(err)
 	}
 	log.LogInfof("target meta hosts:%v,peers:%v", hosts, peers)
@@ -1024,7 +1345,8 @@ func (c *Cluster) hasEnoughWritableMetaHosts(replicaNum int, setID uint64) bool
 }
 
 // Choose the target hosts from the available node sets and meta nodes.
-func (c *Cluster) chooseTargetMetaHosts(replicaNum int) (hosts []string, peers []proto.Peer, err error) {
+// The keptHosts hold the replicas kept by the partition, and the excludeHosts are not chosen either.
+func (c *Cluster) chooseTargetMetaHosts(keptHosts, excludeHosts []string, replicaNum int, placement *placementPolicy) (hosts []string, peers []proto.Peer, err error) {
 	var (
 		masterAddr []string
 		slaveAddrs []string
@@ -1032,12 +1354,18 @@ func (c *Cluster) chooseTargetMetaHosts(replicaNum int) (hosts []string, peers [
 		slavePeers []proto.Peer
 		ns         *nodeSet
 	)
-	if ns, err = c.t.allocNodeSetForMetaNode(uint8(replicaNum)); err != nil {
+	ns, err = c.t.allocNodeSetForMetaNode(uint8(replicaNum))
+	if placement != nil {
+		// the nodes of the whole cluster are tried if there is no node set available
+		return c.chooseMetaNodesByPolicy(placement, ns, keptHosts, excludeHosts, replicaNum)
+	}
+	if err != nil {
 		return nil, nil, errors.NewError(err)
 	}
 
 	hosts = make([]string, 0)
-	if masterAddr, masterPeer, err = ns.getAvailMetaNodeHosts(hosts, 1); err != nil {
+	excludes := append(append([]string{}, keptHosts...), excludeHosts...)
+	if masterAddr, masterPeer, err = ns.getAvailMetaNodeHosts(excludes, 1); err != nil {
 		return nil, nil, errors.NewError(err)
 	}
 	peers = append(peers, masterPeer...)
@@ -1046,7 +1374,7 @@ func (c *Cluster) chooseTargetMetaHosts(replicaNum int) (hosts []string, peers [
 	if otherReplica == 0 {
 		return
 	}
-	if slaveAddrs, slavePeers, err = ns.getAvailMetaNodeHosts(hosts, otherReplica); err != nil {
+	if slaveAddrs, slavePeers, err = ns.getAvailMetaNodeHosts(append(excludes, hosts...), otherReplica); err != nil {
 		return nil, nil, errors.NewError(err)
 	}
 	hosts = append(hosts, slaveAddrs...)
@@ -1077,7 +1405,7 @@ func (c *Cluster) allDataNodes() (dataNodes []NodeView) {
 	dataNodes = make([]NodeView, 0)
 	c.dataNodes.Range(func(addr, node interface{}) bool {
 		dataNode := node.(*DataNode)
-		dataNodes = append(dataNodes, NodeView{Addr: dataNode.Addr, Status: dataNode.isActive, ID: dataNode.ID, IsWritable: dataNode.isWriteAble()})
+		dataNodes = append(dataNodes, NodeView{Addr: dataNode.Addr, Status: dataNode.isActive, ID: dataNode.ID, IsWritable: dataNode.isWriteAble(), AdminState: dataNode.AdminState})
 		return true
 	})
 	return
@@ -1119,7 +1447,7 @@ func (c *Cluster) allMetaNodes() (metaNodes []NodeView) {
 	metaNodes = make([]NodeView, 0)
 	c.metaNodes.Range(func(addr, node interface{}) bool {
 		metaNode := node.(*MetaNode)
-		metaNodes = append(metaNodes, NodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive, IsWritable: metaNode.isWritable()})
+		metaNodes = append(metaNodes, NodeView{ID: metaNode.ID, Addr: metaNode.Addr, Status: metaNode.IsActive, IsWritable: metaNode.isWritable(), AdminState: metaNode.AdminState})
 		return true
 	})
 	return
@@ -110,6 +110,7 @@ func (c *Cluster) updateVolStatInfo() {
 			continue
 		}
 		useRate := float64(used) / float64(total)
+		c.alarmVolSpace(vol, used, total, useRate)
 		c.volStatInfo.Store(vol.Name, newVolStatInfo(vol.Name, total/util.GB, used/util.GB, strconv.FormatFloat(useRate, 'f', 3, 32)))
 	}
 }
@@ -33,7 +33,7 @@ func (c *Cluster) addDataNodeTasks(tasks []*proto.AdminTask) {
 		}
 		if node, err := c.dataNode(t.OperatorAddr); err != nil {
 			log.LogWarn(fmt.Sprintf("action[putTasks],nodeAddr:%v,taskID:%v,err:%v", t.OperatorAddr, t.ID, err))
-		} else {
+		} else if c.enqueueAdminTask(t, taskNodeTypeData) {
 			node.TaskManager.AddTask(t)
 		}
 	}
@@ -47,7 +47,7 @@ func (c *Cluster) addMetaNodeTasks(tasks []*proto.AdminTask) {
 		}
 		if node, err := c.metaNode(t.OperatorAddr); err != nil {
 			log.LogWarn(fmt.Sprintf("action[putTasks],nodeAddr:%v,taskID:%v,err:%v", t.OperatorAddr, t.ID, err.Error()))
-		} else {
+		} else if c.enqueueAdminTask(t, taskNodeTypeMeta) {
 			node.Sender.AddTask(t)
 		}
 	}
@@ -100,6 +100,7 @@ func (c *Cluster) decommissionMetaPartition(nodeAddr string, mp *MetaPartition)
 		removePeer  proto.Peer
 		metaNode    *MetaNode
 		ns          *nodeSet
+		keptHosts   []string
 	)
 	log.LogWarnf("action[decommissionMetaPartition],volName[%v],nodeAddr[%v],partitionID[%v]", mp.volName, nodeAddr, mp.PartitionID)
 	if !contains(mp.Hosts, nodeAddr) {
@@ -119,9 +120,19 @@ func (c *Cluster) decommissionMetaPartition(nodeAddr string, mp *MetaPartition)
 	if err = mp.canBeOffline(nodeAddr, int(vol.mpReplicaNum)); err != nil {
 		goto errHandler
 	}
-	if newHosts, newPeers, err = ns.getAvailMetaNodeHosts(mp.Hosts, 1); err != nil {
+	for _, host := range mp.Hosts {
+		if host != nodeAddr {
+			keptHosts = append(keptHosts, host)
+		}
+	}
+	if placement := vol.getPlacement(); placement != nil {
+		// the new replica has to keep the zones of the replicas satisfying the placement policy
+		if newHosts, newPeers, err = c.chooseMetaNodesByPolicy(placement, ns, keptHosts, []string{nodeAddr}, 1); err != nil {
+			goto errHandler
+		}
+	} else if newHosts, newPeers, err = ns.getAvailMetaNodeHosts(mp.Hosts, 1); err != nil {
 		// choose a meta node in the node set
-		if newHosts, newPeers, err = c.chooseTargetMetaHosts(1); err != nil {
+		if newHosts, newPeers, err = c.chooseTargetMetaHosts(keptHosts, []string{nodeAddr}, 1, nil); err != nil {
 			goto errHandler
 		}
 	}
@@ -262,6 +273,7 @@ func (c *Cluster) handleMetaNodeTaskResponse(nodeAddr string, task *proto.AdminT
 	if err = unmarshalTaskResponse(task); err != nil {
 		goto errHandler
 	}
+	c.finishAdminTask(task)
 
 	switch task.OpCode {
 	case proto.OpMetaNodeHeartbeat:
@@ -458,6 +470,7 @@ func (c *Cluster) handleDataNodeTaskResponse(nodeAddr string, task *proto.AdminT
 	if err = unmarshalTaskResponse(task); err != nil {
 		goto errHandler
 	}
+	c.finishAdminTask(task)
 
 	switch task.OpCode {
 	case proto.OpDeleteDataPartition:
@@ -547,6 +560,7 @@ func (c *Cluster) handleDataNodeHeartbeatResp(nodeAddr string, resp *proto.DataN
 		log.LogErrorf("action[handleDataNodeHeartbeatResp] dataNode[%v] err[%v]", dataNode.Addr, err)
 	}
 	c.updateDataNode(dataNode, resp.PartitionReports)
+	c.checkBrokenDisks(dataNode, resp.DiskReports)
 	logMsg = fmt.Sprintf("action[handleDataNodeHeartbeatResp],dataNode:%v ReportTime:%v  success", dataNode.Addr, time.Now().Unix())
 	log.LogInfof(logMsg)
 	return
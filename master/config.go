@@ -22,7 +22,7 @@ import (
 	"strings"
 )
 
-//config key
+// config key
 const (
 	colonSplit = ":"
 	commaSplit = ","
@@ -33,9 +33,22 @@ const (
 	NumberOfDataPartitionsToLoad        = "NumberOfDataPartitionsToLoad"
 	secondsToFreeDataPartitionAfterLoad = "secondsToFreeDataPartitionAfterLoad"
 	nodeSetCapacity                     = "nodeSetCap"
+	autoDecommissionDisk                = "autoDecommissionDisk"
+	rebalanceThreshold                  = "rebalanceThreshold"
+	rebalanceConcurrency                = "rebalanceConcurrency"
+	clientViewMaxStaleness              = "clientViewMaxStaleness"
+	authEnabled                         = "authEnabled"
+	rootKey                             = "rootKey"
+	secretKey                           = "secretKey"
+	nodeCertOU                          = "nodeCertOU"
+	auditRetentionDays                  = "auditRetentionDays"
+	decommissionConcurrency             = "decommissionConcurrency"
+	volSoftLimitRatio                   = "volSoftLimitRatio"
+	usageSampleInterval                 = "usageSampleInterval"
+	usageRetentionDays                  = "usageRetentionDays"
 )
 
-//default value
+// default value
 const (
 	defaultTobeFreedDataPartitionCount         = 1000
 	defaultSecondsToFreeDataPartitionAfterLoad = 5 * 60 // a data partition can only be freed after loading 5 mins
@@ -59,6 +72,7 @@ const (
 	defaultMetaPartitionMemUsageThreshold      float32 = 0.75    // memory usage threshold on a meta partition
 	defaultMaxMetaPartitionCountOnEachNode             = 100
 	defaultReplicaNum                                  = 3
+	maxReplicaNum                                      = 5
 )
 
 // AddrDatabase is a map that stores the address of a given host (e.g., the leader)
@@ -76,6 +90,19 @@ type clusterConfig struct {
 	numberOfDataPartitionsToLoad        int
 	nodeSetCapacity                     int
 	MetaNodeThreshold                   float32
+	AutoDecommissionDisk                bool    // decommission the partitions on a broken disk automatically
+	RebalanceThreshold                  float64 // data nodes whose usage exceeds the average by more than it are rebalanced
+	RebalanceConcurrency                int     // maximum number of the replicas being moved at the same time
+	ClientViewMaxStaleness              int64   // seconds, followers only serve the client views younger than it, 0 disables it
+	AuthEnabled                         bool    // the admin APIs are only served to the admin users granted the permission
+	rootKey                             string  // the key of the built-in root user
+	secretKey                           []byte  // seals the secrets in the raft store, nil if they are kept in clear
+	NodeCertOU                          string  // the organizational unit in the certificates of the nodes
+	AuditRetentionDays                  int64   // the audit records older than it are trimmed, 0 keeps them
+	DecommissionConcurrency             int     // default maximum number of the partitions recovering at a time in a decommission job
+	VolSoftLimitRatio                   float64 // an alarm is raised if the usage of a volume reaches it, 0 disables it
+	UsageSampleInterval                 int64   // seconds between the samples of the usage history
+	UsageRetentionDays                  int64   // the daily usage averages older than it are trimmed, 0 keeps them
 	peers                               []raftstore.PeerAddress
 	peerAddrs                           []string
 }
@@ -92,6 +119,15 @@ func newClusterConfig() (cfg *clusterConfig) {
 	cfg.numberOfDataPartitionsToLoad = defaultNumberOfDataPartitionsToLoad
 	cfg.PeriodToLoadALLDataPartitions = defaultPeriodToLoadAllDataPartitions
 	cfg.MetaNodeThreshold = defaultMetaPartitionMemUsageThreshold
+	cfg.AutoDecommissionDisk = true
+	cfg.RebalanceThreshold = defaultRebalanceThreshold
+	cfg.RebalanceConcurrency = defaultRebalanceConcurrency
+	cfg.ClientViewMaxStaleness = defaultClientViewMaxStaleness
+	cfg.AuditRetentionDays = defaultAuditRetentionDays
+	cfg.DecommissionConcurrency = defaultDecommissionConcurrency
+	cfg.VolSoftLimitRatio = defaultVolSoftLimitRatio
+	cfg.UsageSampleInterval = defaultUsageSampleInterval
+	cfg.UsageRetentionDays = defaultUsageRetentionDays
 	return
 }
 
@@ -31,6 +31,49 @@ const (
 	volCapacityKey        = "capacity"
 	volOwnerKey           = "owner"
 	volAuthKey            = "authKey"
+	readBandwidthKey      = "readBandwidth"
+	writeBandwidthKey     = "writeBandwidth"
+	readIOPSKey           = "readIOPS"
+	writeIOPSKey          = "writeIOPS"
+	metaOPSKey            = "metaOPS"
+	concurrencyKey        = "concurrency"
+	maxMovesKey           = "maxMoves"
+	dryRunKey             = "dryRun"
+	zoneKey               = "zone"
+	rackKey               = "rack"
+	placementKey          = "placement"
+	dpReplicaNumKey       = "dpReplicaNum"
+	mpReplicaNumKey       = "mpReplicaNum"
+	mediaTypeKey          = "mediaType"
+	encryptionKey         = "encryption"
+	moveAfterKey          = "moveAfter"
+	ruleIDKey             = "ruleID"
+	pathKey               = "path"
+	actionKey             = "action"
+	daysKey               = "days"
+	statusKey             = "status"
+	volKey                = "vol"
+	hostKey               = "host"
+	minUsageKey           = "minUsage"
+	maxUsageKey           = "maxUsage"
+	missingReplicaKey     = "missingReplica"
+	sortKey               = "sort"
+	orderKey              = "order"
+	offsetKey             = "offset"
+	limitKey              = "limit"
+	tokenKey              = "token"
+	modeKey               = "mode"
+	ttlKey                = "ttl"
+	roleKey               = "role"
+	fromKey               = "from"
+	toKey                 = "to"
+	afterKey              = "after"
+	operationKey          = "operation"
+	objectKey             = "object"
+	userKey               = "user"
+	typeKey               = "type"
+	stateKey              = "state"
+	resolutionKey         = "resolution"
 )
 
 const (
@@ -97,6 +140,21 @@ const (
 	opSyncDeleteMetaPartition  uint32 = 0x11
 	opSyncAddNodeSet           uint32 = 0x12
 	opSyncUpdateNodeSet        uint32 = 0x13
+	opSyncPutClientView        uint32 = 0x14
+	opSyncDeleteClientView     uint32 = 0x15
+	opSyncPutUser              uint32 = 0x16
+	opSyncDeleteUser           uint32 = 0x17
+	opSyncPutAuditRecord       uint32 = 0x18
+	opSyncTrimAuditLog         uint32 = 0x19
+	opSyncPutAdminTask         uint32 = 0x1A
+	opSyncDeleteAdminTask      uint32 = 0x1B
+	opSyncPutDecommission      uint32 = 0x1C
+	opSyncDeleteDecommission   uint32 = 0x1D
+	opSyncPutUsageSample       uint32 = 0x1E
+	opSyncTrimUsageHistory     uint32 = 0x1F
+	opSyncPutClientViewLease   uint32 = 0x20
+	opSyncPutRebalanceMove     uint32 = 0x21
+	opSyncDeleteRebalanceMove  uint32 = 0x22
 )
 
 const (
@@ -108,9 +166,17 @@ const (
 	volAcronym            = "vol"
 	clusterAcronym        = "c"
 	nodeSetAcronym        = "s"
+	clientViewAcronym     = "cv"
+	userAcronym           = "user"
+	auditAcronym          = "audit"
+	adminTaskAcronym      = "task"
+	decommissionAcronym   = "dj"
+	usageAcronym          = "usage"
+	rebalanceMoveAcronym  = "rb"
 	maxDataPartitionIDKey = keySeparator + "max_dp_id"
 	maxMetaPartitionIDKey = keySeparator + "max_mp_id"
 	maxCommonIDKey        = keySeparator + "max_common_id"
+	clientViewLeaseKey    = keySeparator + "cv_lease"
 	metaNodePrefix        = keySeparator + metaNodeAcronym + keySeparator
 	dataNodePrefix        = keySeparator + dataNodeAcronym + keySeparator
 	dataPartitionPrefix   = keySeparator + dataPartitionAcronym + keySeparator
@@ -118,4 +184,11 @@ const (
 	metaPartitionPrefix   = keySeparator + metaPartitionAcronym + keySeparator
 	clusterPrefix         = keySeparator + clusterAcronym + keySeparator
 	nodeSetPrefix         = keySeparator + nodeSetAcronym + keySeparator
+	clientViewPrefix      = keySeparator + clientViewAcronym + keySeparator
+	userPrefix            = keySeparator + userAcronym + keySeparator
+	auditPrefix           = keySeparator + auditAcronym + keySeparator
+	adminTaskPrefix       = keySeparator + adminTaskAcronym + keySeparator
+	decommissionPrefix    = keySeparator + decommissionAcronym + keySeparator
+	usagePrefix           = keySeparator + usageAcronym + keySeparator
+	rebalanceMovePrefix   = keySeparator + rebalanceMoveAcronym + keySeparator
 )
@@ -30,6 +30,7 @@ type DataNode struct {
 	AvailableSpace uint64
 	ID             uint64
 	RackName       string `json:"Rack"`
+	ZoneName       string `json:"Zone"`
 	Addr           string
 	ReportTime     time.Time
 	isActive       bool
@@ -39,14 +40,19 @@ type DataNode struct {
 	Carry                float64 // carry is a factor used in cacluate the node's weight
 	TaskManager          *AdminTaskManager
 	dataPartitionReports []*proto.PartitionReport
+	DiskReports          []*proto.DiskReport
 	DataPartitionCount   uint32
 	NodeSetID            uint64
+	ToBeDrained          bool // the data node is asked to drain by the master
+	Draining             bool // the data node reports that it is draining
+	AdminState           string
 }
 
 func newDataNode(addr, clusterID string) (dataNode *DataNode) {
 	dataNode = new(DataNode)
 	dataNode.Carry = rand.Float64()
 	dataNode.Total = 1
+	dataNode.AdminState = proto.NodeStateActive
 	dataNode.Addr = addr
 	dataNode.TaskManager = newAdminTaskManager(dataNode.Addr, clusterID)
 	return
@@ -84,6 +90,8 @@ func (dataNode *DataNode) updateNodeMetric(resp *proto.DataNodeHeartbeatResponse
 	dataNode.RackName = resp.RackName
 	dataNode.DataPartitionCount = resp.CreatedPartitionCnt
 	dataNode.dataPartitionReports = resp.PartitionReports
+	dataNode.DiskReports = resp.DiskReports
+	dataNode.Draining = resp.Draining
 	if dataNode.Total == 0 {
 		dataNode.UsageRatio = 0.0
 	} else {
@@ -97,13 +105,20 @@ func (dataNode *DataNode) isWriteAble() (ok bool) {
 	dataNode.RLock()
 	defer dataNode.RUnlock()
 
-	if dataNode.isActive == true && dataNode.AvailableSpace > 10*util.GB {
+	if dataNode.isActive == true && dataNode.AvailableSpace > 10*util.GB && !dataNode.ToBeDrained && !dataNode.Draining &&
+		dataNode.AdminState == proto.NodeStateActive {
 		ok = true
 	}
 
 	return
 }
 
+func (dataNode *DataNode) isDraining() bool {
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	return dataNode.ToBeDrained || dataNode.Draining
+}
+
 func (dataNode *DataNode) isAvailCarryNode() (ok bool) {
 	dataNode.RLock()
 	defer dataNode.RUnlock()
@@ -131,11 +146,18 @@ func (dataNode *DataNode) clear() {
 	dataNode.TaskManager.exitCh <- struct{}{}
 }
 
-func (dataNode *DataNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (dataNode *DataNode) createHeartbeatTask(masterAddr string, volQoS map[string]*proto.VolQoS, volSpace map[string]*proto.VolSpace,
+	tokenVerify *proto.TokenVerifyInfo) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:    time.Now().Unix(),
+		MasterAddr:  masterAddr,
+		VolQoS:      volQoS,
+		VolSpace:    volSpace,
+		TokenVerify: tokenVerify,
 	}
+	dataNode.RLock()
+	request.Drain = dataNode.ToBeDrained
+	dataNode.RUnlock()
 	task = proto.NewAdminTask(proto.OpDataNodeHeartbeat, dataNode.Addr, request)
 	return
 }
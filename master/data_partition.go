@@ -46,6 +46,7 @@ type DataPartition struct {
 	createTime              int64
 	FileInCoreMap           map[string]*FileInCore
 	FilesWithMissingReplica map[string]int64 // key: file name, value: last time when a missing replica is found
+	MediaType               uint8            // media class of the disks holding the replicas
 }
 
 func newDataPartition(ID uint64, replicaNum uint8, volName string, volID uint64) (partition *DataPartition) {
@@ -79,7 +80,7 @@ func (partition *DataPartition) addReplica(replica *DataReplica) {
 func (partition *DataPartition) createTaskToCreateDataPartition(addr string, dataPartitionSize uint64) (task *proto.AdminTask) {
 
 	task = proto.NewAdminTask(proto.OpCreateDataPartition, addr, newCreateDataPartitionRequest(
-		partition.VolName, partition.PartitionID, partition.Peers, int(dataPartitionSize)))
+		partition.VolName, partition.PartitionID, partition.Peers, int(dataPartitionSize), partition.MediaType))
 	partition.resetTaskID(task)
 	return
 }
@@ -101,6 +102,17 @@ func (partition *DataPartition) createTaskToDecommissionDataPartition(removePeer
 	return
 }
 
+func (partition *DataPartition) createTaskToChangeRaftMember(opCode uint8, removePeer, addPeer proto.Peer) (task *proto.AdminTask, err error) {
+	leaderAddr := partition.getLeaderAddr()
+	if leaderAddr == "" {
+		err = proto.ErrNoLeader
+		return
+	}
+	task = proto.NewAdminTask(opCode, leaderAddr, newOfflineDataPartitionRequest(partition.PartitionID, removePeer, addPeer))
+	partition.resetTaskID(task)
+	return
+}
+
 func (partition *DataPartition) resetTaskID(t *proto.AdminTask) {
 	t.ID = fmt.Sprintf("%v_DataPartitionID[%v]", t.ID, partition.PartitionID)
 }
@@ -247,6 +259,7 @@ func (partition *DataPartition) convertToDataPartitionResponse() (dpr *proto.Dat
 	dpr.Hosts = make([]string, len(partition.Hosts))
 	copy(dpr.Hosts, partition.Hosts)
 	dpr.LeaderAddr = partition.getLeaderAddr()
+	dpr.MediaType = partition.MediaType
 	return
 }
 
@@ -486,6 +499,50 @@ func (partition *DataPartition) updateForOffline(offlineAddr, newAddr, volName s
 	return
 }
 
+// addHostAndPeer persists the host list with a new replica appended.
+func (partition *DataPartition) addHostAndPeer(peer proto.Peer, c *Cluster) (err error) {
+	orgHosts := partition.Hosts
+	oldPeers := partition.Peers
+	partition.Hosts = append(append(make([]string, 0, len(orgHosts)+1), orgHosts...), peer.Addr)
+	partition.Peers = append(append(make([]proto.Peer, 0, len(oldPeers)+1), oldPeers...), peer)
+	if err = c.syncUpdateDataPartition(partition); err != nil {
+		partition.Hosts = orgHosts
+		partition.Peers = oldPeers
+		return errors.Trace(err, "update partition[%v] failed", partition.PartitionID)
+	}
+	log.LogInfof("action[addHostAndPeer] partitionID:%v newPeer:%v oldHosts:%v newHosts:%v",
+		partition.PartitionID, peer, orgHosts, partition.Hosts)
+	return
+}
+
+// removeHostAndPeer persists the host list without the given replica.
+func (partition *DataPartition) removeHostAndPeer(addr string, c *Cluster) (err error) {
+	orgHosts := partition.Hosts
+	oldPeers := partition.Peers
+	newHosts := make([]string, 0, len(orgHosts))
+	for _, host := range orgHosts {
+		if host != addr {
+			newHosts = append(newHosts, host)
+		}
+	}
+	newPeers := make([]proto.Peer, 0, len(oldPeers))
+	for _, peer := range oldPeers {
+		if peer.Addr != addr {
+			newPeers = append(newPeers, peer)
+		}
+	}
+	partition.Hosts = newHosts
+	partition.Peers = newPeers
+	if err = c.syncUpdateDataPartition(partition); err != nil {
+		partition.Hosts = orgHosts
+		partition.Peers = oldPeers
+		return errors.Trace(err, "update partition[%v] failed", partition.PartitionID)
+	}
+	log.LogInfof("action[removeHostAndPeer] partitionID:%v removeAddr:%v oldHosts:%v newHosts:%v",
+		partition.PartitionID, addr, orgHosts, partition.Hosts)
+	return
+}
+
 func (partition *DataPartition) updateMetric(vr *proto.PartitionReport, dataNode *DataNode, c *Cluster) {
 
 	if !partition.hasHost(dataNode.Addr) {
@@ -507,6 +564,8 @@ func (partition *DataPartition) updateMetric(vr *proto.PartitionReport, dataNode
 	replica.setAlive()
 	replica.IsLeader = vr.IsLeader
 	replica.NeedsToCompare = vr.NeedCompare
+	replica.ApplyID = vr.ApplyID
+	replica.SyncedReplicas = vr.SyncedReplicas
 	if replica.DiskPath != vr.DiskPath && vr.DiskPath != "" {
 		replica.DiskPath = vr.DiskPath
 		c.syncUpdateDataPartition(partition)
@@ -37,7 +37,8 @@ func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dp
 	switch len(liveReplicas) {
 	case (int)(partition.ReplicaNum):
 		partition.Status = proto.ReadOnly
-		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true && partition.isReplicaSizeAligned() && partition.canWrite() {
+		if partition.checkReplicaStatusOnLiveNode(liveReplicas) == true && partition.isReplicaSizeAligned() && partition.canWrite() &&
+			!partition.hasDrainingReplica(liveReplicas) {
 			partition.Status = proto.ReadWrite
 		}
 	default:
@@ -50,6 +51,16 @@ func (partition *DataPartition) checkStatus(clusterName string, needLog bool, dp
 	}
 }
 
+// no new extent can be created on a draining data node
+func (partition *DataPartition) hasDrainingReplica(liveReplicas []*DataReplica) bool {
+	for _, replica := range liveReplicas {
+		if replica.dataNode.isDraining() {
+			return true
+		}
+	}
+	return false
+}
+
 func (partition *DataPartition) canWrite() bool {
 	avail := partition.total - partition.used
 	if int64(avail) > 10*util.GB {
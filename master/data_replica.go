@@ -33,6 +33,8 @@ type DataReplica struct {
 	IsLeader        bool
 	NeedsToCompare  bool
 	DiskPath        string
+	ApplyID         uint64
+	SyncedReplicas  []string // the replicas found in sync by the last repair, reported by the first host only
 }
 
 func newDataReplica(dataNode *DataNode) (replica *DataReplica) {
@@ -0,0 +1,641 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// A decommission job moves all the partitions off a data node, a disk or a meta node. The job is replicated
+// by raft and run by the leader, so a new leader resumes it. At most Concurrency partitions of a job are
+// running at a time: a data partition is running until its new replica has recovered, and a meta partition
+// until its new replica reports. Once no partition is pending or running, the job verifies that nothing
+// remains on the node or the disk, and removes the node from the cluster.
+
+const (
+	defaultDecommissionConcurrency  = 4
+	defaultIntervalToDecommission   = 10 // in terms of seconds
+	maxDecommissionAttempts         = 3
+	defaultDecommissionJobRetention = 7 * 24 * 3600 // in terms of seconds
+)
+
+type decommissionJobs struct {
+	sync.RWMutex
+	jobs map[uint64]*proto.DecommissionJob
+}
+
+func newDecommissionJobs() *decommissionJobs {
+	return &decommissionJobs{jobs: make(map[uint64]*proto.DecommissionJob)}
+}
+
+func isDecommissionJobFinished(job *proto.DecommissionJob) bool {
+	return job.State == proto.DecommissionJobSucceeded || job.State == proto.DecommissionJobFailed ||
+		job.State == proto.DecommissionJobCancelled
+}
+
+func cloneDecommissionJob(job *proto.DecommissionJob) *proto.DecommissionJob {
+	clone := *job
+	clone.Partitions = make([]*proto.DecommissionPartition, len(job.Partitions))
+	for i, p := range job.Partitions {
+		cp := *p
+		clone.Partitions[i] = &cp
+	}
+	return &clone
+}
+
+// newDecommissionJobView counts the partitions in each state, and estimates the time to finish the partitions
+// by the average time the finished ones took.
+func newDecommissionJobView(job *proto.DecommissionJob, withPartitions bool) (view *proto.DecommissionJobView) {
+	view = &proto.DecommissionJobView{DecommissionJob: *cloneDecommissionJob(job), Total: len(job.Partitions), ETA: -1}
+	var spent int64
+	for _, p := range job.Partitions {
+		switch p.State {
+		case proto.DecommissionPartitionPending:
+			view.Pending++
+		case proto.DecommissionPartitionRunning:
+			view.Running++
+		case proto.DecommissionPartitionDone:
+			view.Done++
+			if p.StartTime > 0 && p.EndTime >= p.StartTime {
+				spent += p.EndTime - p.StartTime
+			}
+		case proto.DecommissionPartitionFailed:
+			view.Failed++
+		case proto.DecommissionPartitionCancelled:
+			view.Cancelled++
+		}
+	}
+	if left := view.Pending + view.Running; left == 0 {
+		view.ETA = 0
+	} else if view.Done > 0 && !isDecommissionJobFinished(job) && job.Concurrency > 0 {
+		rounds := (left + job.Concurrency - 1) / job.Concurrency
+		view.ETA = spent / int64(view.Done) * int64(rounds)
+	}
+	if !withPartitions {
+		view.Partitions = nil
+	}
+	return
+}
+
+//key=#dj#jobID,value=json.Marshal(proto.DecommissionJob)
+func (c *Cluster) syncPutDecommissionJob(job *proto.DecommissionJob) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutDecommission
+	metadata.K = decommissionPrefix + strconv.FormatUint(job.ID, 10)
+	if metadata.V, err = json.Marshal(job); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteDecommissionJob(id uint64) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteDecommission
+	metadata.K = decommissionPrefix + strconv.FormatUint(id, 10)
+	return c.submit(metadata)
+}
+
+func (c *Cluster) loadDecommissionJobs() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(decommissionPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadDecommissionJobs],err:%v", err.Error())
+		return err
+	}
+	jobs := make(map[uint64]*proto.DecommissionJob, len(result))
+	for _, value := range result {
+		job := new(proto.DecommissionJob)
+		if err = json.Unmarshal(value, job); err != nil {
+			err = fmt.Errorf("action[loadDecommissionJobs],value:%v,unmarshal err:%v", string(value), err)
+			return err
+		}
+		jobs[job.ID] = job
+		log.LogInfof("action[loadDecommissionJobs],job[%v] type[%v] addr[%v] state[%v]", job.ID, job.Type, job.NodeAddr, job.State)
+	}
+	c.decommissionJobs.Lock()
+	c.decommissionJobs.jobs = jobs
+	c.decommissionJobs.Unlock()
+	return
+}
+
+// updateDecommissionJob persists the job changed by the function, which returns false to leave the job as it is.
+func (c *Cluster) updateDecommissionJob(id uint64, change func(job *proto.DecommissionJob) bool) (job *proto.DecommissionJob, err error) {
+	c.decommissionJobs.Lock()
+	defer c.decommissionJobs.Unlock()
+	old, ok := c.decommissionJobs.jobs[id]
+	if !ok {
+		return nil, proto.ErrDecommissionJobNotExists
+	}
+	job = cloneDecommissionJob(old)
+	if !change(job) {
+		return nil, proto.ErrInvalidDecommissionJobState
+	}
+	job.UpdateTime = time.Now().Unix()
+	if isDecommissionJobFinished(job) && job.EndTime == 0 {
+		job.EndTime = job.UpdateTime
+	}
+	if err = c.syncPutDecommissionJob(job); err != nil {
+		log.LogErrorf("action[updateDecommissionJob] job[%v] err[%v]", id, err)
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.decommissionJobs.jobs[id] = job
+	return
+}
+
+// partitionsOnNode returns the partitions having a replica on the node or the disk, in the order of their IDs.
+func (c *Cluster) partitionsOnNode(jobType, addr, diskPath string) (partitions []*proto.DecommissionPartition) {
+	partitions = make([]*proto.DecommissionPartition, 0)
+	add := func(id uint64, volName string) {
+		partitions = append(partitions, &proto.DecommissionPartition{ID: id, VolName: volName, State: proto.DecommissionPartitionPending})
+	}
+	switch jobType {
+	case proto.DecommissionTypeDisk:
+		dataNode, err := c.dataNode(addr)
+		if err != nil {
+			return
+		}
+		for _, id := range dataNode.badPartitionIDs(diskPath) {
+			if dp, err := c.getDataPartitionByID(id); err == nil && dp.isOnHost(addr) {
+				add(dp.PartitionID, dp.VolName)
+			}
+		}
+	case proto.DecommissionTypeDataNode:
+		for _, vol := range c.allVols() {
+			vol.dataPartitions.RLock()
+			dps := make([]*DataPartition, len(vol.dataPartitions.partitions))
+			copy(dps, vol.dataPartitions.partitions)
+			vol.dataPartitions.RUnlock()
+			for _, dp := range dps {
+				if dp.isOnHost(addr) {
+					add(dp.PartitionID, dp.VolName)
+				}
+			}
+		}
+	case proto.DecommissionTypeMetaNode:
+		for _, vol := range c.allVols() {
+			for _, mp := range vol.cloneMetaPartitionMap() {
+				mp.RLock()
+				onHost := contains(mp.Hosts, addr)
+				mp.RUnlock()
+				if onHost {
+					add(mp.PartitionID, mp.volName)
+				}
+			}
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+	return
+}
+
+func (partition *DataPartition) isOnHost(addr string) bool {
+	partition.RLock()
+	defer partition.RUnlock()
+	return partition.hasHost(addr)
+}
+
+// createDecommissionJob starts to decommission the node or the disk. A data node is drained first, so that no
+// new extent is created on it.
+func (c *Cluster) createDecommissionJob(jobType, addr, diskPath string, concurrency int) (view *proto.DecommissionJobView, err error) {
+	var (
+		dataNode *DataNode
+		id       uint64
+	)
+	if jobType == proto.DecommissionTypeMetaNode {
+		if _, err = c.metaNode(addr); err != nil {
+			return nil, proto.ErrMetaNodeNotExists
+		}
+	} else if dataNode, err = c.dataNode(addr); err != nil {
+		return nil, proto.ErrDataNodeNotExists
+	}
+	if concurrency <= 0 {
+		concurrency = c.cfg.DecommissionConcurrency
+	}
+	// the lock is held until the job is added, so that a duplicate job can't be created meanwhile
+	c.decommissionJobs.Lock()
+	defer c.decommissionJobs.Unlock()
+	for _, job := range c.decommissionJobs.jobs {
+		if !isDecommissionJobFinished(job) && job.NodeAddr == addr &&
+			(job.Type == proto.DecommissionTypeMetaNode) == (jobType == proto.DecommissionTypeMetaNode) {
+			return nil, proto.ErrDuplicateDecommissionJob
+		}
+	}
+
+	partitions := c.partitionsOnNode(jobType, addr, diskPath)
+	if jobType == proto.DecommissionTypeDisk && len(partitions) == 0 {
+		return nil, fmt.Errorf("node[%v] disk[%v] does not have any data partition", addr, diskPath)
+	}
+	if jobType == proto.DecommissionTypeDataNode {
+		if err = c.setDataNodeDrain(dataNode, true); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				c.undrainDecommissionedNode(addr)
+			}
+		}()
+	}
+	if id, err = c.idAlloc.allocateCommonID(); err != nil {
+		return
+	}
+	job := &proto.DecommissionJob{
+		ID:          id,
+		Type:        jobType,
+		NodeAddr:    addr,
+		DiskPath:    diskPath,
+		State:       proto.DecommissionJobRunning,
+		Concurrency: concurrency,
+		CreateTime:  time.Now().Unix(),
+		UpdateTime:  time.Now().Unix(),
+		Partitions:  partitions,
+	}
+	if err = c.syncPutDecommissionJob(job); err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.decommissionJobs.jobs[id] = job
+	Warn(c.Name, fmt.Sprintf("action[createDecommissionJob] clusterID[%v] job[%v] type[%v] addr[%v] disk[%v] partitions[%v]",
+		c.Name, id, jobType, addr, diskPath, len(partitions)))
+	return newDecommissionJobView(job, false), nil
+}
+
+func (c *Cluster) scheduleToDecommission() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.runDecommissionJobs()
+			}
+			time.Sleep(time.Second * defaultIntervalToDecommission)
+		}
+	}()
+}
+
+func (c *Cluster) runDecommissionJobs() {
+	c.decommissionJobs.RLock()
+	jobs := make([]*proto.DecommissionJob, 0, len(c.decommissionJobs.jobs))
+	for _, job := range c.decommissionJobs.jobs {
+		jobs = append(jobs, cloneDecommissionJob(job))
+	}
+	c.decommissionJobs.RUnlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	for _, job := range jobs {
+		if !isDecommissionJobFinished(job) {
+			c.runDecommissionJob(job)
+			continue
+		}
+		if time.Now().Unix()-job.EndTime < defaultDecommissionJobRetention {
+			continue
+		}
+		if err := c.syncDeleteDecommissionJob(job.ID); err != nil {
+			log.LogErrorf("action[runDecommissionJobs] delete job[%v] err[%v]", job.ID, err)
+			continue
+		}
+		c.decommissionJobs.Lock()
+		delete(c.decommissionJobs.jobs, job.ID)
+		c.decommissionJobs.Unlock()
+	}
+}
+
+// runDecommissionJob checks the running partitions of the job, and starts the pending ones within the
+// concurrency. The job is changed once a round, since a partition is started again harmlessly after a
+// leader change. Pausing only stops starting new partitions, the running ones are still checked.
+func (c *Cluster) runDecommissionJob(snapshot *proto.DecommissionJob) {
+	results := make(map[uint64]*proto.DecommissionPartition)
+	running := 0
+	for _, p := range snapshot.Partitions {
+		if p.State != proto.DecommissionPartitionRunning {
+			continue
+		}
+		if c.isPartitionDecommissioned(snapshot, p.ID) {
+			result := *p
+			result.State, result.EndTime = proto.DecommissionPartitionDone, time.Now().Unix()
+			results[p.ID] = &result
+			continue
+		}
+		running++
+	}
+	pending := 0
+	for _, p := range snapshot.Partitions {
+		if p.State != proto.DecommissionPartitionPending {
+			continue
+		}
+		if snapshot.State != proto.DecommissionJobRunning || running >= snapshot.Concurrency {
+			pending++
+			continue
+		}
+		result := *p
+		result.Attempts++
+		result.StartTime = time.Now().Unix()
+		if err := c.decommissionPartitionOnNode(snapshot, p.ID); err != nil {
+			result.Msg = err.Error()
+			if result.Attempts >= maxDecommissionAttempts {
+				result.State, result.EndTime = proto.DecommissionPartitionFailed, time.Now().Unix()
+			} else {
+				pending++
+			}
+		} else {
+			result.State, result.Msg = proto.DecommissionPartitionRunning, ""
+			running++
+		}
+		results[p.ID] = &result
+	}
+
+	var verified []*proto.DecommissionPartition
+	if snapshot.State == proto.DecommissionJobRunning && running == 0 && pending == 0 {
+		verified = c.partitionsOnNode(snapshot.Type, snapshot.NodeAddr, snapshot.DiskPath)
+	}
+	if len(results) == 0 && verified == nil {
+		return
+	}
+	_, err := c.updateDecommissionJob(snapshot.ID, func(job *proto.DecommissionJob) bool {
+		for _, p := range job.Partitions {
+			if result, ok := results[p.ID]; ok {
+				*p = *result
+			}
+		}
+		if verified != nil && job.State == proto.DecommissionJobRunning {
+			c.verifyDecommissionJob(job, verified)
+		}
+		return true
+	})
+	if err != nil {
+		log.LogErrorf("action[runDecommissionJob] job[%v] err[%v]", snapshot.ID, err)
+	}
+}
+
+// verifyDecommissionJob checks the partitions remaining on the node or the disk after all the partitions of the job
+// are finished. The partitions created or moved onto the node meanwhile are added into the job, and the node is
+// removed from the cluster if nothing remains.
+func (c *Cluster) verifyDecommissionJob(job *proto.DecommissionJob, remaining []*proto.DecommissionPartition) {
+	partitions := make(map[uint64]*proto.DecommissionPartition, len(job.Partitions))
+	for _, p := range job.Partitions {
+		partitions[p.ID] = p
+	}
+	failed, added := 0, 0
+	for _, r := range remaining {
+		p, ok := partitions[r.ID]
+		switch {
+		case !ok:
+			job.Partitions = append(job.Partitions, r)
+			added++
+		case p.State == proto.DecommissionPartitionPending || p.State == proto.DecommissionPartitionRunning:
+			// changed in this round
+			added++
+		case p.Attempts < maxDecommissionAttempts:
+			p.State, p.Msg = proto.DecommissionPartitionPending, fmt.Sprintf("replica still on %v", job.NodeAddr)
+			added++
+		default:
+			p.State = proto.DecommissionPartitionFailed
+			failed++
+		}
+	}
+	if added > 0 {
+		return
+	}
+	if failed > 0 {
+		job.State, job.Msg = proto.DecommissionJobFailed, fmt.Sprintf("%v partitions remain on the node", failed)
+		Warn(c.Name, fmt.Sprintf("action[verifyDecommissionJob] clusterID[%v] job[%v] addr[%v] failed, %v",
+			c.Name, job.ID, job.NodeAddr, job.Msg))
+		return
+	}
+	if err := c.removeDecommissionedNode(job); err != nil {
+		job.Msg = err.Error()
+		return
+	}
+	job.State, job.Msg = proto.DecommissionJobSucceeded, ""
+	Warn(c.Name, fmt.Sprintf("action[verifyDecommissionJob] clusterID[%v] job[%v] type[%v] addr[%v] disk[%v] succeeded",
+		c.Name, job.ID, job.Type, job.NodeAddr, job.DiskPath))
+}
+
+func (c *Cluster) removeDecommissionedNode(job *proto.DecommissionJob) (err error) {
+	switch job.Type {
+	case proto.DecommissionTypeDataNode:
+		dataNode, err := c.dataNode(job.NodeAddr)
+		if err != nil {
+			return nil
+		}
+		if err = c.syncDeleteDataNode(dataNode); err != nil {
+			return err
+		}
+		c.delDataNodeFromCache(dataNode)
+	case proto.DecommissionTypeMetaNode:
+		metaNode, err := c.metaNode(job.NodeAddr)
+		if err != nil {
+			return nil
+		}
+		if err = c.syncDeleteMetaNode(metaNode); err != nil {
+			return err
+		}
+		c.deleteMetaNodeFromCache(metaNode)
+	}
+	return
+}
+
+// decommissionPartitionOnNode replaces the replica of the partition on the node by a new one.
+func (c *Cluster) decommissionPartitionOnNode(job *proto.DecommissionJob, id uint64) (err error) {
+	if job.Type == proto.DecommissionTypeMetaNode {
+		var mp *MetaPartition
+		if mp, err = c.getMetaPartitionByID(id); err != nil {
+			// the partition has been deleted
+			return nil
+		}
+		if err = c.decommissionMetaPartition(job.NodeAddr, mp); err != nil {
+			return
+		}
+		mp.RLock()
+		defer mp.RUnlock()
+		if contains(mp.Hosts, job.NodeAddr) {
+			return fmt.Errorf("replica still on %v", job.NodeAddr)
+		}
+		return
+	}
+	var dp *DataPartition
+	if dp, err = c.getDataPartitionByID(id); err != nil {
+		return nil
+	}
+	errMsg := dataNodeOfflineErr
+	if job.Type == proto.DecommissionTypeDisk {
+		errMsg = diskOfflineErr
+	}
+	if err = c.decommissionDataPartition(job.NodeAddr, dp, errMsg); err != nil {
+		return
+	}
+	if dp.isOnHost(job.NodeAddr) {
+		return fmt.Errorf("replica still on %v", job.NodeAddr)
+	}
+	return
+}
+
+// isPartitionDecommissioned returns if the replica of the partition on the node has been replaced, and the new
+// replica has recovered.
+func (c *Cluster) isPartitionDecommissioned(job *proto.DecommissionJob, id uint64) bool {
+	if job.Type == proto.DecommissionTypeMetaNode {
+		mp, err := c.getMetaPartitionByID(id)
+		if err != nil {
+			return true
+		}
+		mp.RLock()
+		defer mp.RUnlock()
+		return !contains(mp.Hosts, job.NodeAddr) && len(mp.getLiveReplicas()) >= len(mp.Hosts)
+	}
+	dp, err := c.getDataPartitionByID(id)
+	if err != nil {
+		return true
+	}
+	dp.RLock()
+	defer dp.RUnlock()
+	return !dp.hasHost(job.NodeAddr) && !dp.isRecover
+}
+
+func (c *Cluster) getDecommissionJob(id uint64) (view *proto.DecommissionJobView, err error) {
+	c.decommissionJobs.RLock()
+	defer c.decommissionJobs.RUnlock()
+	job, ok := c.decommissionJobs.jobs[id]
+	if !ok {
+		return nil, proto.ErrDecommissionJobNotExists
+	}
+	return newDecommissionJobView(job, true), nil
+}
+
+// listDecommissionJobs returns the jobs matching the filters without their partitions, in the order of their creation.
+func (c *Cluster) listDecommissionJobs(addr, state string) (views []*proto.DecommissionJobView) {
+	c.decommissionJobs.RLock()
+	views = make([]*proto.DecommissionJobView, 0)
+	for _, job := range c.decommissionJobs.jobs {
+		if (addr != "" && job.NodeAddr != addr) || (state != "" && job.State != state) {
+			continue
+		}
+		views = append(views, newDecommissionJobView(job, false))
+	}
+	c.decommissionJobs.RUnlock()
+	sort.Slice(views, func(i, j int) bool { return views[i].ID < views[j].ID })
+	return
+}
+
+func (c *Cluster) setDecommissionJobState(id uint64, from []string, to string) (view *proto.DecommissionJobView, err error) {
+	job, err := c.updateDecommissionJob(id, func(job *proto.DecommissionJob) bool {
+		for _, state := range from {
+			if job.State != state {
+				continue
+			}
+			job.State = to
+			if to != proto.DecommissionJobCancelled {
+				return true
+			}
+			// the running partitions are replaced already
+			for _, p := range job.Partitions {
+				if p.State == proto.DecommissionPartitionPending {
+					p.State, p.EndTime = proto.DecommissionPartitionCancelled, time.Now().Unix()
+				}
+			}
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return
+	}
+	if to == proto.DecommissionJobCancelled && job.Type == proto.DecommissionTypeDataNode {
+		c.undrainDecommissionedNode(job.NodeAddr)
+	}
+	Warn(c.Name, fmt.Sprintf("action[setDecommissionJobState] clusterID[%v] job[%v] addr[%v] state[%v]", c.Name, id, job.NodeAddr, to))
+	return newDecommissionJobView(job, false), nil
+}
+
+// undrainDecommissionedNode stops draining the data node whose decommission is not going on.
+func (c *Cluster) undrainDecommissionedNode(addr string) {
+	dataNode, err := c.dataNode(addr)
+	if err == nil {
+		err = c.setDataNodeDrain(dataNode, false)
+	}
+	if err != nil {
+		log.LogErrorf("action[undrainDecommissionedNode] clusterID[%v] addr[%v] err[%v]", c.Name, addr, err)
+	}
+}
+
+func parseRequestToCreateDecommissionJob(r *http.Request) (concurrency int, err error) {
+	if value := r.FormValue(concurrencyKey); value != "" {
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency <= 0 {
+			err = unmatchedKey(concurrencyKey)
+		}
+	}
+	return
+}
+
+func parseAndExtractDecommissionJobID(r *http.Request) (id uint64, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	value := r.FormValue(idKey)
+	if value == "" {
+		err = keyNotFound(idKey)
+		return
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func (m *Server) listDecommissionJobs(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(m.cluster.listDecommissionJobs(r.FormValue(addrKey), r.FormValue(stateKey))))
+}
+
+func (m *Server) getDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAndExtractDecommissionJobID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	view, err := m.cluster.getDecommissionJob(id)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
+
+func (m *Server) pauseDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	m.setDecommissionJobState(w, r, []string{proto.DecommissionJobRunning}, proto.DecommissionJobPaused)
+}
+
+func (m *Server) resumeDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	m.setDecommissionJobState(w, r, []string{proto.DecommissionJobPaused}, proto.DecommissionJobRunning)
+}
+
+func (m *Server) cancelDecommissionJob(w http.ResponseWriter, r *http.Request) {
+	m.setDecommissionJobState(w, r, []string{proto.DecommissionJobRunning, proto.DecommissionJobPaused}, proto.DecommissionJobCancelled)
+}
+
+func (m *Server) setDecommissionJobState(w http.ResponseWriter, r *http.Request, from []string, to string) {
+	id, err := parseAndExtractDecommissionJobID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	view, err := m.cluster.setDecommissionJobState(id, from, to)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
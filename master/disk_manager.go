@@ -16,6 +16,7 @@ package master
 
 import (
 	"fmt"
+	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 	"math"
@@ -78,24 +79,30 @@ func (c *Cluster) checkDiskRecoveryProgress() {
 	})
 }
 
-func (c *Cluster) decommissionDisk(dataNode *DataNode, badDiskPath string, badPartitionIds []uint64) (err error) {
-	msg := fmt.Sprintf("action[decommissionDisk], Node[%v] OffLine,disk[%v]", dataNode.Addr, badDiskPath)
-	log.LogWarn(msg)
-
-	vols := c.allVols()
-	for _, vol := range vols {
-		for _, dp := range vol.dataPartitions.partitions {
-			for _, bad := range badPartitionIds {
-				if bad == dp.PartitionID {
-					if err = c.decommissionDataPartition(dataNode.Addr, dp, diskOfflineErr); err != nil {
-						return
-					}
-				}
-			}
+// checkBrokenDisks starts to decommission the disks reported as broken by the data node,
+// so that the replicas of their data partitions are rebuilt on other nodes.
+func (c *Cluster) checkBrokenDisks(dataNode *DataNode, disks []*proto.DiskReport) {
+	if !c.cfg.AutoDecommissionDisk {
+		return
+	}
+	for _, disk := range disks {
+		if disk.Status != proto.Unavailable {
+			continue
+		}
+		badPartitionIds := dataNode.badPartitionIDs(disk.Path)
+		if len(badPartitionIds) == 0 {
+			continue
+		}
+		view, err := c.createDecommissionJob(proto.DecommissionTypeDisk, dataNode.Addr, disk.Path, 0)
+		if err == proto.ErrDuplicateDecommissionJob {
+			continue
+		}
+		if err != nil {
+			log.LogWarnf("action[checkBrokenDisks] clusterID[%v] node[%v] disk[%v] decommission failed, err[%v]",
+				c.Name, dataNode.Addr, disk.Path, err)
+			continue
 		}
+		Warn(c.Name, fmt.Sprintf("action[checkBrokenDisks] clusterID[%v] node[%v] disk[%v] is broken, decommission partitions%v automatically by job[%v]",
+			c.Name, dataNode.Addr, disk.Path, badPartitionIds, view.ID))
 	}
-	msg = fmt.Sprintf("action[decommissionDisk],clusterID[%v] Node[%v] OffLine success",
-		c.Name, dataNode.Addr)
-	Warn(c.Name, msg)
-	return
 }
@@ -18,6 +18,7 @@ import (
 	"net/http"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 	"net/http/httputil"
 )
@@ -25,7 +26,15 @@ import (
 func (m *Server) startHTTPService() {
 	go func() {
 		m.handleFunctions()
-		if err := http.ListenAndServe(colonSplit+m.port, nil); err != nil {
+		server := &http.Server{Addr: colonSplit + m.port, TLSConfig: util.TLSConfig()}
+		var err error
+		if util.TLSEnabled() {
+			// the certificate is provided by the TLS config, so that it can be rotated
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.LogErrorf("action[startHTTPService] failed,err[%v]", err)
 			panic(err)
 		}
@@ -44,11 +53,49 @@ func (m *Server) handleFunctions() {
 	http.Handle(proto.AdminGetVol, m.handlerWithInterceptor())
 	http.Handle(proto.AdminDeleteVol, m.handlerWithInterceptor())
 	http.Handle(proto.AdminUpdateVol, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolQoS, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolPlacement, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolTier, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetLifecycleRule, m.handlerWithInterceptor())
+	http.Handle(proto.AdminDeleteLifecycleRule, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetLifecycle, m.handlerWithInterceptor())
+	http.Handle(proto.ReportLifecycleRun, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetVolReplicaNum, m.handlerWithInterceptor())
 	http.Handle(proto.AdminClusterFreeze, m.handlerWithInterceptor())
 	http.Handle(proto.AddDataNode, m.handlerWithInterceptor())
 	http.Handle(proto.AddMetaNode, m.handlerWithInterceptor())
 	http.Handle(proto.DecommissionDataNode, m.handlerWithInterceptor())
+	http.Handle(proto.DrainDataNode, m.handlerWithInterceptor())
+	http.Handle(proto.SetDataNodeState, m.handlerWithInterceptor())
+	http.Handle(proto.SetMetaNodeState, m.handlerWithInterceptor())
 	http.Handle(proto.DecommissionDisk, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListVols, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListDataPartitions, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListMetaPartitions, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListDataNodes, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListMetaNodes, m.handlerWithInterceptor())
+	http.Handle(proto.AdminIssueToken, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRevokeToken, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRevokeAllTokens, m.handlerWithInterceptor())
+	http.Handle(proto.AdminSetTokenRequired, m.handlerWithInterceptor())
+	http.Handle(proto.GetTokenVerifyInfo, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCreateUser, m.handlerWithInterceptor())
+	http.Handle(proto.AdminDeleteUser, m.handlerWithInterceptor())
+	http.Handle(proto.AdminUpdateUser, m.handlerWithInterceptor())
+	http.Handle(proto.AdminResetUserKey, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListUsers, m.handlerWithInterceptor())
+	http.Handle(proto.AdminQueryAuditLog, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListTasks, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetTask, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRetryTask, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCancelTask, m.handlerWithInterceptor())
+	http.Handle(proto.AdminListDecommissionJobs, m.handlerWithInterceptor())
+	http.Handle(proto.AdminGetDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminPauseDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminResumeDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminCancelDecommissionJob, m.handlerWithInterceptor())
+	http.Handle(proto.AdminBackupMetadata, m.handlerWithInterceptor())
+	http.Handle(proto.AdminQueryUsageHistory, m.handlerWithInterceptor())
 	http.Handle(proto.DecommissionMetaNode, m.handlerWithInterceptor())
 	http.Handle(proto.GetDataNode, m.handlerWithInterceptor())
 	http.Handle(proto.GetMetaNode, m.handlerWithInterceptor())
@@ -65,15 +112,19 @@ func (m *Server) handleFunctions() {
 	http.Handle(proto.RemoveRaftNode, m.handlerWithInterceptor())
 	http.Handle(proto.AdminSetMetaNodeThreshold, m.handlerWithInterceptor())
 	http.Handle(proto.GetTopologyView, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRebalancePlan, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRebalanceProgress, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRebalancePause, m.handlerWithInterceptor())
+	http.Handle(proto.AdminRebalanceResume, m.handlerWithInterceptor())
 
 	return
 }
 
 func (m *Server) newReverseProxy() *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{Director: func(request *http.Request) {
-		request.URL.Scheme = "http"
+		request.URL.Scheme = util.HTTPScheme()
 		request.URL.Host = m.leaderInfo.addr
-	}}
+	}, Transport: util.HTTPTransport()}
 }
 
 func (m *Server) handlerWithInterceptor() http.Handler {
@@ -81,6 +132,18 @@ func (m *Server) handlerWithInterceptor() http.Handler {
 		func(w http.ResponseWriter, r *http.Request) {
 			if m.partition.IsRaftLeader() {
 				if m.metaReady {
+					if isAudited(r.URL.Path) {
+						aw := &auditResponseWriter{ResponseWriter: w}
+						r = withAuditIdentity(r)
+						defer m.cluster.audit(r, aw)
+						w = aw
+					}
+					if !m.checkPermission(w, r) {
+						return
+					}
+					if isClientView(r.URL.Path) {
+						m.setClientViewHeader(w, 0)
+					}
 					m.ServeHTTP(w, r)
 					return
 				} else {
@@ -89,11 +152,19 @@ func (m *Server) handlerWithInterceptor() http.Handler {
 					return
 				}
 			}
+			if isClientView(r.URL.Path) && m.serveClientView(w, r) {
+				return
+			}
 			if m.leaderInfo.addr == "" {
 				log.LogErrorf("action[handlerWithInterceptor] no leader,request[%v]", r.URL)
 				http.Error(w, "no leader", http.StatusBadRequest)
 				return
 			}
+			if err := m.checkNodeIdentity(r); err != nil {
+				log.LogWarnf("action[handlerWithInterceptor] denied,remoteAddr[%v] path[%v] err[%v]", r.RemoteAddr, r.URL.Path, err)
+				sendErrReply(w, r, newErrHTTPReply(proto.ErrPermissionDenied))
+				return
+			}
 			m.proxy(w, r)
 		})
 }
@@ -123,6 +194,76 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.markDeleteVol(w, r)
 	case proto.AdminUpdateVol:
 		m.updateVol(w, r)
+	case proto.AdminSetVolQoS:
+		m.setVolQoS(w, r)
+	case proto.AdminSetVolPlacement:
+		m.setVolPlacement(w, r)
+	case proto.AdminSetVolTier:
+		m.setVolTier(w, r)
+	case proto.AdminSetLifecycleRule:
+		m.setLifecycleRule(w, r)
+	case proto.AdminDeleteLifecycleRule:
+		m.deleteLifecycleRule(w, r)
+	case proto.AdminGetLifecycle:
+		m.getLifecycle(w, r)
+	case proto.ReportLifecycleRun:
+		m.reportLifecycleRun(w, r)
+	case proto.AdminSetVolReplicaNum:
+		m.setVolReplicaNum(w, r)
+	case proto.AdminListVols:
+		m.listVols(w, r)
+	case proto.AdminListDataPartitions:
+		m.listDataPartitions(w, r)
+	case proto.AdminListMetaPartitions:
+		m.listMetaPartitions(w, r)
+	case proto.AdminListDataNodes:
+		m.listDataNodes(w, r)
+	case proto.AdminListMetaNodes:
+		m.listMetaNodes(w, r)
+	case proto.AdminIssueToken:
+		m.issueToken(w, r)
+	case proto.AdminRevokeToken:
+		m.revokeToken(w, r)
+	case proto.AdminRevokeAllTokens:
+		m.revokeAllTokens(w, r)
+	case proto.AdminSetTokenRequired:
+		m.setTokenRequired(w, r)
+	case proto.GetTokenVerifyInfo:
+		m.getTokenVerifyInfo(w, r)
+	case proto.AdminCreateUser:
+		m.createUser(w, r)
+	case proto.AdminDeleteUser:
+		m.deleteUser(w, r)
+	case proto.AdminUpdateUser:
+		m.updateUser(w, r)
+	case proto.AdminResetUserKey:
+		m.resetUserKey(w, r)
+	case proto.AdminListUsers:
+		m.listUsers(w, r)
+	case proto.AdminQueryAuditLog:
+		m.queryAuditLog(w, r)
+	case proto.AdminListTasks:
+		m.listAdminTasks(w, r)
+	case proto.AdminGetTask:
+		m.getAdminTask(w, r)
+	case proto.AdminRetryTask:
+		m.retryAdminTask(w, r)
+	case proto.AdminCancelTask:
+		m.cancelAdminTask(w, r)
+	case proto.AdminListDecommissionJobs:
+		m.listDecommissionJobs(w, r)
+	case proto.AdminGetDecommissionJob:
+		m.getDecommissionJob(w, r)
+	case proto.AdminPauseDecommissionJob:
+		m.pauseDecommissionJob(w, r)
+	case proto.AdminResumeDecommissionJob:
+		m.resumeDecommissionJob(w, r)
+	case proto.AdminCancelDecommissionJob:
+		m.cancelDecommissionJob(w, r)
+	case proto.AdminBackupMetadata:
+		m.backupMetadata(w, r)
+	case proto.AdminQueryUsageHistory:
+		m.queryUsageHistory(w, r)
 	case proto.AdminClusterFreeze:
 		m.setupAutoAllocation(w, r)
 	case proto.AddDataNode:
@@ -131,6 +272,12 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.getDataNode(w, r)
 	case proto.DecommissionDataNode:
 		m.dataNodeOffline(w, r)
+	case proto.DrainDataNode:
+		m.drainDataNode(w, r)
+	case proto.SetDataNodeState:
+		m.setDataNodeAdminState(w, r)
+	case proto.SetMetaNodeState:
+		m.setMetaNodeAdminState(w, r)
 	case proto.DecommissionDisk:
 		m.decommissionDisk(w, r)
 	case proto.GetDataNodeTaskResponse:
@@ -165,6 +312,14 @@ func (m *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.setMetaNodeThreshold(w, r)
 	case proto.GetTopologyView:
 		m.getTopology(w, r)
+	case proto.AdminRebalancePlan:
+		m.planRebalance(w, r)
+	case proto.AdminRebalanceProgress:
+		m.getRebalanceProgress(w, r)
+	case proto.AdminRebalancePause:
+		m.pauseRebalance(w, r)
+	case proto.AdminRebalanceResume:
+		m.resumeRebalance(w, r)
 	default:
 
 	}
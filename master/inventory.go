@@ -0,0 +1,509 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+)
+
+const (
+	defaultInventoryLimit = 100
+	maxInventoryLimit     = 1000
+	orderAsc              = "asc"
+	orderDesc             = "desc"
+)
+
+// inventoryQuery filters, sorts and pages the volumes, partitions or nodes of the cluster.
+// A filter which is not given matches everything.
+type inventoryQuery struct {
+	status         string
+	vol            string
+	owner          string
+	host           string
+	zone           string
+	rack           string
+	minUsage       float64
+	maxUsage       float64
+	missingReplica bool
+	sortBy         string
+	desc           bool
+	offset         int
+	limit          int
+}
+
+// The fields the inventory can be sorted by. The keys are either strings or numbers converted to float64.
+var (
+	volSortKeys = map[string]func(v *proto.VolSummary) interface{}{
+		"name":           func(v *proto.VolSummary) interface{} { return v.Name },
+		"owner":          func(v *proto.VolSummary) interface{} { return v.Owner },
+		"status":         func(v *proto.VolSummary) interface{} { return v.Status },
+		"capacity":       func(v *proto.VolSummary) interface{} { return float64(v.Capacity) },
+		"used":           func(v *proto.VolSummary) interface{} { return float64(v.UsedSize) },
+		"usage":          func(v *proto.VolSummary) interface{} { return v.Usage },
+		"dataPartitions": func(v *proto.VolSummary) interface{} { return float64(v.DataPartitionCount) },
+		"metaPartitions": func(v *proto.VolSummary) interface{} { return float64(v.MetaPartitionCount) },
+	}
+	dataPartitionSortKeys = map[string]func(dp *proto.DataPartitionSummary) interface{}{
+		"id":           func(dp *proto.DataPartitionSummary) interface{} { return float64(dp.PartitionID) },
+		"vol":          func(dp *proto.DataPartitionSummary) interface{} { return dp.VolName },
+		"status":       func(dp *proto.DataPartitionSummary) interface{} { return dp.Status },
+		"used":         func(dp *proto.DataPartitionSummary) interface{} { return float64(dp.UsedSize) },
+		"usage":        func(dp *proto.DataPartitionSummary) interface{} { return dp.Usage },
+		"liveReplicas": func(dp *proto.DataPartitionSummary) interface{} { return float64(dp.LiveReplicas) },
+	}
+	metaPartitionSortKeys = map[string]func(mp *proto.MetaPartitionSummary) interface{}{
+		"id":           func(mp *proto.MetaPartitionSummary) interface{} { return float64(mp.PartitionID) },
+		"vol":          func(mp *proto.MetaPartitionSummary) interface{} { return mp.VolName },
+		"status":       func(mp *proto.MetaPartitionSummary) interface{} { return mp.Status },
+		"start":        func(mp *proto.MetaPartitionSummary) interface{} { return float64(mp.Start) },
+		"maxInodeID":   func(mp *proto.MetaPartitionSummary) interface{} { return float64(mp.MaxInodeID) },
+		"liveReplicas": func(mp *proto.MetaPartitionSummary) interface{} { return float64(mp.LiveReplicas) },
+	}
+	nodeSortKeys = map[string]func(n *proto.NodeSummary) interface{}{
+		"id":         func(n *proto.NodeSummary) interface{} { return float64(n.ID) },
+		"addr":       func(n *proto.NodeSummary) interface{} { return n.Addr },
+		"zone":       func(n *proto.NodeSummary) interface{} { return n.Zone },
+		"rack":       func(n *proto.NodeSummary) interface{} { return n.Rack },
+		"status":     func(n *proto.NodeSummary) interface{} { return n.Status },
+		"used":       func(n *proto.NodeSummary) interface{} { return float64(n.Used) },
+		"usage":      func(n *proto.NodeSummary) interface{} { return n.Usage },
+		"partitions": func(n *proto.NodeSummary) interface{} { return float64(n.PartitionCount) },
+	}
+)
+
+func parseInventoryQuery(r *http.Request, defaultSortBy string) (q *inventoryQuery, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	q = &inventoryQuery{
+		status:   r.FormValue(statusKey),
+		vol:      r.FormValue(volKey),
+		owner:    r.FormValue(volOwnerKey),
+		host:     r.FormValue(hostKey),
+		zone:     r.FormValue(zoneKey),
+		rack:     r.FormValue(rackKey),
+		maxUsage: math.MaxFloat64,
+		sortBy:   defaultSortBy,
+		limit:    defaultInventoryLimit,
+	}
+	if value := r.FormValue(minUsageKey); value != "" {
+		if q.minUsage, err = strconv.ParseFloat(value, 64); err != nil {
+			return
+		}
+	}
+	if value := r.FormValue(maxUsageKey); value != "" {
+		if q.maxUsage, err = strconv.ParseFloat(value, 64); err != nil {
+			return
+		}
+	}
+	if value := r.FormValue(missingReplicaKey); value != "" {
+		if q.missingReplica, err = strconv.ParseBool(value); err != nil {
+			return
+		}
+	}
+	if value := r.FormValue(sortKey); value != "" {
+		q.sortBy = value
+	}
+	switch order := r.FormValue(orderKey); order {
+	case "", orderAsc:
+	case orderDesc:
+		q.desc = true
+	default:
+		return nil, fmt.Errorf("parameter %v should be %v or %v", orderKey, orderAsc, orderDesc)
+	}
+	if value := r.FormValue(offsetKey); value != "" {
+		if q.offset, err = strconv.Atoi(value); err != nil {
+			return
+		}
+		if q.offset < 0 {
+			return nil, fmt.Errorf("parameter %v can't be negative", offsetKey)
+		}
+	}
+	if value := r.FormValue(limitKey); value != "" {
+		if q.limit, err = strconv.Atoi(value); err != nil {
+			return
+		}
+		if q.limit <= 0 {
+			return nil, fmt.Errorf("parameter %v should be positive", limitKey)
+		}
+		if q.limit > maxInventoryLimit {
+			q.limit = maxInventoryLimit
+		}
+	}
+	return
+}
+
+func (q *inventoryQuery) matchValue(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+func (q *inventoryQuery) matchUsage(usage float64) bool {
+	return usage >= q.minUsage && usage <= q.maxUsage
+}
+
+func (q *inventoryQuery) matchHosts(hosts []string) bool {
+	if q.host == "" {
+		return true
+	}
+	for _, host := range hosts {
+		if host == q.host {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *inventoryQuery) matchReplicas(liveReplicas int, replicaNum uint8) bool {
+	return !q.missingReplica || liveReplicas < int(replicaNum)
+}
+
+func compareInventoryKeys(a, b interface{}) (cmp int) {
+	switch a.(type) {
+	case string:
+		if a.(string) < b.(string) {
+			cmp = -1
+		} else if a.(string) > b.(string) {
+			cmp = 1
+		}
+	case float64:
+		if a.(float64) < b.(float64) {
+			cmp = -1
+		} else if a.(float64) > b.(float64) {
+			cmp = 1
+		}
+	}
+	return
+}
+
+// less orders the items by the sort keys, and the items with equal keys by the unique ids in ascending
+// order, so that the pages of the same query don't overlap.
+func (q *inventoryQuery) less(a, b, idA, idB interface{}) bool {
+	cmp := compareInventoryKeys(a, b)
+	if q.desc {
+		cmp = -cmp
+	}
+	if cmp == 0 {
+		return compareInventoryKeys(idA, idB) < 0
+	}
+	return cmp < 0
+}
+
+// page returns the bounds of the requested page among the given number of items.
+func (q *inventoryQuery) page(total int) (start, end int) {
+	start = q.offset
+	if start > total {
+		start = total
+	}
+	end = start + q.limit
+	if end > total {
+		end = total
+	}
+	return
+}
+
+func (q *inventoryQuery) newPage(total int, items interface{}) *proto.InventoryPage {
+	return &proto.InventoryPage{Total: total, Offset: q.offset, Limit: q.limit, Items: items}
+}
+
+func usageRatio(used, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(used) / float64(total)
+}
+
+func inventoryVolStatus(status uint8) string {
+	if status == markDelete {
+		return proto.InventoryStatusMarkDelete
+	}
+	return proto.InventoryStatusNormal
+}
+
+func inventoryPartitionStatus(status int8) string {
+	switch status {
+	case proto.ReadWrite:
+		return proto.InventoryStatusReadWrite
+	case proto.ReadOnly:
+		return proto.InventoryStatusReadOnly
+	default:
+		return proto.InventoryStatusUnavailable
+	}
+}
+
+func inventoryNodeStatus(active bool) string {
+	if active {
+		return proto.InventoryStatusActive
+	}
+	return proto.InventoryStatusInactive
+}
+
+// volsToQuery returns the volumes whose partitions are queried.
+func (c *Cluster) volsToQuery(q *inventoryQuery) (vols map[string]*Vol) {
+	if q.vol == "" {
+		return c.allVols()
+	}
+	vols = make(map[string]*Vol)
+	if vol, err := c.getVol(q.vol); err == nil {
+		vols[vol.Name] = vol
+	}
+	return
+}
+
+func (c *Cluster) volSummaries(q *inventoryQuery) (items []*proto.VolSummary) {
+	items = make([]*proto.VolSummary, 0)
+	for _, vol := range c.allVols() {
+		item := &proto.VolSummary{
+			Name:         vol.Name,
+			Owner:        vol.Owner,
+			Status:       inventoryVolStatus(vol.Status),
+			Capacity:     vol.Capacity,
+			UsedSize:     vol.totalUsedSpace(),
+			DpReplicaNum: vol.dpReplicaNum,
+			MpReplicaNum: vol.mpReplicaNum,
+		}
+		item.Usage = usageRatio(item.UsedSize, vol.Capacity*util.GB)
+		vol.dataPartitions.RLock()
+		item.DataPartitionCount = len(vol.dataPartitions.partitions)
+		vol.dataPartitions.RUnlock()
+		vol.mpsLock.RLock()
+		item.MetaPartitionCount = len(vol.MetaPartitions)
+		vol.mpsLock.RUnlock()
+		if !q.matchValue(q.status, item.Status) || !q.matchValue(q.vol, item.Name) ||
+			!q.matchValue(q.owner, item.Owner) || !q.matchUsage(item.Usage) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+func (c *Cluster) dataPartitionSummaries(q *inventoryQuery) (items []*proto.DataPartitionSummary) {
+	items = make([]*proto.DataPartitionSummary, 0)
+	for _, vol := range c.volsToQuery(q) {
+		vol.dataPartitions.RLock()
+		dps := make([]*DataPartition, len(vol.dataPartitions.partitions))
+		copy(dps, vol.dataPartitions.partitions)
+		vol.dataPartitions.RUnlock()
+		for _, dp := range dps {
+			item := &proto.DataPartitionSummary{UsedSize: dp.getMaxUsedSpace()}
+			item.Usage = usageRatio(item.UsedSize, vol.dataPartitionSize)
+			dp.RLock()
+			item.PartitionID = dp.PartitionID
+			item.VolName = dp.VolName
+			item.Status = inventoryPartitionStatus(dp.Status)
+			item.ReplicaNum = dp.ReplicaNum
+			item.LiveReplicas = len(dp.getLiveReplicasFromHosts(c.cfg.DataPartitionTimeOutSec))
+			item.Hosts = make([]string, len(dp.Hosts))
+			copy(item.Hosts, dp.Hosts)
+			item.LeaderAddr = dp.getLeaderAddr()
+			item.IsRecover = dp.isRecover
+			item.MediaType = dp.MediaType
+			dp.RUnlock()
+			if !q.matchValue(q.status, item.Status) || !q.matchHosts(item.Hosts) ||
+				!q.matchReplicas(item.LiveReplicas, item.ReplicaNum) || !q.matchUsage(item.Usage) {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+func (c *Cluster) metaPartitionSummaries(q *inventoryQuery) (items []*proto.MetaPartitionSummary) {
+	items = make([]*proto.MetaPartitionSummary, 0)
+	for _, vol := range c.volsToQuery(q) {
+		for _, mp := range vol.cloneMetaPartitionMap() {
+			mp.RLock()
+			item := &proto.MetaPartitionSummary{
+				PartitionID:  mp.PartitionID,
+				VolName:      mp.volName,
+				Status:       inventoryPartitionStatus(mp.Status),
+				Start:        mp.Start,
+				End:          mp.End,
+				MaxInodeID:   mp.MaxNodeID,
+				ReplicaNum:   mp.ReplicaNum,
+				LiveReplicas: len(mp.getLiveReplicas()),
+				Hosts:        make([]string, len(mp.Hosts)),
+			}
+			copy(item.Hosts, mp.Hosts)
+			for _, mr := range mp.Replicas {
+				if mr.IsLeader {
+					item.LeaderAddr = mr.Addr
+				}
+			}
+			mp.RUnlock()
+			if !q.matchValue(q.status, item.Status) || !q.matchHosts(item.Hosts) ||
+				!q.matchReplicas(item.LiveReplicas, item.ReplicaNum) {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+func (q *inventoryQuery) matchNode(item *proto.NodeSummary) bool {
+	return q.matchValue(q.status, item.Status) && q.matchValue(q.host, item.Addr) && q.matchValue(q.zone, item.Zone) &&
+		q.matchValue(q.rack, item.Rack) && q.matchUsage(item.Usage)
+}
+
+func (c *Cluster) dataNodeSummaries(q *inventoryQuery) (items []*proto.NodeSummary) {
+	items = make([]*proto.NodeSummary, 0)
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		item := &proto.NodeSummary{
+			ID:             dataNode.ID,
+			Addr:           dataNode.Addr,
+			Zone:           dataNode.ZoneName,
+			Rack:           dataNode.RackName,
+			Status:         inventoryNodeStatus(dataNode.isActive),
+			Total:          dataNode.Total,
+			Used:           dataNode.Used,
+			Usage:          dataNode.UsageRatio,
+			PartitionCount: int(dataNode.DataPartitionCount),
+			NodeSetID:      dataNode.NodeSetID,
+			ReportTime:     dataNode.ReportTime.Unix(),
+		}
+		dataNode.RUnlock()
+		if q.matchNode(item) {
+			items = append(items, item)
+		}
+		return true
+	})
+	return
+}
+
+func (c *Cluster) metaNodeSummaries(q *inventoryQuery) (items []*proto.NodeSummary) {
+	items = make([]*proto.NodeSummary, 0)
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		item := &proto.NodeSummary{
+			ID:             metaNode.ID,
+			Addr:           metaNode.Addr,
+			Zone:           metaNode.ZoneName,
+			Rack:           metaNode.RackName,
+			Status:         inventoryNodeStatus(metaNode.IsActive),
+			Total:          metaNode.Total,
+			Used:           metaNode.Used,
+			Usage:          metaNode.Ratio,
+			PartitionCount: metaNode.MetaPartitionCount,
+			NodeSetID:      metaNode.NodeSetID,
+			ReportTime:     metaNode.ReportTime.Unix(),
+		}
+		metaNode.RUnlock()
+		if q.matchNode(item) {
+			items = append(items, item)
+		}
+		return true
+	})
+	return
+}
+
+func (m *Server) listVols(w http.ResponseWriter, r *http.Request) {
+	q, err := parseInventoryQuery(r, "name")
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	keyOf, ok := volSortKeys[q.sortBy]
+	idOf := volSortKeys["name"]
+	if !ok {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("unknown %v field[%v]", sortKey, q.sortBy)})
+		return
+	}
+	items := m.cluster.volSummaries(q)
+	sort.SliceStable(items, func(i, j int) bool {
+		return q.less(keyOf(items[i]), keyOf(items[j]), idOf(items[i]), idOf(items[j]))
+	})
+	start, end := q.page(len(items))
+	sendOkReply(w, r, newSuccessHTTPReply(q.newPage(len(items), items[start:end])))
+}
+
+func (m *Server) listDataPartitions(w http.ResponseWriter, r *http.Request) {
+	q, err := parseInventoryQuery(r, "id")
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	keyOf, ok := dataPartitionSortKeys[q.sortBy]
+	idOf := dataPartitionSortKeys["id"]
+	if !ok {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("unknown %v field[%v]", sortKey, q.sortBy)})
+		return
+	}
+	items := m.cluster.dataPartitionSummaries(q)
+	sort.SliceStable(items, func(i, j int) bool {
+		return q.less(keyOf(items[i]), keyOf(items[j]), idOf(items[i]), idOf(items[j]))
+	})
+	start, end := q.page(len(items))
+	sendOkReply(w, r, newSuccessHTTPReply(q.newPage(len(items), items[start:end])))
+}
+
+func (m *Server) listMetaPartitions(w http.ResponseWriter, r *http.Request) {
+	q, err := parseInventoryQuery(r, "id")
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	keyOf, ok := metaPartitionSortKeys[q.sortBy]
+	idOf := metaPartitionSortKeys["id"]
+	if !ok {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("unknown %v field[%v]", sortKey, q.sortBy)})
+		return
+	}
+	items := m.cluster.metaPartitionSummaries(q)
+	sort.SliceStable(items, func(i, j int) bool {
+		return q.less(keyOf(items[i]), keyOf(items[j]), idOf(items[i]), idOf(items[j]))
+	})
+	start, end := q.page(len(items))
+	sendOkReply(w, r, newSuccessHTTPReply(q.newPage(len(items), items[start:end])))
+}
+
+func (m *Server) listDataNodes(w http.ResponseWriter, r *http.Request) {
+	m.listNodes(w, r, m.cluster.dataNodeSummaries)
+}
+
+func (m *Server) listMetaNodes(w http.ResponseWriter, r *http.Request) {
+	m.listNodes(w, r, m.cluster.metaNodeSummaries)
+}
+
+func (m *Server) listNodes(w http.ResponseWriter, r *http.Request, summaries func(q *inventoryQuery) []*proto.NodeSummary) {
+	q, err := parseInventoryQuery(r, "id")
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	keyOf, ok := nodeSortKeys[q.sortBy]
+	idOf := nodeSortKeys["id"]
+	if !ok {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: fmt.Sprintf("unknown %v field[%v]", sortKey, q.sortBy)})
+		return
+	}
+	items := summaries(q)
+	sort.SliceStable(items, func(i, j int) bool {
+		return q.less(keyOf(items[i]), keyOf(items[j]), idOf(items[i]), idOf(items[j]))
+	})
+	start, end := q.page(len(items))
+	sendOkReply(w, r, newSuccessHTTPReply(q.newPage(len(items), items[start:end])))
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The lifecycle rules of a volume are stored in the master, and evaluated by the meta nodes, which
+// pull the rules with the volume view and report the result of each run back to the master.
+// The reports are kept in memory only.
+
+const (
+	maxLifecycleRulesPerVol   = 16
+	maxLifecycleReportsPerVol = 64
+)
+
+func (vol *Vol) getLifecycleRules() (rules []*proto.LifecycleRule) {
+	vol.RLock()
+	defer vol.RUnlock()
+	rules = make([]*proto.LifecycleRule, 0, len(vol.lifecycleRules))
+	for _, rule := range vol.lifecycleRules {
+		r := *rule
+		rules = append(rules, &r)
+	}
+	return
+}
+
+func (vol *Vol) setLifecycleRules(rules []*proto.LifecycleRule) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.lifecycleRules = rules
+}
+
+func (vol *Vol) addLifecycleReport(report *proto.LifecycleReport) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.lifecycleReports = append(vol.lifecycleReports, report)
+	if n := len(vol.lifecycleReports); n > maxLifecycleReportsPerVol {
+		vol.lifecycleReports = vol.lifecycleReports[n-maxLifecycleReportsPerVol:]
+	}
+}
+
+func (vol *Vol) getLifecycleView() (view *proto.LifecycleView) {
+	view = &proto.LifecycleView{Rules: vol.getLifecycleRules()}
+	vol.RLock()
+	defer vol.RUnlock()
+	view.Reports = make([]*proto.LifecycleReport, len(vol.lifecycleReports))
+	copy(view.Reports, vol.lifecycleReports)
+	return
+}
+
+// setLifecycleRule adds a lifecycle rule to a volume, or replaces the rule with the same ID.
+func (c *Cluster) setLifecycleRule(name, authKey string, rule *proto.LifecycleRule) (err error) {
+	var (
+		vol      *Vol
+		rules    []*proto.LifecycleRule
+		replaced bool
+	)
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setLifecycleRule] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if err = rule.Validate(); err != nil {
+		goto errHandler
+	}
+	rules = vol.getLifecycleRules()
+	for i, r := range rules {
+		if r.ID == rule.ID {
+			rules[i] = rule
+			replaced = true
+		}
+	}
+	if !replaced {
+		if len(rules) >= maxLifecycleRulesPerVol {
+			err = fmt.Errorf("a vol can have %v lifecycle rules at most", maxLifecycleRulesPerVol)
+			goto errHandler
+		}
+		rules = append(rules, rule)
+	}
+	vol.setLifecycleRules(rules)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setLifecycleRule] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[setLifecycleRule] clusterID[%v] vol[%v] rule[%v]", c.Name, name, rule))
+	return
+errHandler:
+	err = fmt.Errorf("action[setLifecycleRule], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+func (c *Cluster) deleteLifecycleRule(name, authKey, id string) (err error) {
+	var (
+		vol   *Vol
+		rules []*proto.LifecycleRule
+		found bool
+	)
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[deleteLifecycleRule] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	for _, r := range vol.getLifecycleRules() {
+		if r.ID == id {
+			found = true
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if !found {
+		err = fmt.Errorf("lifecycle rule[%v] not found", id)
+		goto errHandler
+	}
+	vol.setLifecycleRules(rules)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[deleteLifecycleRule] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[deleteLifecycleRule] clusterID[%v] vol[%v] rule[%v]", c.Name, name, id))
+	return
+errHandler:
+	err = fmt.Errorf("action[deleteLifecycleRule], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+func (c *Cluster) addLifecycleReport(report *proto.LifecycleReport) (err error) {
+	vol, err := c.getVol(report.VolName)
+	if err != nil {
+		return proto.ErrVolNotExists
+	}
+	vol.addLifecycleReport(report)
+	log.LogInfof("action[addLifecycleReport] vol[%v] rule[%v] node[%v] scanned[%v] expired[%v] failed[%v] err[%v]",
+		report.VolName, report.RuleID, report.NodeID, report.Scanned, report.Expired, report.Failed, report.ErrMsg)
+	return
+}
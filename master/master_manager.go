@@ -116,6 +116,18 @@ func (m *Server) loadMetadata() {
 	if err = m.cluster.loadDataPartitions(); err != nil {
 		panic(err)
 	}
+	if err = m.cluster.loadUsers(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadAdminTasks(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadDecommissionJobs(); err != nil {
+		panic(err)
+	}
+	if err = m.cluster.loadRebalanceMoves(); err != nil {
+		panic(err)
+	}
 	log.LogInfo("action[loadMetadata] end")
 
 }
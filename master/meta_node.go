@@ -28,6 +28,7 @@ type MetaNode struct {
 	IsActive           bool
 	Sender             *AdminTaskManager
 	RackName           string `json:"Rack"`
+	ZoneName           string `json:"Zone"`
 	MaxMemAvailWeight  uint64 `json:"MaxMemAvailWeight"`
 	Total              uint64 `json:"TotalWeight"`
 	Used               uint64 `json:"UsedWeight"`
@@ -39,14 +40,16 @@ type MetaNode struct {
 	metaPartitionInfos []*proto.MetaPartitionReport
 	MetaPartitionCount int
 	NodeSetID          uint64
+	AdminState         string
 	sync.RWMutex
 }
 
 func newMetaNode(addr, clusterID string) (node *MetaNode) {
 	return &MetaNode{
-		Addr:   addr,
-		Sender: newAdminTaskManager(addr, clusterID),
-		Carry:  rand.Float64(),
+		Addr:       addr,
+		Sender:     newAdminTaskManager(addr, clusterID),
+		Carry:      rand.Float64(),
+		AdminState: proto.NodeStateActive,
 	}
 }
 
@@ -73,7 +76,8 @@ func (metaNode *MetaNode) isWritable() (ok bool) {
 	metaNode.RLock()
 	defer metaNode.RUnlock()
 	if metaNode.IsActive && metaNode.MaxMemAvailWeight > defaultMetaNodeReservedMem &&
-		!metaNode.reachesThreshold() && metaNode.MetaPartitionCount < defaultMaxMetaPartitionCountOnEachNode {
+		!metaNode.reachesThreshold() && metaNode.MetaPartitionCount < defaultMaxMetaPartitionCountOnEachNode &&
+		metaNode.AdminState == proto.NodeStateActive {
 		ok = true
 	}
 	return
@@ -106,7 +110,9 @@ func (metaNode *MetaNode) updateMetric(resp *proto.MetaNodeHeartbeatResponse, th
 		metaNode.Ratio = float64(resp.Used) / float64(resp.Total)
 	}
 	metaNode.MaxMemAvailWeight = resp.Total - resp.Used
-	metaNode.RackName = resp.RackName
+	if resp.RackName != "" {
+		metaNode.RackName = resp.RackName
+	}
 	metaNode.Threshold = threshold
 }
 
@@ -117,10 +123,14 @@ func (metaNode *MetaNode) reachesThreshold() bool {
 	return float32(float64(metaNode.Used)/float64(metaNode.Total)) > metaNode.Threshold
 }
 
-func (metaNode *MetaNode) createHeartbeatTask(masterAddr string) (task *proto.AdminTask) {
+func (metaNode *MetaNode) createHeartbeatTask(masterAddr string, volQoS map[string]*proto.VolQoS, volSpace map[string]*proto.VolSpace,
+	tokenVerify *proto.TokenVerifyInfo) (task *proto.AdminTask) {
 	request := &proto.HeartBeatRequest{
-		CurrTime:   time.Now().Unix(),
-		MasterAddr: masterAddr,
+		CurrTime:    time.Now().Unix(),
+		MasterAddr:  masterAddr,
+		VolQoS:      volQoS,
+		VolSpace:    volSpace,
+		TokenVerify: tokenVerify,
 	}
 	task = proto.NewAdminTask(proto.OpMetaNodeHeartbeat, metaNode.Addr, request)
 	return
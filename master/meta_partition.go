@@ -466,6 +466,17 @@ func (mp *MetaPartition) createTaskToDecommissionReplica(volName string, removeP
 	return
 }
 
+func (mp *MetaPartition) createTaskToChangeRaftMember(opCode uint8, removePeer, addPeer proto.Peer) (t *proto.AdminTask, err error) {
+	mr, err := mp.getMetaReplicaLeader()
+	if err != nil {
+		return nil, errors.NewError(err)
+	}
+	req := &proto.MetaPartitionDecommissionRequest{PartitionID: mp.PartitionID, VolName: mp.volName, RemovePeer: removePeer, AddPeer: addPeer}
+	t = proto.NewAdminTask(opCode, mr.Addr, req)
+	resetMetaPartitionTaskID(t, mp.PartitionID)
+	return
+}
+
 func resetMetaPartitionTaskID(t *proto.AdminTask, partitionID uint64) {
 	t.ID = fmt.Sprintf("%v_pid[%v]", t.ID, partitionID)
 }
@@ -521,7 +532,9 @@ func (mp *MetaPartition) updateMetricByRaft(mpv *metaPartitionValue) {
 	mp.End = mpv.End
 	mp.Peers = mpv.Peers
 	mp.Hosts = strings.Split(mpv.Hosts, underlineSeparator)
-
+	if mpv.ReplicaNum != 0 {
+		mp.ReplicaNum = mpv.ReplicaNum
+	}
 }
 
 func (mp *MetaPartition) afterCreation(nodeAddr string, c *Cluster) (err error) {
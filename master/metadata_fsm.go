@@ -23,6 +23,7 @@ import (
 	"github.com/tiglabs/raft/proto"
 	"io"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -107,10 +108,24 @@ func (mf *MetadataFsm) Apply(command []byte, index uint64) (resp interface{}, er
 	cmdMap[cmd.K] = cmd.V
 	cmdMap[applied] = []byte(strconv.FormatUint(uint64(index), 10))
 	switch cmd.Op {
-	case opSyncDeleteDataNode, opSyncDeleteMetaNode, opSyncDeleteVol, opSyncDeleteDataPartition, opSyncDeleteMetaPartition:
+	case opSyncDeleteDataNode, opSyncDeleteMetaNode, opSyncDeleteVol, opSyncDeleteDataPartition, opSyncDeleteMetaPartition,
+		opSyncDeleteClientView, opSyncDeleteUser, opSyncDeleteAdminTask, opSyncDeleteDecommission,
+		opSyncDeleteRebalanceMove:
 		if err = mf.delKeyAndPutIndex(cmd.K, cmdMap); err != nil {
 			panic(err)
 		}
+	case opSyncTrimAuditLog:
+		delete(cmdMap, cmd.K)
+		if err = mf.store.DeleteRangeAndPutIndex(auditPrefix, cmd.K, cmdMap, true); err != nil {
+			panic(err)
+		}
+	case opSyncTrimUsageHistory:
+		// the key is prefixed by the resolution and the type of the samples to trim
+		delete(cmdMap, cmd.K)
+		prefix := cmd.K[:strings.LastIndex(cmd.K, keySeparator)+1]
+		if err = mf.store.DeleteRangeAndPutIndex(prefix, cmd.K, cmdMap, true); err != nil {
+			panic(err)
+		}
 	default:
 		if err = mf.batchPut(cmdMap); err != nil {
 			panic(err)
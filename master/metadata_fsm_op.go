@@ -33,13 +33,16 @@ type clusterValue struct {
 	Name                string
 	Threshold           float32
 	DisableAutoAllocate bool
+	SigningKey          []byte `json:",omitempty"` // the seed of the key signing the volume tokens, in clear
+	SealedSigningKey    []byte `json:",omitempty"` // the seed sealed with the secret key of the masters
 }
 
-func newClusterValue(c *Cluster) (cv *clusterValue) {
+func newClusterValue(c *Cluster, signingKeySeed []byte) (cv *clusterValue) {
 	cv = &clusterValue{
 		Name:                c.Name,
 		Threshold:           c.cfg.MetaNodeThreshold,
 		DisableAutoAllocate: c.DisableAutoAllocate,
+		SigningKey:          signingKeySeed,
 	}
 	return cv
 }
@@ -80,6 +83,7 @@ type dataPartitionValue struct {
 	VolID       uint64
 	VolName     string
 	Replicas    []*replicaValue
+	MediaType   uint8
 }
 
 type replicaValue struct {
@@ -97,6 +101,7 @@ func newDataPartitionValue(dp *DataPartition) (dpv *dataPartitionValue) {
 		VolID:       dp.VolID,
 		VolName:     dp.VolName,
 		Replicas:    make([]*replicaValue, 0),
+		MediaType:   dp.MediaType,
 	}
 	for _, replica := range dp.Replicas {
 		rv := &replicaValue{Addr: replica.Addr, DiskPath: replica.DiskPath}
@@ -109,10 +114,20 @@ type volValue struct {
 	ID                uint64
 	Name              string
 	ReplicaNum        uint8
+	DpReplicaNum      uint8
 	Status            uint8
 	DataPartitionSize uint64
 	Capacity          uint64
 	Owner             string
+	QoS               bsProto.VolQoS
+	Placement         string
+	MediaType         uint8
+	EncryptionMode    uint8
+	MoveAfter         int64
+	LifecycleRules    []*bsProto.LifecycleRule
+	TokenRequired     bool
+	RevokedTokens     map[string]int64
+	TokenEpoch        uint64
 }
 
 func newVolValue(vol *Vol) (vv *volValue) {
@@ -120,39 +135,61 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		ID:                vol.ID,
 		Name:              vol.Name,
 		ReplicaNum:        vol.mpReplicaNum,
+		DpReplicaNum:      vol.dpReplicaNum,
 		Status:            vol.Status,
 		DataPartitionSize: vol.dataPartitionSize,
 		Capacity:          vol.Capacity,
 		Owner:             vol.Owner,
+		QoS:               vol.getQoS(),
+		Placement:         vol.getPlacement().String(),
+		MediaType:         vol.getMediaType(),
+		EncryptionMode:    vol.encryptionMode,
+		MoveAfter:         vol.getMoveAfter(),
+		LifecycleRules:    vol.getLifecycleRules(),
+		TokenRequired:     vol.isTokenRequired(),
+		RevokedTokens:     vol.getRevokedTokens(),
+		TokenEpoch:        vol.getTokenEpoch(),
 	}
 	return
 }
 
 type dataNodeValue struct {
-	ID        uint64
-	NodeSetID uint64
-	Addr      string
+	ID          uint64
+	NodeSetID   uint64
+	Addr        string
+	ZoneName    string
+	ToBeDrained bool
+	AdminState  string
 }
 
 func newDataNodeValue(dataNode *DataNode) *dataNodeValue {
 	return &dataNodeValue{
-		ID:        dataNode.ID,
-		NodeSetID: dataNode.NodeSetID,
-		Addr:      dataNode.Addr,
+		ID:          dataNode.ID,
+		NodeSetID:   dataNode.NodeSetID,
+		Addr:        dataNode.Addr,
+		ZoneName:    dataNode.ZoneName,
+		ToBeDrained: dataNode.ToBeDrained,
+		AdminState:  dataNode.AdminState,
 	}
 }
 
 type metaNodeValue struct {
-	ID        uint64
-	NodeSetID uint64
-	Addr      string
+	ID         uint64
+	NodeSetID  uint64
+	Addr       string
+	ZoneName   string
+	RackName   string
+	AdminState string
 }
 
 func newMetaNodeValue(metaNode *MetaNode) *metaNodeValue {
 	return &metaNodeValue{
-		ID:        metaNode.ID,
-		NodeSetID: metaNode.NodeSetID,
-		Addr:      metaNode.Addr,
+		ID:         metaNode.ID,
+		NodeSetID:  metaNode.NodeSetID,
+		Addr:       metaNode.Addr,
+		ZoneName:   metaNode.ZoneName,
+		RackName:   metaNode.RackName,
+		AdminState: metaNode.AdminState,
 	}
 }
 
@@ -224,10 +261,19 @@ func (m *RaftCmd) setOpType() {
 
 //key=#c#name
 func (c *Cluster) syncPutCluster() (err error) {
+	return c.syncPutClusterValue(newClusterValue(c, c.signingKeySeed()))
+}
+
+func (c *Cluster) syncPutClusterValue(cv *clusterValue) (err error) {
 	metadata := new(RaftCmd)
 	metadata.Op = opSyncPutCluster
 	metadata.K = clusterPrefix + c.Name
-	cv := newClusterValue(c)
+	if cv.SealedSigningKey, err = c.sealSecret(metadata.K, cv.SigningKey); err != nil {
+		return
+	}
+	if cv.SealedSigningKey != nil {
+		cv.SigningKey = nil
+	}
 	metadata.V, err = json.Marshal(cv)
 	if err != nil {
 		return
@@ -476,9 +522,23 @@ func (c *Cluster) applyPutCluster(cmd *RaftCmd) (err error) {
 	}
 	c.cfg.MetaNodeThreshold = cv.Threshold
 	c.DisableAutoAllocate = cv.DisableAutoAllocate
+	seed, err := c.signingKeySeedOf(cmd.K, cv)
+	if err != nil {
+		log.LogErrorf("action[applyPutCluster],err:%v", err.Error())
+		return
+	}
+	c.setSigningKey(seed)
 	return
 }
 
+// signingKeySeedOf returns the seed of the signing key in the cluster value, which may be sealed.
+func (c *Cluster) signingKeySeedOf(recordKey string, cv *clusterValue) (seed []byte, err error) {
+	if cv.SealedSigningKey == nil {
+		return cv.SigningKey, nil
+	}
+	return c.openSecret(recordKey, cv.SealedSigningKey)
+}
+
 func (c *Cluster) applyAddNodeSet(cmd *RaftCmd) (err error) {
 	log.LogInfof("action[applyAddNodeSet] cmd:%v", cmd.K)
 	nsv := &nodeSetValue{}
@@ -517,9 +577,21 @@ func (c *Cluster) applyAddDataNode(cmd *RaftCmd) (err error) {
 		log.LogErrorf("action[applyAddDataNode],err:%v", err.Error())
 		return
 	}
+	// the labels of a registered data node may be updated
+	if dataNode, err := c.dataNode(dnv.Addr); err == nil {
+		dataNode.Lock()
+		dataNode.ZoneName = dnv.ZoneName
+		dataNode.ToBeDrained = dnv.ToBeDrained
+		dataNode.AdminState = nodeAdminState(dnv.AdminState)
+		dataNode.Unlock()
+		return nil
+	}
 	dataNode := newDataNode(dnv.Addr, c.Name)
 	dataNode.ID = dnv.ID
 	dataNode.NodeSetID = dnv.NodeSetID
+	dataNode.ZoneName = dnv.ZoneName
+	dataNode.ToBeDrained = dnv.ToBeDrained
+	dataNode.AdminState = nodeAdminState(dnv.AdminState)
 	c.dataNodes.Store(dataNode.Addr, dataNode)
 	return
 }
@@ -545,12 +617,22 @@ func (c *Cluster) applyAddMetaNode(cmd *RaftCmd) (err error) {
 		log.LogErrorf("action[applyAddMetaNode],err:%v", err.Error())
 		return
 	}
-	if _, err = c.metaNode(mnv.Addr); err != nil {
-		metaNode := newMetaNode(mnv.Addr, c.Name)
-		metaNode.ID = mnv.ID
-		metaNode.NodeSetID = mnv.NodeSetID
-		c.metaNodes.Store(metaNode.Addr, metaNode)
-	}
+	if metaNode, err := c.metaNode(mnv.Addr); err == nil {
+		// the labels of a registered meta node may be updated
+		metaNode.Lock()
+		metaNode.ZoneName = mnv.ZoneName
+		metaNode.RackName = mnv.RackName
+		metaNode.AdminState = nodeAdminState(mnv.AdminState)
+		metaNode.Unlock()
+		return nil
+	}
+	metaNode := newMetaNode(mnv.Addr, c.Name)
+	metaNode.ID = mnv.ID
+	metaNode.NodeSetID = mnv.NodeSetID
+	metaNode.ZoneName = mnv.ZoneName
+	metaNode.RackName = mnv.RackName
+	metaNode.AdminState = nodeAdminState(mnv.AdminState)
+	c.metaNodes.Store(metaNode.Addr, metaNode)
 	return nil
 }
 
@@ -575,7 +657,19 @@ func (c *Cluster) applyAddVol(cmd *RaftCmd) (err error) {
 		log.LogError(fmt.Sprintf("action[applyAddVol] failed,err:%v", err))
 		return
 	}
-	vol := newVol(vv.ID, vv.Name, vv.Owner, vv.DataPartitionSize, vv.Capacity)
+	vol := newVol(vv.ID, vv.Name, vv.Owner, vv.DataPartitionSize, vv.Capacity, vv.DpReplicaNum, vv.ReplicaNum)
+	vol.qos = vv.QoS
+	vol.mediaType = vv.MediaType
+	vol.encryptionMode = vv.EncryptionMode
+	vol.moveAfter = vv.MoveAfter
+	vol.lifecycleRules = vv.LifecycleRules
+	vol.tokenRequired = vv.TokenRequired
+	vol.revokedTokens = vv.RevokedTokens
+	vol.tokenEpoch = vv.TokenEpoch
+	if vol.placement, err = parsePlacementPolicy(vv.Placement); err != nil {
+		log.LogError(fmt.Sprintf("action[applyAddVol] failed,err:%v", err))
+		return
+	}
 	c.putVol(vol)
 	return
 }
@@ -594,6 +688,16 @@ func (c *Cluster) applyUpdateVol(cmd *RaftCmd) (err error) {
 	}
 	vol.setStatus(vv.Status)
 	vol.setCapacity(vv.Capacity)
+	vol.setQoS(vv.QoS)
+	if vv.DpReplicaNum != 0 && vv.ReplicaNum != 0 {
+		vol.setReplicaNum(vv.DpReplicaNum, vv.ReplicaNum)
+	}
+	if placement, err := parsePlacementPolicy(vv.Placement); err == nil {
+		vol.setPlacement(placement)
+	}
+	vol.setTier(vv.MediaType, vv.MoveAfter)
+	vol.setLifecycleRules(vv.LifecycleRules)
+	vol.setTokens(vv.TokenRequired, vv.RevokedTokens, vv.TokenEpoch)
 	return
 }
 
@@ -665,6 +769,7 @@ func (c *Cluster) applyAddDataPartition(cmd *RaftCmd) (err error) {
 	dp.Hosts = strings.Split(dpv.Hosts, underlineSeparator)
 	dp.Peers = dpv.Peers
 	dp.Status = dpv.Status
+	dp.MediaType = dpv.MediaType
 	vol.dataPartitions.put(dp)
 	return
 }
@@ -692,6 +797,9 @@ func (c *Cluster) applyUpdateDataPartition(cmd *RaftCmd) (err error) {
 	}
 	dp.Hosts = strings.Split(dpv.Hosts, underlineSeparator)
 	dp.Peers = dpv.Peers
+	if dpv.ReplicaNum != 0 {
+		dp.ReplicaNum = dpv.ReplicaNum
+	}
 	return
 }
 
@@ -708,6 +816,12 @@ func (c *Cluster) loadClusterValue() (err error) {
 			return err
 		}
 		c.cfg.MetaNodeThreshold = cv.Threshold
+		var seed []byte
+		if seed, err = c.signingKeySeedOf(clusterPrefix+cv.Name, cv); err != nil {
+			log.LogErrorf("action[loadClusterValue], err:%v", err.Error())
+			return err
+		}
+		c.setSigningKey(seed)
 		log.LogInfof("action[loadClusterValue], metaNodeThreshold[%v]", cv.Threshold)
 	}
 	return
@@ -750,6 +864,9 @@ func (c *Cluster) loadDataNodes() (err error) {
 		dataNode := newDataNode(dnv.Addr, c.Name)
 		dataNode.ID = dnv.ID
 		dataNode.NodeSetID = dnv.NodeSetID
+		dataNode.ZoneName = dnv.ZoneName
+		dataNode.ToBeDrained = dnv.ToBeDrained
+		dataNode.AdminState = nodeAdminState(dnv.AdminState)
 		c.dataNodes.Store(dataNode.Addr, dataNode)
 		log.LogInfof("action[loadDataNodes],dataNode[%v]", dataNode.Addr)
 	}
@@ -771,6 +888,9 @@ func (c *Cluster) loadMetaNodes() (err error) {
 		metaNode := newMetaNode(mnv.Addr, c.Name)
 		metaNode.ID = mnv.ID
 		metaNode.NodeSetID = mnv.NodeSetID
+		metaNode.ZoneName = mnv.ZoneName
+		metaNode.RackName = mnv.RackName
+		metaNode.AdminState = nodeAdminState(mnv.AdminState)
 		c.metaNodes.Store(metaNode.Addr, metaNode)
 		log.LogInfof("action[loadMetaNodes],metaNode[%v]", metaNode.Addr)
 	}
@@ -789,8 +909,19 @@ func (c *Cluster) loadVols() (err error) {
 			err = fmt.Errorf("action[loadVols],value:%v,unmarshal err:%v", string(value), err)
 			return err
 		}
-		vol := newVol(vv.ID, vv.Name, vv.Owner, vv.DataPartitionSize, vv.Capacity)
+		vol := newVol(vv.ID, vv.Name, vv.Owner, vv.DataPartitionSize, vv.Capacity, vv.DpReplicaNum, vv.ReplicaNum)
 		vol.Status = vv.Status
+		vol.qos = vv.QoS
+		vol.mediaType = vv.MediaType
+		vol.encryptionMode = vv.EncryptionMode
+		vol.moveAfter = vv.MoveAfter
+		vol.lifecycleRules = vv.LifecycleRules
+		vol.tokenRequired = vv.TokenRequired
+		vol.revokedTokens = vv.RevokedTokens
+		vol.tokenEpoch = vv.TokenEpoch
+		if vol.placement, err = parsePlacementPolicy(vv.Placement); err != nil {
+			return
+		}
 		c.putVol(vol)
 		log.LogInfof("action[loadVols],vol[%v]", vol)
 	}
@@ -819,7 +950,11 @@ func (c *Cluster) loadMetaPartitions() (err error) {
 			Warn(c.Name, fmt.Sprintf("action[loadMetaPartitions] has duplicate vol[%v],vol.ID[%v],mpv.VolID[%v]", mpv.VolName, vol.ID, mpv.VolID))
 			continue
 		}
-		mp := newMetaPartition(mpv.PartitionID, mpv.Start, mpv.End, vol.mpReplicaNum, vol.Name, mpv.VolID)
+		replicaNum := mpv.ReplicaNum
+		if replicaNum == 0 {
+			replicaNum = vol.mpReplicaNum
+		}
+		mp := newMetaPartition(mpv.PartitionID, mpv.Start, mpv.End, replicaNum, vol.Name, mpv.VolID)
 		mp.setHosts(strings.Split(mpv.Hosts, underlineSeparator))
 		mp.setPeers(mpv.Peers)
 		vol.addMetaPartition(mp)
@@ -853,6 +988,7 @@ func (c *Cluster) loadDataPartitions() (err error) {
 		dp := newDataPartition(dpv.PartitionID, dpv.ReplicaNum, dpv.VolName, dpv.VolID)
 		dp.Hosts = strings.Split(dpv.Hosts, underlineSeparator)
 		dp.Peers = dpv.Peers
+		dp.MediaType = dpv.MediaType
 		for _,rv := range dpv.Replicas {
 			dp.afterCreation(rv.Addr,rv.DiskPath,c)
 		}
@@ -0,0 +1,168 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// The administrative state of a node keeps the new partitions and replicas off the node without decommissioning it,
+// e.g. pending a hardware repair or a kernel upgrade. A node in any state but active is not writable, so it is never
+// chosen for a new data partition or meta partition, nor as the target of a decommission or a rebalance. The writable
+// data partitions with a replica on a data node in maintenance are not counted by the auto expansion of the volumes,
+// so that enough partitions are created on the other nodes before the node is stopped.
+
+// nodeAdminState returns the state persisted for a node, which is empty for the nodes registered before the states.
+func nodeAdminState(state string) string {
+	if state == "" {
+		return proto.NodeStateActive
+	}
+	return state
+}
+
+func isValidNodeAdminState(state string) bool {
+	switch state {
+	case proto.NodeStateActive, proto.NodeStateNoNewPartitions, proto.NodeStateMaintenance:
+		return true
+	}
+	return false
+}
+
+func (c *Cluster) setDataNodeAdminState(dataNode *DataNode, state string) (err error) {
+	dataNode.RLock()
+	oldState := dataNode.AdminState
+	dataNode.RUnlock()
+	if oldState == state {
+		return
+	}
+	dataNode.Lock()
+	dataNode.AdminState = state
+	dataNode.Unlock()
+	if err = c.syncAddDataNode(dataNode); err != nil {
+		dataNode.Lock()
+		dataNode.AdminState = oldState
+		dataNode.Unlock()
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] dataNode[%v] state from [%v] to [%v]", c.Name, dataNode.Addr, oldState, state))
+	return
+}
+
+func (c *Cluster) setMetaNodeAdminState(metaNode *MetaNode, state string) (err error) {
+	metaNode.RLock()
+	oldState := metaNode.AdminState
+	metaNode.RUnlock()
+	if oldState == state {
+		return
+	}
+	metaNode.Lock()
+	metaNode.AdminState = state
+	metaNode.Unlock()
+	if err = c.syncAddMetaNode(metaNode); err != nil {
+		metaNode.Lock()
+		metaNode.AdminState = oldState
+		metaNode.Unlock()
+		return
+	}
+	Warn(c.Name, fmt.Sprintf("clusterID[%v] metaNode[%v] state from [%v] to [%v]", c.Name, metaNode.Addr, oldState, state))
+	return
+}
+
+// dataNodesInMaintenance returns the addresses of the data nodes in maintenance.
+func (c *Cluster) dataNodesInMaintenance() (addrs map[string]bool) {
+	addrs = make(map[string]bool)
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		if dataNode.AdminState == proto.NodeStateMaintenance {
+			addrs[dataNode.Addr] = true
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	return
+}
+
+// readWriteCntOnNodes returns the number of the writable partitions of the media type with a replica on one of the
+// nodes. The partitions of all the media types are counted if the type is unspecified.
+func (dpMap *DataPartitionMap) readWriteCntOnNodes(addrs map[string]bool, mediaType uint8) (cnt int) {
+	if len(addrs) == 0 {
+		return
+	}
+	dpMap.RLock()
+	defer dpMap.RUnlock()
+	for _, dp := range dpMap.partitions {
+		if dp.Status != proto.ReadWrite || (mediaType != proto.MediaUnspecified && dp.MediaType != mediaType) {
+			continue
+		}
+		for _, host := range dp.Hosts {
+			if addrs[host] {
+				cnt++
+				break
+			}
+		}
+	}
+	return
+}
+
+func parseRequestToSetNodeAdminState(r *http.Request) (addr, state string, err error) {
+	if addr, err = parseAndExtractNodeAddr(r); err != nil {
+		return
+	}
+	if state = r.FormValue(stateKey); !isValidNodeAdminState(state) {
+		err = fmt.Errorf("parameter %v should be one of %v,%v,%v", stateKey,
+			proto.NodeStateActive, proto.NodeStateNoNewPartitions, proto.NodeStateMaintenance)
+	}
+	return
+}
+
+func (m *Server) setDataNodeAdminState(w http.ResponseWriter, r *http.Request) {
+	addr, state, err := parseRequestToSetNodeAdminState(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	node, err := m.cluster.dataNode(addr)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataNodeNotExists))
+		return
+	}
+	if err = m.cluster.setDataNodeAdminState(node, state); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set state of data node [%v] to [%v] successfully", addr, state)))
+}
+
+func (m *Server) setMetaNodeAdminState(w http.ResponseWriter, r *http.Request) {
+	addr, state, err := parseRequestToSetNodeAdminState(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	node, err := m.cluster.metaNode(addr)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaNodeNotExists))
+		return
+	}
+	if err = m.cluster.setMetaNodeAdminState(node, state); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("set state of meta node [%v] to [%v] successfully", addr, state)))
+}
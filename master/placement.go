@@ -0,0 +1,353 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+const (
+	placementSpread = "spread"
+	anyOtherZone    = "*"
+)
+
+type zoneReplicaNum struct {
+	zone string
+	num  int
+}
+
+// placementPolicy decides the zones of the replicas of a partition.
+// A policy is either "spread", which places every replica in a different zone, or a list like
+// "z1:2,*:1", which places two replicas in zone z1 and one in any zone other than the listed ones.
+type placementPolicy struct {
+	spread bool
+	zones  []zoneReplicaNum
+}
+
+func parsePlacementPolicy(value string) (policy *placementPolicy, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if value == placementSpread {
+		return &placementPolicy{spread: true}, nil
+	}
+	policy = new(placementPolicy)
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, commaSplit) {
+		arr := strings.Split(strings.TrimSpace(item), colonSplit)
+		if len(arr) != 2 || arr[0] == "" || seen[arr[0]] {
+			return nil, fmt.Errorf("invalid placement policy[%v]", value)
+		}
+		var num int
+		if num, err = strconv.Atoi(arr[1]); err != nil || num <= 0 {
+			return nil, fmt.Errorf("invalid placement policy[%v]", value)
+		}
+		seen[arr[0]] = true
+		policy.zones = append(policy.zones, zoneReplicaNum{zone: arr[0], num: num})
+	}
+	return
+}
+
+func (p *placementPolicy) String() string {
+	if p == nil {
+		return ""
+	}
+	if p.spread {
+		return placementSpread
+	}
+	items := make([]string, 0, len(p.zones))
+	for _, z := range p.zones {
+		items = append(items, fmt.Sprintf("%v%v%v", z.zone, colonSplit, z.num))
+	}
+	return strings.Join(items, commaSplit)
+}
+
+// check returns an error if the policy cannot place the given number of replicas.
+func (p *placementPolicy) check(replicaNum int) (err error) {
+	if p == nil || p.spread {
+		return
+	}
+	total := 0
+	for _, z := range p.zones {
+		total += z.num
+	}
+	if total != replicaNum {
+		return fmt.Errorf("placement policy[%v] places %v replicas, but the replica number is %v", p, total, replicaNum)
+	}
+	return
+}
+
+func (p *placementPolicy) isListedZone(zone string) bool {
+	for _, z := range p.zones {
+		if z.zone == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneSlots returns the zone of every new replica, given the zones of the replicas kept.
+// An empty zone means any zone, and anyOtherZone means any zone not listed by the policy.
+func (p *placementPolicy) zoneSlots(keptZones []string, num int) (slots []string) {
+	slots = make([]string, 0, num)
+	if !p.spread {
+		kept := make(map[string]int)
+		others := 0
+		for _, zone := range keptZones {
+			kept[zone]++
+			if !p.isListedZone(zone) {
+				others++
+			}
+		}
+		for _, z := range p.zones {
+			lack := z.num - kept[z.zone]
+			if z.zone == anyOtherZone {
+				lack = z.num - others
+			}
+			for i := 0; i < lack; i++ {
+				slots = append(slots, z.zone)
+			}
+		}
+	}
+	for len(slots) < num {
+		slots = append(slots, "")
+	}
+	return slots[:num]
+}
+
+func (p *placementPolicy) isZoneAllowed(slot, zone string, usedZones map[string]int) bool {
+	if p.spread && usedZones[zone] > 0 {
+		return false
+	}
+	switch slot {
+	case "":
+		return true
+	case anyOtherZone:
+		return !p.isListedZone(zone)
+	default:
+		return zone == slot
+	}
+}
+
+// chooseNodes chooses num nodes out of the candidates, given the labels of the nodes holding the
+// replicas kept. A node in a less used zone is preferred for the slots not bound to a zone, and
+// inside a zone, the nodes on the racks not used by the partition yet are preferred.
+func (p *placementPolicy) chooseNodes(candidates SortedWeightedNodes, kept []nodeLabels, num int) (chosen []*weightedNode, err error) {
+	usedZones := make(map[string]int)
+	usedRacks := make(map[string]bool)
+	keptZones := make([]string, 0, len(kept))
+	for _, l := range kept {
+		usedZones[l.zone]++
+		usedRacks[l.rack] = true
+		keptZones = append(keptZones, l.zone)
+	}
+	if len(candidates) < num {
+		return nil, fmt.Errorf("placement policy[%v] needs %v nodes, but only %v nodes are available", p, num, len(candidates))
+	}
+	availCount := 0
+	for _, nt := range candidates {
+		if nt.Carry >= 1 {
+			availCount++
+		}
+	}
+	candidates.setNodeCarry(availCount, num)
+	sort.Sort(candidates)
+
+	picked := make(map[*weightedNode]bool)
+	for _, slot := range p.zoneSlots(keptZones, num) {
+		var (
+			best      *weightedNode
+			bestLabel nodeLabels
+		)
+		for _, nt := range candidates {
+			if picked[nt] {
+				continue
+			}
+			l := labelsOfNode(nt.Ptr)
+			if !p.isZoneAllowed(slot, l.zone, usedZones) {
+				continue
+			}
+			if best == nil || l.isBetterThan(bestLabel, usedZones, usedRacks) {
+				best, bestLabel = nt, l
+			}
+		}
+		if best == nil {
+			return nil, fmt.Errorf("placement policy[%v] finds no available node for zone[%v], kept zones%v",
+				p, slot, keptZones)
+		}
+		picked[best] = true
+		chosen = append(chosen, best)
+		usedZones[bestLabel.zone]++
+		usedRacks[bestLabel.rack] = true
+	}
+	return
+}
+
+type nodeLabels struct {
+	addr string
+	id   uint64
+	zone string
+	rack string
+}
+
+func labelsOfNode(node Node) (l nodeLabels) {
+	switch n := node.(type) {
+	case *DataNode:
+		n.RLock()
+		l = nodeLabels{addr: n.Addr, id: n.ID, zone: n.ZoneName, rack: n.RackName}
+		n.RUnlock()
+	case *MetaNode:
+		n.RLock()
+		l = nodeLabels{addr: n.Addr, id: n.ID, zone: n.ZoneName, rack: n.RackName}
+		n.RUnlock()
+	}
+	return
+}
+
+// isBetterThan compares two nodes allowed by the same slot. The candidates are sorted by the carry,
+// so the first one wins if the two are equally good.
+func (l nodeLabels) isBetterThan(other nodeLabels, usedZones map[string]int, usedRacks map[string]bool) bool {
+	if usedZones[l.zone] != usedZones[other.zone] {
+		return usedZones[l.zone] < usedZones[other.zone]
+	}
+	return !usedRacks[l.rack] && usedRacks[other.rack]
+}
+
+// chooseDataNodesByPolicy chooses the data nodes for the new replicas of a data partition by the policy.
+// The nodes in the given node set are tried first, and then the ones in the whole cluster.
+func (c *Cluster) chooseDataNodesByPolicy(policy *placementPolicy, ns *nodeSet, keptHosts, excludeHosts []string, replicaNum int, mediaType uint8) (hosts []string, peers []proto.Peer, err error) {
+	kept := make([]nodeLabels, 0, len(keptHosts))
+	for _, host := range keptHosts {
+		var dataNode *DataNode
+		if dataNode, err = c.dataNode(host); err != nil {
+			return
+		}
+		kept = append(kept, labelsOfNode(dataNode))
+	}
+	excludes := append(append([]string{}, keptHosts...), excludeHosts...)
+	var chosen []*weightedNode
+	if ns != nil {
+		chosen, err = policy.chooseNodes(c.availDataNodesForPlacement(ns, excludes, mediaType), kept, replicaNum)
+	}
+	if ns == nil || err != nil {
+		if chosen, err = policy.chooseNodes(c.availDataNodesForPlacement(nil, excludes, mediaType), kept, replicaNum); err != nil {
+			return
+		}
+	}
+	for _, nt := range chosen {
+		nt.Ptr.SelectNodeForWrite()
+		l := labelsOfNode(nt.Ptr)
+		hosts = append(hosts, l.addr)
+		peers = append(peers, proto.Peer{ID: l.id, Addr: l.addr})
+	}
+	return
+}
+
+// availDataNodesForPlacement returns the writable data nodes with the disks of the media class in the node set,
+// or in the whole cluster if ns is nil.
+func (c *Cluster) availDataNodesForPlacement(ns *nodeSet, excludeHosts []string, mediaType uint8) (nodes SortedWeightedNodes) {
+	dataNodes := make([]*DataNode, 0)
+	var maxTotal uint64
+	c.dataNodes.Range(func(key, value interface{}) bool {
+		dataNode := value.(*DataNode)
+		if ns != nil && dataNode.NodeSetID != ns.ID {
+			return true
+		}
+		if contains(excludeHosts, dataNode.Addr) || !dataNode.isWriteAbleForMedia(mediaType) {
+			return true
+		}
+		if dataNode.Total > maxTotal {
+			maxTotal = dataNode.Total
+		}
+		dataNodes = append(dataNodes, dataNode)
+		return true
+	})
+	nodes = make(SortedWeightedNodes, 0, len(dataNodes))
+	for _, dataNode := range dataNodes {
+		nt := new(weightedNode)
+		nt.Carry = dataNode.Carry
+		nt.Weight = float64(dataNode.AvailableSpace) / float64(maxTotal)
+		nt.Ptr = dataNode
+		nt.ID = dataNode.ID
+		nodes = append(nodes, nt)
+	}
+	return
+}
+
+// chooseMetaNodesByPolicy chooses the meta nodes for the new replicas of a meta partition by the policy.
+// The nodes in the given node set are tried first, and then the ones in the whole cluster.
+func (c *Cluster) chooseMetaNodesByPolicy(policy *placementPolicy, ns *nodeSet, keptHosts, excludeHosts []string, replicaNum int) (hosts []string, peers []proto.Peer, err error) {
+	kept := make([]nodeLabels, 0, len(keptHosts))
+	for _, host := range keptHosts {
+		var metaNode *MetaNode
+		if metaNode, err = c.metaNode(host); err != nil {
+			return
+		}
+		kept = append(kept, labelsOfNode(metaNode))
+	}
+	excludes := append(append([]string{}, keptHosts...), excludeHosts...)
+	var chosen []*weightedNode
+	if ns != nil {
+		chosen, err = policy.chooseNodes(c.availMetaNodesForPlacement(ns, excludes), kept, replicaNum)
+	}
+	if ns == nil || err != nil {
+		if chosen, err = policy.chooseNodes(c.availMetaNodesForPlacement(nil, excludes), kept, replicaNum); err != nil {
+			return
+		}
+	}
+	for _, nt := range chosen {
+		nt.Ptr.SelectNodeForWrite()
+		l := labelsOfNode(nt.Ptr)
+		hosts = append(hosts, l.addr)
+		peers = append(peers, proto.Peer{ID: l.id, Addr: l.addr})
+	}
+	return
+}
+
+// availMetaNodesForPlacement returns the writable meta nodes in the node set, or in the whole cluster if ns is nil.
+func (c *Cluster) availMetaNodesForPlacement(ns *nodeSet, excludeHosts []string) (nodes SortedWeightedNodes) {
+	metaNodes := make([]*MetaNode, 0)
+	var maxTotal uint64
+	c.metaNodes.Range(func(key, value interface{}) bool {
+		metaNode := value.(*MetaNode)
+		if ns != nil && metaNode.NodeSetID != ns.ID {
+			return true
+		}
+		if contains(excludeHosts, metaNode.Addr) || !metaNode.isWritable() {
+			return true
+		}
+		if metaNode.Total > maxTotal {
+			maxTotal = metaNode.Total
+		}
+		metaNodes = append(metaNodes, metaNode)
+		return true
+	})
+	nodes = make(SortedWeightedNodes, 0, len(metaNodes))
+	for _, metaNode := range metaNodes {
+		nt := new(weightedNode)
+		nt.Carry = metaNode.Carry
+		nt.Weight = float64(maxTotal-metaNode.Used) / float64(maxTotal)
+		nt.Ptr = metaNode
+		nt.ID = metaNode.ID
+		nodes = append(nodes, nt)
+	}
+	return
+}
@@ -0,0 +1,650 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// status of a rebalance move
+const (
+	rebalanceMovePending    = "pending"
+	rebalanceMoveCatchingUp = "catchingUp"
+	rebalanceMoveDone       = "done"
+	rebalanceMoveFailed     = "failed"
+	rebalanceMoveCanceled   = "canceled"
+)
+
+const (
+	defaultRebalanceThreshold   = 0.1
+	defaultRebalanceConcurrency = 2
+	defaultRebalanceMaxMoves    = 100
+	defaultIntervalToRebalance  = 10           // in terms of seconds
+	rebalanceCatchUpTimeout     = 6 * 60 * 60  // in terms of seconds
+	rebalanceMinNodeAvail       = 10 * util.GB // same as the threshold of a writable data node
+	rebalanceMaxPlanCandidates  = 1024         // the maximum number of replicas examined on a source node
+)
+
+// RebalanceMove defines the migration of a data partition replica from one data node to another.
+// The new replica is added and caught up before the old one is removed, so the number of the
+// replicas never drops below the replica number of the volume.
+type RebalanceMove struct {
+	PartitionID uint64
+	VolName     string
+	Size        uint64
+	Src         string
+	SrcDisk     string
+	Dst         string
+	Status      string
+	Msg         string
+	StartTime   int64
+	EndTime     int64
+}
+
+func (m *RebalanceMove) isRunning() bool {
+	return m.Status == rebalanceMoveCatchingUp
+}
+
+// RebalanceProgress defines the progress of the current rebalance plan.
+type RebalanceProgress struct {
+	Paused      bool
+	Threshold   float64
+	Concurrency int
+	AvgUsage    float64
+	Total       int
+	Pending     int
+	Running     int
+	Done        int
+	Failed      int
+	Canceled    int
+	PlannedSize uint64
+	MovedSize   uint64
+	Moves       []RebalanceMove
+}
+
+// rebalancer moves the data partition replicas from the most used data nodes to the least used ones.
+// The plan is kept in the memory of the leader of the masters, and is only executed by the leader.
+// The moves in progress are persisted, so that the next leader resumes them instead of leaving
+// the partitions with one more replica than their replica number.
+type rebalancer struct {
+	sync.RWMutex
+	c           *Cluster
+	paused      bool
+	threshold   float64
+	concurrency int
+	avgUsage    float64
+	moves       []*RebalanceMove
+}
+
+func newRebalancer(c *Cluster) *rebalancer {
+	return &rebalancer{
+		c:           c,
+		threshold:   defaultRebalanceThreshold,
+		concurrency: defaultRebalanceConcurrency,
+	}
+}
+
+// usage of a data node or a disk while planning
+type rebalanceUsage struct {
+	used  uint64
+	total uint64
+}
+
+func (u *rebalanceUsage) ratio() float64 {
+	if u.total == 0 {
+		return 0
+	}
+	return float64(u.used) / float64(u.total)
+}
+
+type rebalanceNode struct {
+	rebalanceUsage
+	dataNode *DataNode
+	zone     string
+	rack     *Rack
+	disks    map[string]*rebalanceUsage
+	reports  []*proto.PartitionReport
+}
+
+func (c *Cluster) scheduleToRebalance() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.rebalancer.run()
+			}
+			time.Sleep(time.Second * defaultIntervalToRebalance)
+		}
+	}()
+}
+
+// plan builds a new rebalance plan. The pending moves of the last plan are canceled, and the plan
+// is refused if there are moves still in progress.
+func (r *rebalancer) plan(threshold float64, concurrency, maxMoves int, dryRun bool) (progress *RebalanceProgress, err error) {
+	r.RLock()
+	for _, m := range r.moves {
+		if m.isRunning() {
+			r.RUnlock()
+			return nil, fmt.Errorf("rebalance move of partition[%v] from %v to %v is in progress", m.PartitionID, m.Src, m.Dst)
+		}
+	}
+	r.RUnlock()
+
+	moves, avgUsage := r.c.planRebalanceMoves(threshold, maxMoves)
+	if dryRun {
+		progress = &RebalanceProgress{Threshold: threshold, Concurrency: concurrency, AvgUsage: avgUsage}
+		for _, m := range moves {
+			progress.add(m)
+		}
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	for _, m := range r.moves {
+		if m.isRunning() {
+			return nil, fmt.Errorf("rebalance move of partition[%v] from %v to %v is in progress", m.PartitionID, m.Src, m.Dst)
+		}
+		if m.Status == rebalanceMovePending {
+			m.Status = rebalanceMoveCanceled
+		}
+	}
+	r.moves = moves
+	r.threshold = threshold
+	r.concurrency = concurrency
+	r.avgUsage = avgUsage
+	log.LogInfof("action[rebalancePlan] clusterID[%v] threshold[%v] concurrency[%v] avgUsage[%v] moves[%v]",
+		r.c.Name, threshold, concurrency, avgUsage, len(moves))
+	return r.progressWithLock(), nil
+}
+
+func (r *rebalancer) setPaused(paused bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.paused = paused
+}
+
+func (r *rebalancer) progress() *RebalanceProgress {
+	r.RLock()
+	defer r.RUnlock()
+	return r.progressWithLock()
+}
+
+func (r *rebalancer) progressWithLock() (progress *RebalanceProgress) {
+	progress = &RebalanceProgress{
+		Paused:      r.paused,
+		Threshold:   r.threshold,
+		Concurrency: r.concurrency,
+		AvgUsage:    r.avgUsage,
+	}
+	for _, m := range r.moves {
+		progress.add(m)
+	}
+	return
+}
+
+func (progress *RebalanceProgress) add(m *RebalanceMove) {
+	progress.Total++
+	progress.PlannedSize += m.Size
+	switch m.Status {
+	case rebalanceMovePending:
+		progress.Pending++
+	case rebalanceMoveCatchingUp:
+		progress.Running++
+	case rebalanceMoveDone:
+		progress.Done++
+		progress.MovedSize += m.Size
+	case rebalanceMoveFailed:
+		progress.Failed++
+	case rebalanceMoveCanceled:
+		progress.Canceled++
+	}
+	progress.Moves = append(progress.Moves, *m)
+}
+
+func (r *rebalancer) setStatus(m *RebalanceMove, status, msg string) {
+	r.Lock()
+	defer r.Unlock()
+	m.Status = status
+	m.Msg = msg
+	if status != rebalanceMoveCatchingUp {
+		m.EndTime = time.Now().Unix()
+	}
+}
+
+// run checks the moves in progress, and starts the pending ones within the concurrency budget.
+// Pausing only stops starting new moves, the ones in progress are always finished.
+func (r *rebalancer) run() {
+	r.RLock()
+	moves := make([]*RebalanceMove, len(r.moves))
+	copy(moves, r.moves)
+	paused := r.paused
+	concurrency := r.concurrency
+	r.RUnlock()
+
+	running := 0
+	for _, m := range moves {
+		if !r.isRunning(m) {
+			continue
+		}
+		r.c.checkRebalanceMove(r, m)
+		if r.isRunning(m) {
+			running++
+		}
+	}
+	for _, m := range moves {
+		if paused || running >= concurrency {
+			return
+		}
+		if !r.begin(m) {
+			continue
+		}
+		if err := r.c.syncPutRebalanceMove(m); err != nil {
+			r.setStatus(m, rebalanceMoveFailed, err.Error())
+			log.LogErrorf("action[rebalance] clusterID[%v] partitionID[%v] persist failed,err[%v]", r.c.Name, m.PartitionID, err)
+			continue
+		}
+		if err := r.c.startRebalanceMove(m); err != nil {
+			r.c.finishRebalanceMove(r, m, rebalanceMoveFailed, err.Error())
+			log.LogErrorf("action[rebalance] clusterID[%v] partitionID[%v] from[%v] to[%v] start failed,err[%v]",
+				r.c.Name, m.PartitionID, m.Src, m.Dst, err)
+			continue
+		}
+		running++
+	}
+}
+
+func (r *rebalancer) isRunning(m *RebalanceMove) bool {
+	r.RLock()
+	defer r.RUnlock()
+	return m.isRunning()
+}
+
+// isMoving returns true if a replica of the partition is being moved, so that the partition
+// has one more replica than its replica number until the source replica is removed.
+func (r *rebalancer) isMoving(partitionID uint64) bool {
+	r.RLock()
+	defer r.RUnlock()
+	for _, m := range r.moves {
+		if m.PartitionID == partitionID && m.isRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// begin marks a pending move as running, and returns false if it has been canceled.
+func (r *rebalancer) begin(m *RebalanceMove) bool {
+	r.Lock()
+	defer r.Unlock()
+	if m.Status != rebalanceMovePending {
+		return false
+	}
+	m.Status = rebalanceMoveCatchingUp
+	m.StartTime = time.Now().Unix()
+	return true
+}
+
+// planRebalanceMoves plans the moves from the data nodes whose usage is above the average by more
+// than the threshold to the ones below the average. The replicas on the most used disks of a source
+// node are moved first, and a move never reduces the number of racks a partition spans.
+func (c *Cluster) planRebalanceMoves(threshold float64, maxMoves int) (moves []*RebalanceMove, avgUsage float64) {
+	moves = make([]*RebalanceMove, 0)
+	nodes := c.rebalanceNodes()
+	var used, total uint64
+	for _, node := range nodes {
+		used += node.used
+		total += node.total
+	}
+	if total == 0 {
+		return
+	}
+	avgUsage = float64(used) / float64(total)
+	planned := make(map[uint64]bool)
+
+	for len(moves) < maxMoves {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].ratio() > nodes[j].ratio() })
+		var move *RebalanceMove
+		for _, src := range nodes {
+			if src.ratio() <= avgUsage+threshold {
+				break
+			}
+			if move = c.planRebalanceMove(src, nodes, avgUsage, planned); move != nil {
+				break
+			}
+		}
+		if move == nil {
+			break
+		}
+		planned[move.PartitionID] = true
+		moves = append(moves, move)
+	}
+	return
+}
+
+func (c *Cluster) rebalanceNodes() (nodes []*rebalanceNode) {
+	nodes = make([]*rebalanceNode, 0)
+	c.dataNodes.Range(func(addr, value interface{}) bool {
+		dataNode := value.(*DataNode)
+		rack, err := c.t.getRack(dataNode)
+		if err != nil {
+			return true
+		}
+		dataNode.RLock()
+		defer dataNode.RUnlock()
+		if !dataNode.isActive || dataNode.Total == 0 {
+			return true
+		}
+		node := &rebalanceNode{
+			rebalanceUsage: rebalanceUsage{used: dataNode.Used, total: dataNode.Total},
+			dataNode:       dataNode,
+			zone:           dataNode.ZoneName,
+			rack:           rack,
+			disks:          make(map[string]*rebalanceUsage),
+			reports:        dataNode.dataPartitionReports,
+		}
+		for _, disk := range dataNode.DiskReports {
+			node.disks[disk.Path] = &rebalanceUsage{used: disk.Used, total: disk.Total}
+		}
+		nodes = append(nodes, node)
+		return true
+	})
+	return
+}
+
+func (c *Cluster) planRebalanceMove(src *rebalanceNode, nodes []*rebalanceNode, avgUsage float64, planned map[uint64]bool) (move *RebalanceMove) {
+	reports := make([]*proto.PartitionReport, 0)
+	for _, report := range src.reports {
+		if !planned[report.PartitionID] && report.Used > 0 {
+			reports = append(reports, report)
+		}
+	}
+	diskRatio := func(path string) float64 {
+		if disk, ok := src.disks[path]; ok {
+			return disk.ratio()
+		}
+		return 0
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		ri, rj := diskRatio(reports[i].DiskPath), diskRatio(reports[j].DiskPath)
+		if ri != rj {
+			return ri > rj
+		}
+		return reports[i].Used > reports[j].Used
+	})
+	if len(reports) > rebalanceMaxPlanCandidates {
+		reports = reports[:rebalanceMaxPlanCandidates]
+	}
+
+	for _, report := range reports {
+		dp, err := c.getDataPartitionByID(report.PartitionID)
+		if err != nil {
+			continue
+		}
+		dp.RLock()
+		hosts := make([]string, len(dp.Hosts))
+		copy(hosts, dp.Hosts)
+		movable := !dp.isRecover && len(dp.Hosts) == int(dp.ReplicaNum) && dp.hasHost(src.dataNode.Addr)
+		dp.RUnlock()
+		if !movable {
+			continue
+		}
+		vol, err := c.getVol(dp.VolName)
+		if err != nil {
+			continue
+		}
+		// a replica stays in its zone to keep the placement policy satisfied
+		sameZone := vol.getPlacement() != nil
+		dst := c.chooseRebalanceTarget(src, nodes, hosts, report.Used, avgUsage, sameZone, dp.MediaType)
+		if dst == nil {
+			continue
+		}
+		src.used -= report.Used
+		dst.used += report.Used
+		if disk, ok := src.disks[report.DiskPath]; ok && disk.used >= report.Used {
+			disk.used -= report.Used
+		}
+		return &RebalanceMove{
+			PartitionID: report.PartitionID,
+			VolName:     dp.VolName,
+			Size:        report.Used,
+			Src:         src.dataNode.Addr,
+			SrcDisk:     report.DiskPath,
+			Dst:         dst.dataNode.Addr,
+			Status:      rebalanceMovePending,
+		}
+	}
+	return
+}
+
+// chooseRebalanceTarget chooses the least used node below the average which does not hold the
+// partition yet, and on which the partition keeps spanning at least as many racks as before.
+// If sameZone is true, only the nodes in the zone of the source node are chosen.
+// The target has to hold the disks of the media class of the partition.
+func (c *Cluster) chooseRebalanceTarget(src *rebalanceNode, nodes []*rebalanceNode, hosts []string, size uint64, avgUsage float64, sameZone bool, mediaType uint8) (dst *rebalanceNode) {
+	otherRacks := make(map[*Rack]bool)
+	for _, host := range hosts {
+		if host == src.dataNode.Addr {
+			continue
+		}
+		dataNode, err := c.dataNode(host)
+		if err != nil {
+			return nil
+		}
+		rack, err := c.t.getRack(dataNode)
+		if err != nil {
+			return nil
+		}
+		otherRacks[rack] = true
+	}
+	// the number of racks spanned before the move
+	spanned := len(otherRacks)
+	if !otherRacks[src.rack] {
+		spanned++
+	}
+
+	if src.used < size {
+		return nil
+	}
+	srcRatioAfter := float64(src.used-size) / float64(src.total)
+	for _, node := range nodes {
+		if node == src || contains(hosts, node.dataNode.Addr) {
+			continue
+		}
+		if sameZone && node.zone != src.zone {
+			continue
+		}
+		if !node.dataNode.hasMedia(mediaType) {
+			continue
+		}
+		if node.ratio() >= avgUsage || node.total < node.used+size+rebalanceMinNodeAvail {
+			continue
+		}
+		if float64(node.used+size)/float64(node.total) > srcRatioAfter {
+			continue
+		}
+		after := len(otherRacks)
+		if !otherRacks[node.rack] {
+			after++
+		}
+		if after < spanned {
+			continue
+		}
+		if dst == nil || node.ratio() < dst.ratio() {
+			dst = node
+		}
+	}
+	return
+}
+
+// startRebalanceMove creates the new replica and adds it into the raft group of the partition.
+func (c *Cluster) startRebalanceMove(m *RebalanceMove) (err error) {
+	var (
+		dp       *DataPartition
+		vol      *Vol
+		dataNode *DataNode
+	)
+	if dp, err = c.getDataPartitionByID(m.PartitionID); err != nil {
+		return
+	}
+	if vol, err = c.getVol(dp.VolName); err != nil {
+		return
+	}
+	if dataNode, err = c.dataNode(m.Dst); err != nil {
+		return
+	}
+	if !dataNode.isWriteAbleForMedia(dp.MediaType) {
+		return fmt.Errorf("target data node[%v] is not writable", m.Dst)
+	}
+	dpReplicaNum, _ := vol.getReplicaNum()
+	dp.Lock()
+	defer dp.Unlock()
+	if !dp.hasHost(m.Src) || dp.hasHost(m.Dst) {
+		return fmt.Errorf("hosts of the partition have changed to %v", dp.Hosts)
+	}
+	if dp.isRecover || len(dp.Hosts) != int(dpReplicaNum) {
+		return fmt.Errorf("partition is recovering or replica number[%v] mismatches", len(dp.Hosts))
+	}
+	if err = dp.hasMissingOneReplica(int(dpReplicaNum)); err != nil {
+		return
+	}
+
+	if err = c.addDataPartitionReplica(dp, vol, dataNode); err != nil {
+		return
+	}
+	dp.Status = proto.ReadOnly
+	log.LogInfof("action[startRebalanceMove] clusterID[%v] partitionID[%v] added replica on %v to replace %v",
+		c.Name, m.PartitionID, m.Dst, m.Src)
+	return
+}
+
+// checkRebalanceMove removes the old replica once the new one has caught up with the others.
+// If the new replica fails to catch up in time, it is removed instead.
+func (c *Cluster) checkRebalanceMove(r *rebalancer, m *RebalanceMove) {
+	dp, err := c.getDataPartitionByID(m.PartitionID)
+	if err != nil {
+		c.finishRebalanceMove(r, m, rebalanceMoveFailed, err.Error())
+		return
+	}
+	// the last leader may have finished or undone the move before deleting it
+	dp.RLock()
+	hasSrc, hasDst := dp.hasHost(m.Src), dp.hasHost(m.Dst)
+	dp.RUnlock()
+	if !hasDst {
+		c.finishRebalanceMove(r, m, rebalanceMoveFailed, "new replica has been removed")
+		return
+	}
+	if !hasSrc {
+		c.finishRebalanceMove(r, m, rebalanceMoveDone, "")
+		return
+	}
+	if time.Now().Unix()-m.StartTime > rebalanceCatchUpTimeout {
+		if err = c.removeDataPartitionReplica(dp, m.Dst); err != nil {
+			log.LogErrorf("action[checkRebalanceMove] partitionID[%v] remove new replica on %v err[%v]", m.PartitionID, m.Dst, err)
+			return
+		}
+		c.finishRebalanceMove(r, m, rebalanceMoveFailed, "new replica failed to catch up in time")
+		return
+	}
+	if !dp.isRebalanceReplicaCaughtUp(m.Dst, c.cfg.DataPartitionTimeOutSec) {
+		return
+	}
+	if err = c.removeDataPartitionReplica(dp, m.Src); err != nil {
+		log.LogErrorf("action[checkRebalanceMove] partitionID[%v] remove old replica on %v err[%v]", m.PartitionID, m.Src, err)
+		return
+	}
+	c.finishRebalanceMove(r, m, rebalanceMoveDone, "")
+	log.LogInfof("action[checkRebalanceMove] clusterID[%v] partitionID[%v] moved from %v to %v",
+		c.Name, m.PartitionID, m.Src, m.Dst)
+}
+
+// finishRebalanceMove deletes the move from the store before ending it, and leaves it running to be
+// checked again if the deletion fails.
+func (c *Cluster) finishRebalanceMove(r *rebalancer, m *RebalanceMove, status, msg string) {
+	if err := c.syncDeleteRebalanceMove(m.PartitionID); err != nil {
+		log.LogErrorf("action[finishRebalanceMove] partitionID[%v] delete move err[%v]", m.PartitionID, err)
+		return
+	}
+	r.setStatus(m, status, msg)
+}
+
+// isRebalanceReplicaCaughtUp returns true if the new replica holds all the extents of the others up to their
+// watermarks, as found by the last repair of the first host, and has applied the same random writes as it.
+func (partition *DataPartition) isRebalanceReplicaCaughtUp(addr string, timeOutSec int64) bool {
+	partition.RLock()
+	defer partition.RUnlock()
+	if partition.isRecover || len(partition.Hosts) == 0 || partition.Hosts[0] == addr {
+		return false
+	}
+	replica, err := partition.getReplica(addr)
+	if err != nil || !replica.isLive(timeOutSec) {
+		return false
+	}
+	leader, err := partition.getReplica(partition.Hosts[0])
+	if err != nil || !leader.isLive(timeOutSec) {
+		return false
+	}
+	return contains(leader.SyncedReplicas, addr) && replica.ApplyID == leader.ApplyID
+}
+
+// key=#rb#partitionID,value=json.Marshal(RebalanceMove)
+func (c *Cluster) syncPutRebalanceMove(m *RebalanceMove) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutRebalanceMove
+	metadata.K = rebalanceMovePrefix + strconv.FormatUint(m.PartitionID, 10)
+	if metadata.V, err = json.Marshal(m); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteRebalanceMove(partitionID uint64) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteRebalanceMove
+	metadata.K = rebalanceMovePrefix + strconv.FormatUint(partitionID, 10)
+	return c.submit(metadata)
+}
+
+// loadRebalanceMoves replaces the plan by the moves in progress, so that they are finished by this leader.
+func (c *Cluster) loadRebalanceMoves() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(rebalanceMovePrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadRebalanceMoves],err:%v", err.Error())
+		return err
+	}
+	moves := make([]*RebalanceMove, 0, len(result))
+	for _, value := range result {
+		m := new(RebalanceMove)
+		if err = json.Unmarshal(value, m); err != nil {
+			err = fmt.Errorf("action[loadRebalanceMoves],value:%v,unmarshal err:%v", string(value), err)
+			return err
+		}
+		m.Status = rebalanceMoveCatchingUp
+		moves = append(moves, m)
+		log.LogInfof("action[loadRebalanceMoves],partitionID[%v] from[%v] to[%v]", m.PartitionID, m.Src, m.Dst)
+	}
+	c.rebalancer.Lock()
+	c.rebalancer.moves = moves
+	c.rebalancer.Unlock()
+	return
+}
@@ -0,0 +1,325 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	defaultIntervalToChangeReplicaNum = 10 // in terms of seconds
+	maxReplicaNumChangesPerRound      = 8  // maximum number of the replicas added or removed in a round
+)
+
+// The replica number of a volume can be changed at any time. The partitions whose replica number
+// differs from the one of their volume are fixed in the background, by adding or removing one
+// replica of a partition at a time through the raft group of the partition.
+func (c *Cluster) scheduleToChangeReplicaNum() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.changeReplicaNum()
+			}
+			time.Sleep(time.Second * defaultIntervalToChangeReplicaNum)
+		}
+	}()
+}
+
+func (c *Cluster) changeReplicaNum() {
+	changes := 0
+	for _, vol := range c.allVols() {
+		dpReplicaNum, mpReplicaNum := vol.getReplicaNum()
+		vol.dataPartitions.RLock()
+		dps := make([]*DataPartition, len(vol.dataPartitions.partitions))
+		copy(dps, vol.dataPartitions.partitions)
+		vol.dataPartitions.RUnlock()
+		for _, dp := range dps {
+			if changes >= maxReplicaNumChangesPerRound {
+				return
+			}
+			if !dp.needsReplicaNumChange(dpReplicaNum) || c.rebalancer.isMoving(dp.PartitionID) {
+				continue
+			}
+			changes++
+			if err := c.changeDataPartitionReplicaNum(vol, dp, dpReplicaNum); err != nil {
+				log.LogWarnf("action[changeReplicaNum] vol[%v] data partition[%v] err[%v]", vol.Name, dp.PartitionID, err)
+			}
+		}
+		for _, mp := range vol.cloneMetaPartitionMap() {
+			if changes >= maxReplicaNumChangesPerRound {
+				return
+			}
+			if !mp.needsReplicaNumChange(mpReplicaNum) {
+				continue
+			}
+			changes++
+			if err := c.changeMetaPartitionReplicaNum(vol, mp, mpReplicaNum); err != nil {
+				log.LogWarnf("action[changeReplicaNum] vol[%v] meta partition[%v] err[%v]", vol.Name, mp.PartitionID, err)
+			}
+		}
+	}
+}
+
+// A partition which is recovering is left alone, as its hosts may be changed by a decommission.
+func (partition *DataPartition) needsReplicaNumChange(replicaNum uint8) bool {
+	partition.RLock()
+	defer partition.RUnlock()
+	if partition.isRecover {
+		return false
+	}
+	return partition.ReplicaNum != replicaNum || len(partition.Hosts) != int(replicaNum)
+}
+
+// setReplicaNum persists the replica number the partition is going to have.
+func (partition *DataPartition) setReplicaNum(replicaNum uint8, c *Cluster) (err error) {
+	partition.Lock()
+	defer partition.Unlock()
+	if partition.ReplicaNum == replicaNum {
+		return
+	}
+	orgReplicaNum := partition.ReplicaNum
+	partition.ReplicaNum = replicaNum
+	if err = c.syncUpdateDataPartition(partition); err != nil {
+		partition.ReplicaNum = orgReplicaNum
+	}
+	return
+}
+
+// replicaToRemove returns a replica which is missing, or the last one which is not the leader.
+func (partition *DataPartition) replicaToRemove(timeOutSec int64) (addr string) {
+	leaderAddr := partition.getLeaderAddr()
+	if leaderAddr == "" {
+		return
+	}
+	for _, host := range partition.Hosts {
+		if replica, ok := partition.hasReplica(host); !ok || !replica.isLive(timeOutSec) {
+			return host
+		}
+	}
+	for i := len(partition.Hosts) - 1; i >= 0; i-- {
+		if partition.Hosts[i] != leaderAddr {
+			return partition.Hosts[i]
+		}
+	}
+	return
+}
+
+func (c *Cluster) changeDataPartitionReplicaNum(vol *Vol, dp *DataPartition, replicaNum uint8) (err error) {
+	if err = dp.setReplicaNum(replicaNum, c); err != nil {
+		return
+	}
+	dp.RLock()
+	hostNum := len(dp.Hosts)
+	addr := dp.replicaToRemove(c.cfg.DataPartitionTimeOutSec)
+	dp.RUnlock()
+	switch {
+	case hostNum < int(replicaNum):
+		err = c.addReplicaToDataPartition(vol, dp)
+	case hostNum > int(replicaNum):
+		if addr == "" {
+			return proto.ErrNoLeader
+		}
+		err = c.removeDataPartitionReplica(dp, addr)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+	log.LogInfof("action[changeDataPartitionReplicaNum] clusterID[%v] vol[%v] partitionID[%v] replicaNum[%v] changed from %v replicas",
+		c.Name, vol.Name, dp.PartitionID, replicaNum, hostNum)
+	return
+}
+
+func (c *Cluster) addReplicaToDataPartition(vol *Vol, dp *DataPartition) (err error) {
+	var (
+		hosts    []string
+		dataNode *DataNode
+	)
+	dp.Lock()
+	defer dp.Unlock()
+	if dp.isRecover || len(dp.getLiveReplicasFromHosts(c.cfg.DataPartitionTimeOutSec)) != len(dp.Hosts) {
+		return fmt.Errorf("partition is recovering or has replicas missing, hosts%v", dp.Hosts)
+	}
+	if hosts, _, err = c.chooseTargetDataNodes(dp.Hosts, nil, 1, vol.getPlacement(), dp.MediaType); err != nil {
+		return
+	}
+	if dataNode, err = c.dataNode(hosts[0]); err != nil {
+		return
+	}
+	return c.addDataPartitionReplica(dp, vol, dataNode)
+}
+
+func (mp *MetaPartition) needsReplicaNumChange(replicaNum uint8) bool {
+	mp.RLock()
+	defer mp.RUnlock()
+	return mp.ReplicaNum != replicaNum || len(mp.Hosts) != int(replicaNum)
+}
+
+// setReplicaNum persists the replica number the partition is going to have.
+func (mp *MetaPartition) setReplicaNum(replicaNum uint8, c *Cluster) (err error) {
+	mp.Lock()
+	defer mp.Unlock()
+	if mp.ReplicaNum == replicaNum {
+		return
+	}
+	orgReplicaNum := mp.ReplicaNum
+	mp.ReplicaNum = replicaNum
+	if err = c.syncUpdateMetaPartition(mp); err != nil {
+		mp.ReplicaNum = orgReplicaNum
+	}
+	return
+}
+
+// replicaToRemove returns a replica which is missing, or the last one which is not the leader.
+func (mp *MetaPartition) replicaToRemove() (addr string) {
+	leader, err := mp.getMetaReplicaLeader()
+	if err != nil {
+		return
+	}
+	for _, host := range mp.Hosts {
+		if mr, err := mp.getMetaReplica(host); err != nil || !mr.isActive() {
+			return host
+		}
+	}
+	for i := len(mp.Hosts) - 1; i >= 0; i-- {
+		if mp.Hosts[i] != leader.Addr {
+			return mp.Hosts[i]
+		}
+	}
+	return
+}
+
+func (c *Cluster) changeMetaPartitionReplicaNum(vol *Vol, mp *MetaPartition, replicaNum uint8) (err error) {
+	if err = mp.setReplicaNum(replicaNum, c); err != nil {
+		return
+	}
+	mp.RLock()
+	hostNum := len(mp.Hosts)
+	addr := mp.replicaToRemove()
+	mp.RUnlock()
+	switch {
+	case hostNum < int(replicaNum):
+		err = c.addMetaPartitionReplica(vol, mp)
+	case hostNum > int(replicaNum):
+		if addr == "" {
+			return proto.ErrNoLeader
+		}
+		err = c.removeMetaPartitionReplica(mp, addr)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+	log.LogInfof("action[changeMetaPartitionReplicaNum] clusterID[%v] vol[%v] partitionID[%v] replicaNum[%v] changed from %v replicas",
+		c.Name, vol.Name, mp.PartitionID, replicaNum, hostNum)
+	return
+}
+
+// addMetaPartitionReplica creates a new replica of the partition, and adds it into the raft group of the partition.
+// The new replica gets the data of the partition from the snapshot sent by the leader.
+func (c *Cluster) addMetaPartitionReplica(vol *Vol, mp *MetaPartition) (err error) {
+	var (
+		hosts    []string
+		peers    []proto.Peer
+		task     *proto.AdminTask
+		oldHosts []string
+		oldPeers []proto.Peer
+	)
+	mp.Lock()
+	defer mp.Unlock()
+	if len(mp.getLiveReplicas()) != len(mp.Hosts) {
+		return fmt.Errorf("partition has replicas missing, hosts%v", mp.Hosts)
+	}
+	if hosts, peers, err = c.chooseTargetMetaHosts(mp.Hosts, nil, 1, vol.getPlacement()); err != nil {
+		return
+	}
+	oldHosts = append([]string{}, mp.Hosts...)
+	oldPeers = append([]proto.Peer{}, mp.Peers...)
+	if err = mp.persistToRocksDB(append(append([]string{}, oldHosts...), hosts[0]),
+		append(append([]proto.Peer{}, oldPeers...), peers[0]), mp.volName, c); err != nil {
+		return
+	}
+	if err = c.syncCreateMetaPartitionToMetaNode(hosts[0], mp); err != nil {
+		goto errHandler
+	}
+	if err = mp.afterCreation(hosts[0], c); err != nil {
+		goto errHandler
+	}
+	if task, err = mp.createTaskToChangeRaftMember(proto.OpAddMetaPartitionRaftMember, proto.Peer{}, peers[0]); err != nil {
+		goto errHandler
+	}
+	if err = c.syncChangeMetaPartitionRaftMember(task); err != nil {
+		goto errHandler
+	}
+	return
+
+errHandler:
+	if mr, getErr := mp.getMetaReplica(hosts[0]); getErr == nil {
+		mp.removeReplica(mr)
+		c.addMetaNodeTasks([]*proto.AdminTask{mr.createTaskToDeleteReplica(mp.PartitionID)})
+	}
+	if rollbackErr := mp.persistToRocksDB(oldHosts, oldPeers, mp.volName, c); rollbackErr != nil {
+		log.LogErrorf("action[addMetaPartitionReplica] partitionID[%v] rollback host[%v] err[%v]", mp.PartitionID, hosts[0], rollbackErr)
+	}
+	return
+}
+
+// removeMetaPartitionReplica removes the replica from the raft group and the host list of the partition.
+// The meta node deletes its replica itself once it has been removed from the raft group.
+func (c *Cluster) removeMetaPartitionReplica(mp *MetaPartition, addr string) (err error) {
+	var (
+		metaNode *MetaNode
+		task     *proto.AdminTask
+	)
+	if metaNode, err = c.metaNode(addr); err != nil {
+		return
+	}
+	mp.Lock()
+	defer mp.Unlock()
+	if !contains(mp.Hosts, addr) {
+		return
+	}
+	removePeer := proto.Peer{ID: metaNode.ID, Addr: addr}
+	if task, err = mp.createTaskToChangeRaftMember(proto.OpRemoveMetaPartitionRaftMember, removePeer, proto.Peer{}); err != nil {
+		return
+	}
+	if err = c.syncChangeMetaPartitionRaftMember(task); err != nil {
+		return
+	}
+	newHosts := make([]string, 0, len(mp.Hosts))
+	newPeers := make([]proto.Peer, 0, len(mp.Peers))
+	for _, host := range mp.Hosts {
+		if host != addr {
+			newHosts = append(newHosts, host)
+		}
+	}
+	for _, peer := range mp.Peers {
+		if peer.Addr != addr {
+			newPeers = append(newPeers, peer)
+		}
+	}
+	if err = mp.persistToRocksDB(newHosts, newPeers, mp.volName, c); err != nil {
+		return
+	}
+	mp.removeReplicaByAddr(addr)
+	mp.removeMissingReplica(addr)
+	return
+}
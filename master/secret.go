@@ -0,0 +1,82 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// The keys of the admin users and the seed of the key signing the volume tokens are replicated by raft,
+// so they are in the raft logs, the snapshots and the metadata backups. If the masters are configured with
+// a secret key, these records are sealed with AES-GCM under it, and the record key is authenticated with
+// them, so that a sealed secret cannot be moved to another record. Otherwise they are kept in clear.
+
+const secretKeySize = 32
+
+func parseSecretKey(value string) (key []byte, err error) {
+	if value == "" {
+		return
+	}
+	if key, err = hex.DecodeString(value); err != nil || len(key) != secretKeySize {
+		return nil, fmt.Errorf("%v should be %v bytes in hex", secretKey, secretKeySize)
+	}
+	return
+}
+
+// sealSecret encrypts the secret of the record, and returns nil if no secret key is configured.
+func (c *Cluster) sealSecret(recordKey string, secret []byte) (sealed []byte, err error) {
+	if c.cfg.secretKey == nil || secret == nil {
+		return
+	}
+	aead, err := newSecretAEAD(c.cfg.secretKey)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return aead.Seal(nonce, nonce, secret, []byte(recordKey)), nil
+}
+
+// openSecret decrypts the secret sealed by sealSecret.
+func (c *Cluster) openSecret(recordKey string, sealed []byte) (secret []byte, err error) {
+	if c.cfg.secretKey == nil {
+		return nil, fmt.Errorf("the secret of %v is sealed, but %v is not configured", recordKey, secretKey)
+	}
+	aead, err := newSecretAEAD(c.cfg.secretKey)
+	if err != nil {
+		return
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("the sealed secret of %v is truncated", recordKey)
+	}
+	if secret, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(recordKey)); err != nil {
+		return nil, fmt.Errorf("failed to open the secret of %v with %v, err:%v", recordKey, secretKey, err)
+	}
+	return
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
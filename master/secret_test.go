@@ -0,0 +1,62 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSealSecret(t *testing.T) {
+	key, err := parseSecretKey(strings.Repeat("01", secretKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Cluster{cfg: &clusterConfig{secretKey: key}}
+	secret := []byte("admin key")
+	sealed, err := c.sealSecret(userPrefix+"ops", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, secret) {
+		t.Fatalf("secret is kept in clear")
+	}
+	opened, err := c.openSecret(userPrefix+"ops", sealed)
+	if err != nil || !bytes.Equal(opened, secret) {
+		t.Fatalf("opened secret(%s) err(%v)", opened, err)
+	}
+	// a sealed secret cannot be moved to another record
+	if _, err = c.openSecret(userPrefix+"root", sealed); err == nil {
+		t.Fatalf("secret of another record is opened")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err = c.openSecret(userPrefix+"ops", sealed); err == nil {
+		t.Fatalf("corrupted secret is opened")
+	}
+
+	clear := &Cluster{cfg: &clusterConfig{}}
+	if sealed, err = clear.sealSecret(userPrefix+"ops", secret); err != nil || sealed != nil {
+		t.Fatalf("secret is sealed without a secret key, sealed(%v) err(%v)", sealed, err)
+	}
+	if _, err = clear.openSecret(userPrefix+"ops", []byte("sealed")); err == nil {
+		t.Fatalf("secret is opened without a secret key")
+	}
+	for _, invalid := range []string{"01", strings.Repeat("zz", secretKeySize)} {
+		if _, err = parseSecretKey(invalid); err == nil {
+			t.Fatalf("secret key(%v) is accepted", invalid)
+		}
+	}
+}
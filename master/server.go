@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/chubaofs/chubaofs/proto"
 	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/config"
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
@@ -87,6 +88,7 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 	m.cluster.partition = m.partition
 	m.cluster.idAlloc.partition = m.partition
 	m.cluster.scheduleTask()
+	m.scheduleToPublishClientViews()
 	m.startHTTPService()
 	exporter.Init(m.clusterName, ModuleName, cfg)
 	metricsService := newMonitorMetrics(m.cluster)
@@ -169,12 +171,84 @@ func (m *Server) checkConfig(cfg *config.Config) (err error) {
 			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
 		}
 	}
+	if autoDecommission := cfg.GetString(autoDecommissionDisk); autoDecommission != "" {
+		if m.config.AutoDecommissionDisk, err = strconv.ParseBool(autoDecommission); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if threshold := cfg.GetString(rebalanceThreshold); threshold != "" {
+		if m.config.RebalanceThreshold, err = strconv.ParseFloat(threshold, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if concurrency := cfg.GetString(rebalanceConcurrency); concurrency != "" {
+		if m.config.RebalanceConcurrency, err = strconv.Atoi(concurrency); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if m.config.RebalanceConcurrency <= 0 {
+		m.config.RebalanceConcurrency = defaultRebalanceConcurrency
+	}
+	if staleness := cfg.GetString(clientViewMaxStaleness); staleness != "" {
+		if m.config.ClientViewMaxStaleness, err = strconv.ParseInt(staleness, 10, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if enabled := cfg.GetString(authEnabled); enabled != "" {
+		if m.config.AuthEnabled, err = strconv.ParseBool(enabled); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if retention := cfg.GetString(auditRetentionDays); retention != "" {
+		if m.config.AuditRetentionDays, err = strconv.ParseInt(retention, 10, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if concurrency := cfg.GetString(decommissionConcurrency); concurrency != "" {
+		if m.config.DecommissionConcurrency, err = strconv.Atoi(concurrency); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if m.config.DecommissionConcurrency <= 0 {
+		m.config.DecommissionConcurrency = defaultDecommissionConcurrency
+	}
+	if ratio := cfg.GetString(volSoftLimitRatio); ratio != "" {
+		if m.config.VolSoftLimitRatio, err = strconv.ParseFloat(ratio, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if interval := cfg.GetString(usageSampleInterval); interval != "" {
+		if m.config.UsageSampleInterval, err = strconv.ParseInt(interval, 10, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	if m.config.UsageSampleInterval <= 0 {
+		m.config.UsageSampleInterval = defaultUsageSampleInterval
+	}
+	if retention := cfg.GetString(usageRetentionDays); retention != "" {
+		if m.config.UsageRetentionDays, err = strconv.ParseInt(retention, 10, 64); err != nil {
+			return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+		}
+	}
+	m.config.rootKey = cfg.GetString(rootKey)
+	if m.config.AuthEnabled && m.config.rootKey == "" {
+		return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, "rootKey is required if authEnabled is set")
+	}
+	if m.config.secretKey, err = parseSecretKey(cfg.GetString(secretKey)); err != nil {
+		return fmt.Errorf("%v,err:%v", proto.ErrInvalidCfg, err.Error())
+	}
+	if m.config.secretKey == nil {
+		log.LogWarnf("action[checkConfig] %v is not configured, the keys of the admin users and the token signing key are kept in clear in the raft store and the backups", secretKey)
+	}
+	if m.config.NodeCertOU = cfg.GetString(nodeCertOU); m.config.NodeCertOU == "" {
+		m.config.NodeCertOU = defaultNodeCertOU
+	}
 
 	return
 }
 
 func (m *Server) createRaftServer() (err error) {
-	raftCfg := &raftstore.Config{NodeID: m.id, RaftPath: m.walDir, NumOfLogsToRetain: m.retainLogs}
+	raftCfg := &raftstore.Config{NodeID: m.id, RaftPath: m.walDir, NumOfLogsToRetain: m.retainLogs, TLSConfig: util.TLSConfig()}
 	if m.raftStore, err = raftstore.NewRaftStore(raftCfg); err != nil {
 		return errors.Trace(err, "NewRaftStore failed! id[%v] walPath[%v]", m.id, m.walDir)
 	}
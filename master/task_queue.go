@@ -0,0 +1,536 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The admin tasks sent to the nodes, except the heartbeats, are queued through raft, so that the new leader
+// sends the pending ones again. The senders of the nodes resend a task a few times until it is answered,
+// and drop it then. A task dropped without an answer is retried with a back-off, until it fails after
+// maxTaskAttempts. A task is identified by the node, the operation and the partition, so a task is not
+// queued again while the same one is pending or running. A task which can't be persisted is neither queued
+// nor sent, and the changes of the queue are persisted one by one without holding the lock of the queue.
+
+const (
+	taskNodeTypeData                  = "dataNode"
+	taskNodeTypeMeta                  = "metaNode"
+	maxTaskAttempts                   = 3
+	defaultTaskRetryBackoff           = 60      // in terms of seconds, doubled by every attempt
+	maxTaskRetryBackoff               = 30 * 60 // in terms of seconds
+	defaultFinishedTaskRetention      = 24 * 3600
+	defaultIntervalToCheckAdminTasks  = 10 // in terms of seconds
+	errTaskDroppedWithoutResponse     = "no response from the node"
+	errTaskNodeNotExists              = "node not exists"
+	errTaskCancelledByTheAdmin        = "cancelled by the admin"
+	errTaskStateResetByLeaderChange   = "resent by the new leader"
+	errTaskRetriedByTheAdmin          = "retried by the admin"
+	errTaskResponseStatusNotSucceeded = "task failed on the node"
+)
+
+var taskTypeNames = map[uint8]string{
+	proto.OpCreateDataPartition:           "createDataPartition",
+	proto.OpDeleteDataPartition:           "deleteDataPartition",
+	proto.OpLoadDataPartition:             "loadDataPartition",
+	proto.OpDecommissionDataPartition:     "decommissionDataPartition",
+	proto.OpAddDataPartitionRaftMember:    "addDataPartitionRaftMember",
+	proto.OpRemoveDataPartitionRaftMember: "removeDataPartitionRaftMember",
+	proto.OpCreateMetaPartition:           "createMetaPartition",
+	proto.OpDeleteMetaPartition:           "deleteMetaPartition",
+	proto.OpUpdateMetaPartition:           "updateMetaPartition",
+	proto.OpLoadMetaPartition:             "loadMetaPartition",
+	proto.OpDecommissionMetaPartition:     "decommissionMetaPartition",
+	proto.OpAddMetaPartitionRaftMember:    "addMetaPartitionRaftMember",
+	proto.OpRemoveMetaPartitionRaftMember: "removeMetaPartitionRaftMember",
+}
+
+func taskTypeName(opCode uint8) string {
+	if name, ok := taskTypeNames[opCode]; ok {
+		return name
+	}
+	return fmt.Sprintf("op[%v]", opCode)
+}
+
+// adminTaskValue is an admin task in the queue, the request is kept as sent to the node.
+type adminTaskValue struct {
+	ID            string
+	OpCode        uint8
+	NodeAddr      string
+	NodeType      string
+	Request       json.RawMessage
+	State         string
+	Attempts      int
+	CreateTime    int64
+	UpdateTime    int64
+	NextRetryTime int64
+	LastError     string
+}
+
+func newAdminTaskValue(t *proto.AdminTask, nodeType string) (tv *adminTaskValue, err error) {
+	tv = &adminTaskValue{
+		ID:         t.ID,
+		OpCode:     t.OpCode,
+		NodeAddr:   t.OperatorAddr,
+		NodeType:   nodeType,
+		State:      proto.TaskStateRunning,
+		Attempts:   1,
+		CreateTime: time.Now().Unix(),
+		UpdateTime: time.Now().Unix(),
+	}
+	tv.Request, err = json.Marshal(t.Request)
+	return
+}
+
+func (tv *adminTaskValue) isFinished() bool {
+	return tv.State == proto.TaskStateSucceeded || tv.State == proto.TaskStateFailed || tv.State == proto.TaskStateCancelled
+}
+
+func (tv *adminTaskValue) task() *proto.AdminTask {
+	return &proto.AdminTask{ID: tv.ID, OpCode: tv.OpCode, OperatorAddr: tv.NodeAddr, CreateTime: time.Now().Unix(), Request: tv.Request}
+}
+
+func (tv *adminTaskValue) view() *proto.AdminTaskView {
+	return &proto.AdminTaskView{
+		ID:            tv.ID,
+		Type:          taskTypeName(tv.OpCode),
+		NodeAddr:      tv.NodeAddr,
+		NodeType:      tv.NodeType,
+		State:         tv.State,
+		Attempts:      tv.Attempts,
+		CreateTime:    tv.CreateTime,
+		UpdateTime:    tv.UpdateTime,
+		NextRetryTime: tv.NextRetryTime,
+		LastError:     tv.LastError,
+		Request:       tv.Request,
+	}
+}
+
+// retryBackoff returns the seconds to wait before the next attempt.
+func (tv *adminTaskValue) retryBackoff() int64 {
+	backoff := int64(defaultTaskRetryBackoff)
+	for i := 1; i < tv.Attempts && backoff < maxTaskRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxTaskRetryBackoff {
+		backoff = maxTaskRetryBackoff
+	}
+	return backoff
+}
+
+type adminTaskQueue struct {
+	sync.RWMutex
+	tasks       map[string]*adminTaskValue
+	persistLock sync.Mutex // orders the changes persisted through raft with the ones of the memory
+}
+
+func newAdminTaskQueue() *adminTaskQueue {
+	return &adminTaskQueue{tasks: make(map[string]*adminTaskValue)}
+}
+
+//key=#task#taskID,value=json.Marshal(adminTaskValue)
+func (c *Cluster) syncPutAdminTask(tv *adminTaskValue) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutAdminTask
+	metadata.K = adminTaskPrefix + tv.ID
+	if metadata.V, err = json.Marshal(tv); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+func (c *Cluster) syncDeleteAdminTask(id string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncDeleteAdminTask
+	metadata.K = adminTaskPrefix + id
+	return c.submit(metadata)
+}
+
+// loadAdminTasks loads the queue, the tasks running on the old leader are sent again.
+func (c *Cluster) loadAdminTasks() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(adminTaskPrefix))
+	if err != nil {
+		err = fmt.Errorf("action[loadAdminTasks],err:%v", err.Error())
+		return err
+	}
+	tasks := make(map[string]*adminTaskValue, len(result))
+	for _, value := range result {
+		tv := new(adminTaskValue)
+		if err = json.Unmarshal(value, tv); err != nil {
+			err = fmt.Errorf("action[loadAdminTasks],value:%v,unmarshal err:%v", string(value), err)
+			return err
+		}
+		if tv.State == proto.TaskStateRunning {
+			tv.State = proto.TaskStatePending
+			tv.NextRetryTime = time.Now().Unix()
+			tv.LastError = errTaskStateResetByLeaderChange
+		}
+		tasks[tv.ID] = tv
+	}
+	c.taskQueue.Lock()
+	c.taskQueue.tasks = tasks
+	c.taskQueue.Unlock()
+	log.LogInfof("action[loadAdminTasks],load %v tasks", len(tasks))
+	return
+}
+
+// enqueueAdminTask queues the task sent to the node, it returns false if the same task is pending or running,
+// or the task can't be persisted, and then the task must not be sent.
+func (c *Cluster) enqueueAdminTask(t *proto.AdminTask, nodeType string) bool {
+	if t.IsHeartbeatTask() {
+		return true
+	}
+	tv, err := newAdminTaskValue(t, nodeType)
+	if err != nil {
+		log.LogErrorf("action[enqueueAdminTask] task[%v] err[%v]", t.ID, err)
+		return false
+	}
+	if err = c.updateAdminTask(nil, tv); err == proto.ErrInvalidTaskState {
+		log.LogDebugf("action[enqueueAdminTask] task[%v] is pending or running", t.ID)
+		return false
+	} else if err != nil {
+		log.LogErrorf("action[enqueueAdminTask] task[%v] err[%v]", t.ID, err)
+		return false
+	}
+	return true
+}
+
+// updateAdminTask persists the next state of the task and replaces the task in the queue, unless the task
+// has been changed since old was read. old is nil for a new task, which replaces only a finished one.
+func (c *Cluster) updateAdminTask(old, next *adminTaskValue) (err error) {
+	q := c.taskQueue
+	q.persistLock.Lock()
+	defer q.persistLock.Unlock()
+	q.RLock()
+	cur, ok := q.tasks[next.ID]
+	q.RUnlock()
+	if old == nil && ok && !cur.isFinished() || old != nil && cur != old {
+		return proto.ErrInvalidTaskState
+	}
+	if err = c.syncPutAdminTask(next); err != nil {
+		return
+	}
+	q.Lock()
+	q.tasks[next.ID] = next
+	q.Unlock()
+	return
+}
+
+// deleteAdminTask deletes the task from the queue, unless the task has been changed since it was read.
+func (c *Cluster) deleteAdminTask(old *adminTaskValue) (err error) {
+	q := c.taskQueue
+	q.persistLock.Lock()
+	defer q.persistLock.Unlock()
+	q.RLock()
+	cur := q.tasks[old.ID]
+	q.RUnlock()
+	if cur != old {
+		return proto.ErrInvalidTaskState
+	}
+	if err = c.syncDeleteAdminTask(old.ID); err != nil {
+		return
+	}
+	q.Lock()
+	delete(q.tasks, old.ID)
+	q.Unlock()
+	return
+}
+
+// finishAdminTask records the response of the node to a running task.
+func (c *Cluster) finishAdminTask(t *proto.AdminTask) {
+	if t.IsHeartbeatTask() {
+		return
+	}
+	var result struct {
+		Status int8
+		Result string
+	}
+	if data, err := json.Marshal(t.Response); err == nil {
+		json.Unmarshal(data, &result)
+	}
+	c.taskQueue.RLock()
+	tv, ok := c.taskQueue.tasks[t.ID]
+	c.taskQueue.RUnlock()
+	if !ok || tv.State != proto.TaskStateRunning {
+		return
+	}
+	next := *tv
+	next.UpdateTime = time.Now().Unix()
+	if result.Status == proto.TaskSucceeds {
+		next.State, next.LastError = proto.TaskStateSucceeded, ""
+	} else {
+		next.State, next.LastError = proto.TaskStateFailed, result.Result
+		if next.LastError == "" {
+			next.LastError = errTaskResponseStatusNotSucceeded
+		}
+	}
+	if err := c.updateAdminTask(tv, &next); err != nil {
+		log.LogErrorf("action[finishAdminTask] task[%v] state[%v] err[%v]", t.ID, next.State, err)
+	}
+}
+
+func (c *Cluster) scheduleToCheckAdminTasks() {
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.checkAdminTasks()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheckAdminTasks)
+		}
+	}()
+}
+
+// checkAdminTasks sends the pending tasks which are due, retries the ones dropped by the senders
+// without an answer, and deletes the finished ones after the retention.
+func (c *Cluster) checkAdminTasks() {
+	c.taskQueue.RLock()
+	tasks := make([]*adminTaskValue, 0, len(c.taskQueue.tasks))
+	for _, tv := range c.taskQueue.tasks {
+		tasks = append(tasks, tv)
+	}
+	c.taskQueue.RUnlock()
+	now := time.Now().Unix()
+	for _, tv := range tasks {
+		var (
+			id   = tv.ID
+			next = *tv
+			err  error
+		)
+		switch tv.State {
+		case proto.TaskStatePending:
+			if tv.NextRetryTime > now {
+				continue
+			}
+			next.UpdateTime = now
+			if !c.sendAdminTask(tv) {
+				next.State, next.LastError = proto.TaskStateFailed, errTaskNodeNotExists
+			} else {
+				next.State, next.NextRetryTime = proto.TaskStateRunning, 0
+			}
+			err = c.updateAdminTask(tv, &next)
+		case proto.TaskStateRunning:
+			if c.isAdminTaskSending(tv) {
+				continue
+			}
+			next.UpdateTime, next.LastError = now, errTaskDroppedWithoutResponse
+			if tv.Attempts >= maxTaskAttempts {
+				next.State = proto.TaskStateFailed
+				Warn(c.Name, fmt.Sprintf("clusterID[%v] task[%v] failed after %v attempts", c.Name, id, tv.Attempts))
+			} else {
+				next.State = proto.TaskStatePending
+				next.Attempts++
+				next.NextRetryTime = now + tv.retryBackoff()
+			}
+			err = c.updateAdminTask(tv, &next)
+		default:
+			if now-tv.UpdateTime < defaultFinishedTaskRetention {
+				continue
+			}
+			err = c.deleteAdminTask(tv)
+		}
+		if err != nil && err != proto.ErrInvalidTaskState {
+			log.LogErrorf("action[checkAdminTasks] task[%v] state[%v] err[%v]", id, tv.State, err)
+		}
+	}
+}
+
+// sendAdminTask adds the task to the sender of the node, it returns false if the node does not exist.
+func (c *Cluster) sendAdminTask(tv *adminTaskValue) bool {
+	if tv.NodeType == taskNodeTypeMeta {
+		metaNode, err := c.metaNode(tv.NodeAddr)
+		if err != nil {
+			return false
+		}
+		metaNode.Sender.AddTask(tv.task())
+		return true
+	}
+	dataNode, err := c.dataNode(tv.NodeAddr)
+	if err != nil {
+		return false
+	}
+	dataNode.TaskManager.AddTask(tv.task())
+	return true
+}
+
+func (c *Cluster) isAdminTaskSending(tv *adminTaskValue) bool {
+	if tv.NodeType == taskNodeTypeMeta {
+		metaNode, err := c.metaNode(tv.NodeAddr)
+		return err == nil && metaNode.Sender.hasTask(tv.ID)
+	}
+	dataNode, err := c.dataNode(tv.NodeAddr)
+	return err == nil && dataNode.TaskManager.hasTask(tv.ID)
+}
+
+func (c *Cluster) removeAdminTaskFromSender(tv *adminTaskValue) {
+	if tv.NodeType == taskNodeTypeMeta {
+		if metaNode, err := c.metaNode(tv.NodeAddr); err == nil {
+			metaNode.Sender.DelTask(tv.task())
+		}
+		return
+	}
+	if dataNode, err := c.dataNode(tv.NodeAddr); err == nil {
+		dataNode.TaskManager.DelTask(tv.task())
+	}
+}
+
+func (c *Cluster) getAdminTask(id string) (view *proto.AdminTaskView, err error) {
+	c.taskQueue.RLock()
+	defer c.taskQueue.RUnlock()
+	tv, ok := c.taskQueue.tasks[id]
+	if !ok {
+		return nil, proto.ErrTaskNotExists
+	}
+	return tv.view(), nil
+}
+
+// listAdminTasks returns the tasks matching the filters in the order of their creation.
+func (c *Cluster) listAdminTasks(nodeAddr, taskType, state string, q *inventoryQuery) (page *proto.InventoryPage) {
+	c.taskQueue.RLock()
+	views := make([]*proto.AdminTaskView, 0)
+	for _, tv := range c.taskQueue.tasks {
+		if (nodeAddr != "" && tv.NodeAddr != nodeAddr) || (state != "" && tv.State != state) ||
+			(taskType != "" && taskTypeName(tv.OpCode) != taskType) {
+			continue
+		}
+		views = append(views, tv.view())
+	}
+	c.taskQueue.RUnlock()
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].CreateTime != views[j].CreateTime {
+			return views[i].CreateTime < views[j].CreateTime
+		}
+		return views[i].ID < views[j].ID
+	})
+	start, end := q.page(len(views))
+	return q.newPage(len(views), views[start:end])
+}
+
+// retryAdminTask sends a failed or cancelled task again at once.
+func (c *Cluster) retryAdminTask(id string) (view *proto.AdminTaskView, err error) {
+	c.taskQueue.RLock()
+	tv, ok := c.taskQueue.tasks[id]
+	c.taskQueue.RUnlock()
+	if !ok {
+		return nil, proto.ErrTaskNotExists
+	}
+	if tv.State != proto.TaskStateFailed && tv.State != proto.TaskStateCancelled {
+		return nil, proto.ErrInvalidTaskState
+	}
+	next := *tv
+	next.State, next.Attempts, next.LastError = proto.TaskStatePending, 1, errTaskRetriedByTheAdmin
+	next.UpdateTime, next.NextRetryTime = time.Now().Unix(), time.Now().Unix()
+	if err = c.updateAdminTask(tv, &next); err == proto.ErrInvalidTaskState {
+		return nil, err
+	} else if err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	Warn(c.Name, fmt.Sprintf("action[retryAdminTask] clusterID[%v] task[%v]", c.Name, id))
+	return next.view(), nil
+}
+
+// cancelAdminTask stops sending a pending or running task, the node may have executed it already.
+func (c *Cluster) cancelAdminTask(id string) (view *proto.AdminTaskView, err error) {
+	c.taskQueue.RLock()
+	tv, ok := c.taskQueue.tasks[id]
+	c.taskQueue.RUnlock()
+	if !ok {
+		return nil, proto.ErrTaskNotExists
+	}
+	if tv.isFinished() {
+		return nil, proto.ErrInvalidTaskState
+	}
+	next := *tv
+	next.State, next.LastError = proto.TaskStateCancelled, errTaskCancelledByTheAdmin
+	next.UpdateTime, next.NextRetryTime = time.Now().Unix(), 0
+	if err = c.updateAdminTask(tv, &next); err == proto.ErrInvalidTaskState {
+		return nil, err
+	} else if err != nil {
+		return nil, proto.ErrPersistenceByRaft
+	}
+	c.removeAdminTaskFromSender(tv)
+	Warn(c.Name, fmt.Sprintf("action[cancelAdminTask] clusterID[%v] task[%v]", c.Name, id))
+	return next.view(), nil
+}
+
+func parseAndExtractTaskID(r *http.Request) (id string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if id = r.FormValue(idKey); id == "" {
+		err = keyNotFound(idKey)
+	}
+	return
+}
+
+func (m *Server) listAdminTasks(w http.ResponseWriter, r *http.Request) {
+	// the tasks are filtered by their own parameters, only the paging of the inventory is used
+	q, err := parseInventoryQuery(r, "")
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	page := m.cluster.listAdminTasks(r.FormValue(addrKey), r.FormValue(typeKey), r.FormValue(stateKey), q)
+	sendOkReply(w, r, newSuccessHTTPReply(page))
+}
+
+func (m *Server) getAdminTask(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAndExtractTaskID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	view, err := m.cluster.getAdminTask(id)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
+
+func (m *Server) retryAdminTask(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAndExtractTaskID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	view, err := m.cluster.retryAdminTask(id)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
+
+func (m *Server) cancelAdminTask(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAndExtractTaskID(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	view, err := m.cluster.cancelAdminTask(id)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(view))
+}
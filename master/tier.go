@@ -0,0 +1,150 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// A volume with a default media class is tiered. It keeps writable data partitions of every media class
+// available in the cluster, so that the files or directories of any class can be written, and the files
+// can be moved from ssd to hdd by the mover once they have not been accessed for a while.
+
+// hasMedia returns true if the data node has a writable disk of the media class.
+func (dataNode *DataNode) hasMedia(mediaType uint8) bool {
+	if mediaType == proto.MediaUnspecified {
+		return true
+	}
+	dataNode.RLock()
+	defer dataNode.RUnlock()
+	for _, disk := range dataNode.DiskReports {
+		if disk.MediaType == mediaType && disk.Status == proto.ReadWrite && disk.Available > 10*util.GB {
+			return true
+		}
+	}
+	return false
+}
+
+func (dataNode *DataNode) isWriteAbleForMedia(mediaType uint8) bool {
+	return dataNode.isWriteAble() && dataNode.hasMedia(mediaType)
+}
+
+// availMediaTypes returns the media classes of the writable data nodes.
+func (c *Cluster) availMediaTypes() (mediaTypes []uint8) {
+	found := make(map[uint8]bool)
+	c.dataNodes.Range(func(key, value interface{}) bool {
+		dataNode := value.(*DataNode)
+		if !dataNode.isWriteAble() {
+			return true
+		}
+		for _, mediaType := range proto.MediaTypes {
+			if !found[mediaType] && dataNode.hasMedia(mediaType) {
+				found[mediaType] = true
+			}
+		}
+		return true
+	})
+	for _, mediaType := range proto.MediaTypes {
+		if found[mediaType] {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	return
+}
+
+func (dpMap *DataPartitionMap) readWriteCntOfMedia(mediaType uint8) (cnt int) {
+	dpMap.RLock()
+	defer dpMap.RUnlock()
+	for _, dp := range dpMap.partitions {
+		if dp.MediaType == mediaType && dp.Status == proto.ReadWrite {
+			cnt++
+		}
+	}
+	return
+}
+
+func (vol *Vol) setTier(mediaType uint8, moveAfter int64) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.mediaType = mediaType
+	vol.moveAfter = moveAfter
+}
+
+func (vol *Vol) getMediaType() uint8 {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.mediaType
+}
+
+func (vol *Vol) getMoveAfter() int64 {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.moveAfter
+}
+
+// autoCreateTieredDataPartitions keeps enough writable data partitions of every media class in a tiered vol.
+func (vol *Vol) autoCreateTieredDataPartitions(c *Cluster) {
+	inMaintenance := c.dataNodesInMaintenance()
+	for _, mediaType := range c.availMediaTypes() {
+		if vol.dataPartitions.readWriteCntOfMedia(mediaType)-vol.dataPartitions.readWriteCntOnNodes(inMaintenance, mediaType) >= minNumOfRWDataPartitions {
+			continue
+		}
+		count := vol.calculateExpansionNum()
+		log.LogInfof("action[autoCreateTieredDataPartitions] vol[%v] mediaType[%v] count[%v]",
+			vol.Name, proto.MediaTypeString(mediaType), count)
+		for i := 0; i < count; i++ {
+			if _, err := c.createDataPartition(vol.Name, mediaType); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// setVolTier changes the default media class of a volume and the time after which a file not accessed is
+// moved from ssd to hdd. The data partitions of the classes are created in the background.
+func (c *Cluster) setVolTier(name, authKey string, mediaType uint8, moveAfter int64) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setVolTier] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if mediaType == proto.MediaUnspecified && moveAfter > 0 {
+		err = fmt.Errorf("files can only be moved in a vol with a default media type")
+		goto errHandler
+	}
+	vol.setTier(mediaType, moveAfter)
+	if err = c.syncUpdateVol(vol); err != nil {
+		log.LogErrorf("action[setVolTier] vol[%v] err[%v]", name, err)
+		err = proto.ErrPersistenceByRaft
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[setVolTier] clusterID[%v] vol[%v] mediaType[%v] moveAfter[%v]",
+		c.Name, name, proto.MediaTypeString(mediaType), moveAfter))
+	return
+errHandler:
+	err = fmt.Errorf("action[setVolTier], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
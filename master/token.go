@@ -0,0 +1,340 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The master signs the volume tokens with a key generated when the first token is issued, and
+// replicated with the cluster value. Once a volume requires the tokens, the mounts, the deletion and the
+// management of the tokens of the volume need a valid token besides the auth key of the owner.
+// The meta nodes and data nodes verify the tokens with the public key pushed through the heartbeats.
+
+const (
+	defaultTokenTTL = 24 * 3600       // in terms of seconds
+	maxTokenTTL     = 365 * 24 * 3600 // in terms of seconds
+	// The services on the meta nodes, such as the lifecycle scanner, get tokens renewed by every heartbeat.
+	serviceTokenTTL = 10 * defaultIntervalToCheckHeartbeat
+)
+
+func (vol *Vol) isTokenRequired() bool {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.tokenRequired
+}
+
+func (vol *Vol) isTokenRevoked(t *proto.VolumeToken) bool {
+	vol.RLock()
+	defer vol.RUnlock()
+	_, ok := vol.revokedTokens[t.ID]
+	return ok || t.Epoch < vol.tokenEpoch
+}
+
+func (vol *Vol) getTokenEpoch() uint64 {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.tokenEpoch
+}
+
+// getRevokedTokens returns the revoked tokens which have not expired yet.
+func (vol *Vol) getRevokedTokens() (revoked map[string]int64) {
+	vol.RLock()
+	defer vol.RUnlock()
+	now := time.Now().Unix()
+	revoked = make(map[string]int64, len(vol.revokedTokens))
+	for id, expire := range vol.revokedTokens {
+		if expire >= now {
+			revoked[id] = expire
+		}
+	}
+	return
+}
+
+func (vol *Vol) setTokens(required bool, revoked map[string]int64, epoch uint64) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.tokenRequired = required
+	vol.revokedTokens = revoked
+	vol.tokenEpoch = epoch
+}
+
+// initSigningKey generates the key to sign the volume tokens if the cluster has none yet.
+func (c *Cluster) initSigningKey() (err error) {
+	c.signingKeyMutex.Lock()
+	defer c.signingKeyMutex.Unlock()
+	if c.signingKey != nil {
+		return
+	}
+	var key ed25519.PrivateKey
+	if _, key, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		return
+	}
+	if err = c.syncPutClusterValue(newClusterValue(c, key.Seed())); err != nil {
+		return
+	}
+	c.signingKey = key
+	return
+}
+
+func (c *Cluster) setSigningKey(seed []byte) {
+	if len(seed) != ed25519.SeedSize {
+		return
+	}
+	c.signingKeyMutex.Lock()
+	defer c.signingKeyMutex.Unlock()
+	c.signingKey = ed25519.NewKeyFromSeed(seed)
+}
+
+// getSigningKey returns the key to sign the volume tokens, nil if no token has been issued yet.
+func (c *Cluster) getSigningKey() ed25519.PrivateKey {
+	c.signingKeyMutex.Lock()
+	defer c.signingKeyMutex.Unlock()
+	return c.signingKey
+}
+
+func (c *Cluster) signingKeySeed() []byte {
+	key := c.getSigningKey()
+	if key == nil {
+		return nil
+	}
+	return key.Seed()
+}
+
+// checkVolToken checks the token presented to access a volume, which can only be omitted
+// if the volume does not require the tokens.
+func (c *Cluster) checkVolToken(vol *Vol, token string, write bool) (err error) {
+	if token == "" {
+		if vol.isTokenRequired() {
+			return proto.ErrTokenRequired
+		}
+		return
+	}
+	key := c.getSigningKey()
+	if key == nil {
+		return proto.ErrInvalidToken
+	}
+	var t *proto.VolumeToken
+	if t, err = proto.ParseVolumeToken(token, key.Public().(ed25519.PublicKey)); err != nil {
+		return
+	}
+	if vol.isTokenRevoked(t) {
+		return proto.ErrTokenRevoked
+	}
+	return t.Allows(vol.Name, write)
+}
+
+func (c *Cluster) issueToken(name, authKey, token, mode string, ttl int64) (signed string, t *proto.VolumeToken, err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[issueToken] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		err = proto.ErrVolAuthKeyNotMatch
+		return
+	}
+	if err = c.checkVolToken(vol, token, true); err != nil {
+		return
+	}
+	if signed, t, err = c.signToken(vol, mode, ttl); err != nil {
+		goto errHandler
+	}
+	log.LogWarnf("action[issueToken] clusterID[%v] vol[%v] %v", c.Name, name, t)
+	return
+errHandler:
+	err = fmt.Errorf("action[issueToken], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+// signToken signs a new token of the volume in the current token epoch of the volume.
+func (c *Cluster) signToken(vol *Vol, mode string, ttl int64) (signed string, t *proto.VolumeToken, err error) {
+	id := make([]byte, 16)
+	if err = c.initSigningKey(); err != nil {
+		return
+	}
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	t = &proto.VolumeToken{ID: hex.EncodeToString(id), VolName: vol.Name, Mode: mode, Expire: time.Now().Unix() + ttl,
+		Epoch: vol.getTokenEpoch()}
+	signed, err = t.Sign(c.getSigningKey())
+	return
+}
+
+// revokeToken revokes the token of a volume with the given ID. The expiration of the token is
+// unknown, so it is kept revoked as long as a token can live.
+func (c *Cluster) revokeToken(name, authKey, token, id string) (err error) {
+	var (
+		vol     *Vol
+		revoked map[string]int64
+	)
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[revokeToken] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if err = c.checkVolToken(vol, token, true); err != nil {
+		return
+	}
+	revoked = vol.getRevokedTokens()
+	revoked[id] = time.Now().Unix() + maxTokenTTL
+	if err = c.syncUpdateVolTokens(vol, vol.isTokenRequired(), revoked, vol.getTokenEpoch()); err != nil {
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[revokeToken] clusterID[%v] vol[%v] token[%v]", c.Name, name, id))
+	return
+errHandler:
+	err = fmt.Errorf("action[revokeToken], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+// revokeAllTokens revokes all the tokens issued for a volume so far by starting a new token epoch,
+// and returns a new read-write token, so that the owner is not locked out of the volume.
+func (c *Cluster) revokeAllTokens(name, authKey, token string) (signed string, t *proto.VolumeToken, err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[revokeAllTokens] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		err = proto.ErrVolAuthKeyNotMatch
+		return
+	}
+	if err = c.checkVolToken(vol, token, true); err != nil {
+		return
+	}
+	if err = c.syncUpdateVolTokens(vol, vol.isTokenRequired(), vol.getRevokedTokens(), vol.getTokenEpoch()+1); err != nil {
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[revokeAllTokens] clusterID[%v] vol[%v] epoch[%v]", c.Name, name, vol.getTokenEpoch()))
+	if signed, t, err = c.signToken(vol, proto.TokenModeReadWrite, defaultTokenTTL); err != nil {
+		goto errHandler
+	}
+	return
+errHandler:
+	err = fmt.Errorf("action[revokeToken], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+// setTokenRequired makes a volume require the tokens or not. Requiring the tokens needs a read-write
+// token issued before, so that the owner is not locked out of the volume.
+func (c *Cluster) setTokenRequired(name, authKey, token string, required bool) (err error) {
+	var vol *Vol
+	if vol, err = c.getVol(name); err != nil {
+		log.LogErrorf("action[setTokenRequired] err[%v]", err)
+		err = proto.ErrVolNotExists
+		goto errHandler
+	}
+	if !matchKey(vol.Owner, authKey) {
+		return proto.ErrVolAuthKeyNotMatch
+	}
+	if required && token == "" {
+		return proto.ErrTokenRequired
+	}
+	if err = c.checkVolToken(vol, token, true); err != nil {
+		return
+	}
+	if err = c.syncUpdateVolTokens(vol, required, vol.getRevokedTokens(), vol.getTokenEpoch()); err != nil {
+		goto errHandler
+	}
+	Warn(c.Name, fmt.Sprintf("action[setTokenRequired] clusterID[%v] vol[%v] required[%v]", c.Name, name, required))
+	return
+errHandler:
+	err = fmt.Errorf("action[setTokenRequired], clusterID[%v] name:%v, err:%v ", c.Name, name, err.Error())
+	log.LogError(errors.Stack(err))
+	Warn(c.Name, err.Error())
+	return
+}
+
+func (c *Cluster) syncUpdateVolTokens(vol *Vol, required bool, revoked map[string]int64, epoch uint64) (err error) {
+	oldRequired, oldRevoked, oldEpoch := vol.isTokenRequired(), vol.getRevokedTokens(), vol.getTokenEpoch()
+	vol.setTokens(required, revoked, epoch)
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.setTokens(oldRequired, oldRevoked, oldEpoch)
+		return proto.ErrPersistenceByRaft
+	}
+	return
+}
+
+// issueServiceTokens issues the read-write tokens of the enforced volumes to the services on the meta nodes,
+// and to the data nodes for the packets sent among the replicas.
+func (c *Cluster) issueServiceTokens(enforcedVols []string) (tokens map[string]string) {
+	tokens = make(map[string]string, len(enforcedVols))
+	key := c.getSigningKey()
+	if key == nil {
+		return
+	}
+	id := make([]byte, 16)
+	for _, name := range enforcedVols {
+		vol, err := c.getVol(name)
+		if err != nil {
+			continue
+		}
+		if _, err = rand.Read(id); err != nil {
+			log.LogErrorf("action[issueServiceTokens] vol[%v] err[%v]", name, err)
+			continue
+		}
+		t := &proto.VolumeToken{ID: hex.EncodeToString(id), VolName: name, Mode: proto.TokenModeReadWrite,
+			Expire: time.Now().Unix() + serviceTokenTTL, Epoch: vol.getTokenEpoch()}
+		signed, err := t.Sign(key)
+		if err != nil {
+			log.LogErrorf("action[issueServiceTokens] vol[%v] err[%v]", name, err)
+			continue
+		}
+		tokens[name] = signed
+	}
+	return
+}
+
+// tokenVerifyInfo returns what the meta nodes and data nodes need to verify the tokens.
+func (c *Cluster) tokenVerifyInfo() (info *proto.TokenVerifyInfo) {
+	info = &proto.TokenVerifyInfo{EnforcedVols: make([]string, 0), Revoked: make([]*proto.RevokedToken, 0),
+		Epochs: make(map[string]uint64)}
+	if key := c.getSigningKey(); key != nil {
+		info.PublicKey = key.Public().(ed25519.PublicKey)
+	}
+	for _, vol := range c.allVols() {
+		if vol.isTokenRequired() {
+			info.EnforcedVols = append(info.EnforcedVols, vol.Name)
+		}
+		if epoch := vol.getTokenEpoch(); epoch != 0 {
+			info.Epochs[vol.Name] = epoch
+		}
+		for id, expire := range vol.getRevokedTokens() {
+			info.Revoked = append(info.Revoked, &proto.RevokedToken{ID: id, VolName: vol.Name, Expire: expire})
+		}
+	}
+	return
+}
@@ -0,0 +1,403 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The leader samples the usage of the volumes and the nodes periodically, and persists the samples through raft.
+// The raw samples are rolled up to the hourly averages, and the hourly ones to the daily averages, each keyed by
+// the start of its period. A rollup is only written once its period is over and skipped if it exists already, so
+// a new leader completes the rollups missed by the old one from the finer samples still retained. Each resolution
+// is trimmed after its retention.
+
+const (
+	defaultUsageSampleInterval    = 300 // in terms of seconds
+	defaultUsageRetentionDays     = 730 // of the daily averages
+	defaultUsageQueryDays         = 7
+	usageRawRetention             = 2 * 24 * time.Hour
+	usageHourRetention            = 60 * 24 * time.Hour
+	minUsagePointsToProjectGrowth = 2
+)
+
+// usageResolution is a resolution of the usage history, rolled up from the finer one.
+type usageResolution struct {
+	name      string
+	period    time.Duration // 0 for the raw samples
+	retention func(cfg *clusterConfig) time.Duration
+	source    *usageResolution
+}
+
+var (
+	usageRaw = &usageResolution{
+		name:      proto.UsageResolutionRaw,
+		retention: func(cfg *clusterConfig) time.Duration { return usageRawRetention },
+	}
+	usageHour = &usageResolution{
+		name:      proto.UsageResolutionHour,
+		period:    time.Hour,
+		retention: func(cfg *clusterConfig) time.Duration { return usageHourRetention },
+		source:    usageRaw,
+	}
+	usageDay = &usageResolution{
+		name:   proto.UsageResolutionDay,
+		period: 24 * time.Hour,
+		retention: func(cfg *clusterConfig) time.Duration {
+			return time.Duration(cfg.UsageRetentionDays) * 24 * time.Hour
+		},
+		source: usageHour,
+	}
+	usageResolutions = []*usageResolution{usageRaw, usageHour, usageDay}
+	usageTypes       = []string{proto.UsageTypeVol, proto.UsageTypeDataNode, proto.UsageTypeMetaNode}
+)
+
+func getUsageResolution(name string) *usageResolution {
+	for _, res := range usageResolutions {
+		if res.name == name {
+			return res
+		}
+	}
+	return nil
+}
+
+// usageSample is the usage of all the objects of a type at a time, or averaged over a period.
+type usageSample struct {
+	Time   int64
+	Points map[string]*proto.UsagePoint
+}
+
+func usageKeyPrefix(res *usageResolution, typ string) string {
+	return usagePrefix + res.name + keySeparator + typ + keySeparator
+}
+
+func usageKey(res *usageResolution, typ string, t time.Time) string {
+	return usageKeyPrefix(res, typ) + fmt.Sprintf("%020d", t.Unix())
+}
+
+// key=#usage#resolution#type#time,value=json.Marshal(usageSample)
+func (c *Cluster) syncPutUsageSample(res *usageResolution, typ string, sample *usageSample) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncPutUsageSample
+	metadata.K = usageKey(res, typ, time.Unix(sample.Time, 0))
+	if metadata.V, err = json.Marshal(sample); err != nil {
+		return
+	}
+	return c.submit(metadata)
+}
+
+// syncTrimUsageHistory deletes the samples of the same resolution and type before the key.
+func (c *Cluster) syncTrimUsageHistory(key string) (err error) {
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncTrimUsageHistory
+	metadata.K = key
+	return c.submit(metadata)
+}
+
+// loadUsageSamples returns the samples in [from, to).
+func (c *Cluster) loadUsageSamples(res *usageResolution, typ string, from, to time.Time) (samples []*usageSample, err error) {
+	samples = make([]*usageSample, 0)
+	err = c.fsm.store.RangeScan([]byte(usageKey(res, typ, from)), []byte(usageKey(res, typ, to)), func(key string, value []byte) bool {
+		sample := new(usageSample)
+		if err := json.Unmarshal(value, sample); err != nil {
+			log.LogErrorf("action[loadUsageSamples] key[%v] err[%v]", key, err)
+			return true
+		}
+		samples = append(samples, sample)
+		return true
+	})
+	return
+}
+
+// sampleUsage returns the current usage of the objects of each type.
+func (c *Cluster) sampleUsage(now time.Time) (samples map[string]*usageSample) {
+	samples = make(map[string]*usageSample, len(usageTypes))
+	for _, typ := range usageTypes {
+		samples[typ] = &usageSample{Time: now.Unix(), Points: make(map[string]*proto.UsagePoint)}
+	}
+	for name, vol := range c.allVols() {
+		samples[proto.UsageTypeVol].Points[name] = &proto.UsagePoint{
+			Time:       now.Unix(),
+			Used:       vol.totalUsedSpace(),
+			Total:      vol.capacity() * util.GB,
+			Partitions: uint64(len(vol.dataPartitions.partitions)),
+		}
+	}
+	c.dataNodes.Range(func(addr, node interface{}) bool {
+		dataNode := node.(*DataNode)
+		dataNode.RLock()
+		if dataNode.isActive {
+			samples[proto.UsageTypeDataNode].Points[dataNode.Addr] = &proto.UsagePoint{
+				Time:       now.Unix(),
+				Used:       dataNode.Used,
+				Total:      dataNode.Total,
+				Partitions: uint64(dataNode.DataPartitionCount),
+			}
+		}
+		dataNode.RUnlock()
+		return true
+	})
+	c.metaNodes.Range(func(addr, node interface{}) bool {
+		metaNode := node.(*MetaNode)
+		metaNode.RLock()
+		if metaNode.IsActive {
+			samples[proto.UsageTypeMetaNode].Points[metaNode.Addr] = &proto.UsagePoint{
+				Time:       now.Unix(),
+				Used:       metaNode.Used,
+				Total:      metaNode.Total,
+				Partitions: uint64(metaNode.MetaPartitionCount),
+			}
+		}
+		metaNode.RUnlock()
+		return true
+	})
+	return
+}
+
+// averageUsage averages the samples of a period starting at the time. The objects are averaged over the samples
+// they appear in.
+func averageUsage(start time.Time, samples []*usageSample) (avg *usageSample) {
+	avg = &usageSample{Time: start.Unix(), Points: make(map[string]*proto.UsagePoint)}
+	counts := make(map[string]uint64)
+	for _, sample := range samples {
+		for name, point := range sample.Points {
+			sum, ok := avg.Points[name]
+			if !ok {
+				sum = &proto.UsagePoint{Time: start.Unix()}
+				avg.Points[name] = sum
+			}
+			sum.Used += point.Used
+			sum.Total += point.Total
+			sum.Partitions += point.Partitions
+			counts[name]++
+		}
+	}
+	for name, sum := range avg.Points {
+		sum.Used /= counts[name]
+		sum.Total /= counts[name]
+		sum.Partitions /= counts[name]
+	}
+	return
+}
+
+// rollupUsage writes the averages of the periods over since the given time, which are not written yet,
+// and returns the start of the current period.
+func (c *Cluster) rollupUsage(res *usageResolution, typ string, since, now time.Time) (next time.Time, err error) {
+	next = now.Truncate(res.period)
+	if earliest := now.Add(-res.source.retention(c.cfg)).Truncate(res.period); since.Before(earliest) {
+		since = earliest
+	}
+	for start := since; start.Before(next); start = start.Add(res.period) {
+		var existing, samples []*usageSample
+		if existing, err = c.loadUsageSamples(res, typ, start, start.Add(time.Second)); err != nil {
+			return
+		}
+		if len(existing) > 0 {
+			continue
+		}
+		if samples, err = c.loadUsageSamples(res.source, typ, start, start.Add(res.period)); err != nil {
+			return
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		if err = c.syncPutUsageSample(res, typ, averageUsage(start, samples)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *Cluster) trimUsageHistory(now time.Time) {
+	for _, res := range usageResolutions {
+		retention := res.retention(c.cfg)
+		if retention <= 0 {
+			continue
+		}
+		for _, typ := range usageTypes {
+			if err := c.syncTrimUsageHistory(usageKey(res, typ, now.Add(-retention))); err != nil {
+				log.LogErrorf("action[trimUsageHistory] resolution[%v] type[%v] err[%v]", res.name, typ, err)
+			}
+		}
+	}
+}
+
+func (c *Cluster) scheduleToSampleUsage() {
+	go func() {
+		// the start of the next period to roll up by each resolution and type, forgotten by the followers
+		rolledUp := make(map[string]time.Time)
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				now := time.Now()
+				for typ, sample := range c.sampleUsage(now) {
+					if err := c.syncPutUsageSample(usageRaw, typ, sample); err != nil {
+						log.LogErrorf("action[sampleUsage] type[%v] err[%v]", typ, err)
+					}
+				}
+				for _, res := range usageResolutions[1:] {
+					for _, typ := range usageTypes {
+						key := usageKeyPrefix(res, typ)
+						next, err := c.rollupUsage(res, typ, rolledUp[key], now)
+						if err != nil {
+							log.LogErrorf("action[rollupUsage] resolution[%v] type[%v] err[%v]", res.name, typ, err)
+							continue
+						}
+						rolledUp[key] = next
+					}
+				}
+				c.trimUsageHistory(now)
+			} else {
+				rolledUp = make(map[string]time.Time)
+			}
+			time.Sleep(time.Second * time.Duration(c.cfg.UsageSampleInterval))
+		}
+	}()
+}
+
+// projectUsageGrowth sets the growth per day of the history by the linear regression of the used space over time,
+// and the time when the used space reaches the total of the last point at that rate.
+func projectUsageGrowth(history *proto.UsageHistory) {
+	n := len(history.Points)
+	if n < minUsagePointsToProjectGrowth {
+		return
+	}
+	var meanX, meanY float64
+	base := history.Points[0].Time
+	for _, point := range history.Points {
+		meanX += float64(point.Time - base)
+		meanY += float64(point.Used)
+	}
+	meanX, meanY = meanX/float64(n), meanY/float64(n)
+	var covariance, variance float64
+	for _, point := range history.Points {
+		dx := float64(point.Time-base) - meanX
+		covariance += dx * (float64(point.Used) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return
+	}
+	slope := covariance / variance // bytes per second
+	history.GrowthPerDay = int64(slope * 24 * 3600)
+	last := history.Points[n-1]
+	switch {
+	case slope <= 0 || last.Total == 0:
+	case last.Used >= last.Total:
+		history.FullTime = last.Time
+	default:
+		history.FullTime = last.Time + int64(float64(last.Total-last.Used)/slope)
+	}
+}
+
+// queryUsageHistory returns the usage of the object of the type in [from, to), or of all the objects of the type
+// if the name is empty.
+func (c *Cluster) queryUsageHistory(typ, name string, res *usageResolution, from, to time.Time) (histories []*proto.UsageHistory, err error) {
+	samples, err := c.loadUsageSamples(res, typ, from, to)
+	if err != nil {
+		return
+	}
+	byName := make(map[string]*proto.UsageHistory)
+	histories = make([]*proto.UsageHistory, 0)
+	for _, sample := range samples {
+		for objName, point := range sample.Points {
+			if name != "" && objName != name {
+				continue
+			}
+			history, ok := byName[objName]
+			if !ok {
+				history = &proto.UsageHistory{Type: typ, Name: objName, Resolution: res.name, Points: make([]*proto.UsagePoint, 0)}
+				byName[objName] = history
+				histories = append(histories, history)
+			}
+			history.Points = append(history.Points, point)
+		}
+	}
+	sort.Slice(histories, func(i, j int) bool { return histories[i].Name < histories[j].Name })
+	for _, history := range histories {
+		projectUsageGrowth(history)
+	}
+	return
+}
+
+// pickUsageResolution returns the finest resolution retaining the samples since the time.
+func (c *Cluster) pickUsageResolution(from time.Time) *usageResolution {
+	for _, res := range usageResolutions[:len(usageResolutions)-1] {
+		if time.Since(from) <= res.retention(c.cfg) {
+			return res
+		}
+	}
+	return usageResolutions[len(usageResolutions)-1]
+}
+
+func parseRequestToQueryUsageHistory(r *http.Request) (typ, name, resolution string, from, to time.Time, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	typ, name, resolution = r.FormValue(typeKey), r.FormValue(nameKey), r.FormValue(resolutionKey)
+	switch typ {
+	case proto.UsageTypeVol, proto.UsageTypeDataNode, proto.UsageTypeMetaNode:
+	default:
+		err = fmt.Errorf("parameter %v should be one of %v", typeKey, usageTypes)
+		return
+	}
+	if resolution != "" && getUsageResolution(resolution) == nil {
+		err = fmt.Errorf("parameter %v should be one of %v,%v,%v", resolutionKey,
+			proto.UsageResolutionRaw, proto.UsageResolutionHour, proto.UsageResolutionDay)
+		return
+	}
+	to = time.Now().Add(time.Second)
+	from = to.Add(-defaultUsageQueryDays * 24 * time.Hour)
+	parseTime := func(key string, t *time.Time) {
+		if value := r.FormValue(key); value != "" && err == nil {
+			var seconds int64
+			if seconds, err = strconv.ParseInt(value, 10, 64); err == nil {
+				*t = time.Unix(seconds, 0)
+			}
+		}
+	}
+	parseTime(fromKey, &from)
+	parseTime(toKey, &to)
+	if err == nil && !from.Before(to) {
+		err = fmt.Errorf("parameter %v should be before %v", fromKey, toKey)
+	}
+	return
+}
+
+func (m *Server) queryUsageHistory(w http.ResponseWriter, r *http.Request) {
+	typ, name, resolution, from, to, err := parseRequestToQueryUsageHistory(r)
+	if err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	res := getUsageResolution(resolution)
+	if res == nil {
+		res = m.cluster.pickUsageResolution(from)
+	}
+	histories, err := m.cluster.queryUsageHistory(typ, name, res, from, to)
+	if err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(histories))
+}
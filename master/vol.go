@@ -33,18 +33,34 @@ type Vol struct {
 	threshold         float32
 	dataPartitionSize uint64
 	Capacity          uint64 // GB
+	qos               proto.VolQoS
+	placement         *placementPolicy // nil if the replicas can be placed in any zone
+	mediaType         uint8            // default media class of the data, the vol is tiered if specified
+	encryptionMode    uint8            // set when the vol is created, and never changed
+	moveAfter         int64            // seconds after the last access when a file is moved from ssd to hdd
+	lifecycleRules    []*proto.LifecycleRule
+	lifecycleReports  []*proto.LifecycleReport // reports of the recent runs of the lifecycle rules
+	tokenRequired     bool                     // the clients need a volume token to access the vol
+	revokedTokens     map[string]int64         // key: token ID, value: when the token expires
+	tokenEpoch        uint64                   // the tokens issued in an earlier epoch are revoked
 	MetaPartitions    map[uint64]*MetaPartition
 	mpsLock           sync.RWMutex
 	dataPartitions    *DataPartitionMap
 	sync.RWMutex
 }
 
-func newVol(id uint64, name, owner string, dpSize, capacity uint64) (vol *Vol) {
+func newVol(id uint64, name, owner string, dpSize, capacity uint64, dpReplicaNum, mpReplicaNum uint8) (vol *Vol) {
 	vol = &Vol{ID: id, Name: name, MetaPartitions: make(map[uint64]*MetaPartition, 0)}
 	vol.dataPartitions = newDataPartitionMap(name)
-	vol.dpReplicaNum = defaultReplicaNum
+	if dpReplicaNum == 0 {
+		dpReplicaNum = defaultReplicaNum
+	}
+	if mpReplicaNum == 0 {
+		mpReplicaNum = defaultReplicaNum
+	}
+	vol.dpReplicaNum = dpReplicaNum
 	vol.threshold = defaultMetaPartitionMemUsageThreshold
-	vol.mpReplicaNum = defaultReplicaNum
+	vol.mpReplicaNum = mpReplicaNum
 	vol.Owner = owner
 	if dpSize == 0 {
 		dpSize = util.DefaultDataPartitionSize
@@ -123,7 +139,7 @@ func (vol *Vol) initMetaPartitions(c *Cluster, count int) {
 func (vol *Vol) initDataPartitions(c *Cluster) {
 	// initialize k data partitionMap at a time
 	for i := 0; i < defaultInitDataPartitionCnt; i++ {
-		c.createDataPartition(vol.Name)
+		c.createDataPartition(vol.Name, vol.getMediaType())
 	}
 	return
 }
@@ -226,6 +242,45 @@ func (vol *Vol) setCapacity(capacity uint64) {
 	vol.Capacity = capacity
 }
 
+func (vol *Vol) setQoS(qos proto.VolQoS) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.qos = qos
+}
+
+func (vol *Vol) getQoS() proto.VolQoS {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.qos
+}
+
+func (vol *Vol) setPlacement(placement *placementPolicy) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.placement = placement
+}
+
+func (vol *Vol) getPlacement() *placementPolicy {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.placement
+}
+
+// setReplicaNum changes the replica numbers of the vol. The replicas of the existing partitions are
+// added or removed afterwards by the cluster.
+func (vol *Vol) setReplicaNum(dpReplicaNum, mpReplicaNum uint8) {
+	vol.Lock()
+	defer vol.Unlock()
+	vol.dpReplicaNum = dpReplicaNum
+	vol.mpReplicaNum = mpReplicaNum
+}
+
+func (vol *Vol) getReplicaNum() (dpReplicaNum, mpReplicaNum uint8) {
+	vol.RLock()
+	defer vol.RUnlock()
+	return vol.dpReplicaNum, vol.mpReplicaNum
+}
+
 func (vol *Vol) capacity() uint64 {
 	vol.RLock()
 	defer vol.RUnlock()
@@ -252,11 +307,17 @@ func (vol *Vol) checkAutoDataPartitionCreation(c *Cluster) {
 }
 
 func (vol *Vol) autoCreateDataPartitions(c *Cluster) {
-	if (vol.Capacity > 200000 && vol.dataPartitions.readableAndWritableCnt < 200) || vol.dataPartitions.readableAndWritableCnt < minNumOfRWDataPartitions {
+	if vol.getMediaType() != proto.MediaUnspecified {
+		vol.autoCreateTieredDataPartitions(c)
+		return
+	}
+	// the partitions on the data nodes in maintenance become unavailable when the nodes are stopped
+	readWrites := vol.dataPartitions.readableAndWritableCnt - vol.dataPartitions.readWriteCntOnNodes(c.dataNodesInMaintenance(), proto.MediaUnspecified)
+	if (vol.Capacity > 200000 && readWrites < 200) || readWrites < minNumOfRWDataPartitions {
 		count := vol.calculateExpansionNum()
 		log.LogInfof("action[autoCreateDataPartitions] vol[%v] count[%v]", vol.Name, count)
 		for i := 0; i < count; i++ {
-			c.createDataPartition(vol.Name)
+			c.createDataPartition(vol.Name, proto.MediaUnspecified)
 		}
 	}
 }
@@ -0,0 +1,117 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/exporter"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The capacity of a volume is enforced by the grants pushed to the data nodes through the heartbeats.
+// The remaining space of the volume is split among the data nodes leading its writable data partitions,
+// in proportion to the number of the partitions they lead, since the clients write to the leaders of the
+// partitions chosen at random. A data node refuses the writes of the volume once it used up its grant,
+// until the next heartbeat. As the used space is reported by the heartbeats as well, a volume may exceed
+// its capacity by the writes accepted in about one heartbeat interval.
+// The meta nodes refuse to append extent keys to the files of a full volume.
+
+const defaultVolSoftLimitRatio = 0.9
+
+// writableLeaders returns the number of the writable data partitions led by each data node, and their total.
+func (dpMap *DataPartitionMap) writableLeaders() (leaders map[string]int, total int) {
+	dpMap.RLock()
+	defer dpMap.RUnlock()
+	leaders = make(map[string]int)
+	for _, dp := range dpMap.partitions {
+		dp.RLock()
+		if dp.Status == proto.ReadWrite && len(dp.Hosts) > 0 {
+			leaders[dp.Hosts[0]]++
+			total++
+		}
+		dp.RUnlock()
+	}
+	return
+}
+
+// volSpace returns the space of the volumes with a capacity, and the bytes granted to each data node.
+func (c *Cluster) volSpace() (volSpace map[string]*proto.VolSpace, grants map[string]map[string]uint64) {
+	volSpace = make(map[string]*proto.VolSpace)
+	grants = make(map[string]map[string]uint64)
+	for name, vol := range c.allVols() {
+		if vol.status() == markDelete || vol.capacity() == 0 {
+			continue
+		}
+		space := &proto.VolSpace{Capacity: vol.capacity() * util.GB, Used: vol.totalUsedSpace()}
+		volSpace[name] = space
+		if space.IsFull() {
+			continue
+		}
+		leaders, total := vol.dataPartitions.writableLeaders()
+		free := space.Capacity - space.Used
+		for addr, count := range leaders {
+			if grants[addr] == nil {
+				grants[addr] = make(map[string]uint64)
+			}
+			// divided before multiplied, so that the free space times the count never overflows
+			grants[addr][name] = free/uint64(total)*uint64(count) + free%uint64(total)*uint64(count)/uint64(total)
+		}
+	}
+	return
+}
+
+// dataNodeVolSpace returns the space of the volumes with the grants of the data node.
+// The data node is granted nothing for the volumes it leads no writable partition of.
+func dataNodeVolSpace(volSpace map[string]*proto.VolSpace, grants map[string]uint64) (nodeVolSpace map[string]*proto.VolSpace) {
+	nodeVolSpace = make(map[string]*proto.VolSpace, len(volSpace))
+	for name, space := range volSpace {
+		nodeVolSpace[name] = &proto.VolSpace{Capacity: space.Capacity, Used: space.Used, Grant: grants[name]}
+	}
+	return
+}
+
+// pushVolSpace pushes the space of the volumes to the nodes at once after the capacity of the volume is changed.
+func (c *Cluster) pushVolSpace(vol *Vol) {
+	go func() {
+		// the partitions set read-only when the volume was full are writable again if the capacity is raised
+		readWrites := vol.checkDataPartitionStatus(c)
+		vol.dataPartitions.setReadWriteDataPartitions(readWrites, c.Name)
+		vol.dataPartitions.updateResponseCache(true, 0)
+		c.checkDataNodeHeartbeat()
+		c.checkMetaNodeHeartbeat()
+	}()
+}
+
+// alarmVolSpace raises an alarm if the used space of the volume reaches its capacity or the soft limit.
+func (c *Cluster) alarmVolSpace(vol *Vol, used, total uint64, useRate float64) {
+	var key, msg string
+	switch {
+	case used >= total:
+		key = fmt.Sprintf("vol_%v_full", vol.Name)
+		msg = fmt.Sprintf("clusterId[%v] vol[%v] is full, usedSpace[%v] capacity[%v], the writes are refused",
+			c.Name, vol.Name, used, total)
+	case c.cfg.VolSoftLimitRatio > 0 && useRate >= c.cfg.VolSoftLimitRatio:
+		key = fmt.Sprintf("vol_%v_soft_limit", vol.Name)
+		msg = fmt.Sprintf("clusterId[%v] vol[%v] space utilization reached [%v],usedSpace[%v] capacity[%v] please expand the vol",
+			c.Name, vol.Name, useRate, used, total)
+	default:
+		return
+	}
+	log.LogWarn(msg)
+	exporter.NewAlarm(key)
+}
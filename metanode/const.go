@@ -79,6 +79,8 @@ type (
 	EvictInodeReq = proto.EvictInodeRequest
 	// Client -> MetaNOde
 	SetattrRequest = proto.SetAttrRequest
+	// Mover -> MetaNode
+	ReplaceExtentsReq = proto.ReplaceExtentsRequest
 )
 
 const (
@@ -102,6 +104,7 @@ const (
 	opFSMInternalDelExtentFile
 	opFSMInternalDelExtentCursor
 	opExtentFileSnapshot
+	opFSMExtentsReplace
 )
 
 var (
@@ -130,6 +133,8 @@ const (
 	cfgRaftHeartbeatPort = "raftHeartbeatPort"
 	cfgRaftReplicaPort   = "raftReplicaPort"
 	cfgTotalMem          = "totalMem"
+	cfgZone              = "zone"
+	cfgRack              = "rack"
 )
 
 const (
@@ -26,6 +26,8 @@ import (
 
 const (
 	DeleteMarkFlag = 1 << 0
+	EncryptFlag    = 1 << 1 // the data is encrypted, and KeyID follows Reserved in the marshaled value
+	MediaFlag      = 1 << 2 // the media class is set, and MediaType follows KeyID in the marshaled value
 )
 
 // Inode wraps necessary properties of `Inode` information in the file system.
@@ -41,6 +43,8 @@ const (
 //  +-------+------+------+-----+----+----+----+--------+------------------+
 //  | bytes |  4   |  8   |  8  | 8  | 8  | 8  |   4    |      ExtLen      |
 //  +-------+------+------+-----+----+----+----+--------+------------------+
+//  The KeyID (4 bytes) is written right after Reserved only if EncryptFlag is set.
+//  The MediaType (1 byte) is written right after KeyID only if MediaFlag is set.
 // Marshal entity:
 //  +-------+-----------+--------------+-----------+--------------+
 //  | item  | KeyLength | MarshaledKey | ValLength | MarshaledVal |
@@ -62,6 +66,8 @@ type Inode struct {
 	NLink      uint32 // NodeLink counts
 	Flag       int32
 	Reserved   uint64 // reserved space
+	KeyID      uint32 // ID of the data encryption key, valid only if EncryptFlag is set
+	MediaType  uint8  // media class of the data, valid only if MediaFlag is set
 	Extents    *ExtentsTree
 }
 
@@ -85,6 +91,8 @@ func (i *Inode) String() string {
 	buff.WriteString(fmt.Sprintf("NLink[%d]", i.NLink))
 	buff.WriteString(fmt.Sprintf("Flag[%d]", i.Flag))
 	buff.WriteString(fmt.Sprintf("Reserved[%d]", i.Reserved))
+	buff.WriteString(fmt.Sprintf("KeyID[%d]", i.KeyID))
+	buff.WriteString(fmt.Sprintf("MediaType[%d]", i.MediaType))
 	buff.WriteString(fmt.Sprintf("Extents[%s]", i.Extents))
 	buff.WriteString("}")
 	return buff.String()
@@ -135,6 +143,8 @@ func (i *Inode) Copy() BtreeItem {
 	newIno.NLink = i.NLink
 	newIno.Flag = i.Flag
 	newIno.Reserved = i.Reserved
+	newIno.KeyID = i.KeyID
+	newIno.MediaType = i.MediaType
 	newIno.Extents = i.Extents.Clone()
 	i.RUnlock()
 	return newIno
@@ -260,6 +270,16 @@ func (i *Inode) MarshalValue() (val []byte) {
 	if err = binary.Write(buff, binary.BigEndian, &i.Reserved); err != nil {
 		panic(err)
 	}
+	if i.Flag&EncryptFlag == EncryptFlag {
+		if err = binary.Write(buff, binary.BigEndian, &i.KeyID); err != nil {
+			panic(err)
+		}
+	}
+	if i.Flag&MediaFlag == MediaFlag {
+		if err = binary.Write(buff, binary.BigEndian, &i.MediaType); err != nil {
+			panic(err)
+		}
+	}
 	// marshal ExtentsKey
 	extData, err := i.Extents.MarshalBinary()
 	if err != nil {
@@ -322,6 +342,16 @@ func (i *Inode) UnmarshalValue(val []byte) (err error) {
 	if err = binary.Read(buff, binary.BigEndian, &i.Reserved); err != nil {
 		return
 	}
+	if i.Flag&EncryptFlag == EncryptFlag {
+		if err = binary.Read(buff, binary.BigEndian, &i.KeyID); err != nil {
+			return
+		}
+	}
+	if i.Flag&MediaFlag == MediaFlag {
+		if err = binary.Read(buff, binary.BigEndian, &i.MediaType); err != nil {
+			return
+		}
+	}
 	if buff.Len() == 0 {
 		return
 	}
@@ -444,6 +474,44 @@ func (i *Inode) SetAttr(valid, mode, uid, gid uint32) {
 	i.Unlock()
 }
 
+// SetMediaType sets the media class of the data. MediaUnspecified clears it.
+func (i *Inode) SetMediaType(mediaType uint8) {
+	i.Lock()
+	i.setMediaType(mediaType)
+	i.Unlock()
+}
+
+func (i *Inode) setMediaType(mediaType uint8) {
+	i.MediaType = mediaType
+	if mediaType == proto.MediaUnspecified {
+		i.Flag &^= MediaFlag
+	} else {
+		i.Flag |= MediaFlag
+	}
+}
+
+// ReplaceExtents replaces all the extents of the inode if it has not been changed since the given generation,
+// and returns the extents replaced. The data is only moved, so the ModifyTime is kept.
+func (i *Inode) ReplaceExtents(gen uint64, exts []BtreeItem, mediaType uint8) (items []BtreeItem, ok bool) {
+	i.Lock()
+	defer i.Unlock()
+	if i.Generation != gen {
+		return
+	}
+	i.Extents.Range(func(item BtreeItem) bool {
+		items = append(items, item)
+		return true
+	})
+	extents := NewExtentsTree()
+	for _, ext := range exts {
+		extents.Append(ext)
+	}
+	i.Extents = extents
+	i.setMediaType(mediaType)
+	i.Generation++
+	return items, true
+}
+
 func (i *Inode) DoWriteFunc(fn func()) {
 	i.Lock()
 	fn()
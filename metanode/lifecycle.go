@@ -0,0 +1,255 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	LifecycleScanInterval = 6 * time.Hour
+)
+
+// lifecycleScanner evaluates the lifecycle rules of the volumes stored in the master.
+// The rules are spread across the meta nodes: a rule is run by the meta node leading the meta
+// partition of its directory, which walks the directory through the meta wrapper of the volume.
+// Expired files are deleted by unlinking their dentries and evicting their inodes, so their
+// extents are freed by the free list of their meta partitions as usual.
+type lifecycleScanner struct {
+	m        *metadataManager
+	wrappers map[string]*meta.MetaWrapper // by volume, created on the first run of the volume
+	stopC    chan bool
+}
+
+func newLifecycleScanner(m *metadataManager) *lifecycleScanner {
+	return &lifecycleScanner{
+		m:        m,
+		wrappers: make(map[string]*meta.MetaWrapper),
+		stopC:    make(chan bool),
+	}
+}
+
+func (s *lifecycleScanner) start() {
+	go func() {
+		t := time.NewTicker(LifecycleScanInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stopC:
+				return
+			case <-t.C:
+				s.scan()
+			}
+		}
+	}()
+}
+
+func (s *lifecycleScanner) stop() {
+	close(s.stopC)
+}
+
+// leaderPartitions returns the configs of the meta partitions led by this node, by volume.
+func (s *lifecycleScanner) leaderPartitions() (vols map[string][]MetaPartitionConfig) {
+	vols = make(map[string][]MetaPartitionConfig)
+	s.m.Range(func(id uint64, mp MetaPartition) bool {
+		if _, isLeader := mp.IsLeader(); isLeader {
+			conf := mp.GetBaseConfig()
+			vols[conf.VolName] = append(vols[conf.VolName], conf)
+		}
+		return true
+	})
+	return
+}
+
+func (s *lifecycleScanner) scan() {
+	for volName, partitions := range s.leaderPartitions() {
+		view, err := getSimpleVolView(volName)
+		if err != nil {
+			log.LogWarnf("[lifecycleScanner] vol(%v) err(%v)", volName, err)
+			continue
+		}
+		if len(view.Lifecycle) == 0 {
+			continue
+		}
+		mw, err := s.getMetaWrapper(volName, view.Owner)
+		if err != nil {
+			log.LogErrorf("[lifecycleScanner] vol(%v) err(%v)", volName, err)
+			continue
+		}
+		for _, rule := range view.Lifecycle {
+			select {
+			case <-s.stopC:
+				return
+			default:
+			}
+			dir, err := lookupPath(mw, rule.Path)
+			if err != nil {
+				// reported by the leader of the root directory only
+				dir = proto.RootIno
+			}
+			if !inPartitions(partitions, dir) {
+				continue
+			}
+			report := &proto.LifecycleReport{
+				VolName:   volName,
+				RuleID:    rule.ID,
+				NodeID:    s.m.nodeId,
+				StartTime: time.Now().Unix(),
+			}
+			if err != nil {
+				report.ErrMsg = err.Error()
+			} else {
+				s.runRule(mw, rule, dir, report)
+			}
+			report.EndTime = time.Now().Unix()
+			s.report(report)
+		}
+	}
+}
+
+func (s *lifecycleScanner) getMetaWrapper(volName, owner string) (mw *meta.MetaWrapper, err error) {
+	// the tokens pushed by the master expire, so the latest one is set before every run
+	token := s.m.tokens.ServiceToken(volName)
+	if mw = s.wrappers[volName]; mw != nil {
+		mw.SetToken(token)
+		return
+	}
+	if mw, err = meta.NewMetaWrapperWithToken(volName, owner, token, strings.Join(masterHelper.Nodes(), meta.HostsSeparator)); err != nil {
+		return
+	}
+	s.wrappers[volName] = mw
+	return
+}
+
+// runRule walks the directory of the rule, and expires the files not modified within the days of the rule.
+func (s *lifecycleScanner) runRule(mw *meta.MetaWrapper, rule *proto.LifecycleRule, dir uint64, report *proto.LifecycleReport) {
+	deadline := time.Now().Add(-time.Duration(rule.Days) * 24 * time.Hour)
+	dirs := []uint64{dir}
+	for len(dirs) > 0 {
+		select {
+		case <-s.stopC:
+			report.ErrMsg = "interrupted"
+			return
+		default:
+		}
+		parent := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		children, err := mw.ReadDir_ll(parent)
+		if err != nil {
+			log.LogWarnf("[lifecycleScanner] rule(%v) readdir ino(%v) err(%v)", rule, parent, err)
+			report.Failed++
+			continue
+		}
+		names := make(map[uint64]string, len(children))
+		files := make([]uint64, 0, len(children))
+		for _, child := range children {
+			if proto.IsDir(child.Type) {
+				dirs = append(dirs, child.Inode)
+				continue
+			}
+			names[child.Inode] = child.Name
+			files = append(files, child.Inode)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		for _, info := range mw.BatchInodeGet(files) {
+			report.Scanned++
+			if !info.ModifyTime.Before(deadline) {
+				continue
+			}
+			if err = expireFile(mw, rule, parent, names[info.Inode], info); err != nil {
+				log.LogWarnf("[lifecycleScanner] rule(%v) ino(%v) err(%v)", rule, info.Inode, err)
+				report.Failed++
+				continue
+			}
+			report.Expired++
+		}
+	}
+}
+
+func expireFile(mw *meta.MetaWrapper, rule *proto.LifecycleRule, parent uint64, name string, info *proto.InodeInfo) (err error) {
+	switch rule.Action {
+	case proto.LifecycleActionDelete:
+		if _, err = mw.Delete_ll(parent, name, false); err != nil {
+			return
+		}
+		return mw.Evict(info.Inode)
+	case proto.LifecycleActionTransition:
+		// The data is moved by the mover of the volume later.
+		if info.MediaType == proto.MediaHDD {
+			return
+		}
+		return mw.SetMediaType(info.Inode, proto.MediaHDD)
+	default:
+		return fmt.Errorf("unknown action %v", rule.Action)
+	}
+}
+
+func (s *lifecycleScanner) report(report *proto.LifecycleReport) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	if _, err = masterHelper.Request("POST", proto.ReportLifecycleRun, nil, data); err != nil {
+		log.LogErrorf("[lifecycleScanner] report(%v) err(%v)", string(data), err)
+		return
+	}
+	log.LogInfof("[lifecycleScanner] report(%v)", string(data))
+}
+
+func getSimpleVolView(volName string) (view *proto.SimpleVolView, err error) {
+	data, err := masterHelper.Request("GET", proto.AdminGetVol, map[string]string{"name": volName}, nil)
+	if err != nil {
+		return
+	}
+	view = new(proto.SimpleVolView)
+	err = json.Unmarshal(data, view)
+	return
+}
+
+// lookupPath returns the inode of the directory at the absolute path.
+func lookupPath(mw *meta.MetaWrapper, path string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		var mode uint32
+		if ino, mode, err = mw.Lookup_ll(ino, name); err != nil {
+			return 0, fmt.Errorf("lookup %v: %v", path, err)
+		}
+		if !proto.IsDir(mode) {
+			return 0, fmt.Errorf("%v is not a directory", path)
+		}
+	}
+	return
+}
+
+func inPartitions(partitions []MetaPartitionConfig, ino uint64) bool {
+	for _, conf := range partitions {
+		if ino >= conf.Start && ino <= conf.End {
+			return true
+		}
+	}
+	return false
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
+	"github.com/chubaofs/chubaofs/util/ratelimit"
 )
 
 const partitionPrefix = "partition_"
@@ -61,6 +62,10 @@ type metadataManager struct {
 	state      uint32
 	mu         sync.RWMutex
 	partitions map[uint64]MetaPartition // Key: metaRangeId, Val: metaPartition
+	volQoS     *ratelimit.Group         // metadata ops-per-second limits of the volumes
+	fullVols   atomic.Value             // map[string]bool, the volumes reaching their capacity
+	lifecycle  *lifecycleScanner
+	tokens     *proto.TokenVerifier
 }
 
 // HandleMetadataOperation handles the metadata operations.
@@ -69,6 +74,9 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 	metric := exporter.NewTPCnt(p.GetOpMsg())
 	defer metric.Set(err)
 
+	if m.denyVolToken(conn, p) || m.limitVolQoS(conn, p) {
+		return
+	}
 	switch p.Opcode {
 	case proto.OpMetaCreateInode:
 		err = m.opCreateInode(conn, p, remoteAddr)
@@ -104,6 +112,8 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 		err = m.opMetaExtentsDel(conn, p, remoteAddr)
 	case proto.OpMetaTruncate:
 		err = m.opMetaExtentsTruncate(conn, p, remoteAddr)
+	case proto.OpMetaExtentsReplace:
+		err = m.opMetaExtentsReplace(conn, p, remoteAddr)
 	case proto.OpMetaLookup:
 		err = m.opMetaLookup(conn, p, remoteAddr)
 	case proto.OpDeleteMetaPartition:
@@ -114,6 +124,8 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet,
 		err = m.opLoadMetaPartition(conn, p, remoteAddr)
 	case proto.OpDecommissionMetaPartition:
 		err = m.opDecommissionMetaPartition(conn, p, remoteAddr)
+	case proto.OpAddMetaPartitionRaftMember, proto.OpRemoveMetaPartitionRaftMember:
+		err = m.opChangeMetaPartitionRaftMember(conn, p, remoteAddr)
 	case proto.OpMetaBatchInodeGet:
 		err = m.opMetaBatchInodeGet(conn, p, remoteAddr)
 	default:
@@ -152,15 +164,23 @@ func (m *metadataManager) Stop() {
 	}
 }
 
-// onStart creates the connection pool and loads the partitions.
+// onStart creates the connection pool, loads the partitions and starts the lifecycle scanner.
 func (m *metadataManager) onStart() (err error) {
 	m.connPool = util.NewConnectPool()
-	err = m.loadPartitions()
+	if err = m.loadPartitions(); err != nil {
+		return
+	}
+	m.initTokenVerifier()
+	m.lifecycle = newLifecycleScanner(m)
+	m.lifecycle.start()
 	return
 }
 
 // onStop stops each meta partitions.
 func (m *metadataManager) onStop() {
+	if m.lifecycle != nil {
+		m.lifecycle.stop()
+	}
 	if m.partitions != nil {
 		for _, partition := range m.partitions {
 			partition.Stop()
@@ -235,6 +255,7 @@ func (m *metadataManager) loadPartitions() (err error) {
 					RaftStore: m.raftStore,
 					RootDir:   path.Join(m.rootDir, fileName),
 					ConnPool:  m.connPool,
+					Tokens:    m.tokens,
 				}
 				partitionConfig.AfterStop = func() {
 					m.detachPartition(id)
@@ -309,6 +330,7 @@ func (m *metadataManager) createPartition(id uint64, volName string, start,
 		NodeId:      m.nodeId,
 		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
 		ConnPool:    m.connPool,
+		Tokens:      m.tokens,
 	}
 	mpc.AfterStop = func() {
 		// TODO Unhandled errors
@@ -365,5 +387,7 @@ func NewMetadataManager(conf MetadataManagerConfig) MetadataManager {
 		rootDir:    conf.RootDir,
 		raftStore:  conf.RaftStore,
 		partitions: make(map[uint64]MetaPartition),
+		volQoS:     ratelimit.NewGroup(),
+		tokens:     proto.NewTokenVerifier(),
 	}
 }
@@ -46,6 +46,10 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 		goto end
 	}
 
+	m.updateVolQoS(req.VolQoS)
+	m.updateVolSpace(req.VolSpace)
+	m.tokens.Update(req.TokenVerify)
+
 	// collect memory info
 	resp.Total = configTotalMem
 	resp.Used, err = util.GetProcessMemory(os.Getpid())
@@ -442,6 +446,13 @@ func (m *metadataManager) opMetaExtentsAdd(conn net.Conn, p *Packet,
 	if !m.serveProxy(conn, mp, p) {
 		return
 	}
+	if m.isVolFull(mp.GetBaseConfig().VolName) {
+		p.PacketErrorWithBody(proto.OpVolFullErr, []byte(proto.ErrVolFull.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("%s, response to client: %s", proto.ErrVolFull.Error(),
+			p.GetResultMsg())
+		return
+	}
 	err = mp.ExtentAppend(req, p)
 	m.respondToClient(conn, p)
 	if err != nil {
@@ -507,6 +518,30 @@ func (m *metadataManager) opMetaExtentsTruncate(conn net.Conn, p *Packet,
 	return
 }
 
+func (m *metadataManager) opMetaExtentsReplace(conn net.Conn, p *Packet,
+	remoteAddr string) (err error) {
+	req := &ReplaceExtentsReq{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, nil)
+		m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	mp.ExtentsReplace(req, p)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaExtentsReplace] req: %d - %v, resp body: %v, "+
+		"resp body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
 // Delete a meta partition.
 func (m *metadataManager) opDeleteMetaPartition(conn net.Conn,
 	p *Packet, remoteAddr string) (err error) {
@@ -679,6 +714,55 @@ end:
 	return
 }
 
+// Different from the decommission, only one member is changed by
+// opChangeMetaPartitionRaftMember, and the master is replied after the change
+// has been committed, so that the replica number can be changed step by step.
+func (m *metadataManager) opChangeMetaPartitionRaftMember(conn net.Conn,
+	p *Packet, remoteAddr string) (err error) {
+	var reqData []byte
+	req := &proto.MetaPartitionDecommissionRequest{}
+	adminTask := &proto.AdminTask{
+		Request: req,
+	}
+	decode := json.NewDecoder(bytes.NewBuffer(p.Data))
+	decode.UseNumber()
+	if err = decode.Decode(adminTask); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	if reqData, err = json.Marshal(req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+	if p.Opcode == proto.OpAddMetaPartitionRaftMember {
+		_, err = mp.ChangeMember(raftProto.ConfAddNode,
+			raftProto.Peer{ID: req.AddPeer.ID}, reqData)
+	} else {
+		_, err = mp.ChangeMember(raftProto.ConfRemoveNode,
+			raftProto.Peer{ID: req.RemovePeer.ID}, reqData)
+	}
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+	} else {
+		p.PacketOkReply()
+	}
+	m.respondToClient(conn, p)
+	log.LogInfof("%s [opChangeMetaPartitionRaftMember]: partitionID[%v] addPeer[%v] removePeer[%v] err[%v]",
+		remoteAddr, req.PartitionID, req.AddPeer, req.RemovePeer, err)
+	return
+}
+
 func (m *metadataManager) opMetaBatchInodeGet(conn net.Conn, p *Packet,
 	remoteAddr string) (err error) {
 	req := &proto.BatchInodeGetRequest{}
@@ -51,6 +51,8 @@ type MetaNode struct {
 	raftStore         raftstore.RaftStore
 	raftHeartbeatPort string
 	raftReplicatePort string
+	zoneName          string
+	rackName          string
 	httpStopC         chan uint8
 	state             uint32
 	wg                sync.WaitGroup
@@ -136,6 +138,8 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	m.raftDir = cfg.GetString(cfgRaftDir)
 	m.raftHeartbeatPort = cfg.GetString(cfgRaftHeartbeatPort)
 	m.raftReplicatePort = cfg.GetString(cfgRaftReplicaPort)
+	m.zoneName = cfg.GetString(cfgZone)
+	m.rackName = cfg.GetString(cfgRack)
 	configTotalMem, _ = strconv.ParseUint(cfg.GetString(cfgTotalMem), 10, 64)
 	if configTotalMem != 0 && configTotalMem <= util.GB {
 		configTotalMem = util.GB
@@ -156,6 +160,7 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	log.LogInfof("[parseConfig] load raftDir[%v].", m.raftDir)
 	log.LogInfof("[parseConfig] load raftHeartbeatPort[%v].", m.raftHeartbeatPort)
 	log.LogInfof("[parseConfig] load raftReplicatePort[%v].", m.raftReplicatePort)
+	log.LogInfof("[parseConfig] load zone[%v] rack[%v].", m.zoneName, m.rackName)
 
 	addrs := cfg.GetArray(cfgMasterAddrs)
 	masterHelper = util.NewMasterHelper()
@@ -224,6 +229,8 @@ func (m *MetaNode) register() (err error) {
 			}
 			m.clusterId = clusterInfo.Cluster
 			reqParam["addr"] = m.localAddr + ":" + m.listen
+			reqParam["zone"] = m.zoneName
+			reqParam["rack"] = m.rackName
 			step++
 		}
 		var respBody []byte
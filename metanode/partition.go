@@ -59,20 +59,21 @@ func (sp sortedPeers) Swap(i, j int) {
 // MetaPartitionConfig is used to create a meta partition.
 type MetaPartitionConfig struct {
 	// Identity for raftStore group. RaftStore nodes in the same raftStore group must have the same groupID.
-	PartitionId uint64              `json:"partition_id"`
-	VolName     string              `json:"vol_name"`
-	Start       uint64              `json:"start"` // Minimal Inode ID of this range. (Required during initialization)
-	End         uint64              `json:"end"`   // Maximal Inode ID of this range. (Required during initialization)
-	Peers       []proto.Peer        `json:"peers"` // Peers information of the raftStore
-	Cursor      uint64              `json:"-"`     // Cursor ID of the inode that have been assigned
-	NodeId      uint64              `json:"-"`
-	RootDir     string              `json:"-"`
-	BeforeStart func()              `json:"-"`
-	AfterStart  func()              `json:"-"`
-	BeforeStop  func()              `json:"-"`
-	AfterStop   func()              `json:"-"`
-	RaftStore   raftstore.RaftStore `json:"-"`
-	ConnPool    *util.ConnectPool   `json:"-"`
+	PartitionId uint64               `json:"partition_id"`
+	VolName     string               `json:"vol_name"`
+	Start       uint64               `json:"start"` // Minimal Inode ID of this range. (Required during initialization)
+	End         uint64               `json:"end"`   // Maximal Inode ID of this range. (Required during initialization)
+	Peers       []proto.Peer         `json:"peers"` // Peers information of the raftStore
+	Cursor      uint64               `json:"-"`     // Cursor ID of the inode that have been assigned
+	NodeId      uint64               `json:"-"`
+	RootDir     string               `json:"-"`
+	BeforeStart func()               `json:"-"`
+	AfterStart  func()               `json:"-"`
+	BeforeStop  func()               `json:"-"`
+	AfterStop   func()               `json:"-"`
+	RaftStore   raftstore.RaftStore  `json:"-"`
+	ConnPool    *util.ConnectPool    `json:"-"`
+	Tokens      *proto.TokenVerifier `json:"-"` // carries the service tokens on the deletions sent to the data nodes
 }
 
 func (c *MetaPartitionConfig) checkMeta() (err error) {
@@ -129,6 +130,7 @@ type OpExtent interface {
 	ExtentAppend(req *proto.AppendExtentKeyRequest, p *Packet) (err error)
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (err error)
+	ExtentsReplace(req *ReplaceExtentsReq, p *Packet) (err error)
 }
 
 // OpMeta defines the interface for the metadata operations.
@@ -61,7 +61,7 @@ func (mp *metaPartition) updateVolWorker() {
 			t.Stop()
 			return
 		case <-t.C:
-			respBody, err := masterHelper.Request("GET", reqURL, nil, nil)
+			respBody, err := masterHelper.ReadRequest("GET", reqURL, nil, nil)
 			if err != nil {
 				log.LogErrorf("[updateVol] %s", err.Error())
 				break
@@ -241,7 +241,7 @@ func (mp *metaPartition) syncToRaftFollowersFreeInode(hasDeleteInodes []byte) (e
 }
 
 func (mp *metaPartition) notifyRaftFollowerToFreeInodes(wg *sync.WaitGroup, target string, hasDeleteInodes []byte) (err error) {
-	var conn *net.TCPConn
+	var conn net.Conn
 	conn, err = mp.config.ConnPool.GetConnect(target)
 	defer func() {
 		wg.Done()
@@ -297,6 +297,7 @@ func (mp *metaPartition) doDeleteMarkedInodes(ext *proto.ExtentKey) (err error)
 		return
 	}
 	p := NewPacketToDeleteExtent(dp, ext)
+	p.SetToken(mp.config.Tokens.ServiceToken(mp.config.VolName))
 	if err = p.WriteToConn(conn); err != nil {
 		err = errors.NewErrorf("write to dataNode %s, %s", p.GetUniqueLogId(),
 			err.Error())
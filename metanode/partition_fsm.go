@@ -117,6 +117,12 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmAppendExtents(ino)
+	case opFSMExtentsReplace:
+		req := &ReplaceExtentsReq{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmReplaceExtents(req)
 	case opFSMStoreTick:
 		inodeTree := mp.getInodeTree()
 		dentryTree := mp.getDentryTree()
@@ -189,6 +189,30 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 	return
 }
 
+// fsmReplaceExtents replaces the extents of the inode, and deletes the ones replaced.
+// If the inode has been removed or changed since the generation of the request, the extents
+// of the request are deleted instead.
+func (mp *metaPartition) fsmReplaceExtents(req *ReplaceExtentsReq) (status uint8) {
+	status = proto.OpOk
+	exts := make([]BtreeItem, 0, len(req.Extents))
+	for i := range req.Extents {
+		exts = append(exts, &req.Extents[i])
+	}
+	delExtents := exts
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil || item.(*Inode).ShouldDelete() {
+		status = proto.OpNotExistErr
+	} else if items, ok := item.(*Inode).ReplaceExtents(req.Generation, exts, req.MediaType); ok {
+		delExtents = items
+	} else {
+		status = proto.OpArgMismatchErr
+	}
+	for _, ext := range delExtents {
+		mp.extDelCh <- ext
+	}
+	return
+}
+
 func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 	resp = NewInodeResponse()
 
@@ -267,5 +291,20 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 		return
 	}
 	ino.SetAttr(req.Valid, req.Mode, req.Uid, req.Gid)
+	if req.Valid&proto.AttrAccessTime != 0 {
+		ino.DoWriteFunc(func() {
+			ino.AccessTime = req.AccessTime
+		})
+	}
+	if req.Valid&proto.AttrMediaType != 0 {
+		ino.SetMediaType(req.MediaType)
+	}
+	if req.Valid&proto.AttrModifyTime != 0 {
+		ino.DoWriteFunc(func() {
+			// the data is overwritten in place, so the extents copied by a concurrent move are outdated
+			ino.ModifyTime = req.ModifyTime
+			ino.Generation++
+		})
+	}
 	return
 }
@@ -0,0 +1,84 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+func newTestMovePartition(ek proto.ExtentKey) (mp *metaPartition, ino *Inode) {
+	mp = &metaPartition{
+		inodeTree: NewBtree(),
+		extDelCh:  make(chan BtreeItem, 16),
+	}
+	ino = NewInode(1, proto.Mode(0644))
+	ino.ModifyTime = 100
+	ino.Extents.Append(&ek)
+	mp.inodeTree.ReplaceOrInsert(ino, true)
+	return
+}
+
+func firstExtent(ino *Inode) (ek *proto.ExtentKey) {
+	ino.Extents.Range(func(item BtreeItem) bool {
+		ek = item.(*proto.ExtentKey)
+		return false
+	})
+	return
+}
+
+func TestReplaceExtentsAfterOverwrite(t *testing.T) {
+	src := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 4096}
+	dst := proto.ExtentKey{FileOffset: 0, PartitionId: 2, ExtentId: 1, Size: 4096}
+	mp, ino := newTestMovePartition(src)
+
+	// the mover reads the extents, and the data is overwritten in place while it is copied
+	gen := ino.Generation
+	mp.fsmSetAttr(&SetattrRequest{Inode: 1, Valid: proto.AttrModifyTime, ModifyTime: 200})
+	status := mp.fsmReplaceExtents(&ReplaceExtentsReq{Inode: 1, Generation: gen, Extents: []proto.ExtentKey{dst}, MediaType: proto.MediaHDD})
+	if status != proto.OpArgMismatchErr {
+		t.Fatalf("extents copied before an overwrite replace the originals, status(%v)", status)
+	}
+	if ek := firstExtent(ino); ek.PartitionId != src.PartitionId {
+		t.Fatalf("extent is %v, expected %v", ek, src)
+	}
+	if del := (<-mp.extDelCh).(*proto.ExtentKey); del.PartitionId != dst.PartitionId {
+		t.Fatalf("deleted extent is %v, expected the copy %v", del, dst)
+	}
+	if ino.ModifyTime != 200 {
+		t.Fatalf("modify time is %v, expected 200", ino.ModifyTime)
+	}
+}
+
+func TestReplaceExtentsWithoutOverwrite(t *testing.T) {
+	src := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1, Size: 4096}
+	dst := proto.ExtentKey{FileOffset: 0, PartitionId: 2, ExtentId: 1, Size: 4096}
+	mp, ino := newTestMovePartition(src)
+
+	status := mp.fsmReplaceExtents(&ReplaceExtentsReq{Inode: 1, Generation: ino.Generation, Extents: []proto.ExtentKey{dst}, MediaType: proto.MediaHDD})
+	if status != proto.OpOk {
+		t.Fatalf("replace extents status(%v)", status)
+	}
+	if ek := firstExtent(ino); ek.PartitionId != dst.PartitionId {
+		t.Fatalf("extent is %v, expected %v", ek, dst)
+	}
+	if del := (<-mp.extDelCh).(*proto.ExtentKey); del.PartitionId != src.PartitionId {
+		t.Fatalf("deleted extent is %v, expected the original %v", del, src)
+	}
+	if ino.ModifyTime != 100 || ino.MediaType != proto.MediaHDD {
+		t.Fatalf("modify time(%v) media(%v) after the move", ino.ModifyTime, ino.MediaType)
+	}
+}
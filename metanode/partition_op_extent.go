@@ -88,3 +88,19 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq,
 	p.PacketErrorWithBody(msg.Status, nil)
 	return
 }
+
+// ExtentsReplace replaces all the extents of an inode, e.g. by the ones copied to another media class.
+func (mp *metaPartition) ExtentsReplace(req *ReplaceExtentsReq, p *Packet) (err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, nil)
+		return
+	}
+	resp, err := mp.Put(opFSMExtentsReplace, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
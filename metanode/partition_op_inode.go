@@ -33,6 +33,8 @@ func replyInfo(info *proto.InodeInfo, ino *Inode) bool {
 	info.Uid = ino.Uid
 	info.Gid = ino.Gid
 	info.Generation = ino.Generation
+	info.KeyID = ino.KeyID
+	info.MediaType = ino.MediaType
 	if length := len(ino.LinkTarget); length > 0 {
 		info.Target = make([]byte, length)
 		copy(info.Target, ino.LinkTarget)
@@ -55,6 +57,14 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 	ino.Uid = req.Uid
 	ino.Gid = req.Gid
 	ino.LinkTarget = req.Target
+	if req.KeyID != 0 && proto.IsRegular(req.Mode) {
+		ino.Flag |= EncryptFlag
+		ino.KeyID = req.KeyID
+	}
+	if req.MediaType != proto.MediaUnspecified {
+		ino.Flag |= MediaFlag
+		ino.MediaType = req.MediaType
+	}
 	val, err := ino.Marshal()
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
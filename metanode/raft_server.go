@@ -19,6 +19,7 @@ import (
 	"strconv"
 
 	"github.com/chubaofs/chubaofs/raftstore"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
 )
 
@@ -41,6 +42,7 @@ func (m *MetaNode) startRaftServer() (err error) {
 		HeartbeatPort:     heartbeatPort,
 		ReplicaPort:       replicaPort,
 		NumOfLogsToRetain: 2000000,
+		TLSConfig:         util.TLSConfig(),
 	}
 	m.raftStore, err = raftstore.NewRaftStore(raftConf)
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"net"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/log"
 )
 
@@ -26,7 +27,7 @@ import (
 func (m *MetaNode) startServer() (err error) {
 	// initialize and start the server.
 	m.httpStopC = make(chan uint8)
-	ln, err := net.Listen("tcp", ":"+m.listen)
+	ln, err := util.Listen(":" + m.listen)
 	if err != nil {
 		return
 	}
@@ -63,9 +64,7 @@ func (m *MetaNode) stopServer() {
 // Read data from the specified tcp connection until the connection is closed by the remote or the tcp service is down.
 func (m *MetaNode) serveConn(conn net.Conn, stopC chan uint8) {
 	defer conn.Close()
-	c := conn.(*net.TCPConn)
-	c.SetKeepAlive(true)
-	c.SetNoDelay(true)
+	util.SetTCPOptions(conn)
 	remoteAddr := conn.RemoteAddr().String()
 	for {
 		select {
@@ -0,0 +1,85 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+func isWriteMetaOperation(p *Packet) bool {
+	switch p.Opcode {
+	case proto.OpMetaLookup, proto.OpMetaReadDir, proto.OpMetaInodeGet, proto.OpMetaBatchInodeGet,
+		proto.OpMetaExtentsList, proto.OpMetaOpen, proto.OpMetaReleaseOpen:
+		return false
+	}
+	return true
+}
+
+// Returns true, and responds OpNotPerm to the client, if the operation does not carry a valid token
+// of the volume of its partition. The volume is taken from the partition rather than the request,
+// so that a token of a volume cannot be used to access the others.
+func (m *metadataManager) denyVolToken(conn net.Conn, p *Packet) (denied bool) {
+	if !(isClientMetaOperation(p) || p.Opcode == proto.OpMetaExtentsReplace) {
+		return
+	}
+	if !m.tokens.Loaded() {
+		// the volumes requiring the tokens are unknown yet
+		p.PacketErrorWithBody(proto.OpAgain, []byte(proto.ErrTokenInfoNotLoaded.Error()))
+		m.respondToClient(conn, p)
+		return true
+	}
+	if !m.tokens.Enforced() {
+		return
+	}
+	req := &struct {
+		PartitionID uint64 `json:"pid"`
+	}{}
+	if err := json.Unmarshal(p.Data, req); err != nil {
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		return
+	}
+	if err = m.tokens.Verify(mp.GetBaseConfig().VolName, p.GetToken(), isWriteMetaOperation(p)); err == nil {
+		return
+	}
+	p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+	m.respondToClient(conn, p)
+	return true
+}
+
+// Fetches the token verify info at the startup, before serving any request, since the master pushes it
+// through the heartbeats which may not come until a while.
+func (m *metadataManager) initTokenVerifier() {
+	for {
+		data, err := masterHelper.Request(http.MethodGet, proto.GetTokenVerifyInfo, nil, nil)
+		if err == nil {
+			info := new(proto.TokenVerifyInfo)
+			if err = json.Unmarshal(data, info); err == nil {
+				m.tokens.Update(info)
+				return
+			}
+		}
+		log.LogErrorf("[initTokenVerifier] err(%v)", err)
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// A metadata operation is rejected with OpLimitedErr if it cannot be served
+// within this time, and the client backs off and retries.
+const VolQoSMaxWait = 100 * time.Millisecond
+
+// Replaces the metadata ops-per-second limits of the volumes by the ones in the heartbeat.
+func (m *metadataManager) updateVolQoS(volQoS map[string]*proto.VolQoS) {
+	rates := make(map[string]int64)
+	for name, qos := range volQoS {
+		rates[name] = qos.MetaOPS
+	}
+	m.volQoS.Reset(rates)
+}
+
+func isClientMetaOperation(p *Packet) bool {
+	return p.Opcode >= proto.OpMetaCreateInode && p.Opcode <= proto.OpMetaReleaseOpen
+}
+
+// Returns true, and responds OpLimitedErr to the client, if the operation exceeds
+// the limit of its volume. Every volume has its own token bucket, so a busy volume
+// does not delay the others.
+func (m *metadataManager) limitVolQoS(conn net.Conn, p *Packet) (limited bool) {
+	if m.volQoS.Len() == 0 || !isClientMetaOperation(p) {
+		return
+	}
+	req := &struct {
+		VolName string `json:"vol"`
+	}{}
+	if err := json.Unmarshal(p.Data, req); err != nil || req.VolName == "" {
+		return
+	}
+	if m.volQoS.Acquire(req.VolName, 1, VolQoSMaxWait) {
+		return
+	}
+	p.PacketErrorWithBody(proto.OpLimitedErr, []byte(proto.ErrQoSLimited.Error()))
+	m.respondToClient(conn, p)
+	return true
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/chubaofs/chubaofs/proto"
+)
+
+// Replaces the volumes reaching their capacity by the ones in the heartbeat.
+func (m *metadataManager) updateVolSpace(volSpace map[string]*proto.VolSpace) {
+	fullVols := make(map[string]bool)
+	for name, space := range volSpace {
+		if space.IsFull() {
+			fullVols[name] = true
+		}
+	}
+	m.fullVols.Store(fullVols)
+}
+
+// Returns true if the volume reaches its capacity, and no extent key is appended to its files.
+// The data nodes refuse the writes before the volume is full, so it only stops the writes
+// slipping through, e.g. to the data nodes not granted by the master yet.
+func (m *metadataManager) isVolFull(name string) bool {
+	fullVols, _ := m.fullVols.Load().(map[string]bool)
+	return fullVols[name]
+}
@@ -0,0 +1,279 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+const (
+	moveBufferSize = 4 * util.MB
+)
+
+// movedExtents collects the extent keys written by the writer of the mover, instead of
+// appending them to the inodes, so that they can be swapped with the old ones at once.
+type movedExtents struct {
+	sync.Mutex
+	caches map[uint64]*stream.ExtentCache
+}
+
+func newMovedExtents() *movedExtents {
+	return &movedExtents{caches: make(map[uint64]*stream.ExtentCache)}
+}
+
+func (me *movedExtents) append(inode uint64, ek proto.ExtentKey) error {
+	me.Lock()
+	defer me.Unlock()
+	cache, ok := me.caches[inode]
+	if !ok {
+		cache = stream.NewExtentCache(inode)
+		me.caches[inode] = cache
+	}
+	cache.Append(&ek, true)
+	return nil
+}
+
+// getExtents returns nothing, so that the data is written as a new file.
+func (me *movedExtents) getExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+	return
+}
+
+func (me *movedExtents) truncate(inode, size uint64) error {
+	return syscall.EPERM
+}
+
+// take returns the extent keys collected for the inode and forgets them.
+func (me *movedExtents) take(inode uint64) (extents []proto.ExtentKey) {
+	me.Lock()
+	cache, ok := me.caches[inode]
+	delete(me.caches, inode)
+	me.Unlock()
+	if !ok {
+		return
+	}
+	for _, ek := range cache.List() {
+		extents = append(extents, *ek)
+	}
+	return
+}
+
+func (m *Mover) scheduleToMove() {
+	ticker := time.NewTicker(m.scanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopC:
+			return
+		case <-ticker.C:
+			tiered, moveAfter, err := m.getTier()
+			if err != nil {
+				log.LogErrorf("action[scheduleToMove] vol[%v] err[%v]", m.volName, err)
+				continue
+			}
+			if !tiered {
+				continue
+			}
+			ssdPartitions, err := m.getSSDPartitions()
+			if err != nil {
+				log.LogErrorf("action[scheduleToMove] vol[%v] err[%v]", m.volName, err)
+				continue
+			}
+			var deadline time.Time
+			if moveAfter > 0 {
+				deadline = time.Now().Add(-time.Duration(moveAfter) * time.Second)
+			}
+			m.moveColdFiles(deadline, ssdPartitions)
+		}
+	}
+}
+
+// getTier returns whether the volume is tiered, and the time in seconds after which a file
+// not accessed is moved, 0 if disabled.
+func (m *Mover) getTier() (tiered bool, moveAfter int64, err error) {
+	params := map[string]string{"name": m.volName}
+	data, err := m.master.Request(http.MethodGet, proto.AdminGetVol, params, nil)
+	if err != nil {
+		return
+	}
+	view := new(proto.SimpleVolView)
+	if err = json.Unmarshal(data, view); err != nil {
+		return
+	}
+	return view.MediaType != "", view.MoveAfter, nil
+}
+
+// getSSDPartitions returns the IDs of the ssd data partitions of the volume.
+func (m *Mover) getSSDPartitions() (partitions map[uint64]bool, err error) {
+	params := map[string]string{"name": m.volName}
+	data, err := m.master.ReadRequest(http.MethodGet, proto.ClientDataPartitions, params, nil)
+	if err != nil {
+		return
+	}
+	view := proto.NewDataPartitionsView()
+	if err = json.Unmarshal(data, view); err != nil {
+		return
+	}
+	partitions = make(map[uint64]bool)
+	for _, dp := range view.DataPartitions {
+		if dp.MediaType == proto.MediaSSD {
+			partitions[dp.PartitionID] = true
+		}
+	}
+	return
+}
+
+// moveColdFiles walks through the volume, and moves the ssd files not accessed since the deadline to hdd,
+// as well as the files classified as hdd, e.g. by a lifecycle rule, whose data is still on ssd.
+// A zero deadline disables moving the files by access time.
+func (m *Mover) moveColdFiles(deadline time.Time, ssdPartitions map[uint64]bool) {
+	var (
+		moved, failed int
+		dirs          = []uint64{proto.RootIno}
+	)
+	start := time.Now()
+	for len(dirs) > 0 {
+		select {
+		case <-m.stopC:
+			return
+		default:
+		}
+		dir := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		children, err := m.mw.ReadDir_ll(dir)
+		if err != nil {
+			log.LogWarnf("action[moveColdFiles] readdir ino[%v] err[%v]", dir, err)
+			continue
+		}
+		files := make([]uint64, 0, len(children))
+		for _, child := range children {
+			if proto.IsDir(child.Type) {
+				dirs = append(dirs, child.Inode)
+			} else if proto.IsRegular(child.Type) {
+				files = append(files, child.Inode)
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		for _, info := range m.mw.BatchInodeGet(files) {
+			if !m.shouldMove(info, deadline, ssdPartitions) {
+				continue
+			}
+			if err = m.moveFile(info.Inode); err != nil {
+				log.LogWarnf("action[moveColdFiles] ino[%v] err[%v]", info.Inode, err)
+				failed++
+				continue
+			}
+			moved++
+		}
+	}
+	log.LogInfof("action[moveColdFiles] vol[%v] deadline[%v] moved[%v] failed[%v] cost[%v]",
+		m.volName, deadline, moved, failed, time.Since(start))
+}
+
+func (m *Mover) shouldMove(info *proto.InodeInfo, deadline time.Time, ssdPartitions map[uint64]bool) bool {
+	if info.Size == 0 {
+		return false
+	}
+	mediaType := info.MediaType
+	if mediaType == proto.MediaUnspecified {
+		mediaType = m.mw.MediaType()
+	}
+	switch mediaType {
+	case proto.MediaSSD:
+		return !deadline.IsZero() && info.AccessTime.Before(deadline) && info.ModifyTime.Before(deadline)
+	case proto.MediaHDD:
+		if info.MediaType != proto.MediaHDD || len(ssdPartitions) == 0 {
+			return false
+		}
+		_, _, extents, err := m.mw.GetExtents(info.Inode)
+		if err != nil {
+			return false
+		}
+		for _, ek := range extents {
+			if ssdPartitions[ek.PartitionId] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moveFile copies the data of the file to hdd, and replaces its extents with the new ones
+// if it has not been changed during the copy. Otherwise the new extents are deleted by the meta node.
+func (m *Mover) moveFile(inode uint64) (err error) {
+	gen, size, _, err := m.mw.GetExtents(inode)
+	if err != nil {
+		return
+	}
+	if err = m.copyData(inode, int(size)); err != nil {
+		m.moved.take(inode)
+		return
+	}
+	extents := m.moved.take(inode)
+	if err = m.mw.ReplaceExtents(inode, gen, extents, proto.MediaHDD); err != nil {
+		return fmt.Errorf("replace extents: gen(%v) err(%v)", gen, err)
+	}
+	log.LogDebugf("action[moveFile] ino[%v] gen[%v] size[%v] extents[%v]", inode, gen, size, len(extents))
+	return
+}
+
+func (m *Mover) copyData(inode uint64, size int) (err error) {
+	if err = m.reader.OpenStream(inode); err != nil {
+		return
+	}
+	defer func() {
+		m.reader.CloseStream(inode)
+		m.reader.EvictStream(inode)
+	}()
+	if err = m.writer.OpenStream(inode); err != nil {
+		return
+	}
+	defer func() {
+		m.writer.CloseStream(inode)
+		m.writer.EvictStream(inode)
+	}()
+
+	buf := make([]byte, moveBufferSize)
+	for offset := 0; offset < size; {
+		n := moveBufferSize
+		if size-offset < n {
+			n = size - offset
+		}
+		read, err := m.reader.Read(inode, buf[:n], offset, n)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if read == 0 {
+			break
+		}
+		if _, err = m.writer.Write(inode, offset, buf[:read], false); err != nil {
+			return err
+		}
+		offset += read
+	}
+	return m.writer.Flush(inode)
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package mover moves the cold files of a tiered volume from the ssd data partitions to the hdd ones.
+package mover
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/stream"
+	"github.com/chubaofs/chubaofs/sdk/meta"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/errors"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// Configuration keys
+const (
+	ConfigKeyMasterAddr   = "masterAddr"   // array
+	ConfigKeyVolName      = "volName"      // string
+	ConfigKeyOwner        = "owner"        // string
+	ConfigKeyToken        = "token"        // string, the access token if the volume requires one
+	ConfigKeyScanInterval = "scanInterval" // int, in seconds
+)
+
+const (
+	DefaultScanInterval = 3600 // in seconds
+)
+
+// The status of the server
+const (
+	Standby uint32 = iota
+	Start
+	Running
+	Shutdown
+	Stopped
+)
+
+// Mover scans a tiered volume periodically, and moves the files which have not been accessed
+// for the time set by the moveAfter of the volume from ssd to hdd. The files re-classified as hdd,
+// e.g. by a lifecycle rule, are moved as well if their data is still on ssd.
+type Mover struct {
+	volName      string
+	master       util.MasterHelper
+	scanInterval time.Duration
+
+	mw     *meta.MetaWrapper
+	reader *stream.ExtentClient // reads the data of the files as they are
+	writer *stream.ExtentClient // writes the data to hdd without touching the meta data
+	moved  *movedExtents
+
+	stopC chan bool
+	state uint32
+	wg    sync.WaitGroup
+}
+
+func NewServer() *Mover {
+	return &Mover{}
+}
+
+func (m *Mover) Start(cfg *config.Config) (err error) {
+	if atomic.CompareAndSwapUint32(&m.state, Standby, Start) {
+		defer func() {
+			if err != nil {
+				atomic.StoreUint32(&m.state, Standby)
+			} else {
+				atomic.StoreUint32(&m.state, Running)
+			}
+		}()
+		if err = m.onStart(cfg); err != nil {
+			return
+		}
+		m.wg.Add(1)
+	}
+	return
+}
+
+// Shutdown stops the mover. The file being moved is left as it is.
+func (m *Mover) Shutdown() {
+	if atomic.CompareAndSwapUint32(&m.state, Running, Shutdown) {
+		close(m.stopC)
+		m.wg.Done()
+		atomic.StoreUint32(&m.state, Stopped)
+	}
+}
+
+// Sync blocks until the mover is shut down.
+func (m *Mover) Sync() {
+	if atomic.LoadUint32(&m.state) == Running {
+		m.wg.Wait()
+	}
+}
+
+func (m *Mover) onStart(cfg *config.Config) (err error) {
+	masters := cfg.GetArray(ConfigKeyMasterAddr)
+	if len(masters) == 0 {
+		return fmt.Errorf("Err:masterAddr unavalid")
+	}
+	m.master = util.NewMasterHelper()
+	hosts := make([]string, 0, len(masters))
+	for _, addr := range masters {
+		m.master.AddNode(addr.(string))
+		hosts = append(hosts, addr.(string))
+	}
+	if m.volName = cfg.GetString(ConfigKeyVolName); m.volName == "" {
+		return fmt.Errorf("Err:volName unavalid")
+	}
+	owner := cfg.GetString(ConfigKeyOwner)
+	token := cfg.GetString(ConfigKeyToken)
+	m.scanInterval = time.Duration(DefaultScanInterval) * time.Second
+	if interval := cfg.GetInt(ConfigKeyScanInterval); interval > 0 {
+		m.scanInterval = time.Duration(interval) * time.Second
+	}
+	masterHosts := strings.Join(hosts, meta.HostsSeparator)
+
+	if m.mw, err = meta.NewMetaWrapperWithToken(m.volName, owner, token, masterHosts); err != nil {
+		return errors.Trace(err, "NewMetaWrapper failed!")
+	}
+	// The data is copied as it is, so it is still decrypted by the key recorded in the inode.
+	if m.reader, err = stream.NewExtentClient(m.volName, masterHosts, m.mw.AppendExtentKey, m.mw.GetExtents, m.mw.Truncate); err != nil {
+		return errors.Trace(err, "NewExtentClient failed!")
+	}
+	m.moved = newMovedExtents()
+	if m.writer, err = stream.NewExtentClient(m.volName, masterHosts, m.moved.append, m.moved.getExtents, m.moved.truncate); err != nil {
+		return errors.Trace(err, "NewExtentClient failed!")
+	}
+	m.writer.SetToken(token)
+	m.writer.SetMediaTypeProvider(func(inode uint64) (uint8, error) {
+		return proto.MediaHDD, nil
+	})
+
+	m.stopC = make(chan bool)
+	go m.scheduleToMove()
+	log.LogInfof("mover started: vol(%v) scanInterval(%v)", m.volName, m.scanInterval)
+	return
+}
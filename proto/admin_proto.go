@@ -24,12 +24,43 @@ const (
 	AdminDecommissionDataPartition = "/dataPartition/decommission"
 	AdminDeleteVol                 = "/vol/delete"
 	AdminUpdateVol                 = "/vol/update"
+	AdminSetVolQoS                 = "/vol/qos"
+	AdminSetVolPlacement           = "/vol/placement"
+	AdminSetVolReplicaNum          = "/vol/replicaNum"
+	AdminSetVolTier                = "/vol/tier"
 	AdminCreateVol                 = "/admin/createVol"
 	AdminGetVol                    = "/admin/getVol"
 	AdminClusterFreeze             = "/cluster/freeze"
 	AdminGetIP                     = "/admin/getIp"
 	AdminCreateMP                  = "/metaPartition/create"
 	AdminSetMetaNodeThreshold      = "/threshold/set"
+	AdminListVols                  = "/vol/list"
+	AdminListDataPartitions        = "/dataPartition/list"
+	AdminListMetaPartitions        = "/metaPartition/list"
+	AdminListDataNodes             = "/dataNode/list"
+	AdminListMetaNodes             = "/metaNode/list"
+	AdminIssueToken                = "/token/issue"
+	AdminRevokeToken               = "/token/revoke"
+	AdminRevokeAllTokens           = "/token/revokeAll"
+	AdminSetTokenRequired          = "/vol/token/require"
+	GetTokenVerifyInfo             = "/token/verifyInfo"
+	AdminCreateUser                = "/user/create"
+	AdminDeleteUser                = "/user/delete"
+	AdminUpdateUser                = "/user/update"
+	AdminResetUserKey              = "/user/resetKey"
+	AdminListUsers                 = "/user/list"
+	AdminQueryAuditLog             = "/audit/query"
+	AdminListTasks                 = "/task/list"
+	AdminGetTask                   = "/task/get"
+	AdminRetryTask                 = "/task/retry"
+	AdminCancelTask                = "/task/cancel"
+	AdminListDecommissionJobs      = "/decommission/list"
+	AdminGetDecommissionJob        = "/decommission/get"
+	AdminPauseDecommissionJob      = "/decommission/pause"
+	AdminResumeDecommissionJob     = "/decommission/resume"
+	AdminCancelDecommissionJob     = "/decommission/cancel"
+	AdminBackupMetadata            = "/admin/backupMetadata"
+	AdminQueryUsageHistory         = "/usage/history"
 
 	// Client APIs
 	ClientDataPartitions = "/client/partitions"
@@ -37,6 +68,11 @@ const (
 	ClientMetaPartition  = "/client/metaPartition"
 	ClientVolStat        = "/client/volStat"
 
+	// Headers tagging the client views, which may be served by a follower of the master.
+	MasterAppliedIndexHeader  = "X-Cfs-Applied-Index"  // the raft index applied by the master which answers
+	MasterViewStalenessHeader = "X-Cfs-View-Staleness" // seconds since the leader built the view
+	MasterMaxStalenessHeader  = "X-Cfs-Max-Staleness"  // the staleness bound of the views served by followers
+
 	//raft node APIs
 	AddRaftNode    = "/raftNode/add"
 	RemoveRaftNode = "/raftNode/remove"
@@ -46,9 +82,12 @@ const (
 	DecommissionDataNode           = "/dataNode/decommission"
 	DecommissionDisk               = "/disk/decommission"
 	GetDataNode                    = "/dataNode/get"
+	DrainDataNode                  = "/dataNode/drain"
+	SetDataNodeState               = "/dataNode/setState"
 	AddMetaNode                    = "/metaNode/add"
 	DecommissionMetaNode           = "/metaNode/decommission"
 	GetMetaNode                    = "/metaNode/get"
+	SetMetaNodeState               = "/metaNode/setState"
 	AdminLoadMetaPartition         = "/metaPartition/load"
 	AdminDecommissionMetaPartition = "/metaPartition/decommission"
 
@@ -57,6 +96,25 @@ const (
 	GetDataNodeTaskResponse = "/dataNode/response" // Method: 'POST', ContentType: 'application/json'
 
 	GetTopologyView = "/topo/get"
+
+	// Rebalance APIs
+	AdminRebalancePlan     = "/rebalance/plan"
+	AdminRebalanceProgress = "/rebalance/progress"
+	AdminRebalancePause    = "/rebalance/pause"
+	AdminRebalanceResume   = "/rebalance/resume"
+
+	// Lifecycle APIs
+	AdminSetLifecycleRule    = "/vol/lifecycle/set"
+	AdminDeleteLifecycleRule = "/vol/lifecycle/delete"
+	AdminGetLifecycle        = "/vol/lifecycle/get"
+	ReportLifecycleRun       = "/metaNode/lifecycle/report" // Method: 'POST', ContentType: 'application/json'
+)
+
+// The administrative states of the data nodes and the meta nodes.
+const (
+	NodeStateActive          = "active"
+	NodeStateNoNewPartitions = "noNewPartitions" // no new partition or replica is placed on the node
+	NodeStateMaintenance     = "maintenance"     // as noNewPartitions, and its partitions are expected to be unavailable
 )
 
 // HTTPReply uniform response structure
@@ -85,6 +143,7 @@ type CreateDataPartitionRequest struct {
 	VolumeId      string
 	IsRandomWrite bool
 	Members       []Peer
+	MediaType     uint8 // the partition is created on a disk of the media class if specified
 }
 
 // CreateDataPartitionResponse defines the response to the request of creating a data partition.
@@ -161,10 +220,40 @@ type LoadMetaPartitionMetricResponse struct {
 	Result   string
 }
 
+// VolQoS defines the qos limits of a volume on every node. Zero means unlimited.
+type VolQoS struct {
+	ReadBandwidth  int64 // bytes per second
+	WriteBandwidth int64 // bytes per second
+	ReadIOPS       int64
+	WriteIOPS      int64
+	MetaOPS        int64 // metadata operations per second
+}
+
+// IsZero returns true if the volume is not limited at all.
+func (q *VolQoS) IsZero() bool {
+	return q.ReadBandwidth == 0 && q.WriteBandwidth == 0 && q.ReadIOPS == 0 && q.WriteIOPS == 0 && q.MetaOPS == 0
+}
+
+// VolSpace is the space of a volume pushed to the nodes through the heartbeats.
+type VolSpace struct {
+	Capacity uint64 // bytes
+	Used     uint64 // bytes
+	Grant    uint64 // bytes the data node may accept from the clients until the next heartbeat
+}
+
+// IsFull returns true if the used space reaches the capacity.
+func (s *VolSpace) IsFull() bool {
+	return s.Used >= s.Capacity
+}
+
 // HeartBeatRequest define the heartbeat request.
 type HeartBeatRequest struct {
-	CurrTime   int64
-	MasterAddr string
+	CurrTime    int64
+	MasterAddr  string
+	VolQoS      map[string]*VolQoS   // qos limits of the limited volumes
+	VolSpace    map[string]*VolSpace // space of the volumes, with the grants of the data node
+	Drain       bool                 // the data node is asked to drain
+	TokenVerify *TokenVerifyInfo     // what the node needs to verify the volume tokens
 }
 
 // PartitionReport defines the partition report.
@@ -178,6 +267,19 @@ type PartitionReport struct {
 	IsLeader        bool
 	ExtentCount     int
 	NeedCompare     bool
+	ApplyID         uint64
+	SyncedReplicas  []string // the replicas found with the same extents as the others by the last repair of the leader
+}
+
+// DiskReport defines the disk report.
+type DiskReport struct {
+	Path           string
+	Total          uint64
+	Used           uint64
+	Available      uint64
+	Status         int
+	PartitionCount int
+	MediaType      uint8
 }
 
 // DataNodeHeartbeatResponse defines the response to the data node heartbeat.
@@ -191,6 +293,8 @@ type DataNodeHeartbeatResponse struct {
 	MaxCapacity         uint64 // maximum capacity to create partition
 	RackName            string
 	PartitionReports    []*PartitionReport
+	DiskReports         []*DiskReport
+	Draining            bool
 	Status              uint8
 	Result              string
 }
@@ -303,6 +407,7 @@ type DataPartitionResponse struct {
 	ReplicaNum  uint8
 	Hosts       []string
 	LeaderAddr  string
+	MediaType   uint8
 }
 
 // DataPartitionsView defines the view of a data partition
@@ -332,6 +437,8 @@ type VolView struct {
 	Status         uint8
 	MetaPartitions []*MetaPartitionView
 	DataPartitions []*DataPartitionResponse
+	MediaType      uint8 // the default media class of the data
+	EncryptionMode uint8 // the new files have to be encrypted if it is not EncryptionNone
 }
 
 func NewVolView(name string, status uint8) (view *VolView) {
@@ -362,6 +469,12 @@ type SimpleVolView struct {
 	MpReplicaNum uint8
 	Status       uint8
 	Capacity     uint64 // GB
+	QoS          VolQoS
+	Placement    string
+	MediaType    string // the default media class of the data
+	Encryption   string // the encryption mode of the data
+	MoveAfter    int64  // seconds after the last access when a file is moved from ssd to hdd, 0 if disabled
+	Lifecycle    []*LifecycleRule
 	RwDpCnt      int
 	MpCnt        int
 	DpCnt        int
@@ -15,6 +15,7 @@
 package proto
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -29,6 +30,30 @@ const (
 	MaxSendCount     = 5
 )
 
+// The states of the admin tasks queued by the master.
+const (
+	TaskStatePending   = "pending"
+	TaskStateRunning   = "running"
+	TaskStateSucceeded = "succeeded"
+	TaskStateFailed    = "failed"
+	TaskStateCancelled = "cancelled"
+)
+
+// AdminTaskView is an admin task queued by the master, with its state.
+type AdminTaskView struct {
+	ID            string
+	Type          string
+	NodeAddr      string
+	NodeType      string
+	State         string
+	Attempts      int
+	CreateTime    int64
+	UpdateTime    int64
+	NextRetryTime int64 `json:",omitempty"`
+	LastError     string
+	Request       json.RawMessage
+}
+
 // AdminTask defines the administration task.
 type AdminTask struct {
 	ID           string
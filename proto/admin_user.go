@@ -0,0 +1,118 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The roles of the admin users of the master, each one is granted the permissions of the roles before it.
+const (
+	AdminRoleViewer   = "viewer"
+	AdminRoleOperator = "operator"
+	AdminRoleAdmin    = "admin"
+)
+
+// AdminRootUser is the built-in admin user whose key is set in the config of the masters.
+const AdminRootUser = "root"
+
+// Headers authenticating the requests to the admin APIs of the master, either by the API key of the user,
+// or by the signature of the request computed with the key.
+const (
+	AdminUserHeader      = "X-Cfs-User"
+	AdminKeyHeader       = "X-Cfs-Api-Key"
+	AdminDateHeader      = "X-Cfs-Date"  // unix time when the request is signed
+	AdminNonceHeader     = "X-Cfs-Nonce" // a random string used once, so that the signed request can not be replayed
+	AdminSignatureHeader = "X-Cfs-Signature"
+)
+
+// AdminUserInfo is an admin user of the master.
+type AdminUserInfo struct {
+	Name       string
+	Role       string
+	CreateTime int64
+}
+
+// AdminUserView is an admin user with its API key, which is only returned when the key is generated.
+type AdminUserView struct {
+	Name       string
+	Role       string
+	CreateTime int64
+	Key        string
+}
+
+// AdminRoleLevel returns the rank of the role, 0 if the role is unknown.
+func AdminRoleLevel(role string) int {
+	switch role {
+	case AdminRoleViewer:
+		return 1
+	case AdminRoleOperator:
+		return 2
+	case AdminRoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// AdminRequestSignature computes the signature of a request to the admin APIs, which covers the method,
+// the path, the query, the date, the nonce and the SHA256 of the body of the request.
+func AdminRequestSignature(key, method, path, rawQuery, date, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join([]string{method, path, rawQuery, date, nonce, hex.EncodeToString(bodyHash[:])}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ReadRequestBody reads the body of the request, and replaces it with a copy to be read again.
+func ReadRequestBody(r *http.Request) (body []byte, err error) {
+	if r.Body == nil {
+		return
+	}
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return
+}
+
+// SignAdminRequest signs the request to the admin APIs with the key of the user, so that the key
+// itself is not sent. The request must not be modified after it is signed.
+func SignAdminRequest(r *http.Request, user, key string) (err error) {
+	body, err := ReadRequestBody(r)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	date := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(AdminUserHeader, user)
+	r.Header.Set(AdminDateHeader, date)
+	r.Header.Set(AdminNonceHeader, hex.EncodeToString(nonce))
+	r.Header.Set(AdminSignatureHeader, AdminRequestSignature(key, r.Method, r.URL.Path, r.URL.RawQuery, date,
+		r.Header.Get(AdminNonceHeader), body))
+	return
+}
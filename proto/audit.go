@@ -0,0 +1,37 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// AuditRecord records a mutating request to the master, and its result.
+type AuditRecord struct {
+	ID            string // ordered by the time of the records
+	Time          int64  // unix time in nanoseconds
+	User          string // the admin user authenticated, empty if the request is not authenticated
+	Authenticated bool
+	ClaimedUser   string `json:",omitempty"` // the admin user claimed by a request not authenticated
+	RemoteAddr    string
+	ForwardedFor  string            `json:",omitempty"` // the address of the caller if the request is proxied by a follower
+	Operation     string            // the path of the API
+	Object        string            // the volume, node or partition operated
+	Params        map[string]string // the secrets such as the auth keys and the tokens are masked
+	Code          int32             // the code of the reply
+	Result        string            // the message of the reply
+}
+
+// AuditPage is a page of the audit records. The next page starts after the ID of the last record.
+type AuditPage struct {
+	Records []*AuditRecord
+	More    bool
+}
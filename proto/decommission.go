@@ -0,0 +1,78 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The types of the decommission jobs.
+const (
+	DecommissionTypeDataNode = "dataNode"
+	DecommissionTypeDisk     = "disk"
+	DecommissionTypeMetaNode = "metaNode"
+)
+
+// The states of the decommission jobs.
+const (
+	DecommissionJobRunning   = "running"
+	DecommissionJobPaused    = "paused"
+	DecommissionJobSucceeded = "succeeded"
+	DecommissionJobFailed    = "failed"
+	DecommissionJobCancelled = "cancelled"
+)
+
+// The states of the partitions in a decommission job.
+const (
+	DecommissionPartitionPending   = "pending"
+	DecommissionPartitionRunning   = "running" // the replica is replaced, and the new one is recovering
+	DecommissionPartitionDone      = "done"
+	DecommissionPartitionFailed    = "failed"
+	DecommissionPartitionCancelled = "cancelled"
+)
+
+// DecommissionPartition is a partition to be moved off the node or the disk.
+type DecommissionPartition struct {
+	ID        uint64
+	VolName   string
+	State     string
+	Attempts  int
+	StartTime int64
+	EndTime   int64
+	Msg       string `json:",omitempty"`
+}
+
+// DecommissionJob moves all the partitions off a node or a disk, and removes the node at last.
+type DecommissionJob struct {
+	ID          uint64
+	Type        string
+	NodeAddr    string
+	DiskPath    string `json:",omitempty"`
+	State       string
+	Concurrency int // the maximum number of the partitions recovering at a time
+	CreateTime  int64
+	UpdateTime  int64
+	EndTime     int64
+	Msg         string                   `json:",omitempty"`
+	Partitions  []*DecommissionPartition `json:",omitempty"`
+}
+
+// DecommissionJobView is a decommission job with its progress.
+type DecommissionJobView struct {
+	DecommissionJob
+	Total     int
+	Pending   int
+	Running   int
+	Done      int
+	Failed    int
+	Cancelled int
+	ETA       int64 // estimated seconds to finish the partitions, -1 if unknown
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import "fmt"
+
+// The following defines the encryption modes of a volume, which are chosen when the volume is created.
+// The clients of an encrypted volume have to be mounted with a key provider, and encrypt the data of
+// all the new files.
+const (
+	EncryptionNone uint8 = iota
+	EncryptionAESXTS
+)
+
+const (
+	EncryptionNameNone   = "none"
+	EncryptionNameAESXTS = "aes-xts"
+)
+
+// EncryptionModeString returns the name of the encryption mode.
+func EncryptionModeString(mode uint8) string {
+	switch mode {
+	case EncryptionAESXTS:
+		return EncryptionNameAESXTS
+	default:
+		return EncryptionNameNone
+	}
+}
+
+// ParseEncryptionMode parses the name of an encryption mode. An empty name means EncryptionNone.
+func ParseEncryptionMode(name string) (mode uint8, err error) {
+	switch name {
+	case "", EncryptionNameNone:
+		return EncryptionNone, nil
+	case EncryptionNameAESXTS:
+		return EncryptionAESXTS, nil
+	default:
+		return EncryptionNone, fmt.Errorf("invalid encryption mode[%v]", name)
+	}
+}
@@ -57,6 +57,24 @@ var (
 	ErrNoEnoughReplica                 = errors.New("no enough replicas")
 	ErrNoLeader                        = errors.New("no leader")
 	ErrVolAuthKeyNotMatch              = errors.New("client and server auth key do not match")
+	ErrQoSLimited                      = errors.New("vol qos limited, try again later")
+	ErrTokenRequired                   = errors.New("vol requires an access token")
+	ErrInvalidToken                    = errors.New("invalid access token")
+	ErrTokenExpired                    = errors.New("access token expired")
+	ErrTokenRevoked                    = errors.New("access token revoked")
+	ErrTokenNotAllowed                 = errors.New("access token does not allow the access")
+	ErrTokenInfoNotLoaded              = errors.New("access token verify info not loaded, try again later")
+	ErrAuthRequired                    = errors.New("admin api requires authentication")
+	ErrAuthFailed                      = errors.New("admin authentication failed")
+	ErrPermissionDenied                = errors.New("permission denied")
+	ErrUserNotExists                   = errors.New("admin user not exists")
+	ErrDuplicateUser                   = errors.New("duplicate admin user")
+	ErrTaskNotExists                   = errors.New("admin task not exists")
+	ErrInvalidTaskState                = errors.New("admin task is not in a state allowing the operation")
+	ErrDecommissionJobNotExists        = errors.New("decommission job not exists")
+	ErrDuplicateDecommissionJob        = errors.New("the node or disk is being decommissioned")
+	ErrInvalidDecommissionJobState     = errors.New("decommission job is not in a state allowing the operation")
+	ErrVolFull                         = errors.New("vol capacity exceeded")
 )
 
 // http response error code and error message definitions
@@ -94,6 +112,23 @@ const (
 	ErrCodeNoEnoughReplica
 	ErrCodeNoLeader
 	ErrCodeVolAuthKeyNotMatch
+	ErrCodeQoSLimited
+	ErrCodeTokenRequired
+	ErrCodeInvalidToken
+	ErrCodeTokenExpired
+	ErrCodeTokenRevoked
+	ErrCodeTokenNotAllowed
+	ErrCodeAuthRequired
+	ErrCodeAuthFailed
+	ErrCodePermissionDenied
+	ErrCodeUserNotExists
+	ErrCodeDuplicateUser
+	ErrCodeTaskNotExists
+	ErrCodeInvalidTaskState
+	ErrCodeDecommissionJobNotExists
+	ErrCodeDuplicateDecommissionJob
+	ErrCodeInvalidDecommissionJobState
+	ErrCodeVolFull
 )
 
 // Err2CodeMap error map to code
@@ -131,4 +166,21 @@ var Err2CodeMap = map[error]int32{
 	ErrNoEnoughReplica:                 ErrCodeNoEnoughReplica,
 	ErrNoLeader:                        ErrCodeNoLeader,
 	ErrVolAuthKeyNotMatch:              ErrCodeVolAuthKeyNotMatch,
+	ErrQoSLimited:                      ErrCodeQoSLimited,
+	ErrTokenRequired:                   ErrCodeTokenRequired,
+	ErrInvalidToken:                    ErrCodeInvalidToken,
+	ErrTokenExpired:                    ErrCodeTokenExpired,
+	ErrTokenRevoked:                    ErrCodeTokenRevoked,
+	ErrTokenNotAllowed:                 ErrCodeTokenNotAllowed,
+	ErrAuthRequired:                    ErrCodeAuthRequired,
+	ErrAuthFailed:                      ErrCodeAuthFailed,
+	ErrPermissionDenied:                ErrCodePermissionDenied,
+	ErrUserNotExists:                   ErrCodeUserNotExists,
+	ErrDuplicateUser:                   ErrCodeDuplicateUser,
+	ErrTaskNotExists:                   ErrCodeTaskNotExists,
+	ErrInvalidTaskState:                ErrCodeInvalidTaskState,
+	ErrDecommissionJobNotExists:        ErrCodeDecommissionJobNotExists,
+	ErrDuplicateDecommissionJob:        ErrCodeDuplicateDecommissionJob,
+	ErrInvalidDecommissionJobState:     ErrCodeInvalidDecommissionJobState,
+	ErrVolFull:                         ErrCodeVolFull,
 }
@@ -62,6 +62,8 @@ type InodeInfo struct {
 	CreateTime time.Time `json:"ct"`
 	AccessTime time.Time `json:"at"`
 	Target     []byte    `json:"tgt"`
+	KeyID      uint32    `json:"kid,omitempty"`   // ID of the data encryption key, 0 if not encrypted
+	MediaType  uint8     `json:"media,omitempty"` // media class of the data, inherited by the new inodes of a directory
 }
 
 // String returns the string format of the inode.
@@ -89,6 +91,8 @@ type CreateInodeRequest struct {
 	Uid         uint32 `json:"uid"`
 	Gid         uint32 `json:"gid"`
 	Target      []byte `json:"tgt"`
+	KeyID       uint32 `json:"kid,omitempty"`
+	MediaType   uint8  `json:"media,omitempty"`
 }
 
 // CreateInodeResponse defines the response to the request of creating an inode.
@@ -244,6 +248,17 @@ type TruncateRequest struct {
 	Size        uint64 `json:"sz"`
 }
 
+// ReplaceExtentsRequest defines the request to replace all the extents of an inode, e.g. by the ones copied
+// to another media class. The request fails if the inode has been changed since the given generation.
+type ReplaceExtentsRequest struct {
+	VolName     string      `json:"vol"`
+	PartitionID uint64      `json:"pid"`
+	Inode       uint64      `json:"ino"`
+	Generation  uint64      `json:"gen"`
+	Extents     []ExtentKey `json:"eks"`
+	MediaType   uint8       `json:"media"`
+}
+
 // SetAttrRequest defines the request to set attribute.
 type SetAttrRequest struct {
 	VolName     string `json:"vol"`
@@ -252,6 +267,9 @@ type SetAttrRequest struct {
 	Mode        uint32 `json:"mode"`
 	Uid         uint32 `json:"uid"`
 	Gid         uint32 `json:"gid"`
+	AccessTime  int64  `json:"atime,omitempty"`
+	ModifyTime  int64  `json:"mtime,omitempty"`
+	MediaType   uint8  `json:"media,omitempty"`
 	Valid       uint32 `json:"valid"`
 }
 
@@ -259,4 +277,7 @@ const (
 	AttrMode uint32 = 1 << iota
 	AttrUid
 	AttrGid
+	AttrAccessTime
+	AttrMediaType
+	AttrModifyTime
 )
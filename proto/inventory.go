@@ -0,0 +1,92 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The statuses used to filter the inventory of the cluster.
+const (
+	InventoryStatusNormal      = "normal"
+	InventoryStatusMarkDelete  = "markDelete"
+	InventoryStatusReadWrite   = "readWrite"
+	InventoryStatusReadOnly    = "readOnly"
+	InventoryStatusUnavailable = "unavailable"
+	InventoryStatusActive      = "active"
+	InventoryStatusInactive    = "inactive"
+)
+
+// InventoryPage is a page of the items matching an inventory query.
+type InventoryPage struct {
+	Total  int // number of the items matching the query
+	Offset int
+	Limit  int
+	Items  interface{}
+}
+
+// VolSummary is an item of the volume inventory.
+type VolSummary struct {
+	Name               string
+	Owner              string
+	Status             string
+	Capacity           uint64 // GB
+	UsedSize           uint64 // bytes
+	Usage              float64
+	DpReplicaNum       uint8
+	MpReplicaNum       uint8
+	DataPartitionCount int
+	MetaPartitionCount int
+}
+
+// DataPartitionSummary is an item of the data partition inventory.
+type DataPartitionSummary struct {
+	PartitionID  uint64
+	VolName      string
+	Status       string
+	ReplicaNum   uint8
+	LiveReplicas int
+	Hosts        []string
+	LeaderAddr   string
+	UsedSize     uint64 // bytes
+	Usage        float64
+	IsRecover    bool
+	MediaType    uint8
+}
+
+// MetaPartitionSummary is an item of the meta partition inventory.
+type MetaPartitionSummary struct {
+	PartitionID  uint64
+	VolName      string
+	Status       string
+	Start        uint64
+	End          uint64
+	MaxInodeID   uint64
+	ReplicaNum   uint8
+	LiveReplicas int
+	Hosts        []string
+	LeaderAddr   string
+}
+
+// NodeSummary is an item of the data node or meta node inventory.
+type NodeSummary struct {
+	ID             uint64
+	Addr           string
+	Zone           string
+	Rack           string
+	Status         string
+	Total          uint64
+	Used           uint64
+	Usage          float64
+	PartitionCount int
+	NodeSetID      uint64
+	ReportTime     int64
+}
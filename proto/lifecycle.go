@@ -0,0 +1,76 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The actions of the lifecycle rules.
+const (
+	LifecycleActionDelete     = "delete"     // deletes the files
+	LifecycleActionTransition = "transition" // moves the files to the cold media class, i.e. hdd
+)
+
+// LifecycleRule expires the files under a directory whose modification time is older than the given days.
+type LifecycleRule struct {
+	ID     string
+	Path   string // absolute path of the directory, "/" for the whole volume
+	Action string
+	Days   int
+}
+
+// Validate checks the rule and cleans its path.
+func (rule *LifecycleRule) Validate() error {
+	if rule.ID == "" {
+		return fmt.Errorf("lifecycle rule id is empty")
+	}
+	if !strings.HasPrefix(rule.Path, "/") {
+		return fmt.Errorf("lifecycle rule path[%v] is not absolute", rule.Path)
+	}
+	rule.Path = path.Clean(rule.Path)
+	if rule.Action != LifecycleActionDelete && rule.Action != LifecycleActionTransition {
+		return fmt.Errorf("invalid lifecycle rule action[%v]", rule.Action)
+	}
+	if rule.Days <= 0 {
+		return fmt.Errorf("lifecycle rule days[%v] must be positive", rule.Days)
+	}
+	return nil
+}
+
+func (rule *LifecycleRule) String() string {
+	return fmt.Sprintf("LifecycleRule{ID(%v) Path(%v) Action(%v) Days(%v)}", rule.ID, rule.Path, rule.Action, rule.Days)
+}
+
+// LifecycleReport is the result of a run of a lifecycle rule, reported by the meta node which ran it.
+type LifecycleReport struct {
+	VolName   string
+	RuleID    string
+	NodeID    uint64
+	StartTime int64
+	EndTime   int64
+	Scanned   uint64 // files checked
+	Expired   uint64 // files deleted or transitioned
+	Failed    uint64
+	ErrMsg    string `json:",omitempty"`
+}
+
+// LifecycleView shows the lifecycle rules of a volume and the reports of the recent runs.
+type LifecycleView struct {
+	Rules   []*LifecycleRule
+	Reports []*LifecycleReport
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import "fmt"
+
+// The following defines the media classes of the disks.
+// A data partition of a media class is created on the disks of the class only,
+// and MediaUnspecified means that the data can be stored on any disk.
+const (
+	MediaUnspecified uint8 = iota
+	MediaSSD
+	MediaHDD
+)
+
+const (
+	MediaNameSSD = "ssd"
+	MediaNameHDD = "hdd"
+)
+
+// MediaTypes lists the media classes known to the cluster.
+var MediaTypes = []uint8{MediaSSD, MediaHDD}
+
+// MediaTypeString returns the name of the media class.
+func MediaTypeString(mediaType uint8) string {
+	switch mediaType {
+	case MediaSSD:
+		return MediaNameSSD
+	case MediaHDD:
+		return MediaNameHDD
+	default:
+		return ""
+	}
+}
+
+// ParseMediaType parses the name of a media class. An empty name means MediaUnspecified.
+func ParseMediaType(name string) (mediaType uint8, err error) {
+	switch name {
+	case "":
+		return MediaUnspecified, nil
+	case MediaNameSSD:
+		return MediaSSD, nil
+	case MediaNameHDD:
+		return MediaHDD, nil
+	default:
+		return MediaUnspecified, fmt.Errorf("invalid media type[%v]", name)
+	}
+}
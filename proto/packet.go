@@ -62,6 +62,7 @@ const (
 	OpSyncRandomWrite        uint8 = 0x12
 	OpSyncWrite              uint8 = 0x13
 	OpReadTinyDelete         uint8 = 0x14
+	OpTryToLeader            uint8 = 0x15
 
 	// Operations: Client -> MetaNode.
 	OpMetaCreateInode   uint8 = 0x20
@@ -86,22 +87,29 @@ const (
 	//Operations: MetaNode Leader -> MetaNode Follower
 	OpMetaFreeInodesOnRaftFollower uint8 = 0x32
 
+	// Operations: Mover -> MetaNode
+	OpMetaExtentsReplace uint8 = 0x33
+
 	// Operations: Master -> MetaNode
-	OpCreateMetaPartition       uint8 = 0x40
-	OpMetaNodeHeartbeat         uint8 = 0x41
-	OpDeleteMetaPartition       uint8 = 0x42
-	OpUpdateMetaPartition       uint8 = 0x43
-	OpLoadMetaPartition         uint8 = 0x44
-	OpDecommissionMetaPartition uint8 = 0x45
+	OpCreateMetaPartition           uint8 = 0x40
+	OpMetaNodeHeartbeat             uint8 = 0x41
+	OpDeleteMetaPartition           uint8 = 0x42
+	OpUpdateMetaPartition           uint8 = 0x43
+	OpLoadMetaPartition             uint8 = 0x44
+	OpDecommissionMetaPartition     uint8 = 0x45
+	OpAddMetaPartitionRaftMember    uint8 = 0x46
+	OpRemoveMetaPartitionRaftMember uint8 = 0x47
 
 	// Operations: Master -> DataNode
-	OpCreateDataPartition       uint8 = 0x60
-	OpDeleteDataPartition       uint8 = 0x61
-	OpLoadDataPartition         uint8 = 0x62
-	OpDataNodeHeartbeat         uint8 = 0x63
-	OpReplicateFile             uint8 = 0x64
-	OpDeleteFile                uint8 = 0x65
-	OpDecommissionDataPartition uint8 = 0x66
+	OpCreateDataPartition           uint8 = 0x60
+	OpDeleteDataPartition           uint8 = 0x61
+	OpLoadDataPartition             uint8 = 0x62
+	OpDataNodeHeartbeat             uint8 = 0x63
+	OpReplicateFile                 uint8 = 0x64
+	OpDeleteFile                    uint8 = 0x65
+	OpDecommissionDataPartition     uint8 = 0x66
+	OpAddDataPartitionRaftMember    uint8 = 0x67
+	OpRemoveDataPartitionRaftMember uint8 = 0x68
 
 	// Commons
 	OpIntraGroupNetErr uint8 = 0xF3
@@ -116,6 +124,8 @@ const (
 	OpTryOtherAddr     uint8 = 0xFC
 	OpNotPerm          uint8 = 0xFD
 	OpNotEmtpy         uint8 = 0xFE
+	OpLimitedErr       uint8 = 0xF1 // the request exceeds the qos limits of the volume, and should be retried later
+	OpVolFullErr       uint8 = 0xF2 // the volume reaches its capacity
 	OpOk               uint8 = 0xF0
 
 	OpPing uint8 = 0xFF
@@ -135,6 +145,11 @@ const (
 	NormalExtentType = 1
 )
 
+// ZeroCopyReadArg is carried in the arg of a stream read by the clients accepting the zero-copy replies.
+// The CRC of a zero-copy reply is the persisted block CRC rather than the one computed on the data read,
+// so these clients retry the read without this arg on a CRC mismatch.
+const ZeroCopyReadArg = "zerocopy"
+
 // Packet defines the packet structure.
 type Packet struct {
 	Magic              uint8
@@ -241,6 +256,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaUpdateDentry"
 	case OpMetaTruncate:
 		m = "OpMetaTruncate"
+	case OpMetaExtentsReplace:
+		m = "OpMetaExtentsReplace"
 	case OpMetaLinkInode:
 		m = "OpMetaLinkInode"
 	case OpMetaEvictInode:
@@ -259,6 +276,10 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpLoadMetaPartition"
 	case OpDecommissionMetaPartition:
 		m = "OpDecommissionMetaPartition"
+	case OpAddMetaPartitionRaftMember:
+		m = "OpAddMetaPartitionRaftMember"
+	case OpRemoveMetaPartitionRaftMember:
+		m = "OpRemoveMetaPartitionRaftMember"
 	case OpCreateDataPartition:
 		m = "OpCreateDataPartition"
 	case OpDeleteDataPartition:
@@ -267,6 +288,10 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpLoadDataPartition"
 	case OpDecommissionDataPartition:
 		m = "OpDecommissionDataPartition"
+	case OpAddDataPartitionRaftMember:
+		m = "OpAddDataPartitionRaftMember"
+	case OpRemoveDataPartitionRaftMember:
+		m = "OpRemoveDataPartitionRaftMember"
 	case OpDataNodeHeartbeat:
 		m = "OpDataNodeHeartbeat"
 	case OpReplicateFile:
@@ -283,6 +308,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpSyncRandomWrite"
 	case OpReadTinyDelete:
 		m = "OpReadTinyDelete"
+	case OpTryToLeader:
+		m = "OpTryToLeader"
 	case OpPing:
 		m = "OpPing"
 	case OpBroadcastMinAppliedID:
@@ -324,6 +351,10 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "NotPerm"
 	case OpNotEmtpy:
 		m = "DirNotEmpty"
+	case OpLimitedErr:
+		m = "LimitedErr"
+	case OpVolFullErr:
+		m = "VolFullErr"
 	default:
 		return fmt.Sprintf("Unknown ResultCode(%v)", p.ResultCode)
 	}
@@ -476,6 +507,14 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 	return err
 }
 
+// IsZeroCopyRead returns if the sender of the read accepts the zero-copy replies.
+func (p *Packet) IsZeroCopyRead() bool {
+	if p.Opcode != OpStreamRead && p.Opcode != OpRead {
+		return false
+	}
+	return int(p.ArgLen) <= len(p.Arg) && string(p.GetArg()) == ZeroCopyReadArg
+}
+
 // PacketOkReply sets the result code as OpOk, and sets the body as empty.
 func (p *Packet) PacketOkReply() {
 	p.ResultCode = OpOk
@@ -560,5 +599,5 @@ func (p *Packet) LogMessage(action, remote string, start int64, err error) (m st
 
 // ShallRetry returns if we should retry the packet.
 func (p *Packet) ShouldRetry() bool {
-	return p.ResultCode == OpAgain || p.ResultCode == OpErr
+	return p.ResultCode == OpAgain || p.ResultCode == OpErr || p.ResultCode == OpLimitedErr
 }
@@ -0,0 +1,261 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The access modes of the volume tokens.
+const (
+	TokenModeReadOnly  = "ro"
+	TokenModeReadWrite = "rw"
+)
+
+// TokenArgSeparator separates the volume token from the original arg of a packet.
+const TokenArgSeparator = "|token:"
+
+// VolumeToken grants the access to a volume in the given mode until it expires.
+// The master signs the tokens, and the meta nodes and data nodes verify them with its public key.
+type VolumeToken struct {
+	ID      string
+	VolName string
+	Mode    string
+	Expire  int64  // unix time
+	Epoch   uint64 `json:",omitempty"` // the token epoch of the volume when the token is issued
+}
+
+// TokenView is the token issued by the master, and its signed string.
+type TokenView struct {
+	Token   string
+	ID      string
+	VolName string
+	Mode    string
+	Expire  int64
+}
+
+// RevokedToken is a token revoked before its expiration.
+type RevokedToken struct {
+	ID      string
+	VolName string
+	Expire  int64
+}
+
+// TokenVerifyInfo holds what the nodes need to verify the volume tokens, which is pushed through the heartbeats.
+type TokenVerifyInfo struct {
+	PublicKey     []byte
+	EnforcedVols  []string          // the volumes requiring a token
+	Revoked       []*RevokedToken   // the revoked tokens which have not expired yet
+	Epochs        map[string]uint64 `json:",omitempty"` // the tokens of a volume issued before its token epoch are revoked
+	ServiceTokens map[string]string `json:",omitempty"` // short-lived tokens of the enforced volumes for the services on the meta nodes
+}
+
+// ValidTokenMode returns if the mode is one of the access modes of the volume tokens.
+func ValidTokenMode(mode string) bool {
+	return mode == TokenModeReadOnly || mode == TokenModeReadWrite
+}
+
+// Sign encodes the token and its signature into the string carried by the clients.
+func (t *VolumeToken) Sign(key ed25519.PrivateKey) (token string, err error) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	sig := ed25519.Sign(key, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// ParseVolumeToken decodes the token and checks its signature and expiration.
+func ParseVolumeToken(token string, key ed25519.PublicKey) (t *VolumeToken, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || len(key) != ed25519.PublicKeySize {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !ed25519.Verify(key, payload, sig) {
+		return nil, ErrInvalidToken
+	}
+	t = new(VolumeToken)
+	if err = json.Unmarshal(payload, t); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() > t.Expire {
+		return nil, ErrTokenExpired
+	}
+	return
+}
+
+// Allows checks if the token grants the access to the volume, writes need a read-write token.
+func (t *VolumeToken) Allows(volName string, write bool) error {
+	if t.VolName != volName || (write && t.Mode != TokenModeReadWrite) {
+		return ErrTokenNotAllowed
+	}
+	return nil
+}
+
+func (t *VolumeToken) String() string {
+	return fmt.Sprintf("token(%v) vol(%v) mode(%v) expire(%v)", t.ID, t.VolName, t.Mode, time.Unix(t.Expire, 0))
+}
+
+// SetToken carries the volume token after the original arg of the packet, or replaces the one carried.
+func (p *Packet) SetToken(token string) {
+	arg := p.GetArg()
+	if token != "" {
+		arg = append(append(append([]byte{}, arg...), TokenArgSeparator...), token...)
+	}
+	p.Arg = arg
+	p.ArgLen = uint32(len(arg))
+}
+
+// GetToken returns the volume token carried by the packet.
+func (p *Packet) GetToken() string {
+	if int(p.ArgLen) > len(p.Arg) {
+		return ""
+	}
+	arg := p.Arg[:p.ArgLen]
+	if index := bytes.LastIndex(arg, []byte(TokenArgSeparator)); index >= 0 {
+		return string(arg[index+len(TokenArgSeparator):])
+	}
+	return ""
+}
+
+// GetArg returns the arg of the packet without the volume token.
+func (p *Packet) GetArg() []byte {
+	if int(p.ArgLen) > len(p.Arg) {
+		return p.Arg
+	}
+	arg := p.Arg[:p.ArgLen]
+	if index := bytes.LastIndex(arg, []byte(TokenArgSeparator)); index >= 0 {
+		return arg[:index]
+	}
+	return arg
+}
+
+// IsTokenError returns if the error message is caused by a missing or rejected volume token.
+func IsTokenError(msg string) bool {
+	for _, err := range []error{ErrTokenRequired, ErrInvalidToken, ErrTokenExpired, ErrTokenRevoked, ErrTokenNotAllowed} {
+		if strings.Contains(msg, err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenVerifier verifies the volume tokens on the meta nodes and data nodes with the info pushed by the master.
+type TokenVerifier struct {
+	sync.RWMutex
+	publicKey     ed25519.PublicKey
+	enforced      map[string]bool
+	revoked       map[string]bool
+	epochs        map[string]uint64
+	serviceTokens map[string]string
+	loaded        bool // the verify info has been pushed by the master
+}
+
+// NewTokenVerifier creates a new verifier which does not enforce any volume.
+func NewTokenVerifier() *TokenVerifier {
+	return &TokenVerifier{
+		enforced:      make(map[string]bool),
+		revoked:       make(map[string]bool),
+		epochs:        make(map[string]uint64),
+		serviceTokens: make(map[string]string),
+	}
+}
+
+// Update replaces the verify info by the one pushed by the master.
+func (v *TokenVerifier) Update(info *TokenVerifyInfo) {
+	if info == nil {
+		return
+	}
+	enforced := make(map[string]bool, len(info.EnforcedVols))
+	for _, name := range info.EnforcedVols {
+		enforced[name] = true
+	}
+	revoked := make(map[string]bool, len(info.Revoked))
+	for _, t := range info.Revoked {
+		revoked[t.ID] = true
+	}
+	epochs := info.Epochs
+	if epochs == nil {
+		epochs = make(map[string]uint64)
+	}
+	serviceTokens := info.ServiceTokens
+	if serviceTokens == nil {
+		serviceTokens = make(map[string]string)
+	}
+	v.Lock()
+	defer v.Unlock()
+	v.publicKey = info.PublicKey
+	v.enforced = enforced
+	v.revoked = revoked
+	v.epochs = epochs
+	v.serviceTokens = serviceTokens
+	v.loaded = true
+}
+
+// Loaded returns if the verify info has been pushed by the master, before which the volumes requiring
+// the tokens are unknown.
+func (v *TokenVerifier) Loaded() bool {
+	v.RLock()
+	defer v.RUnlock()
+	return v.loaded
+}
+
+// Enforced returns if any volume requires the tokens.
+func (v *TokenVerifier) Enforced() bool {
+	v.RLock()
+	defer v.RUnlock()
+	return len(v.enforced) != 0
+}
+
+// Verify checks the token carried by a request to the volume. The requests to the volumes
+// not requiring the tokens are always allowed.
+func (v *TokenVerifier) Verify(volName, token string, write bool) (err error) {
+	v.RLock()
+	defer v.RUnlock()
+	if !v.enforced[volName] {
+		return
+	}
+	if token == "" {
+		return ErrTokenRequired
+	}
+	var t *VolumeToken
+	if t, err = ParseVolumeToken(token, v.publicKey); err != nil {
+		return
+	}
+	if v.revoked[t.ID] || t.Epoch < v.epochs[volName] {
+		return ErrTokenRevoked
+	}
+	return t.Allows(volName, write)
+}
+
+// ServiceToken returns the token issued by the master to the services on the meta nodes, empty
+// if the volume does not require the tokens.
+func (v *TokenVerifier) ServiceToken(volName string) string {
+	v.RLock()
+	defer v.RUnlock()
+	return v.serviceTokens[volName]
+}
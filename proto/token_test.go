@@ -0,0 +1,165 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func signTestToken(t *testing.T, key ed25519.PrivateKey, token *VolumeToken) string {
+	signed, err := token.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVolumeTokenSignAndParse(t *testing.T) {
+	pub, priv := newTestKey(t)
+	token := &VolumeToken{ID: "1", VolName: "vol", Mode: TokenModeReadOnly, Expire: time.Now().Unix() + 60, Epoch: 2}
+	signed := signTestToken(t, priv, token)
+
+	parsed, err := ParseVolumeToken(signed, pub)
+	if err != nil {
+		t.Fatalf("parse token err(%v)", err)
+	}
+	if *parsed != *token {
+		t.Fatalf("parsed token %v, expected %v", parsed, token)
+	}
+
+	otherPub, _ := newTestKey(t)
+	if _, err = ParseVolumeToken(signed, otherPub); err != ErrInvalidToken {
+		t.Fatalf("token of another key: err(%v)", err)
+	}
+	// the payload is changed to grant the read-write access
+	parts := strings.Split(signed, ".")
+	forged := signTestToken(t, priv, &VolumeToken{ID: "1", VolName: "vol", Mode: TokenModeReadWrite, Expire: token.Expire})
+	if _, err = ParseVolumeToken(strings.Split(forged, ".")[0]+"."+parts[1], pub); err != ErrInvalidToken {
+		t.Fatalf("forged token: err(%v)", err)
+	}
+	for _, invalid := range []string{"", "abc", parts[0], signed + ".x", "!." + parts[1]} {
+		if _, err = ParseVolumeToken(invalid, pub); err != ErrInvalidToken {
+			t.Fatalf("invalid token(%v): err(%v)", invalid, err)
+		}
+	}
+}
+
+func TestVolumeTokenExpire(t *testing.T) {
+	pub, priv := newTestKey(t)
+	signed := signTestToken(t, priv, &VolumeToken{ID: "1", VolName: "vol", Mode: TokenModeReadWrite, Expire: time.Now().Unix() - 1})
+	if _, err := ParseVolumeToken(signed, pub); err != ErrTokenExpired {
+		t.Fatalf("expired token: err(%v)", err)
+	}
+}
+
+func TestVolumeTokenMode(t *testing.T) {
+	ro := &VolumeToken{ID: "1", VolName: "vol", Mode: TokenModeReadOnly}
+	rw := &VolumeToken{ID: "2", VolName: "vol", Mode: TokenModeReadWrite}
+	if ro.Allows("vol", false) != nil || rw.Allows("vol", false) != nil || rw.Allows("vol", true) != nil {
+		t.Fatalf("token rejects the access it grants")
+	}
+	if ro.Allows("vol", true) != ErrTokenNotAllowed {
+		t.Fatalf("read-only token allows the writes")
+	}
+	if rw.Allows("other", false) != ErrTokenNotAllowed {
+		t.Fatalf("token allows another vol")
+	}
+	if !ValidTokenMode(TokenModeReadOnly) || !ValidTokenMode(TokenModeReadWrite) || ValidTokenMode("wo") {
+		t.Fatalf("unexpected valid modes")
+	}
+}
+
+func TestTokenVerifier(t *testing.T) {
+	pub, priv := newTestKey(t)
+	expire := time.Now().Unix() + 60
+	old := signTestToken(t, priv, &VolumeToken{ID: "1", VolName: "vol", Mode: TokenModeReadWrite, Expire: expire})
+	revoked := signTestToken(t, priv, &VolumeToken{ID: "2", VolName: "vol", Mode: TokenModeReadWrite, Expire: expire, Epoch: 1})
+	valid := signTestToken(t, priv, &VolumeToken{ID: "3", VolName: "vol", Mode: TokenModeReadWrite, Expire: expire, Epoch: 1})
+
+	v := NewTokenVerifier()
+	if err := v.Verify("vol", "", true); err != nil || v.Loaded() {
+		t.Fatalf("verifier without info: err(%v) loaded(%v)", err, v.Loaded())
+	}
+	v.Update(&TokenVerifyInfo{
+		PublicKey:    pub,
+		EnforcedVols: []string{"vol"},
+		Revoked:      []*RevokedToken{{ID: "2", VolName: "vol", Expire: expire}},
+		Epochs:       map[string]uint64{"vol": 1},
+	})
+	if !v.Loaded() || !v.Enforced() {
+		t.Fatalf("verify info is not loaded")
+	}
+	if err := v.Verify("other", "", true); err != nil {
+		t.Fatalf("vol not enforced: err(%v)", err)
+	}
+	if err := v.Verify("vol", "", false); err != ErrTokenRequired {
+		t.Fatalf("missing token: err(%v)", err)
+	}
+	if err := v.Verify("vol", old, false); err != ErrTokenRevoked {
+		t.Fatalf("token of an earlier epoch: err(%v)", err)
+	}
+	if err := v.Verify("vol", revoked, false); err != ErrTokenRevoked {
+		t.Fatalf("revoked token: err(%v)", err)
+	}
+	if err := v.Verify("vol", valid, true); err != nil {
+		t.Fatalf("valid token: err(%v)", err)
+	}
+}
+
+func TestPacketToken(t *testing.T) {
+	for _, arg := range [][]byte{nil, []byte("1,2,3"), []byte("a|b")} {
+		p := NewPacket()
+		p.Arg = append([]byte{}, arg...)
+		p.ArgLen = uint32(len(arg))
+
+		p.SetToken("token1")
+		if p.GetToken() != "token1" || !bytes.Equal(p.GetArg(), arg) {
+			t.Fatalf("arg(%s) token(%v) after setting token1 to arg(%s)", p.GetArg(), p.GetToken(), arg)
+		}
+		if int(p.ArgLen) != len(p.Arg) {
+			t.Fatalf("arg length(%v) of %v bytes", p.ArgLen, len(p.Arg))
+		}
+		// the token is replaced, not appended again
+		p.SetToken("token2")
+		if p.GetToken() != "token2" || !bytes.Equal(p.GetArg(), arg) {
+			t.Fatalf("arg(%s) token(%v) after replacing the token of arg(%s)", p.GetArg(), p.GetToken(), arg)
+		}
+		p.SetToken("")
+		if p.GetToken() != "" || !bytes.Equal(p.GetArg(), arg) {
+			t.Fatalf("arg(%s) token(%v) after removing the token of arg(%s)", p.GetArg(), p.GetToken(), arg)
+		}
+	}
+
+	// an arg length beyond the arg is not trusted
+	p := NewPacket()
+	p.Arg = []byte("abc")
+	p.ArgLen = 10
+	if p.GetToken() != "" || !bytes.Equal(p.GetArg(), p.Arg) {
+		t.Fatalf("arg(%s) token(%v) of a broken packet", p.GetArg(), p.GetToken())
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The types of the objects whose usage is sampled by the master.
+const (
+	UsageTypeVol      = "vol"
+	UsageTypeDataNode = "dataNode"
+	UsageTypeMetaNode = "metaNode"
+)
+
+// The resolutions of the usage history. The raw samples are rolled up to the hourly and the daily averages.
+const (
+	UsageResolutionRaw  = "raw"
+	UsageResolutionHour = "hour"
+	UsageResolutionDay  = "day"
+)
+
+// UsagePoint is the usage of a volume or a node sampled at the time, or averaged over the period starting at the time.
+type UsagePoint struct {
+	Time       int64  // unix time in seconds
+	Used       uint64 // bytes, the memory for the meta nodes
+	Total      uint64 // the capacity of the volume, or the space of the node
+	Partitions uint64 // the data partitions of the volume, or the partitions hosted by the node
+}
+
+// UsageHistory is the usage of a volume or a node over time, with the growth projected from it.
+type UsageHistory struct {
+	Type         string
+	Name         string
+	Resolution   string
+	Points       []*UsagePoint
+	GrowthPerDay int64 // bytes per day, by the linear regression of the used space over the points
+	FullTime     int64 // unix time when the used space is projected to reach the total, 0 if it never does
+}
@@ -15,6 +15,8 @@
 package raftstore
 
 import (
+	"crypto/tls"
+
 	"github.com/tiglabs/raft/proto"
 )
 
@@ -32,7 +34,8 @@ type Config struct {
 	IPAddr            string // IP address
 	HeartbeatPort     int
 	ReplicaPort       int
-	NumOfLogsToRetain uint64 // number of logs to be kept after truncation. The default value is 20000.
+	NumOfLogsToRetain uint64      // number of logs to be kept after truncation. The default value is 20000.
+	TLSConfig         *tls.Config // enables the TLS on the heartbeat and replica ports if not nil
 }
 
 // PeerAddress defines the set of addresses that will be used by the peers.
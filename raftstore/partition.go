@@ -65,6 +65,9 @@ type Partition interface {
 
 	// Truncate raft log
 	Truncate(index uint64)
+
+	// TryToLeader makes the local replica campaign to take over the leadership of the raft group.
+	TryToLeader() error
 }
 
 // Default implementation of the Partition interface.
@@ -87,6 +90,13 @@ func (p *partition) ChangeMember(changeType proto.ConfChangeType, peer proto.Pee
 	return
 }
 
+// TryToLeader makes the local replica campaign to take over the leadership of the raft group.
+func (p *partition) TryToLeader() (err error) {
+	future := p.raft.TryToLeader(p.id)
+	_, err = future.Response()
+	return
+}
+
 // Stop removes the raft partition from raft server and shuts down this partition.
 func (p *partition) Stop() (err error) {
 	err = p.raft.RemoveRaft(p.id)
@@ -121,6 +121,7 @@ func NewRaftStore(cfg *Config) (mr RaftStore, err error) {
 	rc.HeartbeatAddr = fmt.Sprintf("%s:%d", cfg.IPAddr, cfg.HeartbeatPort)
 	rc.ReplicateAddr = fmt.Sprintf("%s:%d", cfg.IPAddr, cfg.ReplicaPort)
 	rc.Resolver = resolver
+	rc.TLSConfig = cfg.TLSConfig
 	rc.RetainLogs = cfg.NumOfLogsToRetain
 	rc.TickInterval = 300 * time.Millisecond
 	rc.ElectionTick = 3
@@ -55,6 +55,11 @@ func (rs *RocksDBStore) Open(lruCacheSize, writeBufferSize int) error {
 
 }
 
+// Close closes the RocksDB instance.
+func (rs *RocksDBStore) Close() {
+	rs.db.Close()
+}
+
 // Del deletes a key-value pair.
 func (rs *RocksDBStore) Del(key interface{}, isSync bool) (result interface{}, err error) {
 	ro := gorocksdb.NewDefaultReadOptions()
@@ -187,6 +192,52 @@ func (rs *RocksDBStore) SeekForPrefix(prefix []byte) (result map[string][]byte,
 	return result, nil
 }
 
+// RangeScan calls fn with the key-value pairs in [start, end) in the order of the keys, until fn returns false.
+func (rs *RocksDBStore) RangeScan(start, end []byte, fn func(key string, value []byte) bool) (err error) {
+	snapshot := rs.RocksDBSnapshot()
+	it := rs.Iterator(snapshot)
+	defer func() {
+		it.Close()
+		rs.ReleaseSnapshot(snapshot)
+	}()
+	for it.Seek(start); it.Valid(); it.Next() {
+		key := string(it.Key().Data())
+		value := it.Value().Data()
+		valueByte := make([]byte, len(value))
+		copy(valueByte, value)
+		it.Key().Free()
+		it.Value().Free()
+		if key >= string(end) || !fn(key, valueByte) {
+			break
+		}
+	}
+	return it.Err()
+}
+
+// DeleteRangeAndPutIndex deletes the key-value pairs in [start, end) and put the keys in the cmdMap to RocksDB.
+func (rs *RocksDBStore) DeleteRangeAndPutIndex(start, end string, cmdMap map[string][]byte, isSync bool) error {
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(isSync)
+	wb := gorocksdb.NewWriteBatch()
+	defer func() {
+		wo.Destroy()
+		wb.Destroy()
+	}()
+	if err := rs.RangeScan([]byte(start), []byte(end), func(key string, _ []byte) bool {
+		wb.Delete([]byte(key))
+		return true
+	}); err != nil {
+		return fmt.Errorf("action[deleteRangeFromRocksDB],err:%v", err)
+	}
+	for key, value := range cmdMap {
+		wb.Put([]byte(key), value)
+	}
+	if err := rs.db.Write(wo, wb); err != nil {
+		return fmt.Errorf("action[deleteRangeFromRocksDB],err:%v", err)
+	}
+	return nil
+}
+
 // RocksDBSnapshot returns the RocksDB snapshot.
 func (rs *RocksDBStore) RocksDBSnapshot() *gorocksdb.Snapshot {
 	return rs.db.NewSnapshot()
@@ -36,7 +36,7 @@ var (
 
 type Packet struct {
 	proto.Packet
-	followerConns  []*net.TCPConn
+	followerConns  []net.Conn
 	followersAddrs []string
 	IsReleased     int32 // TODO what is released?
 	Object         interface{}
@@ -105,14 +105,14 @@ func (p *Packet) resolveFollowersAddr() (err error) {
 		err = ErrArgLenMismatch
 		return
 	}
-	str := string(p.Arg[:int(p.ArgLen)])
+	str := string(p.GetArg())
 	followerAddrs := strings.SplitN(str, proto.AddrSplit, -1)
 	followerNum := uint8(len(followerAddrs) - 1)
 	p.followersAddrs = make([]string, followerNum)
 	if followerNum > 0 {
 		p.followersAddrs = followerAddrs[:int(followerNum)]
 	}
-	p.followerConns = make([]*net.TCPConn, followerNum)
+	p.followerConns = make([]net.Conn, followerNum)
 	if p.RemainingFollowers < 0 {
 		err = ErrBadNodes
 		return
@@ -136,7 +136,9 @@ func (p *Packet) IsMasterCommand() bool {
 		proto.OpLoadDataPartition,
 		proto.OpCreateDataPartition,
 		proto.OpDeleteDataPartition,
-		proto.OpDecommissionDataPartition:
+		proto.OpDecommissionDataPartition,
+		proto.OpAddDataPartitionRaftMember,
+		proto.OpRemoveDataPartitionRaftMember:
 		return true
 	}
 	return false
@@ -247,6 +249,14 @@ func (p *Packet) identificationErrorResultCode(errLog string, errMsg string) {
 		p.ResultCode = proto.OpAgain
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
+	} else if strings.Contains(errMsg, proto.ErrQoSLimited.Error()) {
+		p.ResultCode = proto.OpLimitedErr
+	} else if strings.Contains(errMsg, proto.ErrVolFull.Error()) {
+		p.ResultCode = proto.OpVolFullErr
+	} else if strings.Contains(errMsg, proto.ErrTokenInfoNotLoaded.Error()) {
+		p.ResultCode = proto.OpAgain
+	} else if proto.IsTokenError(errMsg) {
+		p.ResultCode = proto.OpNotPerm
 	} else {
 		p.ResultCode = proto.OpIntraGroupNetErr
 	}
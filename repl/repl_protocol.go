@@ -45,24 +45,24 @@ type ReplProtocol struct {
 	toBeProcessedCh chan *Packet // the goroutine receives an available packet and then sends it to this channel
 	responseCh      chan *Packet // this chan is used to write response to the client
 
-	sourceConn *net.TCPConn
+	sourceConn net.Conn
 	exitC      chan bool
 	exited     int32
 	exitedMu   sync.RWMutex
 
-	followerConnects map[string]*net.TCPConn
+	followerConnects map[string]net.Conn
 	lock             sync.RWMutex
 
 	prepareFunc  func(p *Packet) error                 // prepare packet
-	operatorFunc func(p *Packet, c *net.TCPConn) error // operator
+	operatorFunc func(p *Packet, c net.Conn) error // operator
 	postFunc     func(p *Packet) error                 // post-processing packet
 
 	isError int32
 	replId  int64
 }
 
-func NewReplProtocol(inConn *net.TCPConn, prepareFunc func(p *Packet) error,
-	operatorFunc func(p *Packet, c *net.TCPConn) error, postFunc func(p *Packet) error) *ReplProtocol {
+func NewReplProtocol(inConn net.Conn, prepareFunc func(p *Packet) error,
+	operatorFunc func(p *Packet, c net.Conn) error, postFunc func(p *Packet) error) *ReplProtocol {
 	rp := new(ReplProtocol)
 	rp.packetList = list.New()
 	rp.ackCh = make(chan struct{}, RequestChanSize)
@@ -70,7 +70,7 @@ func NewReplProtocol(inConn *net.TCPConn, prepareFunc func(p *Packet) error,
 	rp.responseCh = make(chan *Packet, RequestChanSize)
 	rp.exitC = make(chan bool, 1)
 	rp.sourceConn = inConn
-	rp.followerConnects = make(map[string]*net.TCPConn)
+	rp.followerConnects = make(map[string]net.Conn)
 	rp.prepareFunc = prepareFunc
 	rp.operatorFunc = operatorFunc
 	rp.postFunc = postFunc
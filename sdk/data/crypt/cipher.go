@@ -0,0 +1,184 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"golang.org/x/crypto/xts"
+)
+
+// BlockSize is the size of the blocks in which the data of a file is encrypted.
+const BlockSize = aes.BlockSize
+
+const (
+	shortFileRounds = 10
+	shortFileSector = math.MaxUint64 // never the index of a block, which is a file offset divided by BlockSize
+)
+
+// FileCipher encrypts and decrypts the data of a file with AES-XTS.
+//
+// The extents are addressed in bytes, and a write can cover any byte range of a file
+// (appends, overwrites and the recovery of a packet to another extent), so the cipher
+// has to be length preserving, and the cipher text must only depend on the position in
+// the file, since the mover copies the cipher text to other extents as it is.
+// The key of a volume is made of the two independent AES keys of XTS, from which the keys
+// of each file are derived by the inode ID. Each block of 16 bytes of the file is an XTS
+// data unit numbered by its index, so an overwrite only reveals which blocks are changed.
+// The last partial block of a file is encrypted together with the block before it by the
+// ciphertext stealing, so the writer has to encrypt the whole blocks again if it writes a
+// part of them, or the last blocks change. A file shorter than a block is encrypted as a
+// whole by a Feistel network whose round function is the block cipher, so an overwrite
+// of a short file does not reveal more than whether it is changed either.
+type FileCipher struct {
+	xts *xts.Cipher
+}
+
+// NewFileCipher returns a new file cipher.
+func NewFileCipher(key []byte, inode uint64) (*FileCipher, error) {
+	if err := checkKeyLen(key); err != nil {
+		return nil, err
+	}
+	half := len(key) / 2
+	fileKey := append(deriveFileKey(key[:half], inode), deriveFileKey(key[half:], inode)...)
+	c, err := xts.NewCipher(aes.NewCipher, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCipher{xts: c}, nil
+}
+
+func deriveFileKey(key []byte, inode uint64) []byte {
+	var ino [8]byte
+	binary.BigEndian.PutUint64(ino[:], inode)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(ino[:])
+	return mac.Sum(nil)[:len(key)]
+}
+
+// Encrypt encrypts the data in place. The data must start at a block boundary of the file,
+// and it may only end with a partial block if that is the last block of the file.
+func (c *FileCipher) Encrypt(data []byte, fileOffset uint64) {
+	index := fileOffset / BlockSize
+	full, tail := len(data)/BlockSize, len(data)%BlockSize
+	if tail > 0 && full == 0 {
+		c.feistel(data, false)
+		return
+	}
+	if tail > 0 {
+		full--
+	}
+	for i := 0; i < full; i++ {
+		c.encryptBlock(data[i*BlockSize:(i+1)*BlockSize], index+uint64(i))
+	}
+	if tail == 0 {
+		return
+	}
+	// steal the cipher text of the last full block to fill the partial one
+	last := data[full*BlockSize:]
+	var pp [BlockSize]byte
+	c.encryptBlock(last[:BlockSize], index+uint64(full))
+	copy(pp[:], last[BlockSize:])
+	copy(pp[tail:], last[tail:BlockSize])
+	copy(last[BlockSize:], last[:tail])
+	copy(last[:BlockSize], pp[:])
+	c.encryptBlock(last[:BlockSize], index+uint64(full)+1)
+}
+
+// Decrypt decrypts the data in place, which is aligned the same way as the encrypted one.
+func (c *FileCipher) Decrypt(data []byte, fileOffset uint64) {
+	index := fileOffset / BlockSize
+	full, tail := len(data)/BlockSize, len(data)%BlockSize
+	if tail > 0 && full == 0 {
+		c.feistel(data, true)
+		return
+	}
+	if tail > 0 {
+		full--
+	}
+	for i := 0; i < full; i++ {
+		c.decryptBlock(data[i*BlockSize:(i+1)*BlockSize], index+uint64(i))
+	}
+	if tail == 0 {
+		return
+	}
+	last := data[full*BlockSize:]
+	var cc [BlockSize]byte
+	c.decryptBlock(last[:BlockSize], index+uint64(full)+1)
+	copy(cc[:], last[BlockSize:])
+	copy(cc[tail:], last[tail:BlockSize])
+	copy(last[BlockSize:], last[:tail])
+	copy(last[:BlockSize], cc[:])
+	c.decryptBlock(last[:BlockSize], index+uint64(full))
+}
+
+func (c *FileCipher) encryptBlock(b []byte, index uint64) {
+	c.xts.Encrypt(b, b, index)
+}
+
+func (c *FileCipher) decryptBlock(b []byte, index uint64) {
+	c.xts.Decrypt(b, b, index)
+}
+
+// feistel encrypts or decrypts a file shorter than a block. The data is split into two halves
+// of at most 60 bits, which are mixed alternately with the encrypted other half.
+func (c *FileCipher) feistel(data []byte, decrypt bool) {
+	var buf [BlockSize]byte
+	copy(buf[BlockSize-len(data):], data)
+	hi, lo := binary.BigEndian.Uint64(buf[:8]), binary.BigEndian.Uint64(buf[8:])
+	bits := uint(len(data)) * 4
+	mask := uint64(1)<<bits - 1
+	left, right := (lo>>bits|hi<<(64-bits))&mask, lo&mask
+	for i := 0; i < shortFileRounds; i++ {
+		round := i
+		if decrypt {
+			round = shortFileRounds - 1 - i
+		}
+		if round%2 == 0 {
+			left ^= c.round(round, len(data), right) & mask
+		} else {
+			right ^= c.round(round, len(data), left) & mask
+		}
+	}
+	binary.BigEndian.PutUint64(buf[:8], left>>(64-bits))
+	binary.BigEndian.PutUint64(buf[8:], left<<bits|right)
+	copy(data, buf[BlockSize-len(data):])
+}
+
+func (c *FileCipher) round(round, size int, half uint64) uint64 {
+	var b [BlockSize]byte
+	b[0], b[1] = byte(round), byte(size)
+	binary.BigEndian.PutUint64(b[8:], half)
+	c.xts.Encrypt(b[:], b[:], shortFileSector)
+	return binary.BigEndian.Uint64(b[:8])
+}
+
+// checkKeyLen checks that the key is made of two different AES keys.
+func checkKeyLen(key []byte) error {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return fmt.Errorf("key length(%v) should be 32, 48 or 64", len(key))
+	}
+	if bytes.Equal(key[:len(key)/2], key[len(key)/2:]) {
+		return fmt.Errorf("the two halves of the key should be different")
+	}
+	return nil
+}
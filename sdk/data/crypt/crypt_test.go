@@ -0,0 +1,154 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chubaofs/chubaofs/util/config"
+)
+
+func TestFileCipher(t *testing.T) {
+	c, err := NewFileCipher(testKey(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, 1000)
+	for i := range plain {
+		plain[i] = byte(i)
+	}
+	// whole blocks, a partial last block, and files shorter than a block
+	for _, size := range []int{992, 1000, 17, 15, 7, 1} {
+		data := append([]byte{}, plain[:size]...)
+		c.Encrypt(data, 4096)
+		if bytes.Equal(data, plain[:size]) {
+			t.Fatalf("data of size %v is not encrypted", size)
+		}
+		c.Decrypt(data, 4096)
+		if !bytes.Equal(data, plain[:size]) {
+			t.Fatalf("decryption mismatch of size %v", size)
+		}
+	}
+
+	// the blocks can be decrypted separately, except the last two with the partial one
+	data := append([]byte{}, plain...)
+	c.Encrypt(data, 4096)
+	part := append([]byte{}, data[32:64]...)
+	c.Decrypt(part, 4096+32)
+	if !bytes.Equal(part, plain[32:64]) {
+		t.Fatalf("partial decryption mismatch")
+	}
+
+	// an overwrite does not reuse the key stream of the same position
+	other := append([]byte{}, plain...)
+	other[0] ^= 0xff
+	c.Encrypt(other, 4096)
+	if data[0]^other[0] == 0xff || bytes.Equal(data[1:16], other[1:16]) || !bytes.Equal(data[16:], other[16:]) {
+		t.Fatalf("overwrite should only change the cipher text of the block")
+	}
+
+	// the same data of another file is encrypted differently
+	c2, err := NewFileCipher(testKey(), 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other = append([]byte{}, plain...)
+	c2.Encrypt(other, 4096)
+	if bytes.Equal(data[:16], other[:16]) {
+		t.Fatalf("files share the cipher text")
+	}
+}
+
+func TestShortFileCipher(t *testing.T) {
+	c, err := NewFileCipher(testKey(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an overwrite of a short file must not reveal the XOR of the plain texts
+	for size := 1; size < BlockSize; size++ {
+		a, b := make([]byte, size), make([]byte, size)
+		b[0] = 1
+		plainXor := append([]byte{}, b...)
+		c.Encrypt(a, 0)
+		c.Encrypt(b, 0)
+		xor := make([]byte, size)
+		for i := range xor {
+			xor[i] = a[i] ^ b[i]
+		}
+		if bytes.Equal(xor, plainXor) {
+			t.Fatalf("short file of size %v reuses the key stream", size)
+		}
+	}
+}
+
+func TestCipherKey(t *testing.T) {
+	for _, key := range [][]byte{make([]byte, 16), bytes.Repeat([]byte{1}, 32), make([]byte, 65)} {
+		if _, err := NewFileCipher(key, 100); err == nil {
+			t.Fatalf("key %x is accepted", key)
+		}
+	}
+	for _, size := range []int{32, 48, 64} {
+		key := make([]byte, size)
+		key[0] = 1
+		if _, err := NewFileCipher(key, 100); err != nil {
+			t.Fatalf("key of %v bytes err(%v)", size, err)
+		}
+	}
+}
+
+func testKey() []byte {
+	key := make([]byte, 64)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := path.Join(dir, "keys.json")
+	content := `{"vol": {"current": 2, "keys": {"1": "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "2": "0f0e0d0c0b0a090807060504030201001f1e1d1c1b1a19181716151413121110"}}}`
+	if err = ioutil.WriteFile(keyFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewKeyProvider(FileKeyProviderName, "vol", config.LoadConfigString(`{}`)); err == nil {
+		t.Fatalf("provider without key file should fail")
+	}
+	kp, err := NewKeyProvider(FileKeyProviderName, "vol", config.LoadConfigString(`{"keyFile": "`+keyFile+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := kp.CurrentKeyID(); err != nil || id != 2 {
+		t.Fatalf("current key: id(%v) err(%v)", id, err)
+	}
+	if key, err := kp.GetKey(1); err != nil || key[1] != 1 {
+		t.Fatalf("get key: key(%v) err(%v)", key, err)
+	}
+	if _, err := kp.GetKey(3); err == nil {
+		t.Fatalf("unknown key should fail")
+	}
+	if _, err = NewFileKeyProvider(keyFile, "other"); err == nil {
+		t.Fatalf("unknown vol should fail")
+	}
+}
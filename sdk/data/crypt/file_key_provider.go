@@ -0,0 +1,152 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package crypt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+)
+
+const (
+	FileKeyProviderName = "file"
+	CfgKeyFile          = "keyFile"
+)
+
+func init() {
+	RegisterKeyProvider(FileKeyProviderName, func(volname string, cfg *config.Config) (KeyProvider, error) {
+		return NewFileKeyProvider(cfg.GetString(CfgKeyFile), volname)
+	})
+}
+
+// VolumeKeys defines the keys of a volume in the key file.
+type VolumeKeys struct {
+	Current uint32            `json:"current"`
+	Keys    map[string]string `json:"keys"` // key ID -> hex encoded XTS key, i.e. two AES keys
+}
+
+// FileKeyProvider reads the keys from a local JSON file, which maps each volume to its keys:
+//  {"vol": {"current": 2, "keys": {"1": "<hex key>", "2": "<hex key>"}}}
+// To rotate the key of a volume, add a new key and point "current" to it.
+// The file is reloaded once its modification time changes.
+type FileKeyProvider struct {
+	sync.RWMutex
+	path    string
+	volname string
+	modTime time.Time
+	current uint32
+	keys    map[uint32][]byte
+}
+
+// NewFileKeyProvider returns a new file key provider.
+func NewFileKeyProvider(path, volname string) (fp *FileKeyProvider, err error) {
+	if path == "" {
+		return nil, fmt.Errorf("key file is not specified")
+	}
+	fp = &FileKeyProvider{path: path, volname: volname}
+	if err = fp.reload(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// CurrentKeyID returns the ID of the key used for newly created files.
+func (fp *FileKeyProvider) CurrentKeyID() (uint32, error) {
+	if err := fp.reload(); err != nil {
+		return NoKeyID, err
+	}
+	fp.RLock()
+	defer fp.RUnlock()
+	return fp.current, nil
+}
+
+// GetKey returns the key of the given ID.
+func (fp *FileKeyProvider) GetKey(keyID uint32) ([]byte, error) {
+	fp.RLock()
+	key, ok := fp.keys[keyID]
+	fp.RUnlock()
+	if ok {
+		return key, nil
+	}
+	// the key might be newly added, so reload the file and try again.
+	if err := fp.reload(); err != nil {
+		return nil, err
+	}
+	fp.RLock()
+	defer fp.RUnlock()
+	if key, ok = fp.keys[keyID]; !ok {
+		return nil, fmt.Errorf("key(%v) of vol(%v) not found in %v", keyID, fp.volname, fp.path)
+	}
+	return key, nil
+}
+
+func (fp *FileKeyProvider) reload() (err error) {
+	info, err := os.Stat(fp.path)
+	if err != nil {
+		return
+	}
+	fp.RLock()
+	unchanged := info.ModTime().Equal(fp.modTime)
+	fp.RUnlock()
+	if unchanged {
+		return
+	}
+
+	data, err := ioutil.ReadFile(fp.path)
+	if err != nil {
+		return
+	}
+	vols := make(map[string]*VolumeKeys)
+	if err = json.Unmarshal(data, &vols); err != nil {
+		return fmt.Errorf("parse key file(%v) err(%v)", fp.path, err)
+	}
+	vk, ok := vols[fp.volname]
+	if !ok {
+		return fmt.Errorf("no keys of vol(%v) in %v", fp.volname, fp.path)
+	}
+	keys := make(map[uint32][]byte, len(vk.Keys))
+	for idStr, hexKey := range vk.Keys {
+		var (
+			id  uint64
+			key []byte
+		)
+		if id, err = strconv.ParseUint(idStr, 10, 32); err != nil || id == uint64(NoKeyID) {
+			return fmt.Errorf("invalid key ID(%v) of vol(%v)", idStr, fp.volname)
+		}
+		if key, err = hex.DecodeString(hexKey); err != nil {
+			return fmt.Errorf("invalid key(%v) of vol(%v) err(%v)", idStr, fp.volname, err)
+		}
+		if err = checkKeyLen(key); err != nil {
+			return fmt.Errorf("invalid key(%v) of vol(%v) err(%v)", idStr, fp.volname, err)
+		}
+		keys[uint32(id)] = key
+	}
+	if _, ok = keys[vk.Current]; !ok {
+		return fmt.Errorf("current key(%v) of vol(%v) not found", vk.Current, fp.volname)
+	}
+
+	fp.Lock()
+	fp.modTime = info.ModTime()
+	fp.current = vk.Current
+	fp.keys = keys
+	fp.Unlock()
+	return
+}
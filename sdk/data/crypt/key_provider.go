@@ -0,0 +1,65 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package crypt
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/chubaofs/chubaofs/util/config"
+)
+
+// NoKeyID is the key ID of an inode whose data is stored in plain text.
+const NoKeyID uint32 = 0
+
+// KeyProvider supplies the data keys of a volume.
+// New files are encrypted with the current key, while the key ID recorded on each inode
+// is used to look up the key of existing files, so that keys can be rotated without
+// rewriting the data.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key used for newly created files.
+	CurrentKeyID() (uint32, error)
+	// GetKey returns the key of the given ID.
+	GetKey(keyID uint32) ([]byte, error)
+}
+
+// KeyProviderConstructor creates a key provider of a volume from the client config.
+type KeyProviderConstructor func(volname string, cfg *config.Config) (KeyProvider, error)
+
+var (
+	providerMutex sync.RWMutex
+	providers     = make(map[string]KeyProviderConstructor)
+)
+
+// RegisterKeyProvider registers a key provider under the given name.
+func RegisterKeyProvider(name string, constructor KeyProviderConstructor) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	providers[name] = constructor
+}
+
+// NewKeyProvider returns the key provider of the given name.
+// An empty name means that the encryption is disabled, and a nil provider is returned.
+func NewKeyProvider(name, volname string, cfg *config.Config) (KeyProvider, error) {
+	if name == "" {
+		return nil, nil
+	}
+	providerMutex.RLock()
+	constructor, ok := providers[name]
+	providerMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key provider(%v)", name)
+	}
+	return constructor(volname, cfg)
+}
@@ -18,11 +18,14 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/chubaofs/chubaofs/util/errors"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
 	"github.com/chubaofs/chubaofs/util/exporter"
 	"github.com/chubaofs/chubaofs/util/log"
@@ -31,10 +34,16 @@ import (
 type AppendExtentKeyFunc func(inode uint64, key proto.ExtentKey) error
 type GetExtentsFunc func(inode uint64) (uint64, uint64, []proto.ExtentKey, error)
 type TruncateFunc func(inode, size uint64) error
+type GetKeyIDFunc func(inode uint64) (uint32, error)
+type GetMediaTypeFunc func(inode uint64) (uint8, error)
+type SetModifyTimeFunc func(inode uint64, mtime int64) error
 
 const (
 	MaxMountRetryLimit = 5
 	MountRetryInterval = time.Second * 5
+
+	// The writes extending the files fail with ENOSPC within this interval after the volume is found full.
+	VolFullRetryInterval = time.Second * 5
 )
 
 var (
@@ -54,6 +63,20 @@ type ExtentClient struct {
 	appendExtentKey AppendExtentKeyFunc
 	getExtents      GetExtentsFunc
 	truncate        TruncateFunc
+
+	// Encryption is enabled if keys is not nil. The encrypted files fail to be read
+	// or written if only getKeyID is set.
+	keys     crypt.KeyProvider
+	getKeyID GetKeyIDFunc
+
+	// New data is written to the data partitions of the media class of each inode if it is not nil.
+	getMediaType GetMediaTypeFunc
+
+	// The modify time of the inode is updated before each overwrite if it is not nil.
+	setModifyTime SetModifyTimeFunc
+
+	// The last time the writes are refused since the volume reaches its capacity, in nanoseconds.
+	volFullTime int64
 }
 
 // NewExtentClient returns a new extent client.
@@ -102,6 +125,30 @@ retry:
 	return
 }
 
+// SetKeyProvider enables the encryption of file data. The key of each inode is
+// looked up by the key ID recorded in the meta node. The keys may be nil, so that
+// the encrypted files are refused instead of being read or written as plain text.
+func (client *ExtentClient) SetKeyProvider(keys crypt.KeyProvider, getKeyID GetKeyIDFunc) {
+	client.keys = keys
+	client.getKeyID = getKeyID
+}
+
+// SetToken makes the packets carry the access token of the volume.
+func (client *ExtentClient) SetToken(token string) {
+	gDataWrapper.SetToken(token)
+}
+
+// SetModifyTimeUpdater makes the overwrites update the modify time of the inode first, so that
+// they fail the concurrent moves of the data to another media class.
+func (client *ExtentClient) SetModifyTimeUpdater(setModifyTime SetModifyTimeFunc) {
+	client.setModifyTime = setModifyTime
+}
+
+// SetMediaTypeProvider makes the new data of each inode written to the data partitions of its media class.
+func (client *ExtentClient) SetMediaTypeProvider(getMediaType GetMediaTypeFunc) {
+	client.getMediaType = getMediaType
+}
+
 // Open request shall grab the lock until request is sent to the request channel
 func (client *ExtentClient) OpenStream(inode uint64) error {
 	client.streamerLock.Lock()
@@ -180,12 +227,30 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, direct
 	s.once.Do(func() {
 		// TODO unhandled error
 		s.GetExtents()
+		s.loadCipher()
+		s.loadMediaType()
 	})
 
+	// Never write plain text to an encrypted file.
+	if s.cipherErr != nil {
+		err = errors.Trace(s.cipherErr, "%s", prefix)
+		log.LogError(errors.Stack(err))
+		return 0, err
+	}
+
+	if client.isVolFull() {
+		if size, _ := s.extents.Size(); offset+len(data) > size {
+			return 0, syscall.ENOSPC
+		}
+	}
+
 	write, err = s.IssueWriteRequest(offset, data, direct)
 	if err != nil {
 		err = errors.Trace(err, prefix)
 		log.LogError(errors.Stack(err))
+		if client.isVolFull() {
+			return write, syscall.ENOSPC
+		}
 		exporter.NewAlarm(gDataWrapper.WarningMsg())
 	}
 	return
@@ -198,6 +263,19 @@ func (client *ExtentClient) Truncate(inode uint64, size int) error {
 		return fmt.Errorf("Prefix(%v): stream is not opened yet", prefix)
 	}
 
+	s.once.Do(func() {
+		s.GetExtents()
+		s.loadCipher()
+		s.loadMediaType()
+	})
+
+	// The last blocks of an encrypted file are encrypted again.
+	if s.cipherErr != nil {
+		err := errors.Trace(s.cipherErr, "%s", prefix)
+		log.LogError(errors.Stack(err))
+		return err
+	}
+
 	err := s.IssueTruncRequest(size)
 	if err != nil {
 		err = errors.Trace(err, prefix)
@@ -211,7 +289,20 @@ func (client *ExtentClient) Flush(inode uint64) error {
 	if s == nil {
 		return fmt.Errorf("Flush: stream is not opened yet, ino(%v)", inode)
 	}
-	return s.IssueFlushRequest()
+	err := s.IssueFlushRequest()
+	if err != nil && client.isVolFull() {
+		return syscall.ENOSPC
+	}
+	return err
+}
+
+func (client *ExtentClient) setVolFull() {
+	atomic.StoreInt64(&client.volFullTime, time.Now().UnixNano())
+}
+
+// Returns true if the writes are refused recently since the volume reaches its capacity.
+func (client *ExtentClient) isVolFull() bool {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&client.volFullTime))) < VolFullRetryInterval
 }
 
 func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int) (read int, err error) {
@@ -227,8 +318,15 @@ func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int
 
 	s.once.Do(func() {
 		s.GetExtents()
+		s.loadCipher()
+		s.loadMediaType()
 	})
 
+	if s.cipherErr != nil {
+		err = s.cipherErr
+		return
+	}
+
 	err = s.IssueFlushRequest()
 	if err != nil {
 		return
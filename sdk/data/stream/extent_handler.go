@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/chubaofs/chubaofs/util/errors"
@@ -75,7 +76,7 @@ type ExtentHandler struct {
 
 	// Allocated in the sender, and released in the receiver.
 	// Will not be changed.
-	conn *net.TCPConn
+	conn net.Conn
 	dp   *wrapper.DataPartition
 
 	// Issue a signal to this channel when *inflight* hits zero.
@@ -234,6 +235,7 @@ func (eh *ExtentHandler) sender() {
 			packet.ExtentOffset = int64(extOffset)
 			packet.Arg = ([]byte)(eh.dp.GetAllAddrs())
 			packet.ArgLen = uint32(len(packet.Arg))
+			packet.SetToken(gDataWrapper.Token())
 			packet.RemainingFollowers = uint8(len(eh.dp.Hosts) - 1)
 
 			//log.LogDebugf("ExtentHandler sender: extent allocated, eh(%v) dp(%v) extID(%v) packet(%v)", eh, eh.dp, eh.extID, packet.GetUniqueLogId())
@@ -306,6 +308,15 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 
 	log.LogDebugf("processReply: get reply, eh(%v) packet(%v) reply(%v)", eh, packet, reply)
 
+	if reply.ResultCode == proto.OpLimitedErr {
+		// The volume exceeds its qos limits, which is not a failure of the extent,
+		// so write the packet to the same extent again before the following replies.
+		if reply, err = eh.resendLimitedPacket(packet); err != nil {
+			eh.processReplyError(packet, err.Error())
+			return
+		}
+	}
+
 	if reply.ResultCode != proto.OpOk {
 		errmsg := fmt.Sprintf("reply NOK: reply(%v)", reply)
 		eh.processReplyError(packet, errmsg)
@@ -354,6 +365,28 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	return
 }
 
+// resendLimitedPacket backs off and sends the packet rejected by the qos limits again through
+// another connection to the same data node, until it is not limited or the retries run out.
+func (eh *ExtentHandler) resendLimitedPacket(packet *Packet) (reply *Packet, err error) {
+	var conn net.Conn
+	for i := 0; i < StreamSendMaxRetry; i++ {
+		time.Sleep(StreamSendSleepInterval)
+		if conn, err = StreamConnPool.GetConnect(eh.dp.Hosts[0]); err != nil {
+			return
+		}
+		reply = NewReply(packet.ReqID, packet.PartitionID, packet.ExtentID)
+		if err = packet.writeToConn(conn); err == nil {
+			err = reply.ReadFromConn(conn, proto.ReadDeadlineTime)
+		}
+		StreamConnPool.PutConnect(conn, err != nil)
+		if err != nil || reply.ResultCode != proto.OpLimitedErr {
+			return
+		}
+		log.LogWarnf("resendLimitedPacket: still limited, eh(%v) packet(%v)", eh, packet)
+	}
+	return
+}
+
 func (eh *ExtentHandler) processReplyError(packet *Packet, errmsg string) {
 	eh.setClosed()
 	eh.setRecovery()
@@ -408,6 +441,9 @@ func (eh *ExtentHandler) appendExtentKey() (err error) {
 		if eh.dirty {
 			eh.stream.extents.Append(eh.key, true)
 			err = eh.stream.client.appendExtentKey(eh.inode, *eh.key)
+			if err == syscall.ENOSPC {
+				eh.stream.client.setVolFull()
+			}
 		} else {
 			eh.stream.extents.Append(eh.key, false)
 		}
@@ -476,16 +512,17 @@ func (eh *ExtentHandler) discardPacket(packet *Packet) {
 func (eh *ExtentHandler) allocateExtent() (err error) {
 	var (
 		dp    *wrapper.DataPartition
-		conn  *net.TCPConn
+		conn  net.Conn
 		extID int
 	)
 
 	//log.LogDebugf("ExtentHandler allocateExtent enter: eh(%v)", eh)
 
 	excludePartitions := make([]uint64, 0)
+	volFull := false
 
 	for i := 0; i < MaxSelectDataPartitionForWrite; i++ {
-		if dp, err = gDataWrapper.GetDataPartitionForWrite(excludePartitions); err != nil {
+		if dp, err = gDataWrapper.GetDataPartitionForWrite(excludePartitions, eh.stream.mediaType); err != nil {
 			log.LogWarnf("allocateExtent: failed to get write data partition, eh(%v) exclude(%v)", eh, excludePartitions)
 			continue
 		}
@@ -493,6 +530,11 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 		if eh.storeMode == proto.NormalExtentType {
 			if extID, err = eh.createExtent(dp); err != nil {
 				log.LogWarnf("allocateExtent: failed to create extent, eh(%v) err(%v)", eh, err)
+				if err == syscall.ENOSPC {
+					// the data node used up its grant of the volume, and the others may not
+					excludePartitions = append(excludePartitions, dp.PartitionID)
+					volFull = true
+				}
 				continue
 			}
 		} else {
@@ -514,6 +556,12 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 		return nil
 	}
 
+	if volFull {
+		log.LogWarnf("allocateExtent: vol is full, eh(%v)", eh)
+		eh.stream.client.setVolFull()
+		return syscall.ENOSPC
+	}
+
 	errmsg := fmt.Sprintf("allocateExtent failed: hit max retry limit")
 	if err != nil {
 		err = errors.Trace(err, errmsg)
@@ -523,16 +571,8 @@ func (eh *ExtentHandler) allocateExtent() (err error) {
 	return err
 }
 
-func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (*net.TCPConn, error) {
-	conn, err := net.DialTimeout("tcp", dp.Hosts[0], time.Second)
-	if err != nil {
-		return nil, err
-	}
-	connect := conn.(*net.TCPConn)
-	// TODO unhandled error
-	connect.SetKeepAlive(true)
-	connect.SetNoDelay(true)
-	return connect, nil
+func (eh *ExtentHandler) createConnection(dp *wrapper.DataPartition) (net.Conn, error) {
+	return util.DialTimeout(dp.Hosts[0], time.Second)
 }
 
 func (eh *ExtentHandler) createExtent(dp *wrapper.DataPartition) (extID int, err error) {
@@ -563,6 +603,10 @@ func (eh *ExtentHandler) createExtent(dp *wrapper.DataPartition) (extID int, err
 		return
 	}
 
+	if p.ResultCode == proto.OpVolFullErr {
+		return 0, syscall.ENOSPC
+	}
+
 	if p.ResultCode != proto.OpOk {
 		err = errors.New(fmt.Sprintf("createExtent: ResultCode NOK, packet(%v) datapartionHosts(%v) ResultCode(%v)", p, dp.Hosts[0], p.GetResultMsg()))
 		return
@@ -57,7 +57,7 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 
 	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", size, req, reqPacket)
 
-	err = sc.Send(reqPacket, func(conn *net.TCPConn) (error, bool) {
+	err = sc.Send(reqPacket, func(conn net.Conn) (error, bool) {
 		readBytes = 0
 		for readBytes < size {
 			replyPacket := NewReply(reqPacket.ReqID, reader.dp.PartitionID, reqPacket.ExtentID)
@@ -72,11 +72,19 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 
 			//log.LogDebugf("ExtentReader Read: ResultCode(%v) req(%v) reply(%v) readBytes(%v)", replyPacket.GetResultMsg(), reqPacket, replyPacket, readBytes)
 
-			if replyPacket.ResultCode == proto.OpAgain {
+			// back off and retry if the volume exceeds its qos limits
+			if replyPacket.ResultCode == proto.OpAgain || replyPacket.ResultCode == proto.OpLimitedErr {
 				return nil, true
 			}
 
 			e = reader.checkStreamReply(reqPacket, replyPacket)
+			if e != nil && replyPacket.ResultCode == proto.OpOk && reqPacket.IsZeroCopyRead() {
+				// The persisted block CRC of a zero-copy reply may be stale, so retry without zero-copy.
+				token := reqPacket.GetToken()
+				reqPacket.Arg = nil
+				reqPacket.ArgLen = 0
+				reqPacket.SetToken(token)
+			}
 			if e != nil {
 				// Dont change the error message, since the caller will
 				// check if it is NotLeaderErr.
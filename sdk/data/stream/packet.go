@@ -66,6 +66,7 @@ func NewOverwritePacket(dp *wrapper.DataPartition, extentID uint64, extentOffset
 	p.ReqID = proto.GenerateRequestID()
 	p.Arg = nil
 	p.ArgLen = 0
+	p.SetToken(gDataWrapper.Token())
 	p.RemainingFollowers = 0
 	p.Opcode = proto.OpRandomWrite
 	p.inode = inode
@@ -86,6 +87,9 @@ func NewReadPacket(key *proto.ExtentKey, extentOffset, size int, inode uint64, f
 	p.ExtentType = proto.NormalExtentType
 	p.ReqID = proto.GenerateRequestID()
 	p.RemainingFollowers = 0
+	p.Arg = []byte(proto.ZeroCopyReadArg)
+	p.ArgLen = uint32(len(p.Arg))
+	p.SetToken(gDataWrapper.Token())
 	p.inode = inode
 	p.KernelOffset = uint64(fileOffset)
 	return p
@@ -99,6 +103,7 @@ func NewCreateExtentPacket(dp *wrapper.DataPartition, inode uint64) *Packet {
 	p.ExtentType = proto.NormalExtentType
 	p.Arg = ([]byte)(dp.GetAllAddrs())
 	p.ArgLen = uint32(len(p.Arg))
+	p.SetToken(gDataWrapper.Token())
 	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
 	p.ReqID = proto.GenerateRequestID()
 	p.Opcode = proto.OpCreateExtent
@@ -34,7 +34,7 @@ const (
 	StreamSendSleepInterval = 100 * time.Millisecond
 )
 
-type GetReplyFunc func(conn *net.TCPConn) (err error, again bool)
+type GetReplyFunc func(conn net.Conn) (err error, again bool)
 
 // StreamConn defines the struct of the stream connection.
 type StreamConn struct {
@@ -110,7 +110,7 @@ func (sc *StreamConn) sendToPartition(req *Packet, getReply GetReplyFunc) (err e
 	return errors.New(fmt.Sprintf("sendToPatition Failed: sc(%v) reqPacket(%v)", sc, req))
 }
 
-func (sc *StreamConn) sendToConn(conn *net.TCPConn, req *Packet, getReply GetReplyFunc) (err error) {
+func (sc *StreamConn) sendToConn(conn net.Conn, req *Packet, getReply GetReplyFunc) (err error) {
 	for i := 0; i < StreamSendMaxRetry; i++ {
 		log.LogDebugf("sendToConn: send to addr(%v), reqPacket(%v)", sc.currAddr, req)
 		err = req.WriteToConn(conn)
@@ -17,6 +17,9 @@ package stream
 import (
 	"fmt"
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
+	"github.com/chubaofs/chubaofs/util"
+	"github.com/chubaofs/chubaofs/util/errors"
 	"github.com/chubaofs/chubaofs/util/log"
 	"io"
 	"sync"
@@ -37,6 +40,11 @@ type Streamer struct {
 	extents *ExtentCache
 	once    sync.Once
 
+	cipher    *crypt.FileCipher // nil if the data is not encrypted
+	cipherErr error
+
+	mediaType uint8 // media class of the data partitions to write to
+
 	handler   *ExtentHandler   // current open handler
 	dirtylist *DirtyExtentList // dirty handlers
 	dirty     bool             // whether current open handler is in the dirty list
@@ -70,6 +78,48 @@ func (s *Streamer) GetExtents() error {
 	return s.extents.Refresh(s.inode, s.client.getExtents)
 }
 
+// loadCipher loads the key recorded in the inode, and creates the cipher of the data.
+// The encrypted files can not be read or written without the key provider.
+func (s *Streamer) loadCipher() {
+	if s.client.getKeyID == nil {
+		return
+	}
+	keyID, err := s.client.getKeyID(s.inode)
+	if err != nil {
+		s.cipherErr = errors.Trace(err, "loadCipher: failed to get key ID, ino(%v)", s.inode)
+		return
+	}
+	if keyID == crypt.NoKeyID {
+		return
+	}
+	if s.client.keys == nil {
+		s.cipherErr = errors.New(fmt.Sprintf("loadCipher: ino(%v) is encrypted by key(%v), but no key provider is configured", s.inode, keyID))
+		return
+	}
+	key, err := s.client.keys.GetKey(keyID)
+	if err != nil {
+		s.cipherErr = errors.Trace(err, "loadCipher: failed to get key, ino(%v) keyID(%v)", s.inode, keyID)
+		return
+	}
+	if s.cipher, err = crypt.NewFileCipher(key, s.inode); err != nil {
+		s.cipherErr = errors.Trace(err, "loadCipher: ino(%v) keyID(%v)", s.inode, keyID)
+	}
+}
+
+// loadMediaType loads the media class of the inode. The data is written to any
+// data partition if it fails.
+func (s *Streamer) loadMediaType() {
+	if s.client.getMediaType == nil {
+		return
+	}
+	mediaType, err := s.client.getMediaType(s.inode)
+	if err != nil {
+		log.LogWarnf("loadMediaType: ino(%v) err(%v)", s.inode, err)
+		return
+	}
+	s.mediaType = mediaType
+}
+
 // GetExtentReader returns the extent reader.
 // TODO: use memory pool
 func (s *Streamer) GetExtentReader(ek *proto.ExtentKey) (*ExtentReader, error) {
@@ -82,6 +132,10 @@ func (s *Streamer) GetExtentReader(ek *proto.ExtentKey) (*ExtentReader, error) {
 }
 
 func (s *Streamer) read(data []byte, offset int, size int) (total int, err error) {
+	if s.cipher != nil {
+		return s.readEncrypted(data, offset, size)
+	}
+
 	var (
 		readBytes       int
 		reader          *ExtentReader
@@ -152,3 +206,72 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 	}
 	return
 }
+
+// readEncrypted reads and decrypts the data of an encrypted file.
+func (s *Streamer) readEncrypted(data []byte, offset int, size int) (total int, err error) {
+	filesize, _ := s.extents.Size()
+	if offset >= filesize {
+		if offset == filesize {
+			err = io.EOF
+		}
+		return
+	}
+	if offset+size > filesize {
+		size = filesize - offset
+		err = io.EOF
+	}
+	lo, hi := cipherRange(offset, offset+size, filesize)
+	buf, e := s.readBlocks(lo, hi)
+	if e != nil {
+		return 0, e
+	}
+	total = copy(data[:size], buf[offset-lo:])
+	return
+}
+
+// readBlocks reads and decrypts the blocks in [lo, hi), which must be a range returned by cipherRange.
+// The holes are read as zeros.
+func (s *Streamer) readBlocks(lo, hi int) (buf []byte, err error) {
+	var (
+		reader    *ExtentReader
+		readBytes int
+		holes     []*ExtentRequest
+	)
+	buf = make([]byte, hi-lo)
+	for _, req := range s.extents.PrepareReadRequests(lo, hi-lo, buf) {
+		if req.ExtentKey == nil {
+			holes = append(holes, req)
+			continue
+		}
+		if reader, err = s.GetExtentReader(req.ExtentKey); err != nil {
+			return nil, err
+		}
+		if readBytes, err = reader.Read(req); err != nil {
+			return nil, err
+		}
+		if readBytes < req.Size {
+			return nil, errors.New(fmt.Sprintf("readBlocks: short read, ino(%v) req(%v) readBytes(%v)", s.inode, req, readBytes))
+		}
+	}
+	s.cipher.Decrypt(buf, uint64(lo))
+	for _, req := range holes {
+		for i := range req.Data {
+			req.Data[i] = 0
+		}
+	}
+	return
+}
+
+// cipherRange returns the range of the blocks which are encrypted together with the data in [start, end),
+// given the size of the file. The last partial block of the file is encrypted together with the block before it.
+func cipherRange(start, end, filesize int) (lo, hi int) {
+	lo = start - start%crypt.BlockSize
+	hi = util.Min(end+(crypt.BlockSize-end%crypt.BlockSize)%crypt.BlockSize, filesize)
+	if tail := filesize % crypt.BlockSize; tail != 0 {
+		if last := util.Max(filesize-tail-crypt.BlockSize, 0); hi > last {
+			lo = util.Min(lo, last)
+			hi = filesize
+		}
+	}
+	return
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
 	"github.com/chubaofs/chubaofs/sdk/data/wrapper"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/errors"
@@ -251,6 +252,65 @@ func (s *Streamer) handleRequest(request interface{}) {
 }
 
 func (s *Streamer) write(data []byte, offset, size int, direct bool) (total int, err error) {
+	if s.cipher != nil {
+		return s.writeEncrypted(data, offset, size, direct)
+	}
+	return s.writeExtents(data, offset, size, direct)
+}
+
+// writeEncrypted encrypts and writes the data to an encrypted file. The blocks are encrypted as a whole,
+// so the rest of the first and the last block is read and written again, and so are the last blocks of
+// the file, which are encrypted together differently once the size changes.
+func (s *Streamer) writeEncrypted(data []byte, offset, size int, direct bool) (total int, err error) {
+	if err = s.flush(); err != nil {
+		return
+	}
+	filesize, _ := s.extents.Size()
+	end := offset + size
+	if tail := filesize % crypt.BlockSize; tail != 0 && offset > filesize {
+		// the last partial block is followed by a hole, so fill it with zeros first
+		zeros := make([]byte, util.Min(filesize-tail+crypt.BlockSize, offset)-filesize)
+		if _, err = s.writeEncrypted(zeros, filesize, len(zeros), direct); err != nil {
+			return
+		}
+		if err = s.flush(); err != nil {
+			return
+		}
+		filesize += len(zeros)
+	}
+
+	lo, hi := cipherRange(offset, end, util.Max(filesize, end))
+	if tail := filesize % crypt.BlockSize; tail != 0 && end > filesize {
+		lo = util.Min(lo, util.Max(filesize-tail-crypt.BlockSize, 0))
+	}
+	buf := make([]byte, hi-lo)
+	if lo < offset && lo < filesize {
+		headEnd := util.Min(offset, filesize)
+		a, b := cipherRange(lo, headEnd, filesize)
+		var head []byte
+		if head, err = s.readBlocks(a, b); err != nil {
+			return
+		}
+		copy(buf[:headEnd-lo], head[lo-a:])
+	}
+	if end < hi && end < filesize {
+		tailEnd := util.Min(hi, filesize)
+		a, b := cipherRange(end, tailEnd, filesize)
+		var tail []byte
+		if tail, err = s.readBlocks(a, b); err != nil {
+			return
+		}
+		copy(buf[end-lo:tailEnd-lo], tail[end-a:])
+	}
+	copy(buf[offset-lo:], data[:size])
+	s.cipher.Encrypt(buf, uint64(lo))
+
+	written, err := s.writeExtents(buf, lo, len(buf), direct)
+	total = util.Min(util.Max(written-(offset-lo), 0), size)
+	return
+}
+
+func (s *Streamer) writeExtents(data []byte, offset, size int, direct bool) (total int, err error) {
 	log.LogDebugf("Streamer write enter: ino(%v) offset(%v) size(%v)", s.inode, offset, size)
 
 	requests := s.extents.PrepareWriteRequests(offset, size, data)
@@ -316,6 +376,13 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		return
 	}
 
+	if s.client.setModifyTime != nil {
+		if err = s.client.setModifyTime(s.inode, time.Now().Unix()); err != nil {
+			err = errors.Trace(err, "doOverwrite: ino(%v) failed to update modify time", s.inode)
+			return
+		}
+	}
+
 	sc := NewStreamConn(dp)
 
 	for total < size {
@@ -329,7 +396,7 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		reqPacket.CRC = crc32.ChecksumIEEE(reqPacket.Data[:packSize])
 
 		replyPacket := new(Packet)
-		err = sc.Send(reqPacket, func(conn *net.TCPConn) (error, bool) {
+		err = sc.Send(reqPacket, func(conn net.Conn) (error, bool) {
 			e := replyPacket.ReadFromConn(conn, proto.ReadDeadlineTime)
 			if e != nil {
 				log.LogWarnf("Stream Writer doOverwrite: ino(%v) failed to read from connect, req(%v) err(%v)", s.inode, reqPacket, e)
@@ -337,7 +404,7 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 				return TryOtherAddrError, false
 			}
 
-			if replyPacket.ResultCode == proto.OpAgain {
+			if replyPacket.ResultCode == proto.OpAgain || replyPacket.ResultCode == proto.OpLimitedErr {
 				return nil, true
 			}
 
@@ -536,8 +603,68 @@ func (s *Streamer) truncate(size int) error {
 	if err != nil {
 		return err
 	}
+	if s.cipher != nil {
+		return s.truncateEncrypted(size)
+	}
+	return s.truncateExtents(size)
+}
+
+// truncateEncrypted truncates an encrypted file, and encrypts its new last blocks again.
+func (s *Streamer) truncateEncrypted(size int) (err error) {
+	filesize, _ := s.extents.Size()
+	if size == filesize {
+		return s.truncateExtents(size)
+	}
+	if size > filesize {
+		// write zeros up to the end of the blocks encrypted differently, and leave the rest a hole
+		end := filesize
+		if tail := filesize % crypt.BlockSize; tail != 0 {
+			end = util.Min(filesize-tail+crypt.BlockSize, size)
+		}
+		if tail := size % crypt.BlockSize; tail != 0 && size-tail-crypt.BlockSize < filesize {
+			end = size
+		}
+		if end > filesize {
+			if _, err = s.writeEncrypted(make([]byte, end-filesize), filesize, end-filesize, false); err != nil {
+				return
+			}
+			s.closeOpenHandler()
+			if err = s.flush(); err != nil {
+				return
+			}
+		}
+		if end < size {
+			return s.truncateExtents(size)
+		}
+		return
+	}
+
+	var (
+		lo   int
+		last []byte
+	)
+	if size > 0 {
+		lo, _ = cipherRange(size-1, size, size)
+		a, b := cipherRange(lo, size, filesize)
+		var old []byte
+		if old, err = s.readBlocks(a, b); err != nil {
+			return
+		}
+		last = old[lo-a : size-a]
+	}
+	if err = s.truncateExtents(size); err != nil || last == nil {
+		return
+	}
+	s.cipher.Encrypt(last, uint64(lo))
+	if _, err = s.writeExtents(last, lo, len(last), false); err != nil {
+		return
+	}
+	s.closeOpenHandler()
+	return s.flush()
+}
 
-	err = s.client.truncate(s.inode, uint64(size))
+func (s *Streamer) truncateExtents(size int) error {
+	err := s.client.truncate(s.inode, uint64(size))
 	if err != nil {
 		return err
 	}
@@ -51,6 +51,7 @@ type Wrapper struct {
 	partitions            map[uint64]*DataPartition
 	rwPartition           []*DataPartition
 	localLeaderPartitions []*DataPartition
+	token                 string // the access token of the volume carried by the packets
 }
 
 // NewDataPartitionWrapper returns a new data partition wrapper.
@@ -80,6 +81,20 @@ func NewDataPartitionWrapper(volName, masterHosts string) (w *Wrapper, err error
 func (w *Wrapper) GetClusterName() string {
 	return w.clusterName
 }
+
+// SetToken replaces the access token of the volume carried by the packets.
+func (w *Wrapper) SetToken(token string) {
+	w.Lock()
+	defer w.Unlock()
+	w.token = token
+}
+
+// Token returns the access token of the volume carried by the packets.
+func (w *Wrapper) Token() string {
+	w.RLock()
+	defer w.RUnlock()
+	return w.token
+}
 func (w *Wrapper) updateClusterInfo() error {
 	masterHelper := util.NewMasterHelper()
 	for _, ip := range w.masters {
@@ -115,7 +130,7 @@ func (w *Wrapper) update() {
 func (w *Wrapper) updateDataPartition() error {
 	paras := make(map[string]string, 0)
 	paras["name"] = w.volName
-	msg, err := MasterHelper.Request(http.MethodGet, proto.ClientDataPartitions, paras, nil)
+	msg, err := MasterHelper.ReadRequest(http.MethodGet, proto.ClientDataPartitions, paras, nil)
 	if err != nil {
 		return errors.Trace(err, "updateDataPartition: request to master failed!")
 	}
@@ -194,16 +209,31 @@ func (w *Wrapper) getRandomDataPartition(partitions []*DataPartition, exclude []
 	return nil
 }
 
-func (w *Wrapper) getLocalLeaderDataPartition(exclude []uint64) *DataPartition {
+func (w *Wrapper) getLocalLeaderDataPartition(exclude []uint64, mediaType uint8) *DataPartition {
 	w.RLock()
 	localLeaderPartitions := w.localLeaderPartitions
 	w.RUnlock()
-	return w.getRandomDataPartition(localLeaderPartitions, exclude)
+	return w.getRandomDataPartition(filterByMedia(localLeaderPartitions, mediaType), exclude)
 }
 
-// GetDataPartitionForWrite returns an available data partition for write.
-func (w *Wrapper) GetDataPartitionForWrite(exclude []uint64) (*DataPartition, error) {
-	dp := w.getLocalLeaderDataPartition(exclude)
+// filterByMedia returns the partitions of the media class, or all of them if the class is unspecified.
+func filterByMedia(partitions []*DataPartition, mediaType uint8) []*DataPartition {
+	if mediaType == proto.MediaUnspecified {
+		return partitions
+	}
+	filtered := make([]*DataPartition, 0, len(partitions))
+	for _, dp := range partitions {
+		if dp.MediaType == mediaType {
+			filtered = append(filtered, dp)
+		}
+	}
+	return filtered
+}
+
+// GetDataPartitionForWrite returns an available data partition of the media class for write.
+// Any available data partition is returned if there is none of the class.
+func (w *Wrapper) GetDataPartitionForWrite(exclude []uint64, mediaType uint8) (*DataPartition, error) {
+	dp := w.getLocalLeaderDataPartition(exclude, mediaType)
 	if dp != nil {
 		return dp, nil
 	}
@@ -212,11 +242,15 @@ func (w *Wrapper) GetDataPartitionForWrite(exclude []uint64) (*DataPartition, er
 	rwPartitionGroups := w.rwPartition
 	w.RUnlock()
 
-	dp = w.getRandomDataPartition(rwPartitionGroups, exclude)
+	dp = w.getRandomDataPartition(filterByMedia(rwPartitionGroups, mediaType), exclude)
 	if dp != nil {
 		return dp, nil
 	}
 
+	if mediaType != proto.MediaUnspecified {
+		log.LogWarnf("GetDataPartitionForWrite: no writable data partition of media(%v)", proto.MediaTypeString(mediaType))
+		return w.GetDataPartitionForWrite(exclude, proto.MediaUnspecified)
+	}
 	return nil, fmt.Errorf("no writable data partition")
 }
 
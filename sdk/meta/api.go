@@ -48,6 +48,8 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 		info         *proto.InodeInfo
 		mp           *MetaPartition
 		rwPartitions []*MetaPartition
+		keyID        uint32
+		mediaType    uint8
 	)
 
 	parentMP := mw.getPartitionByInode(parentID)
@@ -56,6 +58,25 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 		return nil, syscall.ENOENT
 	}
 
+	// New files of an encrypted volume are always encrypted with the current key of the volume.
+	if mw.Encrypted() && proto.IsRegular(mode) {
+		if mw.keys == nil {
+			log.LogErrorf("Create_ll: vol(%v) is encrypted, but no key provider is configured, parentID(%v) name(%v)", mw.volname, parentID, name)
+			return nil, syscall.EPERM
+		}
+		if keyID, err = mw.keys.CurrentKeyID(); err != nil {
+			log.LogErrorf("Create_ll: failed to get current key, parentID(%v) name(%v) err(%v)", parentID, name, err)
+			return nil, syscall.EIO
+		}
+	}
+
+	// New inodes inherit the media class of the parent in a tiered volume.
+	if mw.MediaType() != proto.MediaUnspecified {
+		if parent, err := mw.InodeGet_ll(parentID); err == nil {
+			mediaType = parent.MediaType
+		}
+	}
+
 	// Create Inode
 
 	//	mp = mw.getLatestPartition()
@@ -84,7 +105,7 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 	for i := 0; i < length; i++ {
 		index := (int(epoch) + i) % length
 		mp = rwPartitions[index]
-		status, info, err = mw.icreate(mp, mode, uid, gid, target)
+		status, info, err = mw.icreate(mp, mode, uid, gid, target, keyID, mediaType)
 		if err == nil && status == statusOK {
 			goto create_dentry
 		}
@@ -134,6 +155,15 @@ func (mw *MetaWrapper) InodeGet_ll(inode uint64) (*proto.InodeInfo, error) {
 	return info, nil
 }
 
+// Used as a callback by stream sdk
+func (mw *MetaWrapper) GetKeyID(inode uint64) (uint32, error) {
+	info, err := mw.InodeGet_ll(inode)
+	if err != nil {
+		return 0, err
+	}
+	return info.KeyID, nil
+}
+
 func (mw *MetaWrapper) BatchInodeGet(inodes []uint64) []*proto.InodeInfo {
 	var wg sync.WaitGroup
 
@@ -414,3 +444,103 @@ func (mw *MetaWrapper) Setattr(inode uint64, valid, mode, uid, gid uint32) error
 
 	return nil
 }
+
+// SetAccessTime updates the access time of the inode.
+func (mw *MetaWrapper) SetAccessTime(inode uint64, atime int64) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetAccessTime: No such partition, ino(%v)", inode)
+		return syscall.EINVAL
+	}
+
+	req := &proto.SetAttrRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Valid:       proto.AttrAccessTime,
+		AccessTime:  atime,
+	}
+	status, err := mw.doSetattr(mp, req)
+	if err != nil || status != statusOK {
+		log.LogErrorf("SetAccessTime: ino(%v) err(%v) status(%v)", inode, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// SetModifyTime updates the modify time of the inode before its data is overwritten in place,
+// which also fails the moves of the inode in progress.
+func (mw *MetaWrapper) SetModifyTime(inode uint64, mtime int64) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetModifyTime: No such partition, ino(%v)", inode)
+		return syscall.EINVAL
+	}
+
+	req := &proto.SetAttrRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Valid:       proto.AttrModifyTime,
+		ModifyTime:  mtime,
+	}
+	status, err := mw.doSetattr(mp, req)
+	if err != nil || status != statusOK {
+		log.LogErrorf("SetModifyTime: ino(%v) err(%v) status(%v)", inode, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// SetMediaType changes the media class of the inode. For a directory, it is
+// the class inherited by the files created in it afterwards.
+func (mw *MetaWrapper) SetMediaType(inode uint64, mediaType uint8) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetMediaType: No such partition, ino(%v)", inode)
+		return syscall.EINVAL
+	}
+
+	req := &proto.SetAttrRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Valid:       proto.AttrMediaType,
+		MediaType:   mediaType,
+	}
+	status, err := mw.doSetattr(mp, req)
+	if err != nil || status != statusOK {
+		log.LogErrorf("SetMediaType: ino(%v) err(%v) status(%v)", inode, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// Used as a callback by stream sdk
+func (mw *MetaWrapper) GetMediaType(inode uint64) (uint8, error) {
+	info, err := mw.InodeGet_ll(inode)
+	if err != nil {
+		return 0, err
+	}
+	if info.MediaType != proto.MediaUnspecified {
+		return info.MediaType, nil
+	}
+	return mw.MediaType(), nil
+}
+
+// ReplaceExtents atomically replaces all the extents of the inode, as long as it
+// has not been changed since the generation. EINVAL is returned otherwise.
+func (mw *MetaWrapper) ReplaceExtents(inode, gen uint64, extents []proto.ExtentKey, mediaType uint8) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("ReplaceExtents: No such partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	status, err := mw.replaceExtents(mp, inode, gen, extents, mediaType)
+	if err != nil || status != statusOK {
+		log.LogErrorf("ReplaceExtents: ino(%v) gen(%v) err(%v) status(%v)", inode, gen, err, status)
+		return statusToErrno(status)
+	}
+	return nil
+}
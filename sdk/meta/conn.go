@@ -32,7 +32,7 @@ const (
 )
 
 type MetaConn struct {
-	conn *net.TCPConn
+	conn net.Conn
 	id   uint64 //PartitionID
 	addr string //MetaNode addr
 }
@@ -71,6 +71,7 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 		start time.Time
 	)
 
+	req.SetToken(mw.Token())
 	addr = mp.LeaderAddr
 	if addr == "" {
 		err = errors.New(fmt.Sprintf("sendToMetaPartition failed: leader addr empty, req(%v) mp(%v)", req, mp))
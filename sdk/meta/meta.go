@@ -18,10 +18,12 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/chubaofs/chubaofs/proto"
+	"github.com/chubaofs/chubaofs/sdk/data/crypt"
 	"github.com/chubaofs/chubaofs/util"
 	"github.com/chubaofs/chubaofs/util/btree"
 	"github.com/chubaofs/chubaofs/util/errors"
@@ -42,6 +44,7 @@ const (
 	statusError
 	statusInval
 	statusNotPerm
+	statusNoSpace
 )
 
 const (
@@ -72,12 +75,30 @@ type MetaWrapper struct {
 
 	totalSize uint64
 	usedSize  uint64
+
+	// Provides the data encryption key of new files, nil if no key provider is configured.
+	keys crypt.KeyProvider
+
+	// The encryption mode of the volume, the new files have to be encrypted if it is not EncryptionNone.
+	encryptionMode uint32
+
+	// The default media class of the volume, unspecified if the volume is not tiered.
+	mediaType uint32
+
+	// The access token of the volume carried by the requests, empty if the volume does not require one.
+	token atomic.Value
 }
 
 func NewMetaWrapper(volname, owner, masterHosts string) (*MetaWrapper, error) {
+	return NewMetaWrapperWithToken(volname, owner, "", masterHosts)
+}
+
+// NewMetaWrapperWithToken returns a meta wrapper of a volume requiring the access tokens.
+func NewMetaWrapperWithToken(volname, owner, token, masterHosts string) (*MetaWrapper, error) {
 	mw := new(MetaWrapper)
 	mw.volname = volname
 	mw.owner = owner
+	mw.token.Store(token)
 	master := strings.Split(masterHosts, HostsSeparator)
 	mw.master = util.NewMasterHelper()
 	for _, ip := range master {
@@ -107,6 +128,31 @@ retry:
 	return mw, nil
 }
 
+// SetKeyProvider supplies the keys to encrypt the new files of an encrypted volume.
+func (mw *MetaWrapper) SetKeyProvider(keys crypt.KeyProvider) {
+	mw.keys = keys
+}
+
+// SetToken replaces the access token carried by the requests, e.g. before the old one expires.
+func (mw *MetaWrapper) SetToken(token string) {
+	mw.token.Store(token)
+}
+
+// Token returns the access token carried by the requests.
+func (mw *MetaWrapper) Token() string {
+	return mw.token.Load().(string)
+}
+
+// Encrypted returns whether the data of the volume is encrypted.
+func (mw *MetaWrapper) Encrypted() bool {
+	return atomic.LoadUint32(&mw.encryptionMode) != uint32(proto.EncryptionNone)
+}
+
+// MediaType returns the default media class of the volume.
+func (mw *MetaWrapper) MediaType() uint8 {
+	return uint8(atomic.LoadUint32(&mw.mediaType))
+}
+
 func (mw *MetaWrapper) Cluster() string {
 	return mw.cluster
 }
@@ -126,12 +172,14 @@ func parseStatus(result uint8) (status int) {
 		status = statusNoent
 	case proto.OpInodeFullErr:
 		status = statusFull
-	case proto.OpAgain:
+	case proto.OpAgain, proto.OpLimitedErr:
 		status = statusAgain
 	case proto.OpArgMismatchErr:
 		status = statusInval
 	case proto.OpNotPerm:
 		status = statusNotPerm
+	case proto.OpVolFullErr:
+		status = statusNoSpace
 	default:
 		status = statusError
 	}
@@ -155,6 +203,8 @@ func statusToErrno(status int) error {
 		return syscall.EINVAL
 	case statusNotPerm:
 		return syscall.EPERM
+	case statusNoSpace:
+		return syscall.ENOSPC
 	case statusError:
 		return syscall.EPERM
 	default:
@@ -28,7 +28,7 @@ import (
 // API implementations
 //
 
-func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte) (status int, info *proto.InodeInfo, err error) {
+func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target []byte, keyID uint32, mediaType uint8) (status int, info *proto.InodeInfo, err error) {
 	req := &proto.CreateInodeRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
@@ -36,6 +36,8 @@ func (mw *MetaWrapper) icreate(mp *MetaPartition, mode, uid, gid uint32, target
 		Uid:         uid,
 		Gid:         gid,
 		Target:      target,
+		KeyID:       keyID,
+		MediaType:   mediaType,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -619,7 +621,10 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 		Uid:         uid,
 		Gid:         gid,
 	}
+	return mw.doSetattr(mp, req)
+}
 
+func (mw *MetaWrapper) doSetattr(mp *MetaPartition, req *proto.SetAttrRequest) (status int, err error) {
 	packet := proto.NewPacketReqID()
 	packet.Opcode = proto.OpMetaSetattr
 	err = packet.MarshalData(req)
@@ -648,3 +653,40 @@ func (mw *MetaWrapper) setattr(mp *MetaPartition, inode uint64, valid, mode, uid
 	log.LogDebugf("setattr exit: packet(%v) mp(%v) req(%v)", packet, mp, *req)
 	return statusOK, nil
 }
+
+func (mw *MetaWrapper) replaceExtents(mp *MetaPartition, inode, gen uint64, extents []proto.ExtentKey, mediaType uint8) (status int, err error) {
+	req := &proto.ReplaceExtentsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Generation:  gen,
+		Extents:     extents,
+		MediaType:   mediaType,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaExtentsReplace
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("replaceExtents: ino(%v) err(%v)", inode, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer metric.Set(err)
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("replaceExtents: packet(%v) mp(%v) ino(%v) err(%v)", packet, mp, inode, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("replaceExtents: packet(%v) mp(%v) ino(%v) gen(%v) result(%v)", packet, mp, inode, gen, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("replaceExtents exit: packet(%v) mp(%v) ino(%v) gen(%v)", packet, mp, inode, gen)
+	return statusOK, nil
+}
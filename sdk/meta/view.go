@@ -33,6 +33,8 @@ const (
 type VolumeView struct {
 	VolName        string
 	MetaPartitions []*MetaPartition
+	MediaType      uint8
+	EncryptionMode uint8
 }
 
 type VolStatInfo struct {
@@ -51,7 +53,10 @@ func (mw *MetaWrapper) fetchVolumeView() (*VolumeView, error) {
 		return nil, err
 	}
 	params["authKey"] = authKey
-	body, err := mw.master.Request(http.MethodPost, proto.ClientVol, params, nil)
+	if token := mw.Token(); token != "" {
+		params["token"] = token
+	}
+	body, err := mw.master.ReadRequest(http.MethodPost, proto.ClientVol, params, nil)
 	if err != nil {
 		log.LogWarnf("fetchVolumeView request: err(%v)", err)
 		return nil, err
@@ -86,7 +91,7 @@ func (mw *MetaWrapper) updateClusterInfo() error {
 func (mw *MetaWrapper) updateVolStatInfo() error {
 	params := make(map[string]string)
 	params["name"] = mw.volname
-	body, err := mw.master.Request(http.MethodPost, proto.ClientVolStat, params, nil)
+	body, err := mw.master.ReadRequest(http.MethodPost, proto.ClientVolStat, params, nil)
 	if err != nil {
 		log.LogWarnf("updateVolStatInfo request: err(%v)", err)
 		return err
@@ -109,6 +114,8 @@ func (mw *MetaWrapper) updateMetaPartitions() error {
 		return err
 	}
 
+	atomic.StoreUint32(&mw.mediaType, uint32(view.MediaType))
+	atomic.StoreUint32(&mw.encryptionMode, uint32(view.EncryptionMode))
 	rwPartitions := make([]*MetaPartition, 0)
 	for _, mp := range view.MetaPartitions {
 		mw.replaceOrInsertPartition(mp)
@@ -347,6 +347,32 @@ func (s *ExtentStore) Read(extentID uint64, offset, size int64, nbuf []byte, isR
 	return
 }
 
+// ZeroCopyBlock returns the file of a normal extent and the persisted crc of the whole block at the given offset,
+// so that the block can be sent to the network without being read into the user space.
+// The crc is zero if the range is not a whole written block, or if the crc of the block is not persisted.
+func (s *ExtentStore) ZeroCopyBlock(extentID uint64, offset, size int64) (file *os.File, crc uint32, err error) {
+	if IsTinyExtent(extentID) || offset%util.BlockSize != 0 || size != util.BlockSize {
+		return
+	}
+	var e *Extent
+	s.eiMutex.RLock()
+	ei := s.extentInfoMap[extentID]
+	s.eiMutex.RUnlock()
+	if e, err = s.extentWithHeader(ei); err != nil {
+		return
+	}
+	if err = s.checkOffsetAndSize(extentID, offset, size); err != nil {
+		return
+	}
+	if offset+size > e.Size() {
+		return
+	}
+	blockNo := int(offset / util.BlockSize)
+	crc = binary.BigEndian.Uint32(e.header[blockNo*util.PerBlockCrcSize : (blockNo+1)*util.PerBlockCrcSize])
+	file = e.file
+	return
+}
+
 func (s *ExtentStore) tinyDelete(e *Extent, offset, size, tinyDeleteFileOffset int64) (err error) {
 	if offset+size > e.dataSize {
 		return
@@ -21,7 +21,7 @@ import (
 )
 
 type Object struct {
-	conn *net.TCPConn
+	conn net.Conn
 	idle int64
 }
 
@@ -44,7 +44,7 @@ func NewConnectPool() (cp *ConnectPool) {
 	return cp
 }
 
-func (cp *ConnectPool) GetConnect(targetAddr string) (c *net.TCPConn, err error) {
+func (cp *ConnectPool) GetConnect(targetAddr string) (c net.Conn, err error) {
 	cp.RLock()
 	pool, ok := cp.pools[targetAddr]
 	cp.RUnlock()
@@ -58,7 +58,7 @@ func (cp *ConnectPool) GetConnect(targetAddr string) (c *net.TCPConn, err error)
 	return pool.GetConnectFromPool()
 }
 
-func (cp *ConnectPool) PutConnect(c *net.TCPConn, forceClose bool) {
+func (cp *ConnectPool) PutConnect(c net.Conn, forceClose bool) {
 	if c == nil {
 		return
 	}
@@ -117,11 +117,8 @@ func NewPool(min, max int, timeout int64, target string) (p *Pool) {
 
 func (p *Pool) initAllConnect() {
 	for i := 0; i < p.mincap; i++ {
-		c, err := net.Dial("tcp", p.target)
+		conn, err := DialTimeout(p.target, 0)
 		if err == nil {
-			conn := c.(*net.TCPConn)
-			conn.SetKeepAlive(true)
-			conn.SetNoDelay(true)
 			o := &Object{conn: conn, idle: time.Now().UnixNano()}
 			p.PutConnectObjectToPool(o)
 		}
@@ -156,19 +153,11 @@ func (p *Pool) autoRelease() {
 	}
 }
 
-func (p *Pool) NewConnect(target string) (c *net.TCPConn, err error) {
-	var connect net.Conn
-	connect, err = net.Dial("tcp", p.target)
-	if err == nil {
-		conn := connect.(*net.TCPConn)
-		conn.SetKeepAlive(true)
-		conn.SetNoDelay(true)
-		c = conn
-	}
-	return
+func (p *Pool) NewConnect(target string) (c net.Conn, err error) {
+	return DialTimeout(p.target, 0)
 }
 
-func (p *Pool) GetConnectFromPool() (c *net.TCPConn, err error) {
+func (p *Pool) GetConnectFromPool() (c net.Conn, err error) {
 	var (
 		o *Object
 	)
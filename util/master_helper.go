@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,12 +38,14 @@ type MasterHelper interface {
 	Nodes() []string
 	Leader() string
 	Request(method, path string, param map[string]string, body []byte) (data []byte, err error)
+	ReadRequest(method, path string, param map[string]string, body []byte) (data []byte, err error)
 }
 
 type masterHelper struct {
 	sync.RWMutex
 	masters    []string
 	leaderAddr string
+	readIndex  uint32
 }
 
 // AddNode add the given address as the master address.
@@ -73,6 +76,56 @@ func (helper *masterHelper) Request(method, path string, param map[string]string
 	return
 }
 
+// ReadRequest sends out a read-only request to the masters in turn, so that the followers share the
+// polls of the clients with the leader. It falls back to the leader if none of the masters answers.
+func (helper *masterHelper) ReadRequest(method, path string, param map[string]string, reqData []byte) (respData []byte, err error) {
+	nodes := helper.Nodes()
+	if len(nodes) > 1 {
+		start := int(atomic.AddUint32(&helper.readIndex, 1))
+		for i := 0; i < len(nodes); i++ {
+			host := nodes[(start+i)%len(nodes)]
+			var ok bool
+			if respData, ok, err = helper.readFrom(host, method, path, param, reqData); ok {
+				return
+			}
+		}
+	}
+	respData, err = helper.request(method, path, param, reqData)
+	return
+}
+
+// readFrom sends the read-only request to the given master. The returned ok is false if
+// the master has not given an answer, so that the request should be sent to another one.
+func (helper *masterHelper) readFrom(host, method, path string, param map[string]string, reqData []byte) (respData []byte, ok bool, err error) {
+	resp, err := helper.httpRequest(method, fmt.Sprintf("%s://%s%s", HTTPScheme(), host, path), param, reqData)
+	if err != nil {
+		log.LogWarnf("[masterHelper] %s", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.LogWarnf("[masterHelper] read from master[%v] uri[%v] statusCode[%v]", host, path, resp.StatusCode)
+		return
+	}
+	if respData, err = ioutil.ReadAll(resp.Body); err != nil {
+		log.LogWarnf("[masterHelper] %s", err)
+		return
+	}
+	ok = true
+	var body = &struct {
+		Code int32  `json:"code"`
+		Msg  string `json:"msg"`
+		Data json.RawMessage
+	}{}
+	if err = json.Unmarshal(respData, body); err != nil {
+		return nil, ok, fmt.Errorf("unmarshal response body err:%v", err)
+	}
+	if body.Code != 0 {
+		return nil, ok, fmt.Errorf("request error, code[%d], msg[%s]", body.Code, body.Msg)
+	}
+	return []byte(body.Data), ok, nil
+}
+
 func (helper *masterHelper) request(method, path string, param map[string]string, reqData []byte) (repsData []byte, err error) {
 	leaderAddr, nodes := helper.prepareRequest()
 	host := leaderAddr
@@ -85,7 +138,7 @@ func (helper *masterHelper) request(method, path string, param map[string]string
 			host = nodes[i]
 		}
 		var resp *http.Response
-		resp, err = helper.httpRequest(method, fmt.Sprintf("http://%s%s", host,
+		resp, err = helper.httpRequest(method, fmt.Sprintf("%s://%s%s", HTTPScheme(), host,
 			path), param, reqData)
 		if err != nil {
 			log.LogErrorf("[masterHelper] %s", err)
@@ -156,7 +209,7 @@ func (helper *masterHelper) prepareRequest() (addr string, nodes []string) {
 }
 
 func (helper *masterHelper) httpRequest(method, url string, param map[string]string, reqData []byte) (resp *http.Response, err error) {
-	client := &http.Client{}
+	client := &http.Client{Transport: HTTPTransport()}
 	reader := bytes.NewReader(reqData)
 	client.Timeout = time.Second * 3
 	var req *http.Request
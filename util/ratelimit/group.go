@@ -0,0 +1,110 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Group holds a limiter for each key, such as a volume name, so that the keys
+// are queued separately and one busy key does not delay the others.
+type Group struct {
+	sync.RWMutex
+	limiters map[string]*Limiter
+}
+
+// NewGroup creates a new group without any limit.
+func NewGroup() *Group {
+	return &Group{limiters: make(map[string]*Limiter)}
+}
+
+// Reset replaces the rates of all the keys. The keys not in rates, or with a rate not greater
+// than zero, become unlimited. The limiters of the unchanged keys keep their tokens.
+func (g *Group) Reset(rates map[string]int64) {
+	g.Lock()
+	defer g.Unlock()
+	for key, l := range g.limiters {
+		if rate := rates[key]; rate <= 0 {
+			delete(g.limiters, key)
+		} else if rate != l.Rate() {
+			l.SetRate(rate, 0)
+		}
+	}
+	for key, rate := range rates {
+		if _, ok := g.limiters[key]; !ok && rate > 0 {
+			g.limiters[key] = NewLimiter(rate, 0)
+		}
+	}
+}
+
+// Len returns the number of the limited keys.
+func (g *Group) Len() int {
+	g.RLock()
+	defer g.RUnlock()
+	return len(g.limiters)
+}
+
+// Acquire waits for n tokens of the given key, and gives up without taking
+// any token if they cannot be available within maxWait.
+func (g *Group) Acquire(key string, n int64, maxWait time.Duration) bool {
+	return AcquireAll(maxWait, Tokens{Group: g, Key: key, N: n})
+}
+
+func (g *Group) get(key string) *Limiter {
+	g.RLock()
+	defer g.RUnlock()
+	return g.limiters[key]
+}
+
+// Tokens are the tokens of a key in a group.
+type Tokens struct {
+	Group *Group
+	Key   string
+	N     int64
+}
+
+// AcquireAll waits for all the tokens, and gives up without taking any of them
+// if some cannot be available within maxWait.
+func AcquireAll(maxWait time.Duration, tokens ...Tokens) bool {
+	var (
+		wait     time.Duration
+		reserved = make([]*Limiter, 0, len(tokens))
+	)
+	for _, t := range tokens {
+		l := t.Group.get(t.Key)
+		if l == nil {
+			reserved = append(reserved, nil)
+			continue
+		}
+		w, ok := l.TryReserve(t.N, maxWait)
+		if !ok {
+			for i, r := range reserved {
+				if r != nil {
+					r.Cancel(tokens[i].N)
+				}
+			}
+			return false
+		}
+		reserved = append(reserved, l)
+		if w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return true
+}
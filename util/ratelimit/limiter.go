@@ -0,0 +1,147 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket refilled by rate tokens per second, holding at most burst tokens.
+// A rate not greater than zero means unlimited.
+type Limiter struct {
+	sync.Mutex
+	rate   int64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a new limiter. The burst defaults to the rate if it is not greater than zero.
+func NewLimiter(rate, burst int64) (l *Limiter) {
+	l = new(Limiter)
+	l.SetRate(rate, burst)
+	return
+}
+
+// SetRate changes the rate and the burst of the limiter.
+func (l *Limiter) SetRate(rate, burst int64) {
+	l.Lock()
+	defer l.Unlock()
+	l.advance(time.Now())
+	if burst <= 0 {
+		burst = rate
+	}
+	l.rate = rate
+	l.burst = burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+	if rate <= 0 {
+		l.tokens = 0
+	}
+}
+
+// Rate returns the rate of the limiter.
+func (l *Limiter) Rate() int64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.rate
+}
+
+func (l *Limiter) advance(now time.Time) {
+	if l.rate > 0 && !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// Allow takes n tokens if they are available right now.
+func (l *Limiter) Allow(n int64) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	l.advance(time.Now())
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Reserve takes n tokens, which may drive the bucket into debt, and
+// returns how long the caller should wait before using them.
+func (l *Limiter) Reserve(n int64) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	l.advance(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+}
+
+// Wait blocks until n tokens are available.
+func (l *Limiter) Wait(n int64) {
+	if d := l.Reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// TryReserve takes n tokens only if they can be used within maxWait, and returns how long
+// the caller should wait before using them. More tokens than the burst are taken once the
+// bucket is full, and the debt delays the following requests.
+func (l *Limiter) TryReserve(n int64, maxWait time.Duration) (wait time.Duration, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+	if l.rate <= 0 {
+		return 0, true
+	}
+	l.advance(time.Now())
+	need := n
+	if need > l.burst {
+		need = l.burst
+	}
+	if missing := float64(need) - l.tokens; missing > 0 {
+		wait = time.Duration(missing / float64(l.rate) * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	l.tokens -= float64(n)
+	return wait, true
+}
+
+// Cancel gives back n tokens taken by Reserve or TryReserve.
+func (l *Limiter) Cancel(n int64) {
+	l.Lock()
+	defer l.Unlock()
+	if l.rate <= 0 {
+		return
+	}
+	l.tokens += float64(n)
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnlimited(t *testing.T) {
+	l := NewLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if !l.Allow(1 << 30) {
+			t.Fatalf("unlimited limiter rejects")
+		}
+	}
+	if d := l.Reserve(1 << 30); d != 0 {
+		t.Fatalf("unlimited limiter waits %v", d)
+	}
+}
+
+func TestAllowAndReserve(t *testing.T) {
+	l := NewLimiter(100, 10)
+	// the bucket starts empty
+	if l.Allow(1) {
+		t.Fatalf("empty bucket allows")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !l.Allow(10) {
+		t.Fatalf("refilled bucket rejects")
+	}
+	if l.Allow(10) {
+		t.Fatalf("bucket exceeds its burst")
+	}
+	if d := l.Reserve(50); d < 400*time.Millisecond || d > 600*time.Millisecond {
+		t.Fatalf("unexpected wait %v", d)
+	}
+
+	l.SetRate(0, 0)
+	if !l.Allow(1000) {
+		t.Fatalf("limiter rejects after being unlimited")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup()
+	if !g.Acquire("vol", 1<<30, 0) {
+		t.Fatalf("unlimited key rejects")
+	}
+	g.Reset(map[string]int64{"vol": 100, "other": 0})
+	if g.Len() != 1 {
+		t.Fatalf("unexpected limited keys %v", g.Len())
+	}
+	if g.Acquire("vol", 50, 100*time.Millisecond) {
+		t.Fatalf("key exceeds its limit")
+	}
+	if !g.Acquire("vol", 5, 100*time.Millisecond) {
+		t.Fatalf("key rejects within the wait")
+	}
+	if !g.Acquire("other", 1<<30, 0) {
+		t.Fatalf("unlimited key rejects")
+	}
+	g.Reset(nil)
+	if g.Len() != 0 || !g.Acquire("vol", 1<<30, 0) {
+		t.Fatalf("reset does not clear the limits")
+	}
+}
+
+func TestAcquireAll(t *testing.T) {
+	iops, bandwidth := NewGroup(), NewGroup()
+	iops.Reset(map[string]int64{"vol": 100})
+	bandwidth.Reset(map[string]int64{"vol": 1000})
+	time.Sleep(100 * time.Millisecond)
+	// the bandwidth is not enough, so the iops tokens are not taken either
+	if AcquireAll(0, Tokens{iops, "vol", 5}, Tokens{bandwidth, "vol", 500}) {
+		t.Fatalf("tokens exceed the limit")
+	}
+	if !iops.Acquire("vol", 10, 0) {
+		t.Fatalf("iops tokens are taken by a failed acquire")
+	}
+
+	// a request larger than the burst passes once the bucket is full
+	l := NewLimiter(100, 0)
+	time.Sleep(time.Second)
+	if _, ok := l.TryReserve(300, 0); !ok {
+		t.Fatalf("request larger than the burst rejects")
+	}
+	if _, ok := l.TryReserve(1, 100*time.Millisecond); ok {
+		t.Fatalf("debt does not delay the following requests")
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+	"github.com/chubaofs/chubaofs/util/log"
+)
+
+// The keys of the TLS configuration, which are the same for the nodes and the clients.
+// The mutual TLS is enabled if the certificate is set, and the peers are authenticated by the CA.
+const (
+	ConfigKeyTLSCAFile         = "tlsCAFile"         // string, the CA certificates in PEM
+	ConfigKeyTLSCertFile       = "tlsCertFile"       // string, the certificate chain of the process in PEM
+	ConfigKeyTLSKeyFile        = "tlsKeyFile"        // string, the private key of the certificate in PEM
+	ConfigKeyTLSReloadInterval = "tlsReloadInterval" // int, in seconds
+)
+
+const DefaultTLSReloadInterval = 60 // in terms of seconds
+
+var (
+	ErrTLSConfig = errors.New("tlsCAFile, tlsCertFile and tlsKeyFile should be set together")
+
+	tlsConfig *tls.Config
+)
+
+// tlsCertificates holds the certificate and the CA of the process, which are reloaded when the files change,
+// so that the certificates can be rotated without restarting the process.
+type tlsCertificates struct {
+	sync.RWMutex
+	caFile   string
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+	roots    *x509.CertPool
+}
+
+// InitTLS enables the mutual TLS on the connections of the process if the certificate is configured.
+// The same config is used by the servers and the clients, since every node is both.
+func InitTLS(cfg *config.Config) (err error) {
+	c := &tlsCertificates{
+		caFile:   cfg.GetString(ConfigKeyTLSCAFile),
+		certFile: cfg.GetString(ConfigKeyTLSCertFile),
+		keyFile:  cfg.GetString(ConfigKeyTLSKeyFile),
+	}
+	if c.caFile == "" && c.certFile == "" && c.keyFile == "" {
+		return
+	}
+	if c.caFile == "" || c.certFile == "" || c.keyFile == "" {
+		return ErrTLSConfig
+	}
+	if err = c.load(); err != nil {
+		return
+	}
+	interval := cfg.GetInt64(ConfigKeyTLSReloadInterval)
+	if interval <= 0 {
+		interval = DefaultTLSReloadInterval
+	}
+	go c.scheduleToReload(time.Duration(interval) * time.Second)
+	tlsConfig = c.config()
+	log.LogInfof("action[InitTLS] mutual TLS enabled, ca[%v] cert[%v]", c.caFile, c.certFile)
+	return
+}
+
+// TLSConfig returns the TLS config of the process, nil if the TLS is disabled.
+func TLSConfig() *tls.Config {
+	return tlsConfig
+}
+
+// TLSEnabled returns if the connections of the process are encrypted.
+func TLSEnabled() bool {
+	return tlsConfig != nil
+}
+
+// HTTPScheme returns the scheme of the HTTP services of the cluster.
+func HTTPScheme() string {
+	if TLSEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// HTTPTransport returns the transport of the HTTP clients of the cluster, which presents the
+// certificate of the process if the TLS is enabled.
+func HTTPTransport() http.RoundTripper {
+	if tlsConfig == nil {
+		return http.DefaultTransport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// Listen announces on the TCP address, and accepts the TLS connections only if the TLS is enabled.
+func Listen(addr string) (ln net.Listener, err error) {
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		return
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(tcpListener{ln}, tlsConfig)
+	}
+	return
+}
+
+// tcpListener sets the TCP options of the accepted connections, before the TLS hides them.
+type tcpListener struct {
+	net.Listener
+}
+
+func (l tcpListener) Accept() (conn net.Conn, err error) {
+	if conn, err = l.Listener.Accept(); err == nil {
+		SetTCPOptions(conn)
+	}
+	return
+}
+
+// DialTimeout connects to the TCP address, through the TLS if it is enabled.
+func DialTimeout(addr string, timeout time.Duration) (conn net.Conn, err error) {
+	if conn, err = net.DialTimeout("tcp", addr, timeout); err != nil {
+		return
+	}
+	SetTCPOptions(conn)
+	if tlsConfig == nil {
+		return
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// SetTCPOptions sets the keepalive and nodelay options of the TCP connection. The options of
+// the TLS connections are set by Listen and DialTimeout already.
+func SetTCPOptions(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetKeepAlive(true)
+		tcpConn.SetNoDelay(true)
+	}
+}
+
+func (c *tlsCertificates) lastModTime() (modTime time.Time, err error) {
+	for _, name := range []string{c.caFile, c.certFile, c.keyFile} {
+		var info os.FileInfo
+		if info, err = os.Stat(name); err != nil {
+			return
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return
+}
+
+func (c *tlsCertificates) load() (err error) {
+	modTime, err := c.lastModTime()
+	if err != nil {
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return
+	}
+	ca, err := ioutil.ReadFile(c.caFile)
+	if err != nil {
+		return
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no certificate found in %v", c.caFile)
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.cert = &cert
+	c.roots = roots
+	c.modTime = modTime
+	return
+}
+
+// scheduleToReload reloads the certificates once the files are changed. The old certificates are kept
+// if the new ones are invalid, e.g. when the files are being replaced.
+func (c *tlsCertificates) scheduleToReload(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		modTime, err := c.lastModTime()
+		if err != nil {
+			log.LogErrorf("action[reloadTLS] err[%v]", err)
+			continue
+		}
+		c.RLock()
+		changed := modTime.After(c.modTime)
+		c.RUnlock()
+		if !changed {
+			continue
+		}
+		if err = c.load(); err != nil {
+			log.LogErrorf("action[reloadTLS] err[%v]", err)
+			continue
+		}
+		log.LogWarnf("action[reloadTLS] certificates reloaded, cert[%v]", c.certFile)
+	}
+}
+
+func (c *tlsCertificates) certificate() *tls.Certificate {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert
+}
+
+// verifyPeerCertificate verifies the certificate chain of the peer with the current CA. The host name is
+// not verified, since the nodes are addressed by their IP, and any certificate issued by the CA is trusted.
+func (c *tlsCertificates) verifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) (err error) {
+	if len(rawCerts) == 0 {
+		return errors.New("no certificate presented by the peer")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		if certs[i], err = x509.ParseCertificate(raw); err != nil {
+			return
+		}
+	}
+	c.RLock()
+	roots := c.roots
+	c.RUnlock()
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(opts)
+	return
+}
+
+func (c *tlsCertificates) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+		// The chains of both sides are verified by verifyPeerCertificate instead.
+		ClientAuth:            tls.RequireAnyClientCert,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: c.verifyPeerCertificate,
+	}
+}
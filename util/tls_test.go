@@ -0,0 +1,188 @@
+// Copyright 2018 The Chubao Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/chubaofs/chubaofs/util/config"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate of the node issued by the CA, and its key, into the directory.
+func (ca *testCA) issue(t *testing.T, dir, name string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = path.Join(dir, name+".crt"), path.Join(dir, name+".key")
+	writeTestFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) {
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { tlsConfig = nil }()
+
+	ca := newTestCA(t)
+	caFile := path.Join(dir, "ca.crt")
+	writeTestFile(t, caFile, ca.pem)
+	certFile, keyFile := ca.issue(t, dir, "node")
+	cfg := config.LoadConfigString(fmt.Sprintf(`{"%v": "%v", "%v": "%v", "%v": "%v"}`,
+		ConfigKeyTLSCAFile, caFile, ConfigKeyTLSCertFile, certFile, ConfigKeyTLSKeyFile, keyFile))
+	if err = InitTLS(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !TLSEnabled() || HTTPScheme() != "https" {
+		t.Fatalf("TLS is not enabled")
+	}
+
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	// a node with a certificate issued by the CA
+	conn, err := DialTimeout(ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err = io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("unexpected echo %q err %v", buf, err)
+	}
+	conn.Close()
+
+	// a peer with a certificate issued by another CA is rejected
+	other := newTestCA(t)
+	otherCert, otherKey := other.issue(t, dir, "other")
+	cert, err := tls.LoadX509KeyPair(otherCert, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherConfig := &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true}
+	if conn, err := tls.Dial("tcp", ln.Addr().String(), otherConfig); err == nil {
+		// the server rejects the client certificate after the handshake of the client
+		if _, err = conn.Read(buf); err == nil {
+			t.Fatalf("certificate of another CA is accepted")
+		}
+		conn.Close()
+	}
+
+	// a peer without a certificate is rejected
+	if conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true}); err == nil {
+		if _, err = conn.Read(buf); err == nil {
+			t.Fatalf("peer without certificate is accepted")
+		}
+		conn.Close()
+	}
+}
+
+func TestTLSDisabled(t *testing.T) {
+	if err := InitTLS(config.LoadConfigString(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if TLSEnabled() || HTTPScheme() != "http" {
+		t.Fatalf("TLS is enabled without certificate")
+	}
+	cfg := config.LoadConfigString(fmt.Sprintf(`{"%v": "ca.crt"}`, ConfigKeyTLSCAFile))
+	if err := InitTLS(cfg); err != ErrTLSConfig {
+		t.Fatalf("unexpected err %v", err)
+	}
+}